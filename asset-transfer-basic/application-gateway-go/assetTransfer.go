@@ -15,6 +15,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/hyperledger/fabric-gateway/pkg/client"
@@ -76,6 +77,7 @@ func main() {
 	network := gw.GetNetwork(channelName)
 	contract := network.GetContract(chaincodeName)
 
+	setEncryptionKey(contract)
 	initLedger(contract)
 	getAllAssets(contract)
 	createAsset(contract)
@@ -175,6 +177,19 @@ func initLedger(contract *client.Contract) {
 	fmt.Printf("*** Transaction committed successfully\n")
 }
 
+// Submit a write of the bond chaincode and wait for its commit, keeping the receipt of the write with the block it was committed in.
+// Setting the encryption key is the first write of every organization, and can be run again
+func setEncryptionKey(contract *client.Contract) {
+	fmt.Printf("\n--> Submit Transaction: SetEncryptionKey, stores the party hash of the organization in its implicit collection \n")
+
+	receipt, err := submitWithReceipt(contract, "SetEncryptionKey")
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("*** Transaction %s of %s committed successfully in block %d\n", receipt.TxID, receipt.Timestamp.Format(time.RFC3339), receipt.BlockNumber)
+}
+
 // Evaluate a transaction to query ledger state.
 func getAllAssets(contract *client.Contract) {
 	fmt.Println("\n--> Evaluate Transaction: GetAllAssets, function returns all the current assets on the ledger")
@@ -226,13 +241,100 @@ func transferAssetAsync(contract *client.Contract) {
 	fmt.Printf("\n*** Successfully submitted transaction to transfer ownership from %s to Mark. \n", string(submitResult))
 	fmt.Println("*** Waiting for transaction commit.")
 
-	if commitStatus, err := commit.Status(); err != nil {
+	commitStatus, err := commit.Status()
+	if err != nil {
 		panic(fmt.Errorf("failed to get commit status: %w", err))
-	} else if !commitStatus.Successful {
+	}
+	if !commitStatus.Successful {
 		panic(fmt.Errorf("transaction %s failed to commit with status: %d", commitStatus.TransactionID, int32(commitStatus.Code)))
 	}
 
-	fmt.Printf("*** Transaction committed successfully\n")
+	fmt.Printf("*** Transaction %s committed successfully in block %d\n", commitStatus.TransactionID, commitStatus.BlockNumber)
+}
+
+// Envelope is what every function of the bond chaincode answers with: the function's return value in Data,
+// or Error for a failed call
+type Envelope struct {
+	Data          json.RawMessage `json:"data"`
+	Error         *EnvelopeError  `json:"error"`
+	TxID          string          `json:"txId"`
+	SchemaVersion string          `json:"schemaVersion"`
+}
+
+// EnvelopeError is the error of a failed call of the bond chaincode. Code is, for instance, NOT_FOUND, CONFLICT or ERROR
+type EnvelopeError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *EnvelopeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// WriteReceipt is what a client keeps of a committed write for later audit. TxID, Timestamp and Result come from the
+// data of the envelope returned by the write functions of the bond chaincode, the block number from the commit status
+type WriteReceipt struct {
+	TxID        string          `json:"txID"`
+	Timestamp   time.Time       `json:"timestamp"`
+	BlockNumber uint64          `json:"blockNumber"`
+	Result      json.RawMessage `json:"result"`
+}
+
+// Submit a transaction to the bond chaincode and wait for its commit, returning the receipt of the write
+func submitWithReceipt(contract *client.Contract, name string, args ...string) (*WriteReceipt, error) {
+	submitResult, commit, err := contract.SubmitAsync(name, client.WithArguments(args...))
+	if err != nil {
+		if envelopeErr := chaincodeError(err); envelopeErr != nil {
+			return nil, fmt.Errorf("failed to submit transaction: %w", envelopeErr)
+		}
+		return nil, fmt.Errorf("failed to submit transaction: %w", err)
+	}
+
+	envelope := &Envelope{}
+	if err := json.Unmarshal(submitResult, envelope); err != nil {
+		return nil, fmt.Errorf("failed to parse response envelope: %w", err)
+	}
+	if envelope.Error != nil {
+		return nil, envelope.Error
+	}
+
+	commitStatus, err := commit.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit status: %w", err)
+	}
+	if !commitStatus.Successful {
+		return nil, fmt.Errorf("transaction %s failed to commit with status: %d", commitStatus.TransactionID, int32(commitStatus.Code))
+	}
+
+	receipt := &WriteReceipt{}
+	if err := json.Unmarshal(envelope.Data, receipt); err != nil {
+		return nil, fmt.Errorf("failed to parse write response: %w", err)
+	}
+	receipt.BlockNumber = commitStatus.BlockNumber
+
+	return receipt, nil
+}
+
+// Extract the error of a failed call of the bond chaincode from the endorsement error details, where the peers return
+// the response envelope after the status, e.g. "chaincode response 500, {...}". Returns nil when no detail carries one
+func chaincodeError(err error) *EnvelopeError {
+	for _, detail := range status.Convert(err).Details() {
+		errorDetail, ok := detail.(*gateway.ErrorDetail)
+		if !ok {
+			continue
+		}
+		start := strings.Index(errorDetail.Message, "{")
+		if start == -1 {
+			continue
+		}
+
+		envelope := &Envelope{}
+		if json.Unmarshal([]byte(errorDetail.Message[start:]), envelope) == nil && envelope.Error != nil {
+			return envelope.Error
+		}
+	}
+
+	return nil
 }
 
 // Submit transaction, passing in the wrong number of arguments ,expected to throw an error containing details of any error responses from the smart contract.
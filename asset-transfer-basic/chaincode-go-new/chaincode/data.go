@@ -1,907 +0,0 @@
-package chaincode
-
-var InitData = []byte(`
-[
-  {
-    "bond": "FR RA9851",
-    "cusip": "3133KR5L4",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Freddie Mac",
-    "class4": "LB200",
-    "coupon": 6,
-    "couponType": "FIXED",
-    "issueYear": 2023,
-    "issueDate": "2023-01-09T12:00:00Z",
-    "originationAmount": 231480386,
-    "factor": 0.96735693,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 6.895,
-    "weightedAverageLoanAge": 7,
-    "weightedAverageMaturity": 350,
-    "weightedAverageOriginalMaturity": 359,
-    "loanSize": 189432.61,
-    "loanToValue": 74,
-    "fico": 747,
-    "cpr1m": 7.07805522352359,
-    "cpr3m": 6.72923614321762,
-    "cpr6m": 0,
-    "cpr12m": 0,
-    "servicer": "MULTIPLE",
-    "geography": "8.1% MI",
-    "purchasePercent": 86.11,
-    "refinancePercent": 2.57,
-    "thirdpartyOriginationPercent": 22.74,
-    "loanCount": 1202
-  },
-  {
-    "bond": "FR RA9630",
-    "cusip": "3133KRVX9",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Freddie Mac",
-    "class4": "LB200",
-    "coupon": 6,
-    "couponType": "FIXED",
-    "issueYear": 2023,
-    "issueDate": "2023-01-08T12:00:00Z",
-    "originationAmount": 145753859,
-    "factor": 0.96495693,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 6.893,
-    "weightedAverageLoanAge": 7,
-    "weightedAverageMaturity": 350,
-    "weightedAverageOriginalMaturity": 359,
-    "loanSize": 189055.63,
-    "loanToValue": 76,
-    "fico": 747,
-    "cpr1m": 2.24030129615409,
-    "cpr3m": 6.68839252011055,
-    "cpr6m": 5.91022494514619,
-    "cpr12m": 0,
-    "servicer": "MULTIPLE",
-    "geography": "8.6% OH",
-    "purchasePercent": 90.38,
-    "refinancePercent": 2.38,
-    "thirdpartyOriginationPercent": 21.03,
-    "loanCount": 755
-  },
-  {
-    "bond": "FN CB7268",
-    "cusip": "3140QTCE7",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Fannie Mae",
-    "class4": "LB200",
-    "coupon": 6,
-    "couponType": "FIXED",
-    "issueYear": 2023,
-    "issueDate": "2023-01-09T12:00:00Z",
-    "originationAmount": 125362602,
-    "factor": 0.97162608,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 6.906,
-    "weightedAverageLoanAge": 6,
-    "weightedAverageMaturity": 350,
-    "weightedAverageOriginalMaturity": 359,
-    "loanSize": 189749.62,
-    "loanToValue": 70,
-    "fico": 755,
-    "cpr1m": 5.14250638462958,
-    "cpr3m": 6.15683152909675,
-    "cpr6m": 0,
-    "cpr12m": 0,
-    "servicer": "MULTIPLE",
-    "geography": "8.0% TX",
-    "purchasePercent": 85.7,
-    "refinancePercent": 3.98,
-    "thirdpartyOriginationPercent": 19.41,
-    "loanCount": 651
-  },
-  {
-    "bond": "FN MA5217",
-    "cusip": "31418EYP9",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Fannie Mae",
-    "class4": "MULTI",
-    "coupon": 6.5,
-    "couponType": "FIXED",
-    "issueYear": 2023,
-    "issueDate": "2023-01-11T12:00:00Z",
-    "originationAmount": 4093714449,
-    "factor": 0.96469766,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 7.426,
-    "weightedAverageLoanAge": 4,
-    "weightedAverageMaturity": 354,
-    "weightedAverageOriginalMaturity": 359,
-    "loanSize": 464760.8,
-    "loanToValue": 79,
-    "fico": 762,
-    "cpr1m": 17.4297745645987,
-    "cpr3m": 12.5874571255959,
-    "cpr6m": 0,
-    "cpr12m": 0,
-    "servicer": "MULTIPLE",
-    "geography": "18.4% CA",
-    "purchasePercent": 90.86,
-    "refinancePercent": 3.78,
-    "thirdpartyOriginationPercent": 51.06,
-    "loanCount": 8654
-  },
-  {
-    "bond": "FN MA5140",
-    "cusip": "31418EWA4",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Fannie Mae",
-    "class4": "MULTI",
-    "coupon": 6.5,
-    "couponType": "FIXED",
-    "issueYear": 2023,
-    "issueDate": "2023-01-08T12:00:00Z",
-    "originationAmount": 3627717925,
-    "factor": 0.93678064,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 7.268,
-    "weightedAverageLoanAge": 7,
-    "weightedAverageMaturity": 350,
-    "weightedAverageOriginalMaturity": 360,
-    "loanSize": 449226.29,
-    "loanToValue": 82,
-    "fico": 753,
-    "cpr1m": 11.5825375586612,
-    "cpr3m": 10.8221700509957,
-    "cpr6m": 11.3947131671694,
-    "cpr12m": 0,
-    "servicer": "MULTIPLE",
-    "geography": "17.1% CA",
-    "purchasePercent": 89.08,
-    "refinancePercent": 3.68,
-    "thirdpartyOriginationPercent": 43.05,
-    "loanCount": 7791
-  },
-  {
-    "bond": "FN CB6748",
-    "cusip": "3140QSQA2",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Fannie Mae",
-    "class4": "NY",
-    "coupon": 5.5,
-    "couponType": "FIXED",
-    "issueYear": 2023,
-    "issueDate": "2023-01-06T12:00:00Z",
-    "originationAmount": 178226754,
-    "factor": 0.96860093,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 6.428,
-    "weightedAverageLoanAge": 9,
-    "weightedAverageMaturity": 349,
-    "weightedAverageOriginalMaturity": 360,
-    "loanSize": 410749.42,
-    "loanToValue": 79,
-    "fico": 756,
-    "cpr1m": 3.33560710227496,
-    "cpr3m": 2.96609726644229,
-    "cpr6m": 3.77247130718431,
-    "cpr12m": 0,
-    "servicer": "MULTIPLE",
-    "geography": "NY",
-    "purchasePercent": 90.11,
-    "refinancePercent": 2.46,
-    "thirdpartyOriginationPercent": 35.9,
-    "loanCount": 431
-  },
-  {
-    "bond": "FN CB5908",
-    "cusip": "3140QRR62",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Fannie Mae",
-    "class4": "NY",
-    "coupon": 5.5,
-    "couponType": "FIXED",
-    "issueYear": 2023,
-    "issueDate": "2023-01-02T12:00:00Z",
-    "originationAmount": 150133068,
-    "factor": 0.957715,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 6.284,
-    "weightedAverageLoanAge": 13,
-    "weightedAverageMaturity": 343,
-    "weightedAverageOriginalMaturity": 358,
-    "loanSize": 435681.42,
-    "loanToValue": 80,
-    "fico": 748,
-    "cpr1m": 9.87178820013022,
-    "cpr3m": 4.71902372731735,
-    "cpr6m": 4.18701229340098,
-    "cpr12m": 3.07513950515229,
-    "servicer": "MULTIPLE",
-    "geography": "NY",
-    "purchasePercent": 88.28,
-    "refinancePercent": 5.81,
-    "thirdpartyOriginationPercent": 36.46,
-    "loanCount": 339
-  },
-  {
-    "bond": "FN CB6869",
-    "cusip": "3140QST35",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Fannie Mae",
-    "class4": "NY",
-    "coupon": 5.5,
-    "couponType": "FIXED",
-    "issueYear": 2023,
-    "issueDate": "2023-01-07T12:00:00Z",
-    "originationAmount": 140533292,
-    "factor": 0.95464635,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 6.437,
-    "weightedAverageLoanAge": 8,
-    "weightedAverageMaturity": 348,
-    "weightedAverageOriginalMaturity": 359,
-    "loanSize": 432619.5,
-    "loanToValue": 79,
-    "fico": 758,
-    "cpr1m": 0.472854139209777,
-    "cpr3m": 4.15121986406464,
-    "cpr6m": 7.15802681452632,
-    "cpr12m": 0,
-    "servicer": "MULTIPLE",
-    "geography": "NY",
-    "purchasePercent": 93.88,
-    "refinancePercent": 1.71,
-    "thirdpartyOriginationPercent": 18.38,
-    "loanCount": 318
-  },
-  {
-    "bond": "FN CB6482",
-    "cusip": "3140QSFY2",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Fannie Mae",
-    "class4": "NY",
-    "coupon": 5.5,
-    "couponType": "FIXED",
-    "issueYear": 2023,
-    "issueDate": "2023-01-05T12:00:00Z",
-    "originationAmount": 107951236,
-    "factor": 0.97083992,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 6.18,
-    "weightedAverageLoanAge": 10,
-    "weightedAverageMaturity": 345,
-    "weightedAverageOriginalMaturity": 358,
-    "loanSize": 425277.78,
-    "loanToValue": 79,
-    "fico": 759,
-    "cpr1m": 7.09079150697088,
-    "cpr3m": 3.08763010454798,
-    "cpr6m": 2.27910112599279,
-    "cpr12m": 0,
-    "servicer": "MULTIPLE",
-    "geography": "NY",
-    "purchasePercent": 90.84,
-    "refinancePercent": 3.33,
-    "thirdpartyOriginationPercent": 28.84,
-    "loanCount": 252
-  },
-  {
-    "bond": "FN CB5139",
-    "cusip": "3140QQV93",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Fannie Mae",
-    "class4": "HLTV",
-    "coupon": 5.5,
-    "couponType": "FIXED",
-    "issueYear": 2022,
-    "issueDate": "2022-01-10T12:00:00Z",
-    "originationAmount": 250041394,
-    "factor": 0.93455934,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 5.96,
-    "weightedAverageLoanAge": 16,
-    "weightedAverageMaturity": 342,
-    "weightedAverageOriginalMaturity": 360,
-    "loanSize": 397554.83,
-    "loanToValue": 95,
-    "fico": 746,
-    "cpr1m": 1.85853778624079,
-    "cpr3m": 1.73742104895436,
-    "cpr6m": 2.22735569330397,
-    "cpr12m": 2.94321370074915,
-    "servicer": "MULTIPLE",
-    "geography": "9.7% CA",
-    "purchasePercent": 99.43,
-    "refinancePercent": 0.57,
-    "thirdpartyOriginationPercent": 23.34,
-    "loanCount": 611
-  },
-  {
-    "bond": "FN CB4970",
-    "cusip": "3140QQQY4",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Fannie Mae",
-    "class4": "HLTV",
-    "coupon": 5.5,
-    "couponType": "FIXED",
-    "issueYear": 2022,
-    "issueDate": "2022-01-10T12:00:00Z",
-    "originationAmount": 216061246,
-    "factor": 0.93019788,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 6.061,
-    "weightedAverageLoanAge": 17,
-    "weightedAverageMaturity": 341,
-    "weightedAverageOriginalMaturity": 360,
-    "loanSize": 407607.07,
-    "loanToValue": 95,
-    "fico": 749,
-    "cpr1m": 3.07185291854932,
-    "cpr3m": 3.98656080876489,
-    "cpr6m": 2.42421433080189,
-    "cpr12m": 3.77665965970383,
-    "servicer": "MULTIPLE",
-    "geography": "14.7% CA",
-    "purchasePercent": 99.62,
-    "refinancePercent": 0.38,
-    "thirdpartyOriginationPercent": 16.83,
-    "loanCount": 509
-  },
-  {
-    "bond": "FN CB5297",
-    "cusip": "3140QQ3F0",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Fannie Mae",
-    "class4": "HLTV",
-    "coupon": 5.5,
-    "couponType": "FIXED",
-    "issueYear": 2022,
-    "issueDate": "2022-01-11T12:00:00Z",
-    "originationAmount": 203183101,
-    "factor": 0.92149284,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 6.299,
-    "weightedAverageLoanAge": 16,
-    "weightedAverageMaturity": 342,
-    "weightedAverageOriginalMaturity": 360,
-    "loanSize": 388695.56,
-    "loanToValue": 95,
-    "fico": 753,
-    "cpr1m": 5.03400491529074,
-    "cpr3m": 1.8721699451223,
-    "cpr6m": 3.4246334092068,
-    "cpr12m": 4.54522159747978,
-    "servicer": "MULTIPLE",
-    "geography": "10.1% CA",
-    "purchasePercent": 99.05,
-    "refinancePercent": 0.95,
-    "thirdpartyOriginationPercent": 33.09,
-    "loanCount": 496
-  },
-  {
-    "bond": "G2 MA9242",
-    "cusip": "36179YHT4",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Government National Mortgage A",
-    "class4": "MULTI",
-    "coupon": 6,
-    "couponType": "FIXED",
-    "issueYear": 2023,
-    "issueDate": "2023-01-10T12:00:00Z",
-    "originationAmount": 7980943418,
-    "factor": 0.9909056,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 6.566,
-    "weightedAverageLoanAge": 5,
-    "weightedAverageMaturity": 354,
-    "weightedAverageOriginalMaturity": 359,
-    "loanSize": 356995.64,
-    "loanToValue": 93,
-    "fico": 704,
-    "cpr1m": 1.89015816537793,
-    "cpr3m": 1.67157730000239,
-    "cpr6m": 0,
-    "cpr12m": 0,
-    "servicer": "MULTIPLE ISSUER",
-    "geography": "12.0% CA",
-    "purchasePercent": 82.4,
-    "refinancePercent": 16.32,
-    "thirdpartyOriginationPercent": 62.94,
-    "loanCount": 22281
-  },
-  {
-    "bond": "G2 MA9172",
-    "cusip": "36179YFM1",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Government National Mortgage A",
-    "class4": "MULTI",
-    "coupon": 6,
-    "couponType": "FIXED",
-    "issueYear": 2023,
-    "issueDate": "2023-01-09T12:00:00Z",
-    "originationAmount": 7667201482,
-    "factor": 0.98812788,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 6.6,
-    "weightedAverageLoanAge": 6,
-    "weightedAverageMaturity": 353,
-    "weightedAverageOriginalMaturity": 359,
-    "loanSize": 358440.11,
-    "loanToValue": 93,
-    "fico": 699,
-    "cpr1m": 2.25655741333923,
-    "cpr3m": 1.89286200022102,
-    "cpr6m": 0,
-    "cpr12m": 0,
-    "servicer": "MULTIPLE ISSUER",
-    "geography": "11.4% FL",
-    "purchasePercent": 82.27,
-    "refinancePercent": 15.81,
-    "thirdpartyOriginationPercent": 60.91,
-    "loanCount": 21292
-  },
-  {
-    "bond": "G2 MA9306",
-    "cusip": "36179YKT0",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Government National Mortgage A",
-    "class4": "MULTI",
-    "coupon": 6,
-    "couponType": "FIXED",
-    "issueYear": 2023,
-    "issueDate": "2023-01-11T12:00:00Z",
-    "originationAmount": 7532195555,
-    "factor": 0.99398447,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 6.563,
-    "weightedAverageLoanAge": 4,
-    "weightedAverageMaturity": 355,
-    "weightedAverageOriginalMaturity": 359,
-    "loanSize": 352156.54,
-    "loanToValue": 92,
-    "fico": 709,
-    "cpr1m": 1.63031849396913,
-    "cpr3m": 1.31659980095864,
-    "cpr6m": 0,
-    "cpr12m": 0,
-    "servicer": "MULTIPLE ISSUER",
-    "geography": "12.2% CA",
-    "purchasePercent": 81.34,
-    "refinancePercent": 17.35,
-    "thirdpartyOriginationPercent": 64.71,
-    "loanCount": 21353
-  },
-  {
-    "bond": "G2 MA9107",
-    "cusip": "36179YDL5",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Government National Mortgage A",
-    "class4": "MULTI",
-    "coupon": 6,
-    "couponType": "FIXED",
-    "issueYear": 2023,
-    "issueDate": "2023-01-08T12:00:00Z",
-    "originationAmount": 6327021200,
-    "factor": 0.98436757,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 6.598,
-    "weightedAverageLoanAge": 7,
-    "weightedAverageMaturity": 352,
-    "weightedAverageOriginalMaturity": 359,
-    "loanSize": 366104.43,
-    "loanToValue": 94,
-    "fico": 694,
-    "cpr1m": 2.58494646624586,
-    "cpr3m": 2.4455304423796,
-    "cpr6m": 2.03768966510508,
-    "cpr12m": 0,
-    "servicer": "MULTIPLE ISSUER",
-    "geography": "11.2% CA",
-    "purchasePercent": 85.28,
-    "refinancePercent": 11.53,
-    "thirdpartyOriginationPercent": 57.41,
-    "loanCount": 17158
-  },
-  {
-    "bond": "G2 CD7420",
-    "cusip": "3617VJG55",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Government National Mortgage A",
-    "class4": "LB150",
-    "coupon": 3,
-    "couponType": "FIXED",
-    "issueYear": 2021,
-    "issueDate": "2021-01-07T12:00:00Z",
-    "originationAmount": 39881182,
-    "factor": 0.8374336,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 3.428,
-    "weightedAverageLoanAge": 32,
-    "weightedAverageMaturity": 326,
-    "weightedAverageOriginalMaturity": 358,
-    "loanSize": 138048.31,
-    "loanToValue": 95,
-    "fico": 666,
-    "cpr1m": 4.73835702832061,
-    "cpr3m": 4.58150912021613,
-    "cpr6m": 2.34172188130535,
-    "cpr12m": 2.6766140237733,
-    "servicer": "NEWREZ LLC",
-    "geography": "10.2% NY",
-    "purchasePercent": 83.56,
-    "refinancePercent": 16.44,
-    "thirdpartyOriginationPercent": 90.28,
-    "loanCount": 256
-  },
-  {
-    "bond": "G2 CD8339",
-    "cusip": "3617VKHQ5",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Government National Mortgage A",
-    "class4": "LB150",
-    "coupon": 3,
-    "couponType": "FIXED",
-    "issueYear": 2021,
-    "issueDate": "2021-01-06T12:00:00Z",
-    "originationAmount": 40931490,
-    "factor": 0.77394632,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 3.339,
-    "weightedAverageLoanAge": 34,
-    "weightedAverageMaturity": 322,
-    "weightedAverageOriginalMaturity": 357,
-    "loanSize": 138633.61,
-    "loanToValue": 93,
-    "fico": 670,
-    "cpr1m": 0.162513786364982,
-    "cpr3m": 1.7340241850262,
-    "cpr6m": 4.07962204678599,
-    "cpr12m": 7.02610781936022,
-    "servicer": "PENNYMAC LOAN SERVICES, LLC",
-    "geography": "11.1% OH",
-    "purchasePercent": 56.01,
-    "refinancePercent": 43.99,
-    "thirdpartyOriginationPercent": 62.72,
-    "loanCount": 244
-  },
-  {
-    "bond": "G2 CI6403",
-    "cusip": "3617XBDG9",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Government National Mortgage A",
-    "class4": "LB150",
-    "coupon": 3,
-    "couponType": "FIXED",
-    "issueYear": 2021,
-    "issueDate": "2022-01-01T12:00:00Z",
-    "originationAmount": 31585764,
-    "factor": 0.87259142,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 3.378,
-    "weightedAverageLoanAge": 26,
-    "weightedAverageMaturity": 332,
-    "weightedAverageOriginalMaturity": 358,
-    "loanSize": 137330.11,
-    "loanToValue": 91,
-    "fico": 665,
-    "cpr1m": 5.58503852105383,
-    "cpr3m": 7.20326548392994,
-    "cpr6m": 5.61736073217209,
-    "cpr12m": 5.53665961656541,
-    "servicer": "PENNYMAC LOAN SERVICES, LLC",
-    "geography": "9.5% OH",
-    "purchasePercent": 62.02,
-    "refinancePercent": 37.98,
-    "thirdpartyOriginationPercent": 71.27,
-    "loanCount": 210
-  },
-  {
-    "bond": "G2 CI6252",
-    "cusip": "3617XA5M7",
-    "class1": "passthrough",
-    "class2": "MBS 30yr",
-    "class3": "Government National Mortgage A",
-    "class4": "LB150",
-    "coupon": 3,
-    "couponType": "FIXED",
-    "issueYear": 2021,
-    "issueDate": "2021-01-12T12:00:00Z",
-    "originationAmount": 31595517,
-    "factor": 0.84680051,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 3.387,
-    "weightedAverageLoanAge": 27,
-    "weightedAverageMaturity": 330,
-    "weightedAverageOriginalMaturity": 358,
-    "loanSize": 138052.3,
-    "loanToValue": 92,
-    "fico": 664,
-    "cpr1m": 0.347370802139957,
-    "cpr3m": 0.369522269827982,
-    "cpr6m": 4.25979281990627,
-    "cpr12m": 5.43103969958879,
-    "servicer": "PENNYMAC LOAN SERVICES, LLC",
-    "geography": "14.4% OH",
-    "purchasePercent": 74.49,
-    "refinancePercent": 25.51,
-    "thirdpartyOriginationPercent": 82.62,
-    "loanCount": 204
-  },
-  {
-    "bond": "FN MA4303",
-    "cusip": "31418DX98",
-    "class1": "passthrough",
-    "class2": "MBS 15yr",
-    "class3": "Fannie Mae",
-    "class4": "MULTI",
-    "coupon": 2,
-    "couponType": "FIXED",
-    "issueYear": 2021,
-    "issueDate": "2021-01-03T12:00:00Z",
-    "originationAmount": 6547621205,
-    "factor": 0.68041477,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 2.442,
-    "weightedAverageLoanAge": 36,
-    "weightedAverageMaturity": 140,
-    "weightedAverageOriginalMaturity": 179,
-    "loanSize": 299809.59,
-    "loanToValue": 59,
-    "fico": 774,
-    "cpr1m": 2.95853000235295,
-    "cpr3m": 3.46195573159662,
-    "cpr6m": 4.07004739151163,
-    "cpr12m": 4.28951534003942,
-    "servicer": "MULTIPLE",
-    "geography": "21.1% CA",
-    "purchasePercent": 2.42,
-    "refinancePercent": 68.18,
-    "thirdpartyOriginationPercent": 25.42,
-    "loanCount": 18581
-  },
-  {
-    "bond": "FN MA4360",
-    "cusip": "31418DZ21",
-    "class1": "passthrough",
-    "class2": "MBS 15yr",
-    "class3": "Fannie Mae",
-    "class4": "MULTI",
-    "coupon": 2,
-    "couponType": "FIXED",
-    "issueYear": 2021,
-    "issueDate": "2021-01-05T12:00:00Z",
-    "originationAmount": 6157411313,
-    "factor": 0.71341521,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 2.517,
-    "weightedAverageLoanAge": 34,
-    "weightedAverageMaturity": 143,
-    "weightedAverageOriginalMaturity": 179,
-    "loanSize": 301555.08,
-    "loanToValue": 59,
-    "fico": 772,
-    "cpr1m": 3.25063169533527,
-    "cpr3m": 3.0865801237884,
-    "cpr6m": 3.79107352052478,
-    "cpr12m": 4.05899524720996,
-    "servicer": "MULTIPLE",
-    "geography": "20.7% CA",
-    "purchasePercent": 7.19,
-    "refinancePercent": 60.15,
-    "thirdpartyOriginationPercent": 26.47,
-    "loanCount": 17891
-  },
-  {
-    "bond": "FN MA4329",
-    "cusip": "31418DY30",
-    "class1": "passthrough",
-    "class2": "MBS 15yr",
-    "class3": "Fannie Mae",
-    "class4": "MULTI",
-    "coupon": 2,
-    "couponType": "FIXED",
-    "issueYear": 2021,
-    "issueDate": "2021-01-04T12:00:00Z",
-    "originationAmount": 6062342490,
-    "factor": 0.69449444,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 2.478,
-    "weightedAverageLoanAge": 35,
-    "weightedAverageMaturity": 141,
-    "weightedAverageOriginalMaturity": 179,
-    "loanSize": 299082.66,
-    "loanToValue": 59,
-    "fico": 773,
-    "cpr1m": 3.51800150001851,
-    "cpr3m": 3.15239343007757,
-    "cpr6m": 3.81078385739642,
-    "cpr12m": 4.20489645854161,
-    "servicer": "MULTIPLE",
-    "geography": "21.2% CA",
-    "purchasePercent": 3.24,
-    "refinancePercent": 64.58,
-    "thirdpartyOriginationPercent": 24.65,
-    "loanCount": 17420
-  },
-  {
-    "bond": "FN MA5222",
-    "cusip": "31418EYU8",
-    "class1": "passthrough",
-    "class2": "MBS 20yr",
-    "class3": "Fannie Mae",
-    "class4": "MULTI",
-    "coupon": 6,
-    "couponType": "FIXED",
-    "issueYear": 2023,
-    "issueDate": "2023-01-11T12:00:00Z",
-    "originationAmount": 104406055,
-    "factor": 0.95179642,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 6.906,
-    "weightedAverageLoanAge": 4,
-    "weightedAverageMaturity": 229,
-    "weightedAverageOriginalMaturity": 238,
-    "loanSize": 295937.14,
-    "loanToValue": 64,
-    "fico": 756,
-    "cpr1m": 23.8053122933415,
-    "cpr3m": 15.9177110212683,
-    "cpr6m": 0,
-    "cpr12m": 0,
-    "servicer": "MULTIPLE",
-    "geography": "8.0% WI",
-    "purchasePercent": 60.77,
-    "refinancePercent": 4.42,
-    "thirdpartyOriginationPercent": 14.71,
-    "loanCount": 350
-  },
-  {
-    "bond": "FN MA5205",
-    "cusip": "31418EYB0",
-    "class1": "passthrough",
-    "class2": "MBS 20yr",
-    "class3": "Fannie Mae",
-    "class4": "MULTI",
-    "coupon": 6,
-    "couponType": "FIXED",
-    "issueYear": 2023,
-    "issueDate": "2023-01-10T12:00:00Z",
-    "originationAmount": 94136040,
-    "factor": 0.96315848,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 6.804,
-    "weightedAverageLoanAge": 5,
-    "weightedAverageMaturity": 231,
-    "weightedAverageOriginalMaturity": 239,
-    "loanSize": 274950.29,
-    "loanToValue": 66,
-    "fico": 752,
-    "cpr1m": 3.86388065352944,
-    "cpr3m": 6.73709082756004,
-    "cpr6m": 0,
-    "cpr12m": 0,
-    "servicer": "MULTIPLE",
-    "geography": "12.5% TX",
-    "purchasePercent": 55.81,
-    "refinancePercent": 6.48,
-    "thirdpartyOriginationPercent": 12.83,
-    "loanCount": 342
-  },
-  {
-    "bond": "FN MA5176",
-    "cusip": "31418EXE5",
-    "class1": "passthrough",
-    "class2": "MBS 20yr",
-    "class3": "Fannie Mae",
-    "class4": "MULTI",
-    "coupon": 6,
-    "couponType": "FIXED",
-    "issueYear": 2023,
-    "issueDate": "2023-01-09T12:00:00Z",
-    "originationAmount": 78466831,
-    "factor": 0.91303118,
-    "factorDate": "2024-01-02T12:00:00Z",
-    "weightedAverageCoupon": 6.918,
-    "weightedAverageLoanAge": 6,
-    "weightedAverageMaturity": 231,
-    "weightedAverageOriginalMaturity": 238,
-    "loanSize": 245426.71,
-    "loanToValue": 65,
-    "fico": 741,
-    "cpr1m": 13.8766323207317,
-    "cpr3m": 11.5894532282547,
-    "cpr6m": 0,
-    "cpr12m": 0,
-    "servicer": "MULTIPLE",
-    "geography": "17.6% TX",
-    "purchasePercent": 48.76,
-    "refinancePercent": 4.53,
-    "thirdpartyOriginationPercent": 18.98,
-    "loanCount": 307
-  }
-]
-`)
-
-// var InitData = []byte(`
-// [
-//   {
-//     "bond": "FR RA9851",
-//     "cusip": "3133KR5L4",
-//     "class1": "passthrough",
-//     "class2": "MBS 30yr",
-//     "class3": "Freddie Mac",
-//     "class4": "LB200",
-//     "coupon": 6,
-//     "couponType": "FIXED",
-//     "issueYear": 2023,
-//     "issueDate": "2023-01-09T12:04:05Z",
-//     "originationAmount": 231480386,
-//     "factor": 0.96735693,
-//     "factorDate": "2024-01-02T12:04:05Z",
-//     "weightedAverageCoupon": 6.895,
-//     "weightedAverageLoanAge": 7,
-//     "weightedAverageMaturity": 350,
-//     "weightedAverageOriginalMaturity": 359,
-//     "loanSize": 189432.61,
-//     "loanToValue": 74,
-//     "fico": 747,
-//     "cpr1m": 7.07805522352359,
-//     "cpr3m": 6.72923614321762,
-//     "cpr6m": 0,
-//     "cpr12m": 0,
-//     "servicer": "MULTIPLE",
-//     "geography": "8.1% MI",
-//     "purchasePercent": 86.11,
-//     "refinancePercent": 2.57,
-//     "thirdpartyOriginationPercent": 22.74,
-//     "loanCount": 1202
-//   },
-//   {
-//     "bond": "FR RA9630",
-//     "cusip": "3133KRVX9",
-//     "class1": "passthrough",
-//     "class2": "MBS 30yr",
-//     "class3": "Freddie Mac",
-//     "class4": "LB200",
-//     "coupon": 6,
-//     "couponType": "FIXED",
-//     "issueYear": 2023,
-//     "issueDate": "2023-01-08T12:04:05Z",
-//     "originationAmount": 145753859,
-//     "factor": 0.96495693,
-//     "factorDate": "2024-01-02T12:04:05Z",
-//     "weightedAverageCoupon": 6.893,
-//     "weightedAverageLoanAge": 7,
-//     "weightedAverageMaturity": 350,
-//     "weightedAverageOriginalMaturity": 359,
-//     "loanSize": 189055.63,
-//     "loanToValue": 76,
-//     "fico": 747,
-//     "cpr1m": 2.24030129615409,
-//     "cpr3m": 6.68839252011055,
-//     "cpr6m": 5.91022494514619,
-//     "cpr12m": 0,
-//     "servicer": "MULTIPLE",
-//     "geography": "8.6% OH",
-//     "purchasePercent": 90.38,
-//     "refinancePercent": 2.38,
-//     "thirdpartyOriginationPercent": 21.03,
-//     "loanCount": 755
-//   }
-// ]
-// `)
\ No newline at end of file
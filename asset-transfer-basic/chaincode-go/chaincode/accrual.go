@@ -0,0 +1,185 @@
+package chaincode
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// AccruedInterest is the coupon interest a buyer owes the seller for the days of the accrual period before settlement,
+// with the terms it was computed from so that both counterparties can check it
+type AccruedInterest struct {
+	Cusip        string  `json:"cusip"`
+	SettleDate   string  `json:"settleDate"`   // YYYY-MM-DD
+	Face         int64   `json:"face"`         // Original face, in cents
+	Coupon       float64 `json:"coupon"`       // Annual coupon rate in percent
+	Factor       float64 `json:"factor"`       // Factor the current face was computed at
+	FactorDate   string  `json:"factorDate"`   // Date of that factor. Empty when the cusip has none
+	AccrualStart string  `json:"accrualStart"` // First day of interest, YYYY-MM-DD
+	Days         int     `json:"days"`         // 30/360 days from the accrual start to the settle date
+	Amount       float64 `json:"amount"`       // In dollars
+}
+
+// couponTerms are the terms of a cusip accrued interest is computed from
+type couponTerms struct {
+	coupon     float64
+	factor     float64
+	factorDate string
+	issueDate  time.Time // Zero when no bond of the cusip has one
+}
+
+const (
+	// Agency MBS pay on the 25th of the month after the month interest accrued in, the UMBS delay
+	mbsPaymentDay = 25
+	// Days in a 30/360 year
+	thirty360Basis = 360.0
+)
+
+// ⭐ Functions ⭐
+
+// AccruedInterest returns the interest accrued on a face amount of a cusip at a settle date (YYYY-MM-DD).
+// Following the MBS convention interest accrues from the first of the settle month, or from the issue date in the issue month,
+// on the current face at the 30/360 day count. The pool data is the reference for coupon and factor, bonds without it use their own
+func (s *SmartContract) AccruedInterest(ctx contractapi.TransactionContextInterface, cusip, settleDate string, face int64) (*AccruedInterest, error) {
+	if face <= 0 {
+		return nil, fmt.Errorf("face must be positive: %d", face)
+	}
+	settle, err := parseDate(settleDate)
+	if err != nil {
+		return nil, err
+	}
+
+	terms, err := s.getCouponTerms(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Date(settle.Year(), settle.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if !terms.issueDate.IsZero() {
+		if settle.Before(terms.issueDate) {
+			return nil, fmt.Errorf("settle date %s is before the issue date %s", settleDate, terms.issueDate.Format(markDateLayout))
+		}
+		if terms.issueDate.After(start) {
+			start = terms.issueDate
+		}
+	}
+
+	days := days360(start, settle)
+	return &AccruedInterest{
+		Cusip:        cusip,
+		SettleDate:   settleDate,
+		Face:         face,
+		Coupon:       terms.coupon,
+		Factor:       terms.factor,
+		FactorDate:   terms.factorDate,
+		AccrualStart: start.Format(markDateLayout),
+		Days:         days,
+		Amount:       dollars(face) * terms.factor * terms.coupon / 100 * float64(days) / thirty360Basis,
+	}, nil
+}
+
+// NextPaymentDate returns the next date (YYYY-MM-DD) a cusip pays interest and principal after the transaction date.
+// Payments fall on the 25th, and the first one in the month after the issue month
+func (s *SmartContract) NextPaymentDate(ctx contractapi.TransactionContextInterface, cusip string) (string, error) {
+	terms, err := s.getCouponTerms(ctx, cusip)
+	if err != nil {
+		return "", err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	next := time.Date(now.Year(), now.Month(), mbsPaymentDay, 0, 0, 0, 0, time.UTC)
+	if !next.After(now) {
+		next = next.AddDate(0, 1, 0)
+	}
+	if !terms.issueDate.IsZero() {
+		first := time.Date(terms.issueDate.Year(), terms.issueDate.Month()+1, mbsPaymentDay, 0, 0, 0, 0, time.UTC)
+		if next.Before(first) {
+			next = first
+		}
+	}
+
+	return next.Format(markDateLayout), nil
+}
+
+// ⭐ Helper functions ⭐
+
+// getCouponTerms returns the coupon, factor and issue date of a cusip. The pool data is used when the cusip has some,
+// otherwise the first bond of the cusip with a coupon. Bonds without a factor are taken at a factor of 1
+func (s *SmartContract) getCouponTerms(ctx contractapi.TransactionContextInterface, cusip string) (*couponTerms, error) {
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bonds, err := stores.Bonds.GetBondsByCusip(cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	terms := &couponTerms{factor: 1}
+	for _, bond := range bonds {
+		if terms.issueDate.IsZero() && bond.IssueDate != "" {
+			terms.issueDate, err = parseIssueDate(bond.IssueDate)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if terms.coupon == 0 && bond.Coupon != 0 {
+			terms.coupon = bond.Coupon
+			if bond.Factor != 0 {
+				terms.factor = bond.Factor
+				terms.factorDate = bond.FactorDate
+			}
+		}
+	}
+
+	var pool Pool
+	exists, err := s.getRecord(ctx, poolObjectType, cusip, &pool)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		terms.coupon = pool.Coupon
+		terms.factor = pool.Factor
+		terms.factorDate = pool.FactorDate
+	}
+
+	if terms.coupon == 0 {
+		return nil, fmt.Errorf("no coupon is known for Cusip %s", cusip)
+	}
+	return terms, nil
+}
+
+// parseIssueDate parses the issue date of a bond, a calendar date or an RFC3339 timestamp, to the start of its day in UTC
+func parseIssueDate(value string) (time.Time, error) {
+	parsed, err := time.Parse(markDateLayout, value)
+	if err != nil {
+		parsed, err = time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return time.Time{}, NewError(ErrInvalidInput, "issue date must be a YYYY-MM-DD date or an RFC3339 timestamp: %v", err)
+		}
+	}
+
+	parsed = parsed.UTC()
+	return time.Date(parsed.Year(), parsed.Month(), parsed.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// days360 returns the days between two dates on the 30/360 bond basis: a 31st counts as the 30th,
+// and the end date only does so when the start date is the 30th or 31st
+func days360(start, end time.Time) int {
+	d1 := start.Day()
+	d2 := end.Day()
+	if d1 == 31 {
+		d1 = 30
+	}
+	if d2 == 31 && d1 == 30 {
+		d2 = 30
+	}
+
+	return 360*(end.Year()-start.Year()) + 30*(int(end.Month())-int(start.Month())) + d2 - d1
+}
@@ -0,0 +1,183 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// Allocation is the part of a block trade's face booked to a sub-account. A sub-account can split its part further
+// across its own sub-accounts, e.g. a fund across its client accounts
+type Allocation struct {
+	SubAccount   string       `json:"subAccount"`
+	OriginalFace int64        `json:"originalFace"`          // In cents
+	Allocations  []Allocation `json:"allocations,omitempty"` // Split of this sub-account's face, which it must add up to. Empty when it is not split
+}
+
+// TransactionAllocation is how a party to a settled transaction split its side of the fill across its sub-accounts
+type TransactionAllocation struct {
+	TxnID        string       `json:"txnID"` // ID of the transaction, the ExecID of its FIX execution report
+	Side         string       `json:"side"`  //"Buy" or "Sell"
+	Cusip        string       `json:"cusip"`
+	OriginalFace int64        `json:"originalFace"` // Face of the transaction, in cents
+	Allocations  []Allocation `json:"allocations"`
+	AllocatedAt  time.Time    `json:"allocatedAt"`
+}
+
+const allocationObjectType = "allocation"
+
+// ⭐ Functions ⭐
+
+// AllocateTransaction splits the caller's side of a settled transaction across sub-accounts. allocationsJSON is an array of
+// Allocation whose faces add up to the face of the transaction, at every level. The transaction is named by its ID, the ExecID
+// ExportTransactionsFIX reports it under. Allocating again replaces the split. Sub-accounts are the caller's own business,
+// so the split is kept in its implicit collection
+func (s *SmartContract) AllocateTransaction(ctx contractapi.TransactionContextInterface, txnID, allocationsJSON string) (*WriteResponse, error) {
+	var allocations []Allocation
+	err := json.Unmarshal([]byte(allocationsJSON), &allocations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal allocations: %v", err)
+	}
+
+	partyHash, err := s.GenerateOrgHash(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate owner hash: %v", err)
+	}
+	transaction, err := s.getPartyTransaction(ctx, partyHash, txnID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateAllocations(allocations, transaction.OriginalFace, "the transaction")
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	allocation := TransactionAllocation{
+		TxnID:        txnID,
+		Side:         "Buy",
+		Cusip:        transaction.Cusip,
+		OriginalFace: transaction.OriginalFace,
+		Allocations:  allocations,
+		AllocatedAt:  timestamp,
+	}
+	if transaction.SellerID == partyHash {
+		allocation.Side = "Sell"
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(allocationObjectType, []string{txnID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s key: %v", allocationObjectType, err)
+	}
+	allocationJSON, err := json.Marshal(allocation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal allocation: %v", err)
+	}
+	err = ctx.GetStub().PutPrivateData(implicitCollection(mspID), key, allocationJSON)
+	if err != nil {
+		return nil, fmt.Errorf("%s - failed to put allocation: %v", implicitCollection(mspID), err)
+	}
+
+	return newWriteResponse(ctx, &allocation)
+}
+
+// GetAllocations returns how the caller split its side of a transaction across sub-accounts
+func (s *SmartContract) GetAllocations(ctx contractapi.TransactionContextInterface, txnID string) (*TransactionAllocation, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(allocationObjectType, []string{txnID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s key: %v", allocationObjectType, err)
+	}
+	allocationJSON, err := ctx.GetStub().GetPrivateData(implicitCollection(mspID), key)
+	if err != nil {
+		return nil, fmt.Errorf("%s - failed to get allocation: %v", implicitCollection(mspID), err)
+	}
+	if allocationJSON == nil {
+		return nil, fmt.Errorf("transaction %s is not allocated", txnID)
+	}
+
+	var allocation TransactionAllocation
+	err = json.Unmarshal(allocationJSON, &allocation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal allocation: %v", err)
+	}
+
+	return &allocation, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// getPartyTransaction returns the transaction with the given ID among those the party bought or sold in
+func (s *SmartContract) getPartyTransaction(ctx contractapi.TransactionContextInterface, partyHash, txnID string) (*Transaction, error) {
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	transactions, _, err := stores.Trades.QueryTransactions(transactionPartyIndexType, partyHash, time.Time{}, time.Time{}, 0, "")
+	if err != nil {
+		return nil, err
+	}
+
+	for i, transaction := range transactions {
+		id, err := transactionID(transaction)
+		if err != nil {
+			return nil, err
+		}
+		if id == txnID {
+			return &transactions[i], nil
+		}
+	}
+
+	return nil, NewError(ErrNotFound, "transaction %s not found among your transactions", txnID)
+}
+
+// validateAllocations checks that allocations split face exactly, each sub-account once and with a positive face,
+// and that the split of each sub-account is valid for its own face in turn
+func validateAllocations(allocations []Allocation, face int64, of string) error {
+	if len(allocations) == 0 {
+		return fmt.Errorf("%s must be allocated to at least one sub-account", of)
+	}
+
+	var total int64
+	subAccounts := []string{}
+	for _, allocation := range allocations {
+		if allocation.SubAccount == "" {
+			return fmt.Errorf("sub-account cannot be empty")
+		}
+		if containsString(subAccounts, allocation.SubAccount) {
+			return fmt.Errorf("sub-account %s is allocated more than once", allocation.SubAccount)
+		}
+		subAccounts = append(subAccounts, allocation.SubAccount)
+		if allocation.OriginalFace <= 0 {
+			return fmt.Errorf("the face allocated to %s must be positive: %d", allocation.SubAccount, allocation.OriginalFace)
+		}
+		total += allocation.OriginalFace
+
+		if len(allocation.Allocations) > 0 {
+			err := validateAllocations(allocation.Allocations, allocation.OriginalFace, "sub-account "+allocation.SubAccount)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if total != face {
+		return fmt.Errorf("the allocations of %s add up to %d but its face is %d", of, total, face)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+// setUpAllocation returns a world where Org1 bought tradeFace from Org2, and the ID of the transaction
+func setUpAllocation(t *testing.T, contract *chaincode.SmartContract) (*world, string) {
+	w := setUp(t, contract)
+	recordTransactions(t, w, contract, testCusip, [][2]string{{org1, org2}})
+
+	messages, err := contract.ExportTransactionsFIX(w.begin(org1), "2023-01-01", "2023-01-31")
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	return w, fixFields(t, messages[0])["17"]
+}
+
+func TestAllocateTransaction(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w, txnID := setUpAllocation(t, contract)
+
+			_, err := contract.AllocateTransaction(w.begin(org1), txnID, `[
+				{"subAccount":"FUND1","originalFace":60000000,"allocations":[
+					{"subAccount":"CLIENT-A","originalFace":40000000},
+					{"subAccount":"CLIENT-B","originalFace":20000000}
+				]},
+				{"subAccount":"FUND2","originalFace":40000000}
+			]`)
+			require.NoError(t, err)
+			w.commit()
+
+			allocation, err := contract.GetAllocations(w.begin(org1), txnID)
+			require.NoError(t, err)
+			require.Equal(t, "Buy", allocation.Side)
+			require.Equal(t, testCusip, allocation.Cusip)
+			require.Equal(t, int64(tradeFace), allocation.OriginalFace)
+			require.Len(t, allocation.Allocations, 2)
+			require.Len(t, allocation.Allocations[0].Allocations, 2)
+
+			// The seller allocates its own side, and cannot see the buyer's
+			_, err = contract.GetAllocations(w.begin(org2), txnID)
+			require.EqualError(t, err, "transaction "+txnID+" is not allocated")
+			_, err = contract.AllocateTransaction(w.begin(org2), txnID, `[{"subAccount":"BOOK1","originalFace":100000000}]`)
+			require.NoError(t, err)
+			w.commit()
+
+			allocation, err = contract.GetAllocations(w.begin(org2), txnID)
+			require.NoError(t, err)
+			require.Equal(t, "Sell", allocation.Side)
+		})
+	}
+}
+
+func TestAllocateTransactionErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		txnID       string
+		allocations string
+		err         string
+	}{
+		{name: "unknown transaction", txnID: "UNKNOWN", allocations: `[{"subAccount":"FUND1","originalFace":100000000}]`, err: contractError(chaincode.ErrNotFound, "transaction UNKNOWN not found among your transactions")},
+		{name: "short of the face", allocations: `[{"subAccount":"FUND1","originalFace":60000000}]`, err: "the allocations of the transaction add up to 60000000 but its face is 100000000"},
+		{name: "sub-account split short", allocations: `[{"subAccount":"FUND1","originalFace":100000000,"allocations":[{"subAccount":"CLIENT-A","originalFace":1}]}]`, err: "the allocations of sub-account FUND1 add up to 1 but its face is 100000000"},
+		{name: "repeated sub-account", allocations: `[{"subAccount":"FUND1","originalFace":50000000},{"subAccount":"FUND1","originalFace":50000000}]`, err: "sub-account FUND1 is allocated more than once"},
+		{name: "negative face", allocations: `[{"subAccount":"FUND1","originalFace":110000000},{"subAccount":"FUND2","originalFace":-10000000}]`, err: "the face allocated to FUND2 must be positive: -10000000"},
+		{name: "nothing allocated", allocations: `[]`, err: "the transaction must be allocated to at least one sub-account"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract := &chaincode.SmartContract{}
+			w, txnID := setUpAllocation(t, contract)
+			if test.txnID != "" {
+				txnID = test.txnID
+			}
+
+			_, err := contract.AllocateTransaction(w.begin(org1), txnID, test.allocations)
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package chaincode
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// TradeAmendment is a change to the terms of an open direct trade, proposed by one side and accepted or rejected by the other.
+// A face of zero proposes to cancel the trade
+type TradeAmendment struct {
+	ProposerHash string    `json:"proposerHash"`
+	Price        Price     `json:"price"`
+	Face         int64     `json:"face"`  // In cents
+	State        string    `json:"state"` // "Proposed", "Accepted", "Rejected" or "Superseded"
+	ProposedAt   time.Time `json:"proposedAt"`
+	DeciderHash  string    `json:"deciderHash,omitempty"` // The party that accepted or rejected it
+	DecidedAt    time.Time `json:"decidedAt,omitempty"`
+}
+
+// TradeAmendmentEvent is the payload of the events raised as an amendment of a direct trade is proposed and decided
+type TradeAmendmentEvent struct {
+	DirectTradeID string `json:"directTradeID"`
+	Reference     string `json:"reference,omitempty"`
+	Cusip         string `json:"cusip"`
+	ProposerHash  string `json:"proposerHash"`
+	DeciderHash   string `json:"deciderHash,omitempty"`
+	Face          int64  `json:"face"` // In cents
+	Price         Price  `json:"price"`
+	State         string `json:"state"`
+}
+
+// ⭐ Functions ⭐
+
+// ProposeTradeAmendment proposes a new price and face for an open direct trade, or its cancellation with a face of zero.
+// The bidder and any seller that answered the trade can propose. A new proposal supersedes the pending one
+func (s *SmartContract) ProposeTradeAmendment(ctx contractapi.TransactionContextInterface, tradeID, newPrice string, newFace int64) (*WriteResponse, error) {
+	callerHash, err := s.getEncryptionKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ledger, trade, err := s.getAmendableTrade(ctx, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	if callerHash != trade.BidderHash && trade.findAnswer(callerHash) == nil {
+		return nil, NewError(ErrUnauthorized, "you are not a party to direct trade %s", tradeID)
+	}
+
+	price, err := s.parsePrice(ctx, newPrice)
+	if err != nil {
+		return nil, err
+	}
+	filled := trade.OriginalFace - trade.openFace()
+	if newFace < 0 || (newFace != 0 && newFace <= filled) {
+		return nil, fmt.Errorf("the face of direct trade %s must be more than the %d already filled, or zero to cancel it", tradeID, filled)
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if pending := trade.pendingAmendment(); pending != nil {
+		pending.State = "Superseded"
+		pending.DecidedAt = now
+	}
+	trade.Amendments = append(trade.Amendments, TradeAmendment{
+		ProposerHash: callerHash,
+		Price:        price,
+		Face:         newFace,
+		State:        "Proposed",
+		ProposedAt:   now,
+	})
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+	err = emitAmendmentEvent(ctx, TradeAmendmentProposedEvent, trade, trade.pendingAmendment())
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// AcceptAmendment accepts the pending amendment of a direct trade. The bidder accepts the amendments of sellers, and any seller
+// that answered accepts those of the bidder. The answers given on the old terms are cleared and the bonds held for them freed,
+// so that every seller answers the new terms and the trade is open again. An accepted cancellation cancels the trade
+func (s *SmartContract) AcceptAmendment(ctx contractapi.TransactionContextInterface, tradeID string) (*WriteResponse, error) {
+	ledger, trade, amendment, deciderHash, err := s.decideAmendment(ctx, tradeID, "Accepted")
+	if err != nil {
+		return nil, err
+	}
+
+	releaseReservations(ledger, tradeID, "")
+	for i := range trade.Answers {
+		trade.Answers[i].SellerResponse = AnswerResponse{}
+		trade.Answers[i].BuyerResponse = AnswerResponse{}
+		trade.Answers[i].BondUID = ""
+	}
+
+	event := OrderEvent{Action: "Amend", OrderType: "Trade", OrderID: tradeID, Cusip: trade.Cusip, Face: amendment.Face, Price: amendment.Price}
+	if amendment.Face == 0 {
+		event = OrderEvent{Action: "Cancel", OrderType: "Trade", OrderID: tradeID, Cusip: trade.Cusip, Face: trade.openFace(), Price: trade.BidPrice}
+		err = directTradeLifecycle.move(tradeID, &trade.State, trade.closingState())
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if trade.State == "Answered" {
+			err = directTradeLifecycle.move(tradeID, &trade.State, "Open")
+			if err != nil {
+				return nil, err
+			}
+		}
+		trade.RemainingFace = amendment.Face - (trade.OriginalFace - trade.openFace())
+		trade.OriginalFace = amendment.Face
+		trade.BidPrice = amendment.Price
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+	err = emitAmendmentEvent(ctx, TradeAmendedEvent, trade, amendment)
+	if err != nil {
+		return nil, err
+	}
+	if amendment.Face == 0 {
+		err = emitTradeEvent(ctx, TradeClosedEvent, trade, deciderHash, trade.BidPrice, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = s.recordOrderEvents(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// RejectAmendment rejects the pending amendment of a direct trade, which keeps its terms.
+// The parties that can accept an amendment are the ones that can reject it
+func (s *SmartContract) RejectAmendment(ctx contractapi.TransactionContextInterface, tradeID string) (*WriteResponse, error) {
+	ledger, trade, amendment, _, err := s.decideAmendment(ctx, tradeID, "Rejected")
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+	err = emitAmendmentEvent(ctx, TradeAmendmentRejectedEvent, trade, amendment)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// ⭐ Helper functions ⭐
+
+// getAmendableTrade returns the ledger of a direct trade and the trade, which must be open and unexpired
+func (s *SmartContract) getAmendableTrade(ctx contractapi.TransactionContextInterface, tradeID string) (*Ledger, *DirectTrade, error) {
+	ledger, err := s.getTradeLedger(ctx, tradeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range ledger.DirectTrades {
+		trade := &ledger.DirectTrades[i]
+		if trade.DirectTradeID != tradeID {
+			continue
+		}
+		if !trade.open() {
+			return nil, nil, fmt.Errorf("direct trade is closed")
+		}
+		err = requireUnexpiredTrade(ctx, trade)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ledger, trade, nil
+	}
+
+	return nil, nil, NewError(ErrNotFound, "direct trade not found")
+}
+
+// decideAmendment marks the pending amendment of a direct trade with the caller's decision, after checking that the caller
+// is on the other side from the proposer. It returns the ledger, the trade, the amendment and the caller's party hash
+func (s *SmartContract) decideAmendment(ctx contractapi.TransactionContextInterface, tradeID, decision string) (*Ledger, *DirectTrade, *TradeAmendment, string, error) {
+	callerHash, err := s.getEncryptionKey(ctx)
+	if err != nil {
+		return nil, nil, nil, "", err
+	}
+	ledger, trade, err := s.getAmendableTrade(ctx, tradeID)
+	if err != nil {
+		return nil, nil, nil, "", err
+	}
+	amendment := trade.pendingAmendment()
+	if amendment == nil {
+		return nil, nil, nil, "", fmt.Errorf("direct trade %s has no pending amendment", tradeID)
+	}
+
+	// The bidder decides on the sellers' proposals, the sellers on the bidder's
+	counterparty := callerHash == trade.BidderHash
+	if amendment.ProposerHash == trade.BidderHash {
+		counterparty = callerHash != trade.BidderHash && trade.findAnswer(callerHash) != nil
+	}
+	if !counterparty {
+		return nil, nil, nil, "", NewError(ErrUnauthorized, "only the other side of direct trade %s can decide on its amendment", tradeID)
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, nil, nil, "", err
+	}
+	amendment.State = decision
+	amendment.DeciderHash = callerHash
+	amendment.DecidedAt = now
+
+	return ledger, trade, amendment, callerHash, nil
+}
+
+// pendingAmendment returns the amendment of the trade awaiting a decision, or nil if there is none
+func (t *DirectTrade) pendingAmendment() *TradeAmendment {
+	if len(t.Amendments) == 0 || t.Amendments[len(t.Amendments)-1].State != "Proposed" {
+		return nil
+	}
+
+	return &t.Amendments[len(t.Amendments)-1]
+}
+
+// findAnswer returns the answer of a seller to the trade, or nil if it did not answer
+func (t *DirectTrade) findAnswer(sellerHash string) *Answer {
+	for i := range t.Answers {
+		if t.Answers[i].SellerIDHash == sellerHash {
+			return &t.Answers[i]
+		}
+	}
+
+	return nil
+}
+
+// emitAmendmentEvent raises one of the amendment events of a direct trade
+func emitAmendmentEvent(ctx contractapi.TransactionContextInterface, name string, trade *DirectTrade, amendment *TradeAmendment) error {
+	return emitEvent(ctx, name, TradeAmendmentEvent{
+		DirectTradeID: trade.DirectTradeID,
+		Reference:     trade.Reference,
+		Cusip:         trade.Cusip,
+		ProposerHash:  amendment.ProposerHash,
+		DeciderHash:   amendment.DeciderHash,
+		Face:          amendment.Face,
+		Price:         amendment.Price,
+		State:         amendment.State,
+	})
+}
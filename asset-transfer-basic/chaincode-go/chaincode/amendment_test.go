@@ -0,0 +1,90 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTradeAmendment(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUpTrade(t, contract, 200000000)
+
+			_, err := contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+
+			// The seller asks for a better price, which only the bidder can accept
+			_, err = contract.ProposeTradeAmendment(w.begin(org2), "trade1", "99.75", tradeFace)
+			require.NoError(t, err)
+			require.Contains(t, w.events(), chaincode.TradeAmendmentProposedEvent)
+			w.commit()
+			_, err = contract.AcceptAmendment(w.begin(org2), "trade1")
+			require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "only the other side of direct trade trade1 can decide on its amendment"))
+
+			_, err = contract.AcceptAmendment(w.begin(org1), "trade1")
+			require.NoError(t, err)
+			require.Contains(t, w.events(), chaincode.TradeAmendedEvent)
+			w.commit()
+
+			trades, err := contract.GetYourDirectTrades(w.begin(org1))
+			require.NoError(t, err)
+			require.Len(t, trades, 1)
+			require.Equal(t, chaincode.Price("99.75"), trades[0].BidPrice)
+			require.Len(t, trades[0].Amendments, 1)
+			require.Equal(t, "Accepted", trades[0].Amendments[0].State)
+			require.Empty(t, trades[0].Answers[0].SellerResponse.Value)
+
+			// The seller answers the new terms, and the trade settles at the amended price
+			_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.AnswerTradeAsOwner(w.begin(org1), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			require.Contains(t, w.events(), chaincode.TradeSettledEvent)
+			w.commit()
+
+			transactions, err := contract.GetAllTransactions(w.begin(org1))
+			require.NoError(t, err)
+			require.Len(t, transactions, 1)
+			require.Equal(t, chaincode.Price("99.75"), transactions[0].BoughtPrice)
+		})
+	}
+}
+
+func TestTradeAmendmentRejectedAndCancelled(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpTrade(t, contract, 200000000)
+
+	_, err := contract.ProposeTradeAmendment(w.begin(org2), "trade1", "99.75", tradeFace)
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "you are not a party to direct trade trade1"))
+
+	_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "no", testTime, "")
+	require.NoError(t, err)
+	w.commit()
+
+	// The bidder proposes to cancel, and the seller who answered decides
+	_, err = contract.ProposeTradeAmendment(w.begin(org1), "trade1", tradePrice, 0)
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.RejectAmendment(w.begin(org2), "trade1")
+	require.NoError(t, err)
+	require.Contains(t, w.events(), chaincode.TradeAmendmentRejectedEvent)
+	w.commit()
+	_, err = contract.AcceptAmendment(w.begin(org2), "trade1")
+	require.EqualError(t, err, "direct trade trade1 has no pending amendment")
+
+	_, err = contract.ProposeTradeAmendment(w.begin(org1), "trade1", tradePrice, 0)
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.AcceptAmendment(w.begin(org2), "trade1")
+	require.NoError(t, err)
+	require.Contains(t, w.events(), chaincode.TradeClosedEvent)
+	w.commit()
+
+	_, err = contract.ProposeTradeAmendment(w.begin(org1), "trade1", tradePrice, tradeFace)
+	require.EqualError(t, err, "direct trade is closed")
+}
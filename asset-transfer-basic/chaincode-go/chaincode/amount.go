@@ -0,0 +1,267 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Faces, origination amounts and cash balances are whole numbers of cents, so that splitting and summing them never rounds.
+// Prices and rates stay float64, and the cash they price is rounded to the cent once, when it is computed
+const centsPerDollar = 100
+
+const (
+	faceCentsConfigID = "facecents"
+	cashCentsConfigID = "cashcents"
+	// Key of the marker of the migrated inventory in an organization's implicit collection
+	faceCentsInventoryKey = "face_cents_migrated"
+)
+
+// Public record types with face fields, and the JSON names of those fields
+var faceFieldsByObjectType = map[string][]string{
+	offerObjectType:        {"originalFace", "remainingFace", "minPiece"},
+	pledgeObjectType:       {"originalFace"},
+	loanObjectType:         {"originalFace"},
+	repoObjectType:         {"originalFace"},
+	rfmObjectType:          {"originalFace"},
+	distributionObjectType: {"originalFace"},
+	axeObjectType:          {"approxFace"},
+	orderEventKeyType:      {"face"},
+}
+
+// ⭐ Functions ⭐
+
+// MigrateFaceToCents converts the faces stored on the public ledger from whole dollars to cents: the bonds, direct trades
+// and transactions, and the records of offers, pledges, loans, repos, RFMs, distributions, axes and order events.
+// Only the admin organization can run it, and only once
+func (s *SmartContract) MigrateFaceToCents(ctx contractapi.TransactionContextInterface) (*WriteResponse, error) {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrated bool
+	exists, err := s.getRecord(ctx, configObjectType, faceCentsConfigID, &migrated)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("faces are already in cents")
+	}
+
+	ledger, err := s.GetLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ledger.Bonds {
+		ledger.Bonds[i].OriginalFace *= centsPerDollar
+		ledger.Bonds[i].OriginationAmount *= centsPerDollar
+	}
+	for i := range ledger.DirectTrades {
+		ledger.DirectTrades[i].OriginalFace *= centsPerDollar
+		ledger.DirectTrades[i].RemainingFace *= centsPerDollar
+	}
+	for i := range ledger.Transactions {
+		ledger.Transactions[i].OriginalFace *= centsPerDollar
+	}
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, err
+	}
+
+	for objectType, fields := range faceFieldsByObjectType {
+		err = scaleRecordFields(ctx, objectType, fields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.putRecord(ctx, configObjectType, faceCentsConfigID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// MigrateInventoryFaceToCents converts the faces kept in the caller's implicit collection from whole dollars to cents:
+// the inventory items and the minimum pieces of the private bonds. Each organization runs it once, after MigrateFaceToCents.
+// Inventory transfers still offered at that point carry the hash of the item before the migration and should be settled first
+func (s *SmartContract) MigrateInventoryFaceToCents(ctx contractapi.TransactionContextInterface) (*WriteResponse, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	marker, err := ctx.GetStub().GetPrivateData(implicitCollection(mspID), faceCentsInventoryKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s - failed to get migration marker: %v", implicitCollection(mspID), err)
+	}
+	if marker != nil {
+		return nil, fmt.Errorf("the inventory of %s is already in cents", mspID)
+	}
+
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inventory, err := stores.Inventory.GetInventory(mspID)
+	if err != nil {
+		return nil, err
+	}
+	if inventory != nil {
+		for _, item := range inventory.Assets {
+			if item.Content == nil {
+				continue
+			}
+			item.Content.OriginalFace *= centsPerDollar
+			item.Content.OriginationAmount *= centsPerDollar
+		}
+		err = stores.Inventory.PutInventory(mspID, inventory)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store inventory: %v", err)
+		}
+	}
+
+	privateBonds, err := s.getPrivateBonds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range privateBonds {
+		privateBonds[i].MinPiece *= centsPerDollar
+	}
+	err = s.putPrivateBonds(ctx, privateBonds)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.GetStub().PutPrivateData(implicitCollection(mspID), faceCentsInventoryKey, []byte{1})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store migration marker: %v", err)
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// MigrateCashToCents converts the cash accounts from balances in dollars to balances in cents. Only the admin organization
+// can run it, and only once
+func (s *SmartContract) MigrateCashToCents(ctx contractapi.TransactionContextInterface) (*WriteResponse, error) {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrated bool
+	exists, err := s.getRecord(ctx, configObjectType, cashCentsConfigID, &migrated)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("cash balances are already in cents")
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(cashAccountObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cash accounts: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over cash accounts: %v", err)
+		}
+
+		var legacy struct {
+			OwnerHash string             `json:"ownerHash"`
+			Currency  string             `json:"currency"`
+			Balance   float64            `json:"balance"`
+			Balances  map[string]float64 `json:"balances"`
+		}
+		err = json.Unmarshal(queryResponse.Value, &legacy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal cash account %s: %v", queryResponse.Key, err)
+		}
+
+		account := CashAccount{OwnerHash: legacy.OwnerHash, Currency: legacy.Currency, Balance: cents(legacy.Balance)}
+		for currency, balance := range legacy.Balances {
+			account.adjust(currency, cents(balance))
+		}
+		err = s.putRecord(ctx, cashAccountObjectType, legacy.OwnerHash, account)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.putRecord(ctx, configObjectType, cashCentsConfigID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// ⭐ Helper functions ⭐
+
+// dollars converts an amount in cents to dollars, for the price computations and the messages
+func dollars(cents int64) float64 {
+	return float64(cents) / centsPerDollar
+}
+
+// cents converts an amount in dollars to cents, rounded to the cent, for the cash it moves
+func cents(dollars float64) int64 {
+	return int64(math.Round(dollars * centsPerDollar))
+}
+
+// scaleRecordFields multiplies the given integer fields of every record of an object type by centsPerDollar.
+// The records are rewritten field by field, so fields this version of the chaincode does not know survive
+func scaleRecordFields(ctx contractapi.TransactionContextInterface, objectType string, fields []string) error {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(objectType, []string{})
+	if err != nil {
+		return fmt.Errorf("failed to get %s records: %v", objectType, err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return fmt.Errorf("error iterating over %s records: %v", objectType, err)
+		}
+
+		// Numbers are decoded as json.Number so that large faces are not rounded through float64
+		var record map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(queryResponse.Value))
+		decoder.UseNumber()
+		err = decoder.Decode(&record)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal %s %s: %v", objectType, queryResponse.Key, err)
+		}
+
+		for _, field := range fields {
+			number, ok := record[field].(json.Number)
+			if !ok {
+				continue
+			}
+			value, err := strconv.ParseInt(number.String(), 10, 64)
+			if err != nil {
+				return fmt.Errorf("failed to parse %s of %s %s: %v", field, objectType, queryResponse.Key, err)
+			}
+			record[field] = value * centsPerDollar
+		}
+
+		recordBytes, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %v", objectType, err)
+		}
+		err = ctx.GetStub().PutState(queryResponse.Key, recordBytes)
+		if err != nil {
+			return fmt.Errorf("failed to put %s %s: %v", objectType, queryResponse.Key, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package chaincode
+
+import (
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// BondAnalytics is the price, yield and weighted average life of a cusip under a constant prepayment rate, with the terms they
+// were computed from so that both counterparties can reproduce them. Cash flows are projected month by month: the loans amortize
+// at their gross rate over the remaining term, a single monthly mortality of the balance prepays on top, and holders receive
+// the net coupon. Prices are per 100 of current face, and yields annual percents compounded monthly, without payment delay
+type BondAnalytics struct {
+	Cusip  string  `json:"cusip"`
+	Coupon float64 `json:"coupon"` // Net coupon paid to holders, in percent
+	WAC    float64 `json:"wac"`    // Gross rate the loans amortize at, in percent. The coupon when the cusip has none
+	WAM    int     `json:"wam"`    // Remaining term in months
+	WALA   int     `json:"wala"`   // Loan age in months, zero when unknown
+	Factor float64 `json:"factor"`
+	CPR    float64 `json:"cpr"`             // Assumed conditional prepayment rate, annual percent
+	Price  Price   `json:"price,omitempty"` // Per 100 of current face
+	Yield  float64 `json:"yield,omitempty"` // Annual percent
+	WAL    float64 `json:"wal"`             // Weighted average life in years
+}
+
+// ⭐ Functions ⭐
+
+// ComputeYield returns the yield of a cusip at a price under a CPR assumption (annual percent), with its weighted average life
+func (s *SmartContract) ComputeYield(ctx contractapi.TransactionContextInterface, cusip, price string, cprAssumption float64) (*BondAnalytics, error) {
+	parsed, err := s.parsePrice(ctx, price)
+	if err != nil {
+		return nil, err
+	}
+	analytics, err := s.bondAnalytics(ctx, cusip, cprAssumption)
+	if err != nil {
+		return nil, err
+	}
+
+	flows := analytics.cashFlows()
+	analytics.Price = parsed
+	analytics.Yield = yieldFromCashFlows(parsed.value(), flows)
+	analytics.WAL = weightedAverageLife(flows)
+	return analytics, nil
+}
+
+// ComputeWAL returns the weighted average life of a cusip in years under a CPR assumption (annual percent)
+func (s *SmartContract) ComputeWAL(ctx contractapi.TransactionContextInterface, cusip string, cprAssumption float64) (*BondAnalytics, error) {
+	analytics, err := s.bondAnalytics(ctx, cusip, cprAssumption)
+	if err != nil {
+		return nil, err
+	}
+
+	analytics.WAL = weightedAverageLife(analytics.cashFlows())
+	return analytics, nil
+}
+
+// PriceFromYield returns the price of a cusip at a yield (annual percent) under a CPR assumption (annual percent),
+// with its weighted average life
+func (s *SmartContract) PriceFromYield(ctx contractapi.TransactionContextInterface, cusip string, yield, cpr float64) (*BondAnalytics, error) {
+	analytics, err := s.bondAnalytics(ctx, cusip, cpr)
+	if err != nil {
+		return nil, err
+	}
+
+	flows := analytics.cashFlows()
+	analytics.Price = formatPrice(priceFromCashFlows(yield, flows), 6)
+	analytics.Yield = yield
+	analytics.WAL = weightedAverageLife(flows)
+	return analytics, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// cashFlow is what a holder of 100 of current face receives in a month of the projection
+type cashFlow struct {
+	interest  float64
+	principal float64
+}
+
+// bondAnalytics returns the terms of a cusip the analytics are computed from. Coupon and factor follow AccruedInterest.
+// The remaining term is the pool's WAM, otherwise the weighted average maturity of the first bond that has one,
+// and the gross rate and loan age come from the first bonds that have them
+func (s *SmartContract) bondAnalytics(ctx contractapi.TransactionContextInterface, cusip string, cpr float64) (*BondAnalytics, error) {
+	if cpr < 0 || cpr >= 100 {
+		return nil, NewError(ErrInvalidInput, "the CPR assumption must be at least 0 and below 100: %v", cpr)
+	}
+	terms, err := s.getCouponTerms(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bonds, err := stores.Bonds.GetBondsByCusip(cusip)
+	if err != nil {
+		return nil, err
+	}
+	analytics := &BondAnalytics{Cusip: cusip, Coupon: terms.coupon, Factor: terms.factor, CPR: cpr}
+	for _, bond := range bonds {
+		if analytics.WAM == 0 && bond.WeightedAverageMaturity > 0 {
+			analytics.WAM = int(math.Ceil(bond.WeightedAverageMaturity))
+		}
+		if analytics.WALA == 0 && bond.WeightedAverageLoanAge > 0 {
+			analytics.WALA = int(math.Round(bond.WeightedAverageLoanAge))
+		}
+		if analytics.WAC == 0 && bond.WeightedAverageCoupon > 0 {
+			analytics.WAC = bond.WeightedAverageCoupon
+		}
+	}
+
+	var pool Pool
+	exists, err := s.getRecord(ctx, poolObjectType, cusip, &pool)
+	if err != nil {
+		return nil, err
+	}
+	if exists && pool.WAM > 0 {
+		analytics.WAM = pool.WAM
+	}
+	if analytics.WAM == 0 {
+		return nil, NewError(ErrInvalidInput, "no weighted average maturity is known for Cusip %s", cusip)
+	}
+	if analytics.WAC == 0 {
+		analytics.WAC = analytics.Coupon
+	}
+
+	return analytics, nil
+}
+
+// cashFlows projects the monthly cash flows of 100 of current face over the remaining term
+func (a *BondAnalytics) cashFlows() []cashFlow {
+	gross := a.WAC / 100 / 12
+	net := a.Coupon / 100 / 12
+	smm := 1 - math.Pow(1-a.CPR/100, 1.0/12)
+
+	flows := make([]cashFlow, 0, a.WAM)
+	balance := 100.0
+	for month := 1; month <= a.WAM; month++ {
+		remaining := float64(a.WAM - month + 1)
+		scheduled := balance / remaining
+		if gross != 0 {
+			scheduled = balance*gross/(1-math.Pow(1+gross, -remaining)) - balance*gross
+		}
+		principal := scheduled + smm*(balance-scheduled)
+		flows = append(flows, cashFlow{interest: balance * net, principal: principal})
+		balance -= principal
+	}
+
+	return flows
+}
+
+// priceFromCashFlows returns the price of the cash flows discounted at an annual yield in percent compounded monthly
+func priceFromCashFlows(yield float64, flows []cashFlow) float64 {
+	rate := yield / 100 / 12
+
+	price := 0.0
+	discount := 1.0
+	for _, flow := range flows {
+		discount /= 1 + rate
+		price += (flow.interest + flow.principal) * discount
+	}
+
+	return price
+}
+
+// yieldFromCashFlows returns the annual yield in percent implied by a price of the cash flows, solved by bisection
+func yieldFromCashFlows(price float64, flows []cashFlow) float64 {
+	low, high := -50.0, 100.0
+	for i := 0; i < 100; i++ {
+		mid := (low + high) / 2
+		// Price falls as the yield rises
+		if priceFromCashFlows(mid, flows) > price {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+
+	return math.Round((low+high)/2*1e6) / 1e6
+}
+
+// weightedAverageLife returns the average time in years until the principal is repaid, weighted by the principal
+func weightedAverageLife(flows []cashFlow) float64 {
+	weighted, total := 0.0, 0.0
+	for i, flow := range flows {
+		weighted += float64(i+1) * flow.principal
+		total += flow.principal
+	}
+	if total == 0 {
+		return 0
+	}
+
+	return math.Round(weighted/total/12*1e4) / 1e4
+}
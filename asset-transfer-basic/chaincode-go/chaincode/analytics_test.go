@@ -0,0 +1,57 @@
+package chaincode_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBondAnalytics(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+	_, err := contract.RegisterPool(w.begin(org1), testCusip, "", 6, 0.8, "2023-01-01", 360)
+	require.NoError(t, err)
+	w.commit()
+
+	// Without prepayments a bond priced at its coupon is at par
+	analytics, err := contract.PriceFromYield(w.begin(org1), testCusip, 6, 0)
+	require.NoError(t, err)
+	require.Equal(t, chaincode.Price("100"), analytics.Price)
+	require.Equal(t, 360, analytics.WAM)
+	require.Equal(t, 0.8, analytics.Factor)
+	require.InDelta(t, 19.3, analytics.WAL, 0.1)
+
+	// Prepayments shorten the life
+	fast, err := contract.ComputeWAL(w.begin(org1), testCusip, 20)
+	require.NoError(t, err)
+	require.Less(t, fast.WAL, 5.0)
+
+	// The yield of a price is the yield that price comes from
+	analytics, err = contract.PriceFromYield(w.begin(org1), testCusip, 5.25, 8)
+	require.NoError(t, err)
+	price, err := strconv.ParseFloat(string(analytics.Price), 64)
+	require.NoError(t, err)
+	require.Greater(t, price, 100.0)
+	yield, err := contract.ComputeYield(w.begin(org1), testCusip, string(analytics.Price), 8)
+	require.NoError(t, err)
+	require.InDelta(t, 5.25, yield.Yield, 0.0001)
+	require.Equal(t, analytics.WAL, yield.WAL)
+}
+
+func TestBondAnalyticsErrors(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+
+	_, err := contract.ComputeWAL(w.begin(org1), testCusip, 8)
+	require.EqualError(t, err, "no coupon is known for Cusip "+testCusip)
+
+	_, err = contract.RegisterPool(w.begin(org1), testCusip, "", 6, 1, "2023-01-01", 360)
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.ComputeWAL(w.begin(org1), testCusip, 100)
+	require.EqualError(t, err, contractError(chaincode.ErrInvalidInput, "the CPR assumption must be at least 0 and below 100: 100"))
+	_, err = contract.ComputeYield(w.begin(org1), testCusip, "par", 8)
+	require.EqualError(t, err, contractError(chaincode.ErrInvalidInput, `invalid price "par": prices are decimal strings such as "101.25"`))
+}
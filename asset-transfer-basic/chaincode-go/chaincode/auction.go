@@ -0,0 +1,470 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// Auction sells one of the seller's bonds to the highest sealed bid at or above the seller's private reserve price.
+// The bond is held for the auction from its creation until it is settled or goes unsold
+type Auction struct {
+	AuctionID  string         `json:"auctionID"`
+	UID        string         `json:"uid"` // Bond sold
+	Cusip      string         `json:"cusip"`
+	Face       int64          `json:"face"` // Face of the bond sold, in cents
+	SellerMSP  string         `json:"sellerMSP"`
+	SellerHash string         `json:"sellerHash"`
+	EndTime    time.Time      `json:"endTime"`
+	Bids       []AuctionBid   `json:"bids"`
+	State      string         `json:"state"` //"Open", "Closed", "Settled" or "Unsold"
+	Winner     *AuctionWinner `json:"winner,omitempty"`
+	CreatedAt  time.Time      `json:"createdAt"`
+}
+
+// AuctionBid is the public trace of a sealed bid. The price stays in the bidder's implicit collection, only its hash is shown
+type AuctionBid struct {
+	BidderMSP   string    `json:"bidderMSP"`
+	BidHash     string    `json:"bidHash"`
+	SubmittedAt time.Time `json:"submittedAt"`
+}
+
+// AuctionWinner is the bid an auction was settled at
+type AuctionWinner struct {
+	BidderMSP  string `json:"bidderMSP"`
+	BidderHash string `json:"bidderHash"`
+	Price      Price  `json:"price"`
+}
+
+// SealedBid is a bidder's bid for an auction. The salt keeps the price from being guessed from the public hash
+type SealedBid struct {
+	AuctionID  string `json:"auctionID"`
+	BidderMSP  string `json:"bidderMSP"`
+	BidderHash string `json:"bidderHash"` // Owner hash the bidder trades under
+	Price      Price  `json:"price"`
+	Salt       string `json:"salt"`
+}
+
+const (
+	auctionObjectType = "auction"
+	sealedBidKeyType  = "auctionbid"
+)
+
+// ⭐ Functions ⭐
+
+// CreateAuction puts up for auction one of the seller's free bonds of a cusip with a face of at least minFace.
+// Sealed bids are taken until endTime
+func (s *SmartContract) CreateAuction(ctx contractapi.TransactionContextInterface, auctionID, sellerHash, cusip string, minFace int64, endTime, createdAt time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	toUTC(&endTime)
+
+	if minFace <= 0 {
+		return nil, fmt.Errorf("minimum face must be positive: %v", minFace)
+	}
+	if !endTime.After(createdAt) {
+		return nil, fmt.Errorf("the auction must end after it is created")
+	}
+	if !s.IsOwner(ctx, sellerHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of %s", sellerHash)
+	}
+	err = s.requireActivePool(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing Auction
+	exists, err := s.getRecord(ctx, auctionObjectType, auctionID, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, NewError(ErrAlreadyExists, "auction %s already exists", auctionID)
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	owner, err := s.callerOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ledger, err := s.getCusipLedger(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+	bondIndex := -1
+	for i, bond := range ledger.Bonds {
+		if owner.owns(&ledger.Bonds[i]) && bond.ReservedFor == "" && bond.OriginalFace >= minFace {
+			bondIndex = i
+			break
+		}
+	}
+	if bondIndex == -1 {
+		return nil, fmt.Errorf("you have no free bond of Cusip %s with a face of at least %d", cusip, minFace)
+	}
+	err = ledger.Bonds[bondIndex].reserve(auctionID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	auction := Auction{
+		AuctionID:  auctionID,
+		UID:        ledger.Bonds[bondIndex].UID,
+		Cusip:      cusip,
+		Face:       ledger.Bonds[bondIndex].OriginalFace,
+		SellerMSP:  mspID,
+		SellerHash: sellerHash,
+		EndTime:    endTime,
+		Bids:       []AuctionBid{},
+		State:      "Open",
+		CreatedAt:  createdAt,
+	}
+	err = s.putRecord(ctx, auctionObjectType, auctionID, auction)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.recordOrderEvents(ctx, OrderEvent{Action: "Create", OrderType: "Auction", OrderID: auctionID, Cusip: cusip, Face: auction.Face})
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, auctionID)
+}
+
+// SubmitSealedBid stores the calling bidder's bid, passed in the transient field "bid", in the bidder's implicit collection
+// and puts its hash on the auction. Bids are taken until the end time, and bidding again replaces the previous bid
+func (s *SmartContract) SubmitSealedBid(ctx contractapi.TransactionContextInterface, auctionID string, timestamp time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	auction, err := s.getAuctionInState(ctx, auctionID, "Open")
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !now.Before(auction.EndTime) {
+		return nil, fmt.Errorf("auction %s stopped taking bids at %v", auctionID, auction.EndTime)
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	if mspID == auction.SellerMSP {
+		return nil, fmt.Errorf("you cannot bid in your own auction")
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("error getting transient: %v", err)
+	}
+	bidJSON, ok := transientMap["bid"]
+	if !ok {
+		return nil, NewError(ErrInvalidInput, "bid key not found in the transient map")
+	}
+
+	bid, err := s.parseSealedBid(ctx, bidJSON)
+	if err != nil {
+		return nil, err
+	}
+	if bid.AuctionID != auctionID || bid.BidderMSP != mspID {
+		return nil, fmt.Errorf("the bid must be for auction %s from %s", auctionID, mspID)
+	}
+	// Settling debits the bidder hash of the winning bid, so it must be the caller's
+	if !s.IsOwner(ctx, bid.BidderHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of %s", bid.BidderHash)
+	}
+
+	bidKey, err := ctx.GetStub().CreateCompositeKey(sealedBidKeyType, []string{auctionID, mspID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	// The bid hash is verified when settling, so the bid bytes are stored as they were passed
+	err = ctx.GetStub().PutPrivateData(implicitCollection(mspID), bidKey, bidJSON)
+	if err != nil {
+		return nil, fmt.Errorf("%s - failed to put bid: %v", implicitCollection(mspID), err)
+	}
+
+	hash := sha256.Sum256(bidJSON)
+	sealed := AuctionBid{
+		BidderMSP:   mspID,
+		BidHash:     hex.EncodeToString(hash[:]),
+		SubmittedAt: timestamp,
+	}
+
+	replaced := false
+	for i, existing := range auction.Bids {
+		if existing.BidderMSP == mspID {
+			auction.Bids[i] = sealed
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		auction.Bids = append(auction.Bids, sealed)
+	}
+
+	err = s.putRecord(ctx, auctionObjectType, auctionID, auction)
+	if err != nil {
+		return nil, err
+	}
+
+	// The price is sealed, so only the hash goes into the public event
+	err = s.recordOrderEvents(ctx, OrderEvent{Action: "Answer", OrderType: "Auction", OrderID: auctionID, Cusip: auction.Cusip, Face: auction.Face, Detail: sealed.BidHash})
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// CloseAuction stops an auction from taking bids once its end time passed. Only the seller can close it
+func (s *SmartContract) CloseAuction(ctx contractapi.TransactionContextInterface, auctionID string) (*WriteResponse, error) {
+	auction, err := s.getAuctionInState(ctx, auctionID, "Open")
+	if err != nil {
+		return nil, err
+	}
+	err = s.requireAuctionSeller(ctx, auction)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if now.Before(auction.EndTime) {
+		return nil, fmt.Errorf("auction %s takes bids until %v", auctionID, auction.EndTime)
+	}
+
+	auction.State = "Closed"
+	err = s.putRecord(ctx, auctionObjectType, auctionID, auction)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// RevealAndSettle settles a closed auction. The bidders hand their sealed bids to the seller, who passes them as a JSON array
+// in the transient field "bids". Each bid is checked against its hash on the auction, and the highest one at or above the
+// seller's reserve price for the bond wins, the earliest of equal bids first. The bond goes to the winner and a Transaction
+// is recorded. Bids left out cannot win. Without a winning bid the auction goes unsold and the bond is freed
+func (s *SmartContract) RevealAndSettle(ctx contractapi.TransactionContextInterface, auctionID string, timestamp time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	auction, err := s.getAuctionInState(ctx, auctionID, "Closed")
+	if err != nil {
+		return nil, err
+	}
+	err = s.requireAuctionSeller(ctx, auction)
+	if err != nil {
+		return nil, err
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("error getting transient: %v", err)
+	}
+	bidsJSON, ok := transientMap["bids"]
+	if !ok {
+		return nil, NewError(ErrInvalidInput, "bids key not found in the transient map")
+	}
+	var revealed []json.RawMessage
+	err = json.Unmarshal(bidsJSON, &revealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bids JSON: %v", err)
+	}
+
+	hashes := map[string]AuctionBid{}
+	for _, sealed := range auction.Bids {
+		hashes[sealed.BidHash] = sealed
+	}
+
+	// The reserve price applies as of the end of the auction
+	reserve := Price("")
+	privateBonds, err := s.getPrivateBonds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, privateBond := range privateBonds {
+		if privateBond.UID == auction.UID {
+			reserve, err = privateBond.reserveAt(auction.EndTime)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	var winner *SealedBid
+	var winnerSubmittedAt time.Time
+	for _, bidJSON := range revealed {
+		hash := sha256.Sum256(bidJSON)
+		sealed, ok := hashes[hex.EncodeToString(hash[:])]
+		if !ok {
+			return nil, fmt.Errorf("a revealed bid does not match any bid hash of auction %s", auctionID)
+		}
+
+		bid, err := s.parseSealedBid(ctx, bidJSON)
+		if err != nil {
+			return nil, err
+		}
+		if bid.AuctionID != auctionID || bid.BidderMSP != sealed.BidderMSP {
+			return nil, fmt.Errorf("the bid of %s is not for auction %s", sealed.BidderMSP, auctionID)
+		}
+		if bid.Price.value() < reserve.value() {
+			continue
+		}
+		if winner == nil || bid.Price.value() > winner.Price.value() ||
+			(bid.Price.value() == winner.Price.value() && sealed.SubmittedAt.Before(winnerSubmittedAt)) {
+			winner = bid
+			winnerSubmittedAt = sealed.SubmittedAt
+		}
+	}
+
+	ledger, err := s.getCusipLedger(ctx, auction.Cusip)
+	if err != nil {
+		return nil, err
+	}
+	bondIndex := findBondByUID(ledger, auction.UID)
+	if bondIndex == -1 || ledger.Bonds[bondIndex].ReservedFor != auctionID {
+		return nil, fmt.Errorf("the bond of auction %s is no longer held for it", auctionID)
+	}
+
+	if winner == nil {
+		releaseReservations(ledger, auctionID, "")
+		err = s.updateLedger(ctx, ledger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update ledger: %v", err)
+		}
+
+		auction.State = "Unsold"
+		err = s.putRecord(ctx, auctionObjectType, auctionID, auction)
+		if err != nil {
+			return nil, err
+		}
+		return newWriteResponse(ctx, nil)
+	}
+
+	err = ledger.Bonds[bondIndex].deliver(s.ownerHashFor(ctx, winner.BidderHash, ledger.Bonds[bondIndex].UID))
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitBondTransferred(ctx, ledger.Bonds[bondIndex], auction.SellerHash, auctionID)
+	if err != nil {
+		return nil, err
+	}
+
+	executed := len(ledger.Transactions)
+	transaction := s.GenerateTransactionObject(winner.BidderHash, auction.SellerHash, auction.Cusip, auction.Face, string(winner.Price), timestamp)
+	err = s.settleTransaction(ctx, ledger, transaction, winner.Price.value())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	auction.State = "Settled"
+	auction.Winner = &AuctionWinner{BidderMSP: winner.BidderMSP, BidderHash: winner.BidderHash, Price: winner.Price}
+	err = s.putRecord(ctx, auctionObjectType, auctionID, auction)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.recordOrderEvents(ctx, executionEvents(ledger, executed, "Auction", auctionID)...)
+	if err != nil {
+		return nil, err
+	}
+	return newWriteResponse(ctx, auction.Winner)
+}
+
+// GetAuction returns an auction with the hashes of its sealed bids, and its winner once settled
+func (s *SmartContract) GetAuction(ctx contractapi.TransactionContextInterface, auctionID string) (*Auction, error) {
+	var auction Auction
+	exists, err := s.getRecord(ctx, auctionObjectType, auctionID, &auction)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, NewError(ErrNotFound, "auction %s not found", auctionID)
+	}
+
+	return &auction, nil
+}
+
+// ⭐ Helper functions ⭐
+
+func (s *SmartContract) getAuctionInState(ctx contractapi.TransactionContextInterface, auctionID, state string) (*Auction, error) {
+	auction, err := s.GetAuction(ctx, auctionID)
+	if err != nil {
+		return nil, err
+	}
+	if auction.State != state {
+		return nil, fmt.Errorf("auction %s is %s", auctionID, auction.State)
+	}
+
+	return auction, nil
+}
+
+func (s *SmartContract) requireAuctionSeller(ctx contractapi.TransactionContextInterface, auction *Auction) error {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	if mspID != auction.SellerMSP {
+		return NewError(ErrUnauthorized, "you are not the seller of auction %s", auction.AuctionID)
+	}
+
+	return nil
+}
+
+// parseSealedBid unmarshals a sealed bid and validates its price and salt
+func (s *SmartContract) parseSealedBid(ctx contractapi.TransactionContextInterface, bidJSON []byte) (*SealedBid, error) {
+	var bid SealedBid
+	err := json.Unmarshal(bidJSON, &bid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bid JSON: %v", err)
+	}
+	if bid.Salt == "" {
+		return nil, fmt.Errorf("the bid must have a salt")
+	}
+	err = s.validatePrice(ctx, bid.Price)
+	if err != nil {
+		return nil, err
+	}
+	if bid.Price.value() <= 0 {
+		return nil, fmt.Errorf("the bid must have a positive price: %s", bid.Price)
+	}
+
+	return &bid, nil
+}
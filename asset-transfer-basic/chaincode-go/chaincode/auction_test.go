@@ -0,0 +1,85 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+// setUpAuction returns a world where Org2 auctions uid1, with a reserve price of 99 valid until validUntil, for an hour after testTime
+func setUpAuction(t *testing.T, contract *chaincode.SmartContract, validUntil string) *world {
+	w := setUp(t, contract)
+	createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+	_, err := contract.CreateBondPrivate(w.begin(org2), "uid1", "99", "", validUntil)
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.DepositCash(w.begin(org1), org1, "USD", 200000000)
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.CreateAuction(w.begin(org2), "auction1", org2, testCusip, tradeFace, testTime.Add(time.Hour), testTime)
+	require.NoError(t, err)
+	w.commit()
+	return w
+}
+
+// submitSealedBid seals a bid of Org1 on auction1 under bidderHash and returns the bid JSON as it was passed
+func submitSealedBid(t *testing.T, w *world, contract *chaincode.SmartContract, bidderHash string) ([]byte, error) {
+	bidJSON, err := json.Marshal(chaincode.SealedBid{AuctionID: "auction1", BidderMSP: org1, BidderHash: bidderHash, Price: tradePrice, Salt: "salt"})
+	require.NoError(t, err)
+	w.transient = map[string][]byte{"bid": bidJSON}
+	_, err = contract.SubmitSealedBid(w.begin(org1), "auction1", testTime)
+	return bidJSON, err
+}
+
+// revealAndSettle closes auction1 after its end time and settles it with the given bids
+func revealAndSettle(t *testing.T, w *world, contract *chaincode.SmartContract, bids ...json.RawMessage) error {
+	closed := testTime.Add(2 * time.Hour)
+	_, err := contract.CloseAuction(w.beginAt(org2, closed), "auction1")
+	require.NoError(t, err)
+	w.commit()
+
+	bidsJSON, err := json.Marshal(bids)
+	require.NoError(t, err)
+	w.transient = map[string][]byte{"bids": bidsJSON}
+	_, err = contract.RevealAndSettle(w.beginAt(org2, closed), "auction1", closed)
+	return err
+}
+
+func TestRevealAndSettle(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpAuction(t, contract, "")
+
+	bidJSON, err := submitSealedBid(t, w, contract, org1)
+	require.NoError(t, err)
+	w.commit()
+
+	require.NoError(t, revealAndSettle(t, w, contract, bidJSON))
+	w.commit()
+
+	bonds, err := contract.GetAllBonds(w.begin(org1), false)
+	require.NoError(t, err)
+	require.Len(t, bonds, 1)
+	require.Equal(t, org1, bonds[0].OwnerHash)
+	requireCash(t, w, contract, 100500000, 99500000)
+}
+
+func TestRevealAndSettleErrors(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpAuction(t, contract, testTime.Add(30*time.Minute).Format(time.RFC3339))
+
+	// A bidder can only bid with its own cash
+	_, err := submitSealedBid(t, w, contract, org2)
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "you are not the owner of Org2MSP"))
+
+	bidJSON, err := submitSealedBid(t, w, contract, org1)
+	require.NoError(t, err)
+	w.commit()
+
+	// The reserve expired before the auction ended, so it is not taken as no reserve at all
+	err = revealAndSettle(t, w, contract, bidJSON)
+	require.ErrorContains(t, err, "the reserve price of bond uid1 expired at")
+}
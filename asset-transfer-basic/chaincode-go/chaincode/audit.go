@@ -0,0 +1,143 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// AuditEntry records a successful call of a contract function. The caller's identity and the arguments are hashed,
+// so the trail proves who called what without disclosing either
+type AuditEntry struct {
+	TxID         string    `json:"txID"`
+	Function     string    `json:"function"` // As called, qualified with the contract name, e.g. "TraderContract:CreateTrade"
+	MSPID        string    `json:"mspID"`
+	CallerIDHash string    `json:"callerIDHash"` // SHA-256 of the caller's client identity ID, in hex
+	Timestamp    time.Time `json:"timestamp"`
+	ArgsDigest   string    `json:"argsDigest"` // SHA-256 of the JSON array of the arguments, in hex
+}
+
+const (
+	auditObjectType = "audit"
+	// Audit entries read per range query page
+	auditPageSize = 100
+)
+
+// ⭐ Functions ⭐
+
+// GetAuditTrail returns the audit entries from fromDate to toDate (YYYY-MM-DD), both included, in time order.
+// Either date can be empty to leave that end of the range open. Only the admin organizations and the regulator can read it
+func (s *SmartContract) GetAuditTrail(ctx contractapi.TransactionContextInterface, fromDate, toDate string) ([]AuditEntry, error) {
+	err := s.requireAuditor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	from, to, err := parseDateRange(fromDate, toDate)
+	if err != nil {
+		return nil, err
+	}
+
+	// Audit keys start with the entry time, so the trail is read from the first key at or after from and stops at to.
+	// The shim takes no composite keys as GetStateByRange bounds, so the range is read in pages from a bookmark instead
+	stub := ctx.GetStub()
+	prefix, err := stub.CreateCompositeKey(auditObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s key: %v", auditObjectType, err)
+	}
+	bookmark, toKey := "", ""
+	if !from.IsZero() {
+		bookmark = prefix + from.UTC().Format(transactionKeyTimeLayout)
+	}
+	if !to.IsZero() {
+		toKey = prefix + to.UTC().Format(transactionKeyTimeLayout)
+	}
+
+	entries := []AuditEntry{}
+	for {
+		page, bookmarkAfter, done, err := readAuditPage(stub, bookmark, toKey)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, page...)
+		if done {
+			return entries, nil
+		}
+		bookmark = bookmarkAfter
+	}
+}
+
+// ⭐ Helper functions ⭐
+
+// recordAuditEntry records the audit entry of the current transaction. The contracts run it after every function that
+// succeeded, so only the calls of committed transactions stay on the trail. Entries are keyed by time, then transaction ID
+func (s *SmartContract) recordAuditEntry(ctx contractapi.TransactionContextInterface) error {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	callerID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client ID: %v", err)
+	}
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	function, args := ctx.GetStub().GetFunctionAndParameters()
+	argsJSON, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("failed to marshal arguments: %v", err)
+	}
+	callerIDHash := sha256.Sum256([]byte(callerID))
+	argsDigest := sha256.Sum256(argsJSON)
+
+	entry := AuditEntry{
+		TxID:         ctx.GetStub().GetTxID(),
+		Function:     function,
+		MSPID:        mspID,
+		CallerIDHash: hex.EncodeToString(callerIDHash[:]),
+		Timestamp:    timestamp,
+		ArgsDigest:   hex.EncodeToString(argsDigest[:]),
+	}
+
+	return s.putCompositeRecord(ctx, auditObjectType, []string{timestamp.Format(transactionKeyTimeLayout), entry.TxID}, entry)
+}
+
+// readAuditPage reads a page of audit entries from the bookmark on, up to toKey when it is set. It returns the bookmark of
+// the next page, and whether the range is done
+func readAuditPage(stub shim.ChaincodeStubInterface, bookmark, toKey string) ([]AuditEntry, string, bool, error) {
+	resultsIterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(auditObjectType, []string{}, auditPageSize, bookmark)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to get audit entries: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	entries := []AuditEntry{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, "", false, fmt.Errorf("error iterating over audit entries: %v", err)
+		}
+		if toKey != "" && queryResponse.Key >= toKey {
+			return entries, "", true, nil
+		}
+
+		var entry AuditEntry
+		err = json.Unmarshal(queryResponse.Value, &entry)
+		if err != nil {
+			return nil, "", false, fmt.Errorf("error unmarshalling audit entry JSON: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	done := metadata.Bookmark == "" || metadata.FetchedRecordsCount < auditPageSize
+	return entries, metadata.Bookmark, done, nil
+}
@@ -0,0 +1,76 @@
+package chaincode_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditTrail(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+	audit := chaincode.NewContracts("")[0].GetAfterTransaction().(func(contractapi.TransactionContextInterface) error)
+
+	for _, at := range []time.Time{testTime.Add(-24 * time.Hour), testTime} {
+		ctx := w.beginAt(org2, at)
+		ctx.GetStub().(*mocks.ChaincodeStub).GetFunctionAndParametersReturns("TraderContract:CreateTrade", []string{"trade1", org2})
+		require.NoError(t, audit(ctx))
+		w.commit()
+	}
+
+	trail, err := contract.GetAuditTrail(w.begin(org1), "", "")
+	require.NoError(t, err)
+	require.Len(t, trail, 2)
+	require.Equal(t, "TraderContract:CreateTrade", trail[1].Function)
+	require.Equal(t, org2, trail[1].MSPID)
+	require.Equal(t, testTime, trail[1].Timestamp)
+	callerIDHash := sha256.Sum256([]byte("x509::CN=user1::CN=ca.org2msp"))
+	require.Equal(t, hex.EncodeToString(callerIDHash[:]), trail[1].CallerIDHash)
+	argsDigest := sha256.Sum256([]byte(`["trade1","Org2MSP"]`))
+	require.Equal(t, hex.EncodeToString(argsDigest[:]), trail[1].ArgsDigest)
+
+	trail, err = contract.GetAuditTrail(w.begin(org1), "2023-01-09", "2023-01-09")
+	require.NoError(t, err)
+	require.Len(t, trail, 1)
+
+	_, err = contract.GetAuditTrail(w.begin(org2), "", "")
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "only the admin organizations and the regulator can audit"))
+}
+
+func TestAuditTrailReadsOnlyTheRange(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+	audit := chaincode.NewContracts("")[0].GetAfterTransaction().(func(contractapi.TransactionContextInterface) error)
+
+	// More entries on the day than fit a page, between entries the day before and after
+	times := []time.Time{testTime.Add(-24 * time.Hour), testTime.Add(24 * time.Hour)}
+	for i := 0; i < 150; i++ {
+		times = append(times, testTime.Add(time.Duration(i)*time.Second))
+	}
+	for _, at := range times {
+		ctx := w.beginAt(org2, at)
+		ctx.GetStub().(*mocks.ChaincodeStub).GetFunctionAndParametersReturns("TraderContract:CreateTrade", []string{"trade1"})
+		require.NoError(t, audit(ctx))
+		w.commit()
+	}
+
+	ctx := w.begin(org1)
+	trail, err := contract.GetAuditTrail(ctx, "2023-01-09", "2023-01-09")
+	require.NoError(t, err)
+	require.Len(t, trail, 150)
+	require.Equal(t, testTime, trail[0].Timestamp)
+	require.Equal(t, testTime.Add(149*time.Second), trail[149].Timestamp)
+
+	// The read starts at the from date instead of scanning the whole trail
+	stub := ctx.GetStub().(*mocks.ChaincodeStub)
+	require.Zero(t, stub.GetStateByPartialCompositeKeyCallCount())
+	require.Equal(t, 2, stub.GetStateByPartialCompositeKeyWithPaginationCallCount())
+	_, _, _, bookmark := stub.GetStateByPartialCompositeKeyWithPaginationArgsForCall(0)
+	require.Equal(t, "\x00audit\x0020230109T000000.000000000Z", bookmark)
+}
@@ -0,0 +1,189 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// Axe advertises an organization's interest in buying or selling, without a firm price.
+// It names either a cusip or a coupon and vintage bucket, which matches every cusip of that coupon issued in that year
+type Axe struct {
+	AxeID      string    `json:"axeID"`
+	OwnerHash  string    `json:"ownerHash"`
+	Direction  string    `json:"direction"`  //"Buy" or "Sell"
+	Cusip      string    `json:"cusip"`      // Empty for a bucket axe
+	Coupon     float64   `json:"coupon"`     // Coupon of a bucket axe, in percent
+	Vintage    int       `json:"vintage"`    // Issue year of a bucket axe. Zero matches every year
+	ApproxFace int64     `json:"approxFace"` // In cents
+	State      string    `json:"state"`      //"Open" or "Withdrawn"
+	CreatedAt  time.Time `json:"createdAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+const axeObjectType = "axe"
+
+// ⭐ Functions ⭐
+
+// PublishAxe advertises the caller's interest in a cusip, or in a coupon and vintage bucket when cusip is empty
+func (s *SmartContract) PublishAxe(ctx contractapi.TransactionContextInterface, axeID, direction, cusip string, coupon float64, vintage int, approxFace int64, createdAt, expiresAt time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	toUTC(&expiresAt)
+
+	if direction != "Buy" && direction != "Sell" {
+		return nil, fmt.Errorf("direction must be Buy or Sell: %v", direction)
+	}
+	if cusip == "" && coupon <= 0 {
+		return nil, fmt.Errorf("an axe must name a cusip or a coupon")
+	}
+	if approxFace <= 0 {
+		return nil, fmt.Errorf("face must be positive: %v", approxFace)
+	}
+	if !expiresAt.After(createdAt) {
+		return nil, fmt.Errorf("axe must expire after it is created")
+	}
+
+	var existing Axe
+	exists, err := s.getRecord(ctx, axeObjectType, axeID, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, NewError(ErrAlreadyExists, "axe %s already exists", axeID)
+	}
+
+	ownerHash, err := s.GenerateOrgHash(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate owner hash: %v", err)
+	}
+
+	axe := Axe{
+		AxeID:      axeID,
+		OwnerHash:  ownerHash,
+		Direction:  direction,
+		Cusip:      cusip,
+		ApproxFace: approxFace,
+		State:      "Open",
+		CreatedAt:  createdAt,
+		ExpiresAt:  expiresAt,
+	}
+	// A cusip axe names the security itself, so bucket fields would only be misleading
+	if cusip == "" {
+		axe.Coupon = coupon
+		axe.Vintage = vintage
+	}
+
+	err = s.putRecord(ctx, axeObjectType, axeID, axe)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.notifyWatchers(ctx, "Axe", axeID, axe.Cusip, axe.Coupon)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, axeID)
+}
+
+// WithdrawAxe takes down an open axe if the caller published it
+func (s *SmartContract) WithdrawAxe(ctx contractapi.TransactionContextInterface, axeID string) (*WriteResponse, error) {
+	var axe Axe
+	exists, err := s.getRecord(ctx, axeObjectType, axeID, &axe)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, NewError(ErrNotFound, "axe %s not found", axeID)
+	}
+	if !s.IsOwner(ctx, axe.OwnerHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of the axe")
+	}
+	if axe.State != "Open" {
+		return nil, fmt.Errorf("axe %s is %s", axeID, axe.State)
+	}
+
+	axe.State = "Withdrawn"
+	err = s.putRecord(ctx, axeObjectType, axeID, axe)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// GetAxes returns the live axes on a cusip: those naming it, and the bucket axes its pool coupon and issue year fall in.
+// Bucket axes only match cusips with registered pool data, since that is where the coupon comes from
+func (s *SmartContract) GetAxes(ctx contractapi.TransactionContextInterface, cusip string) ([]Axe, error) {
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pool Pool
+	hasPool, err := s.getRecord(ctx, poolObjectType, cusip, &pool)
+	if err != nil {
+		return nil, err
+	}
+	vintages, err := s.issueYears(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(axeObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get axes: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	axes := []Axe{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over axes: %v", err)
+		}
+
+		var axe Axe
+		err = json.Unmarshal(queryResponse.Value, &axe)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling axe JSON: %v", err)
+		}
+		if axe.State != "Open" || !now.Before(axe.ExpiresAt) {
+			continue
+		}
+
+		if axe.Cusip == cusip {
+			axes = append(axes, axe)
+		} else if axe.Cusip == "" && hasPool && axe.Coupon == pool.Coupon && (axe.Vintage == 0 || vintages[axe.Vintage]) {
+			axes = append(axes, axe)
+		}
+	}
+
+	return axes, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// issueYears returns the issue years recorded on the ledger bonds of a cusip
+func (s *SmartContract) issueYears(ctx contractapi.TransactionContextInterface, cusip string) (map[int]bool, error) {
+	bonds, err := s.getAllBonds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	years := map[int]bool{}
+	for _, bond := range bonds {
+		if bond.Cusip == cusip && bond.IssueYear != 0 {
+			years[bond.IssueYear] = true
+		}
+	}
+
+	return years, nil
+}
@@ -0,0 +1,160 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Functions ⭐
+
+// ExportBondsCSV returns the active bonds matching a CouchDB selector, e.g. {"couponType":"FIXED"}, as an RFC 4180 CSV document
+// with a header row. There is one column per field of AgencyMBSPassthrough, named after its JSON field and in the order the fields
+// are declared. CurrentFace is derived from the original face and the factor for bonds that do not carry it, and ownership shares
+// are written as JSON. On the legacy layout, only selectors comparing fields for equality can be run
+func (s *SmartContract) ExportBondsCSV(ctx contractapi.TransactionContextInterface, selectorJSON string) (string, error) {
+	selector := map[string]interface{}{}
+	if selectorJSON != "" {
+		err := json.Unmarshal([]byte(selectorJSON), &selector)
+		if err != nil {
+			return "", fmt.Errorf("failed to unmarshal selector JSON: %v", err)
+		}
+	}
+
+	bonds, err := s.findBonds(ctx, selector, false, equalityMatch(selector))
+	if err != nil {
+		return "", err
+	}
+
+	columns := bondCSVColumns()
+	header := []string{}
+	for _, column := range columns {
+		header = append(header, column.name)
+	}
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.UseCRLF = true
+	err = writer.Write(header)
+	if err != nil {
+		return "", fmt.Errorf("failed to write CSV header: %v", err)
+	}
+	for _, bond := range bonds {
+		if bond.CurrentFace == 0 {
+			bond.CurrentFace = bond.derivedCurrentFace()
+		}
+		value := reflect.ValueOf(bond)
+		record := []string{}
+		for _, column := range columns {
+			cell, err := csvCell(value.Field(column.index))
+			if err != nil {
+				return "", err
+			}
+			record = append(record, cell)
+		}
+		err = writer.Write(record)
+		if err != nil {
+			return "", fmt.Errorf("failed to write bond %s as CSV: %v", bond.UID, err)
+		}
+	}
+	writer.Flush()
+	err = writer.Error()
+	if err != nil {
+		return "", fmt.Errorf("failed to write CSV: %v", err)
+	}
+
+	return buffer.String(), nil
+}
+
+// ⭐ Helper functions ⭐
+
+// csvColumn is a field of AgencyMBSPassthrough exported as a CSV column
+type csvColumn struct {
+	name  string
+	index int
+}
+
+// bondCSVColumns returns the CSV columns of a bond: its fields, named after their JSON field, in declaration order
+func bondCSVColumns() []csvColumn {
+	bondType := reflect.TypeOf(AgencyMBSPassthrough{})
+	columns := []csvColumn{}
+	for i := 0; i < bondType.NumField(); i++ {
+		name := strings.Split(bondType.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		columns = append(columns, csvColumn{name: name, index: i})
+	}
+
+	return columns
+}
+
+// csvCell formats a field of a bond as a CSV cell. Numbers are written in full, without exponents
+func csvCell(value reflect.Value) (string, error) {
+	switch value.Kind() {
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Int, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 64), nil
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool()), nil
+	case reflect.Map:
+		if value.Len() == 0 {
+			return "", nil
+		}
+		cellJSON, err := json.Marshal(value.Interface())
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal CSV cell: %v", err)
+		}
+		return string(cellJSON), nil
+	}
+
+	return "", fmt.Errorf("cannot write a %s as a CSV cell", value.Kind())
+}
+
+// derivedCurrentFace returns the original face times the factor, or the original face of a bond without a factor
+func (bond *AgencyMBSPassthrough) derivedCurrentFace() int64 {
+	if bond.Factor == 0 {
+		return bond.OriginalFace
+	}
+
+	return int64(math.Round(float64(bond.OriginalFace) * bond.Factor))
+}
+
+// equalityMatch returns a match agreeing with a selector that only compares fields for equality, for the legacy layout.
+// It returns nil for selectors using operators, which only CouchDB can run
+func equalityMatch(selector map[string]interface{}) func(AgencyMBSPassthrough) bool {
+	for _, expected := range selector {
+		switch expected.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil
+		}
+	}
+
+	return func(bond AgencyMBSPassthrough) bool {
+		bondJSON, err := json.Marshal(bond)
+		if err != nil {
+			return false
+		}
+		fields := map[string]interface{}{}
+		err = json.Unmarshal(bondJSON, &fields)
+		if err != nil {
+			return false
+		}
+		for field, expected := range selector {
+			if !reflect.DeepEqual(fields[field], expected) {
+				return false
+			}
+		}
+		return true
+	}
+}
@@ -0,0 +1,50 @@
+package chaincode_test
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportBondsCSV(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+	_, err := contract.ImportBonds(w.begin(org1), `[
+		{"uid":"uid1","ownerHash":"Org2MSP","bond":"FR RA8888, 6%","cusip":"3132DWAR4","originalFace":100000000,"coupon":6,"couponType":"FIXED","factor":0.5},
+		{"uid":"uid2","ownerHash":"Org2MSP","cusip":"3133KR5L4","originalFace":50000000,"coupon":5.5,"couponType":"FIXED","factor":1}
+	]`)
+	require.NoError(t, err)
+	w.commit()
+
+	document, err := contract.ExportBondsCSV(w.begin(org1), `{"cusip":"3132DWAR4"}`)
+	require.NoError(t, err)
+	require.True(t, strings.HasSuffix(document, "\r\n"))
+
+	records, err := csv.NewReader(strings.NewReader(document)).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	header, row := records[0], records[1]
+	require.Equal(t, []string{"uid", "bond", "cusip", "originalFace", "ownerHash"}, header[:5])
+	require.Equal(t, len(header), len(row))
+
+	cell := func(name string) string {
+		for i, column := range header {
+			if column == name {
+				return row[i]
+			}
+		}
+		t.Fatalf("no %s column", name)
+		return ""
+	}
+	require.Equal(t, "FR RA8888, 6%", cell("bond"))
+	require.Equal(t, "50000000", cell("currentFace"))
+	require.Equal(t, "0.5", cell("factor"))
+	require.Equal(t, "", cell("ownershipShares"))
+
+	// Operators need CouchDB, which the legacy layout does not query
+	_, err = contract.ExportBondsCSV(w.begin(org1), `{"coupon":{"$gt":5}}`)
+	require.EqualError(t, err, "rich queries need the perkey layout. Run MigrateLedgerLayout first")
+}
@@ -0,0 +1,139 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// BondImportReport is what ImportBonds did with each record of the batch, in the order of the records
+type BondImportReport struct {
+	Created    int                `json:"created"`
+	Duplicates int                `json:"duplicates"`
+	Invalid    int                `json:"invalid"`
+	Results    []BondImportResult `json:"results"`
+}
+
+// BondImportResult is the outcome of one record of an import
+type BondImportResult struct {
+	Index      int              `json:"index"` // Position of the record in the batch
+	UID        string           `json:"uid"`
+	Cusip      string           `json:"cusip"`
+	Status     string           `json:"status"`               //"Created", "SkippedDuplicate" or "ValidationError"
+	Violations []FieldViolation `json:"violations,omitempty"` // Set for a ValidationError
+}
+
+// ⭐ Functions ⭐
+
+// ImportBonds adds a JSON array of bonds to the public ledger in one transaction. Each record needs its UID and owner hash.
+// A record whose UID is already on the ledger, or earlier in the batch, is skipped, and an invalid record is reported
+// with its violations, without failing the rest of the batch. Imported bonds are drafts that cannot trade until ListBond
+// lists them. Only the admin organization can import bonds
+func (s *SmartContract) ImportBonds(ctx contractapi.TransactionContextInterface, bondsJSON string) (*WriteResponse, error) {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []json.RawMessage
+	err = json.Unmarshal([]byte(bondsJSON), &records)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bonds JSON: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("at least one bond must be imported")
+	}
+
+	config, err := s.GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The bonds can span any number of cusips, so they are all added to one ledger
+	ledger, err := s.GetLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := BondImportReport{Results: []BondImportResult{}}
+	for i, record := range records {
+		result := BondImportResult{Index: i}
+
+		var bond AgencyMBSPassthrough
+		err = json.Unmarshal(record, &bond)
+		if err != nil {
+			result.Status = "ValidationError"
+			result.Violations = []FieldViolation{{Code: InvalidRecordCode, Message: fmt.Sprintf("the record is not a bond: %v", err)}}
+			report.Invalid++
+			report.Results = append(report.Results, result)
+			continue
+		}
+		result.UID = bond.UID
+		result.Cusip = bond.Cusip
+
+		if bond.UID != "" && findBondByUID(ledger, bond.UID) != -1 {
+			result.Status = "SkippedDuplicate"
+			report.Duplicates++
+			report.Results = append(report.Results, result)
+			continue
+		}
+
+		violations := importViolations(&bond, config.AllowedCouponTypes)
+		if len(violations) > 0 {
+			result.Status = "ValidationError"
+			result.Violations = violations
+			report.Invalid++
+			report.Results = append(report.Results, result)
+			continue
+		}
+
+		// An imported bond starts free and in draft until an admin lists it, with its current face at the factor it was imported with
+		bond.ReservedFor = ""
+		bond.Status = BondDraft
+		if bond.CurrentFace == 0 {
+			bond.CurrentFace = int64(math.Round(float64(bond.OriginalFace) * bond.Factor))
+		}
+		err = s.registerBondUID(ctx, &bond)
+		if err != nil {
+			return nil, err
+		}
+		ledger.Bonds = append(ledger.Bonds, bond)
+
+		result.Status = "Created"
+		report.Created++
+		report.Results = append(report.Results, result)
+	}
+
+	if report.Created > 0 {
+		err = s.updateLedger(ctx, ledger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update ledger: %v", err)
+		}
+	}
+
+	return newWriteResponse(ctx, report)
+}
+
+// ⭐ Helper functions ⭐
+
+// importViolations returns the rules an imported bond breaks: those of validateBond, and the ledger fields it must come with
+func importViolations(bond *AgencyMBSPassthrough, couponTypes []string) []FieldViolation {
+	violations := []FieldViolation{}
+	if bond.UID == "" {
+		violations = append(violations, FieldViolation{Field: "uid", Code: InvalidUIDCode, Message: "uid cannot be empty"})
+	}
+	if bond.OwnerHash == "" {
+		violations = append(violations, FieldViolation{Field: "ownerHash", Code: InvalidOwnerCode, Message: "owner hash cannot be empty"})
+	}
+
+	err := validateBond(bond, couponTypes)
+	if validationError, ok := err.(*ValidationError); ok {
+		violations = append(violations, validationError.Violations...)
+	}
+
+	return violations
+}
@@ -0,0 +1,113 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// BondQueryPage is one page of the bonds matching a query
+type BondQueryPage struct {
+	Bonds    []AgencyMBSPassthrough `json:"bonds"`
+	Bookmark string                 `json:"bookmark"` // Pass it back to get the next page. Empty on the last page
+}
+
+// Largest page QueryBondsWithPagination returns
+const maxBondQueryPageSize = 100
+
+// ⭐ Functions ⭐
+
+// QueryBonds returns the bonds matching a CouchDB query, e.g. {"selector":{"couponType":"FIXED"}}.
+// Rich queries need CouchDB as state database and the ledger in the per-key layout. Retired bonds are left out unless includeRetired is set
+func (s *SmartContract) QueryBonds(ctx contractapi.TransactionContextInterface, queryString string, includeRetired bool) ([]AgencyMBSPassthrough, error) {
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bonds, _, err := stores.Bonds.QueryBonds(queryString, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return filterRetired(bonds, includeRetired), nil
+}
+
+// QueryBondsWithPagination returns up to pageSize bonds matching a CouchDB query, starting at the bookmark of the previous page.
+// Records other than bonds, and retired bonds unless includeRetired is set, count towards the page size, so a page can hold
+// fewer bonds and still not be the last one
+func (s *SmartContract) QueryBondsWithPagination(ctx contractapi.TransactionContextInterface, queryString string, pageSize int, bookmark string, includeRetired bool) (*BondQueryPage, error) {
+	if pageSize <= 0 || pageSize > maxBondQueryPageSize {
+		return nil, fmt.Errorf("page size must be between 1 and %d: %d", maxBondQueryPageSize, pageSize)
+	}
+
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bonds, nextBookmark, err := stores.Bonds.QueryBonds(queryString, nil, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BondQueryPage{Bonds: filterRetired(bonds, includeRetired), Bookmark: nextBookmark}, nil
+}
+
+// GetBondsByCoupon returns the bonds with a coupon between min and max, both included.
+// Retired bonds are left out unless includeRetired is set
+func (s *SmartContract) GetBondsByCoupon(ctx contractapi.TransactionContextInterface, min, max float64, includeRetired bool) ([]AgencyMBSPassthrough, error) {
+	if min > max {
+		return nil, fmt.Errorf("the minimum coupon %v is above the maximum %v", min, max)
+	}
+
+	return s.findBonds(ctx, map[string]interface{}{"coupon": map[string]interface{}{"$gte": min, "$lte": max}}, includeRetired, func(bond AgencyMBSPassthrough) bool {
+		return bond.Coupon >= min && bond.Coupon <= max
+	})
+}
+
+// GetBondsByServicer returns the bonds of a servicer. The name must match exactly.
+// Retired bonds are left out unless includeRetired is set
+func (s *SmartContract) GetBondsByServicer(ctx contractapi.TransactionContextInterface, servicer string, includeRetired bool) ([]AgencyMBSPassthrough, error) {
+	if strings.TrimSpace(servicer) == "" {
+		return nil, fmt.Errorf("servicer cannot be empty")
+	}
+
+	return s.findBonds(ctx, map[string]interface{}{"servicer": servicer}, includeRetired, func(bond AgencyMBSPassthrough) bool {
+		return bond.Servicer == servicer
+	})
+}
+
+// GetBondsByIssueYear returns the bonds issued in a year. Retired bonds are left out unless includeRetired is set
+func (s *SmartContract) GetBondsByIssueYear(ctx contractapi.TransactionContextInterface, issueYear int, includeRetired bool) ([]AgencyMBSPassthrough, error) {
+	return s.findBonds(ctx, map[string]interface{}{"issueYear": issueYear}, includeRetired, func(bond AgencyMBSPassthrough) bool {
+		return bond.IssueYear == issueYear
+	})
+}
+
+// ⭐ Helper functions ⭐
+
+// findBonds returns the bonds matching a CouchDB selector. On the legacy layout, where the bonds are one blob,
+// they are read whole and picked with match, which must agree with the selector. Retired bonds are left out unless includeRetired is set
+func (s *SmartContract) findBonds(ctx contractapi.TransactionContextInterface, selector map[string]interface{}, includeRetired bool, match func(AgencyMBSPassthrough) bool) ([]AgencyMBSPassthrough, error) {
+	queryJSON, err := json.Marshal(map[string]interface{}{"selector": selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal bond query: %v", err)
+	}
+
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bonds, _, err := stores.Bonds.QueryBonds(string(queryJSON), match, 0, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return filterRetired(bonds, includeRetired), nil
+}
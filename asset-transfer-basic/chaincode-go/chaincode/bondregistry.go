@@ -0,0 +1,145 @@
+package chaincode
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// BondUIDEntry reserves a UID for the bond created with it, so that the UID cannot be given to another bond
+type BondUIDEntry struct {
+	UID          string    `json:"uid"`
+	Cusip        string    `json:"cusip"`
+	RegisteredAt time.Time `json:"registeredAt"`
+}
+
+// DuplicateBonds is a set of bonds of the ledger that look like the same bond created more than once
+type DuplicateBonds struct {
+	Reason    string   `json:"reason"` // "UID" for bonds sharing a UID, "Lot" for bonds of one owner with the same cusip, bond ID and face
+	Cusip     string   `json:"cusip"`
+	OwnerHash string   `json:"ownerHash,omitempty"` // Set for a Lot
+	UIDs      []string `json:"uids"`                // In ledger order
+}
+
+const bondUIDObjectType = "bonduid"
+
+// ⭐ Functions ⭐
+
+// FindDuplicateBonds reports the bonds of the ledger created more than once, for cleanup: bonds sharing a UID, and bonds
+// of one owner with the same cusip, bond ID and original face. Duplicates are in cusip order
+func (s *SmartContract) FindDuplicateBonds(ctx contractapi.TransactionContextInterface) ([]DuplicateBonds, error) {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byUID := map[string]*DuplicateBonds{}
+	byLot := map[string]*DuplicateBonds{}
+	groups := []*DuplicateBonds{}
+	err = stores.Bonds.ForEachBond(func(bond AgencyMBSPassthrough) {
+		uidGroup, ok := byUID[bond.UID]
+		if !ok {
+			uidGroup = &DuplicateBonds{Reason: "UID", Cusip: bond.Cusip}
+			byUID[bond.UID] = uidGroup
+			groups = append(groups, uidGroup)
+		}
+		uidGroup.UIDs = append(uidGroup.UIDs, bond.UID)
+
+		lot := bondLot(&bond)
+		lotGroup, ok := byLot[lot]
+		if !ok {
+			lotGroup = &DuplicateBonds{Reason: "Lot", Cusip: bond.Cusip, OwnerHash: bond.OwnerHash}
+			byLot[lot] = lotGroup
+			groups = append(groups, lotGroup)
+		}
+		// Bonds sharing a UID are reported once, as such
+		if !containsString(lotGroup.UIDs, bond.UID) {
+			lotGroup.UIDs = append(lotGroup.UIDs, bond.UID)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	duplicates := []DuplicateBonds{}
+	for _, group := range groups {
+		if len(group.UIDs) > 1 {
+			duplicates = append(duplicates, *group)
+		}
+	}
+	sort.SliceStable(duplicates, func(i, j int) bool {
+		return duplicates[i].Cusip < duplicates[j].Cusip
+	})
+
+	return duplicates, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// requireNewBond checks that a bond about to be created is not on the ledger already: its UID must be free, and its owner
+// must not hold a bond of the cusip with the same bond ID and original face, which is what replaying a creation leaves behind.
+// The bonds of the cusip are passed in, since the caller has them
+func (s *SmartContract) requireNewBond(ctx contractapi.TransactionContextInterface, bond *AgencyMBSPassthrough, cusipBonds []AgencyMBSPassthrough) error {
+	err := s.requireFreeBondUID(ctx, bond.UID)
+	if err != nil {
+		return err
+	}
+
+	lot := bondLot(bond)
+	for i := range cusipBonds {
+		if bondLot(&cusipBonds[i]) == lot {
+			return NewError(ErrAlreadyExists, "the owner already holds bond %s of Cusip %s for %d, with UID %s", bond.Bond, bond.Cusip, bond.OriginalFace, cusipBonds[i].UID)
+		}
+	}
+
+	return nil
+}
+
+// requireFreeBondUID checks that no bond was ever created with the UID
+func (s *SmartContract) requireFreeBondUID(ctx contractapi.TransactionContextInterface, uid string) error {
+	var entry BondUIDEntry
+	exists, err := s.getRecord(ctx, bondUIDObjectType, uid, &entry)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		// Bonds created before the registry are only on the ledger
+		stores, err := s.stores(ctx)
+		if err != nil {
+			return err
+		}
+		existing, err := stores.Bonds.GetBond(uid)
+		if err != nil {
+			return err
+		}
+		exists = existing != nil
+	}
+	if exists {
+		return NewError(ErrAlreadyExists, "bond with UID %s already exists", uid)
+	}
+
+	return nil
+}
+
+// registerBondUID reserves the UID of a bond being created
+func (s *SmartContract) registerBondUID(ctx contractapi.TransactionContextInterface, bond *AgencyMBSPassthrough) error {
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.putRecord(ctx, bondUIDObjectType, bond.UID, BondUIDEntry{UID: bond.UID, Cusip: bond.Cusip, RegisteredAt: now})
+}
+
+// bondLot identifies the bonds that are the same holding created twice
+func bondLot(bond *AgencyMBSPassthrough) string {
+	return fmt.Sprintf("%s|%s|%s|%d", bond.Cusip, bond.OwnerHash, bond.Bond, bond.OriginalFace)
+}
@@ -0,0 +1,51 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateBondPublicRefusesDuplicates(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUp(t, contract)
+			createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+
+			// Replayed, under the same UID or another one
+			_, err := contract.CreateBondPublic(w.begin(org1), "uid1", org1, "FR uid9", otherCusip, "passthrough", tradeFace)
+			require.EqualError(t, err, contractError(chaincode.ErrAlreadyExists, "bond with UID uid1 already exists"))
+			_, err = contract.CreateBondPublic(w.begin(org1), "uid2", org2, "FR uid1", testCusip, "passthrough", tradeFace)
+			require.EqualError(t, err, contractError(chaincode.ErrAlreadyExists, "the owner already holds bond FR uid1 of Cusip "+testCusip+" for 100000000, with UID uid1"))
+
+			// Another lot of the same owner is a new bond
+			createBond(t, w, contract, "uid2", org2, testCusip, tradeFace)
+		})
+	}
+}
+
+func TestFindDuplicateBonds(t *testing.T) {
+	contract := &chaincode.SmartContract{StorageLayout: chaincode.LegacyBlobLayout}
+	w := setUp(t, contract)
+
+	// Bonds replayed before the guard existed
+	w.state["ledger"] = []byte(`{"bonds":[` +
+		`{"uid":"uid1","bond":"FR A","cusip":"3133KR5L4","originalFace":100,"ownerHash":"Org2MSP"},` +
+		`{"uid":"uid1","bond":"FR A","cusip":"3133KR5L4","originalFace":100,"ownerHash":"Org2MSP"},` +
+		`{"uid":"uid2","bond":"FR B","cusip":"3132DWAR4","originalFace":100,"ownerHash":"Org2MSP"},` +
+		`{"uid":"uid3","bond":"FR B","cusip":"3132DWAR4","originalFace":100,"ownerHash":"Org2MSP"},` +
+		`{"uid":"uid4","bond":"FR B","cusip":"3132DWAR4","originalFace":100,"ownerHash":"Org1MSP"}` +
+		`],"directTrades":[],"transactions":[]}`)
+
+	duplicates, err := contract.FindDuplicateBonds(w.begin(org1))
+	require.NoError(t, err)
+	require.Equal(t, []chaincode.DuplicateBonds{
+		{Reason: "Lot", Cusip: testCusip, OwnerHash: org2, UIDs: []string{"uid2", "uid3"}},
+		{Reason: "UID", Cusip: otherCusip, UIDs: []string{"uid1", "uid1"}},
+	}, duplicates)
+
+	_, err = contract.FindDuplicateBonds(w.begin(org2))
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "only Org1MSP can run this function"))
+}
@@ -0,0 +1,179 @@
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Functions ⭐
+
+// RetireBond takes the caller's bond with the given UID out of circulation. The bond stays on the ledger with its history,
+// but queries leave it out by default and it cannot trade. A bond held for a pending trade, offer, pledge, loan or repo
+// must be freed first
+func (s *SmartContract) RetireBond(ctx contractapi.TransactionContextInterface, uid string) (*WriteResponse, error) {
+	ledger, err := s.getBondLedger(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	bondIndex := findBondByUID(ledger, uid)
+	if bondIndex == -1 {
+		return nil, NewError(ErrNotFound, "bond with UID %s not found", uid)
+	}
+	bond := &ledger.Bonds[bondIndex]
+	if !s.ownsBond(ctx, bond) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of the bond")
+	}
+	if bond.ReservedFor != "" {
+		return nil, NewConflictError(bond.UID, bond.Cusip, bond.ReservedFor)
+	}
+
+	err = bondLifecycle.move(uid, &bond.Status, BondRetired)
+	if err != nil {
+		return nil, err
+	}
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// RestoreBond puts a retired bond back in circulation. Only the admin organization can restore bonds
+func (s *SmartContract) RestoreBond(ctx contractapi.TransactionContextInterface, uid string) (*WriteResponse, error) {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger, err := s.getBondLedger(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	bondIndex := findBondByUID(ledger, uid)
+	if bondIndex == -1 {
+		return nil, NewError(ErrNotFound, "bond with UID %s not found", uid)
+	}
+	bond := &ledger.Bonds[bondIndex]
+	err = bondLifecycle.move(uid, &bond.Status, BondListed)
+	if err != nil {
+		return nil, err
+	}
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// ListBond lists a draft bond, so that it can trade. Only the admin organization can list bonds
+func (s *SmartContract) ListBond(ctx contractapi.TransactionContextInterface, uid string) (*WriteResponse, error) {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger, err := s.getBondLedger(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	bondIndex := findBondByUID(ledger, uid)
+	if bondIndex == -1 {
+		return nil, NewError(ErrNotFound, "bond with UID %s not found", uid)
+	}
+	bond := &ledger.Bonds[bondIndex]
+	if bond.Status != BondDraft {
+		return nil, NewTransitionError("bond", uid, bond.Status, BondListed, "only a Draft bond can be listed")
+	}
+	err = bondLifecycle.move(uid, &bond.Status, BondListed)
+	if err != nil {
+		return nil, err
+	}
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// ⭐ Helper functions ⭐
+
+// tradable reports whether a bond can trade: it is neither a draft nor retired nor matured. Bonds without a status,
+// from before statuses, are listed
+func (bond *AgencyMBSPassthrough) tradable() bool {
+	switch bond.Status {
+	case BondDraft, BondRetired, BondMatured:
+		return false
+	}
+	return true
+}
+
+// reserve holds the bond for a trade, offer, pledge, loan, repo or auction
+func (bond *AgencyMBSPassthrough) reserve(holdID string) error {
+	err := bondLifecycle.move(bond.UID, &bond.Status, BondReserved)
+	if err != nil {
+		return err
+	}
+	bond.ReservedFor = holdID
+
+	return nil
+}
+
+// pledge puts the bond reserved for a repo under the lien of the cash lender lienHolder
+func (bond *AgencyMBSPassthrough) pledge(lienHolder string) error {
+	err := bondLifecycle.move(bond.UID, &bond.Status, BondPledged)
+	if err != nil {
+		return err
+	}
+	bond.LienHolder = lienHolder
+
+	return nil
+}
+
+// release frees the bond from what it was held for and lifts its lien. A reserved, traded or pledged bond is listed again,
+// which the lifecycle always allows. Bonds held before statuses and bonds that matured while held keep their status
+func (bond *AgencyMBSPassthrough) release() {
+	bond.ReservedFor = ""
+	bond.LienHolder = ""
+	if bond.Status == BondReserved || bond.Status == BondTraded || bond.Status == BondPledged {
+		_ = bondLifecycle.move(bond.UID, &bond.Status, BondListed)
+	}
+}
+
+// deliver hands the bond to the owner commitment ownerHash, settles it and frees it from what it was held for.
+// A bond that matured while held for a pledge, loan or repo goes back to its owner as it is
+func (bond *AgencyMBSPassthrough) deliver(ownerHash string) error {
+	if bond.Status != BondMatured {
+		err := bondLifecycle.move(bond.UID, &bond.Status, BondSettled)
+		if err != nil {
+			return err
+		}
+	}
+	bond.OwnerHash = ownerHash
+	bond.ReservedFor = ""
+	bond.LienHolder = ""
+
+	return nil
+}
+
+// filterRetired returns bonds without the retired ones, or bonds as they are when includeRetired is set
+func filterRetired(bonds []AgencyMBSPassthrough, includeRetired bool) []AgencyMBSPassthrough {
+	if includeRetired {
+		return bonds
+	}
+
+	filtered := []AgencyMBSPassthrough{}
+	for _, bond := range bonds {
+		if bond.Status != BondRetired {
+			filtered = append(filtered, bond)
+		}
+	}
+
+	return filtered
+}
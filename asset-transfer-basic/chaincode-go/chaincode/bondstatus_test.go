@@ -0,0 +1,94 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetireAndRestoreBond(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUp(t, contract)
+			createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+			createBond(t, w, contract, "uid2", org2, otherCusip, tradeFace)
+
+			_, err := contract.RetireBond(w.begin(org2), "uid1")
+			require.NoError(t, err)
+			w.commit()
+
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Len(t, bonds, 1)
+			require.Equal(t, "uid2", bonds[0].UID)
+			bonds, err = contract.GetAllBonds(w.begin(org1), true)
+			require.NoError(t, err)
+			require.Len(t, bonds, 2)
+
+			// A retired bond cannot trade
+			_, err = contract.TransferBond(w.begin(org2), "uid1", org1)
+			require.EqualError(t, err, "bond uid1 is Retired")
+
+			_, err = contract.RestoreBond(w.begin(org1), "uid1")
+			require.NoError(t, err)
+			w.commit()
+
+			bonds, err = contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Len(t, bonds, 2)
+			require.Equal(t, chaincode.BondListed, bonds[0].Status)
+			_, err = contract.TransferBond(w.begin(org2), "uid1", org1)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRetireBondErrors(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpTrade(t, contract, 200000000)
+
+	_, err := contract.RetireBond(w.begin(org1), "uid1")
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "you are not the owner of the bond"))
+	_, err = contract.RestoreBond(w.begin(org1), "uid1")
+	require.EqualError(t, err, chaincode.NewTransitionError("bond", "uid1", chaincode.BondListed, chaincode.BondListed, "a bond that is Listed can only become Reserved or Settled or Retired or Matured").Error())
+
+	// The bond is held for the trade Org2 answered
+	_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.RetireBond(w.begin(org2), "uid1")
+	var conflict *chaincode.ConflictError
+	require.ErrorAs(t, err, &conflict)
+
+	_, err = contract.RestoreBond(w.begin(org2), "uid1")
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "only Org1MSP can run this function"))
+}
+
+func TestListImportedBond(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+	_, err := contract.ImportBonds(w.begin(org1), `[{"uid":"uid1","ownerHash":"Org2MSP","cusip":"3132DWAR4","originalFace":100000000,"coupon":5.5,"couponType":"FIXED","factor":1}]`)
+	require.NoError(t, err)
+	w.commit()
+
+	// A draft cannot trade until an admin lists it
+	_, err = contract.TransferBond(w.begin(org2), "uid1", org1)
+	require.EqualError(t, err, "bond uid1 is Draft")
+	_, err = contract.ListBond(w.begin(org2), "uid1")
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "only Org1MSP can run this function"))
+
+	_, err = contract.ListBond(w.begin(org1), "uid1")
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.ListBond(w.begin(org1), "uid1")
+	require.EqualError(t, err, chaincode.NewTransitionError("bond", "uid1", chaincode.BondListed, chaincode.BondListed, "only a Draft bond can be listed").Error())
+
+	_, err = contract.TransferBond(w.begin(org2), "uid1", org1)
+	require.NoError(t, err)
+	w.commit()
+	bonds, err := contract.GetAllBonds(w.begin(org1), false)
+	require.NoError(t, err)
+	require.Equal(t, chaincode.BondSettled, bonds[0].Status)
+}
@@ -0,0 +1,524 @@
+package chaincode
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// CashAccount is the cash balance of an organization, a fungible cash token. The cash agent mints it with DepositCash and burns it
+// with WithdrawCash, and it moves between accounts with every settlement
+type CashAccount struct {
+	OwnerHash string           `json:"ownerHash"`
+	Currency  string           `json:"currency"`           // Set by the first deposit. Trades are priced in the settlement currency and converted at settlement
+	Balance   int64            `json:"balance"`            // In cents of Currency
+	Balances  map[string]int64 `json:"balances,omitempty"` // Balances in cents of other currencies, which trades priced in that currency settle against
+}
+
+// FXRate is the admin-maintained conversion rate from the settlement currency, USD unless the contract settings change it, to another currency
+type FXRate struct {
+	Currency    string    `json:"currency"`
+	UnitsPerUSD float64   `json:"unitsPerUSD"` // Units per unit of the settlement currency
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// CashAgentConfig names the organization acting as cash agent, the only one allowed to deposit and withdraw cash
+type CashAgentConfig struct {
+	MSPID     string    `json:"mspID"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+const (
+	cashAccountObjectType = "cashaccount"
+	fxRateObjectType      = "fxrate"
+	cashAgentConfigID     = "cashagent"
+
+	// Organization acting as cash agent until the admin designates another one
+	defaultCashAgentMSP = "Org1MSP"
+)
+
+// ⭐ Functions ⭐
+
+// DepositCash mints cash: it credits an organization's cash account with an amount in cents of a currency. The first deposit
+// sets the currency of the account, and later deposits in other currencies are kept as separate balances. Only the cash agent can deposit
+func (s *SmartContract) DepositCash(ctx contractapi.TransactionContextInterface, ownerHash, currency string, amount int64) (*WriteResponse, error) {
+	err := s.requireCashAgent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive: %d", amount)
+	}
+
+	account, err := s.getCashAccount(ctx, ownerHash)
+	if err != nil {
+		return nil, err
+	}
+	if account.Currency == "" {
+		account.Currency = currency
+	}
+	settlementCurrency, err := s.settlementCurrency(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if currency != settlementCurrency {
+		_, err = s.GetFXRate(ctx, currency)
+		if err != nil {
+			return nil, err
+		}
+	}
+	account.adjust(currency, amount)
+
+	err = s.putRecord(ctx, cashAccountObjectType, ownerHash, account)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, account)
+}
+
+// WithdrawCash burns cash: it debits an organization's cash account with an amount in cents of a currency. Only the cash agent
+// can withdraw, and never more than the balance
+func (s *SmartContract) WithdrawCash(ctx contractapi.TransactionContextInterface, ownerHash, currency string, amount int64) (*WriteResponse, error) {
+	err := s.requireCashAgent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive: %d", amount)
+	}
+
+	account, err := s.getCashAccount(ctx, ownerHash)
+	if err != nil {
+		return nil, err
+	}
+	if account.balance(currency) < amount {
+		return nil, fmt.Errorf("insufficient cash: balance %.2f %s, needed %.2f", dollars(account.balance(currency)), currency, dollars(amount))
+	}
+	account.adjust(currency, -amount)
+
+	err = s.putRecord(ctx, cashAccountObjectType, ownerHash, account)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, account)
+}
+
+// GetCashBalance returns the cash account of an organization. Only the organization itself and the cash agent can read it
+func (s *SmartContract) GetCashBalance(ctx contractapi.TransactionContextInterface, ownerHash string) (*CashAccount, error) {
+	if !s.IsOwner(ctx, ownerHash) && s.requireCashAgent(ctx) != nil {
+		return nil, NewError(ErrUnauthorized, "you are not allowed to read this cash account")
+	}
+
+	return s.getCashAccount(ctx, ownerHash)
+}
+
+// ConvertCash converts an amount in cents of the caller's cash from one currency to another at the FX rates of both against
+// the settlement currency, rounded to the cent. Each currency must be the settlement currency or have an FX rate
+func (s *SmartContract) ConvertCash(ctx contractapi.TransactionContextInterface, fromCurrency, toCurrency string, amount int64) (*WriteResponse, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive: %d", amount)
+	}
+	if fromCurrency == "" || toCurrency == "" || fromCurrency == toCurrency {
+		return nil, NewError(ErrInvalidInput, "cash can only be converted between two different currencies: %q and %q", fromCurrency, toCurrency)
+	}
+	ownerHash, err := s.getEncryptionKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encryption key: %v", err)
+	}
+
+	account, err := s.getCashAccount(ctx, ownerHash)
+	if err != nil {
+		return nil, err
+	}
+	if account.balance(fromCurrency) < amount {
+		return nil, fmt.Errorf("insufficient cash: balance %.2f %s, needed %.2f", dollars(account.balance(fromCurrency)), fromCurrency, dollars(amount))
+	}
+	fromRate, err := s.fxRateFromUSD(ctx, fromCurrency)
+	if err != nil {
+		return nil, err
+	}
+	toRate, err := s.fxRateFromUSD(ctx, toCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	account.adjust(fromCurrency, -amount)
+	account.adjust(toCurrency, convertCents(amount, toRate/fromRate))
+
+	err = s.putRecord(ctx, cashAccountObjectType, ownerHash, account)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, account)
+}
+
+// SetFXRate stores the number of units of a currency per unit of the settlement currency. Only the admin organization can maintain FX rates
+func (s *SmartContract) SetFXRate(ctx contractapi.TransactionContextInterface, currency string, unitsPerUSD float64, updatedAt time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	settlementCurrency, err := s.settlementCurrency(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if currency == settlementCurrency {
+		return nil, fmt.Errorf("%s is the settlement currency", settlementCurrency)
+	}
+	if unitsPerUSD <= 0 {
+		return nil, fmt.Errorf("FX rate must be positive: %v", unitsPerUSD)
+	}
+
+	rate := FXRate{
+		Currency:    currency,
+		UnitsPerUSD: unitsPerUSD,
+		UpdatedAt:   updatedAt,
+	}
+	err = s.putRecord(ctx, fxRateObjectType, currency, rate)
+	if err != nil {
+		return nil, err
+	}
+	return newWriteResponse(ctx, nil)
+}
+
+// GetFXRate returns the FX rate of a currency
+func (s *SmartContract) GetFXRate(ctx contractapi.TransactionContextInterface, currency string) (*FXRate, error) {
+	var rate FXRate
+	exists, err := s.getRecord(ctx, fxRateObjectType, currency, &rate)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("no FX rate for %s", currency)
+	}
+
+	return &rate, nil
+}
+
+// SetCashAgent designates the organization allowed to deposit and withdraw cash. Only the admin organization can change it.
+// Balances stay where they are, only who can mint and burn cash changes
+func (s *SmartContract) SetCashAgent(ctx contractapi.TransactionContextInterface, mspID string) (*WriteResponse, error) {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if mspID == "" {
+		return nil, fmt.Errorf("the cash agent MSP ID cannot be empty")
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config := CashAgentConfig{
+		MSPID:     mspID,
+		UpdatedAt: timestamp,
+	}
+	err = s.putRecord(ctx, configObjectType, cashAgentConfigID, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// GetCashAgent returns the organization acting as cash agent. A channel where it was never set uses the default one
+func (s *SmartContract) GetCashAgent(ctx contractapi.TransactionContextInterface) (*CashAgentConfig, error) {
+	config := CashAgentConfig{MSPID: defaultCashAgentMSP}
+	_, err := s.getRecord(ctx, configObjectType, cashAgentConfigID, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// getCashAccount returns the cash account of an organization, empty if it was never funded
+func (s *SmartContract) getCashAccount(ctx contractapi.TransactionContextInterface, ownerHash string) (*CashAccount, error) {
+	account := CashAccount{OwnerHash: ownerHash}
+	_, err := s.getRecord(ctx, cashAccountObjectType, ownerHash, &account)
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+// cashBook holds the cash accounts a transaction moves cash between. Each account is read once and written once by saveCash,
+// so that a transaction can move cash to and from an account several times
+type cashBook struct {
+	accounts map[string]*CashAccount
+	changed  []string // Owner hashes of the changed accounts, in the order they first changed
+}
+
+// cashBook returns the cash accounts the transaction moves cash between, which updateLedger stores along with the ledger
+func (ledger *Ledger) cashBook() *cashBook {
+	if ledger.cash == nil {
+		ledger.cash = &cashBook{}
+	}
+	return ledger.cash
+}
+
+// account returns the cash account of an organization, read on first use
+func (book *cashBook) account(s *SmartContract, ctx contractapi.TransactionContextInterface, ownerHash string) (*CashAccount, error) {
+	if account, ok := book.accounts[ownerHash]; ok {
+		return account, nil
+	}
+
+	account, err := s.getCashAccount(ctx, ownerHash)
+	if err != nil {
+		return nil, err
+	}
+	if book.accounts == nil {
+		book.accounts = map[string]*CashAccount{}
+	}
+	book.accounts[ownerHash] = account
+	return account, nil
+}
+
+// markChanged records that an account of the book changed and must be stored
+func (book *cashBook) markChanged(ownerHash string) {
+	for _, changed := range book.changed {
+		if changed == ownerHash {
+			return
+		}
+	}
+	book.changed = append(book.changed, ownerHash)
+}
+
+// saveCash stores the accounts of the book that changed
+func (s *SmartContract) saveCash(ctx contractapi.TransactionContextInterface, book *cashBook) error {
+	for _, ownerHash := range book.changed {
+		err := s.putRecord(ctx, cashAccountObjectType, ownerHash, book.accounts[ownerHash])
+		if err != nil {
+			return err
+		}
+	}
+	book.changed = nil
+
+	return nil
+}
+
+// transferCash moves an amount in cents of the settlement currency from the payer's cash account to the payee's in the book,
+// each converted to the currency of the account and rounded to the cent. The payer cannot go overdrawn, nor pay itself.
+// It returns the FX rates applied to the payer and to the payee
+func (s *SmartContract) transferCash(ctx contractapi.TransactionContextInterface, book *cashBook, payerHash, payeeHash string, amount int64) (float64, float64, error) {
+	if payerHash == payeeHash {
+		return 0, 0, fmt.Errorf("%s cannot pay cash to itself", payerHash)
+	}
+	payer, err := book.account(s, ctx, payerHash)
+	if err != nil {
+		return 0, 0, err
+	}
+	payee, err := book.account(s, ctx, payeeHash)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	payerRate, err := s.fxRateFromUSD(ctx, payer.Currency)
+	if err != nil {
+		return 0, 0, err
+	}
+	payeeRate, err := s.fxRateFromUSD(ctx, payee.Currency)
+	if err != nil {
+		return 0, 0, err
+	}
+	if amount <= 0 {
+		return payerRate, payeeRate, nil
+	}
+
+	debit := convertCents(amount, payerRate)
+	if payer.Balance < debit {
+		return 0, 0, fmt.Errorf("insufficient cash for %s: balance %.2f, needed %.2f", payerHash, dollars(payer.Balance), dollars(debit))
+	}
+	payer.Balance -= debit
+	payee.Balance += convertCents(amount, payeeRate)
+	book.markChanged(payerHash)
+	book.markChanged(payeeHash)
+
+	return payerRate, payeeRate, nil
+}
+
+// transferCashIn moves an amount in cents of a currency from the payer's balance in that currency to the payee's in the book,
+// without conversion. The payer cannot go overdrawn, nor pay itself
+func (s *SmartContract) transferCashIn(ctx contractapi.TransactionContextInterface, book *cashBook, payerHash, payeeHash, currency string, amount int64) error {
+	if payerHash == payeeHash {
+		return fmt.Errorf("%s cannot pay cash to itself", payerHash)
+	}
+	payer, err := book.account(s, ctx, payerHash)
+	if err != nil {
+		return err
+	}
+	payee, err := book.account(s, ctx, payeeHash)
+	if err != nil {
+		return err
+	}
+	if payer.balance(currency) < amount {
+		return fmt.Errorf("insufficient cash for %s: balance %.2f %s, needed %.2f", payerHash, dollars(payer.balance(currency)), currency, dollars(amount))
+	}
+	payer.adjust(currency, -amount)
+	payee.adjust(currency, amount)
+	book.markChanged(payerHash)
+	book.markChanged(payeeHash)
+
+	return nil
+}
+
+// settleTransactionIn settles a transaction priced in a currency from the buyer's balance in it. Both sides are recorded in that
+// currency, without FX rates. An empty currency is the settlement currency, converted to each account's currency
+func (s *SmartContract) settleTransactionIn(ctx contractapi.TransactionContextInterface, ledger *Ledger, transaction Transaction, price float64, currency string) error {
+	if currency == "" {
+		return s.settleTransaction(ctx, ledger, transaction, price)
+	}
+
+	err := s.transferCashIn(ctx, ledger.cashBook(), transaction.BuyerID, transaction.SellerID, currency, settlementAmount(transaction.OriginalFace, price))
+	if err != nil {
+		return err
+	}
+	transaction.BuyerCurrency = currency
+	transaction.SellerCurrency = currency
+
+	return s.recordTransaction(ctx, ledger, transaction, price, 1, 1)
+}
+
+// settleTransaction records a transaction on the ledger and pays the seller from the buyer's cash account,
+// capturing the currencies and FX rates applied
+func (s *SmartContract) settleTransaction(ctx contractapi.TransactionContextInterface, ledger *Ledger, transaction Transaction, price float64) error {
+	buyerRate, sellerRate, err := s.transferCash(ctx, ledger.cashBook(), transaction.BuyerID, transaction.SellerID, settlementAmount(transaction.OriginalFace, price))
+	if err != nil {
+		return err
+	}
+
+	return s.recordTransaction(ctx, ledger, transaction, price, buyerRate, sellerRate)
+}
+
+// recordTransaction records a transaction whose cash was already paid on the ledger, with the currencies of the accounts,
+// unless the transaction already names the currencies it was paid in, and the FX rates applied to the buyer and to the seller
+func (s *SmartContract) recordTransaction(ctx contractapi.TransactionContextInterface, ledger *Ledger, transaction Transaction, price, buyerRate, sellerRate float64) error {
+	var err error
+	if transaction.BuyerCurrency == "" {
+		transaction.BuyerCurrency, err = s.accountCurrency(ctx, ledger.cashBook(), transaction.BuyerID)
+		if err != nil {
+			return err
+		}
+	}
+	if transaction.SellerCurrency == "" {
+		transaction.SellerCurrency, err = s.accountCurrency(ctx, ledger.cashBook(), transaction.SellerID)
+		if err != nil {
+			return err
+		}
+	}
+	transaction.BuyerFXRate = buyerRate
+	transaction.SellerFXRate = sellerRate
+
+	err = s.captureYieldAndSpread(ctx, &transaction, price)
+	if err != nil {
+		return err
+	}
+
+	appendTransaction(ctx, ledger, transaction)
+	return nil
+}
+
+// fxRateFromUSD returns the number of units of a currency per unit of the settlement currency.
+// Accounts without a currency yet are in the settlement currency
+func (s *SmartContract) fxRateFromUSD(ctx contractapi.TransactionContextInterface, currency string) (float64, error) {
+	settlementCurrency, err := s.settlementCurrency(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if currency == "" || currency == settlementCurrency {
+		return 1, nil
+	}
+
+	rate, err := s.GetFXRate(ctx, currency)
+	if err != nil {
+		return 0, err
+	}
+
+	return rate.UnitsPerUSD, nil
+}
+
+// accountCurrency returns the currency of an organization's cash account in the book
+func (s *SmartContract) accountCurrency(ctx contractapi.TransactionContextInterface, book *cashBook, ownerHash string) (string, error) {
+	account, err := book.account(s, ctx, ownerHash)
+	if err != nil {
+		return "", err
+	}
+	if account.Currency == "" {
+		return s.settlementCurrency(ctx)
+	}
+
+	return account.Currency, nil
+}
+
+// balance returns the account's balance in cents of a currency. An account without a currency yet has none
+func (account *CashAccount) balance(currency string) int64 {
+	if currency == account.Currency {
+		return account.Balance
+	}
+
+	return account.Balances[currency]
+}
+
+// adjust adds an amount in cents, negative to debit, to the account's balance in a currency
+func (account *CashAccount) adjust(currency string, amount int64) {
+	if account.Currency == "" {
+		account.Currency = currency
+	}
+	if currency == account.Currency {
+		account.Balance += amount
+		return
+	}
+	if account.Balances == nil {
+		account.Balances = map[string]int64{}
+	}
+	account.Balances[currency] += amount
+}
+
+// settlementAmount returns the cash owed for a face amount at a price per 100, in cents rounded to the cent
+func settlementAmount(originalFace int64, price float64) int64 {
+	return int64(math.Round(float64(originalFace) * price / 100))
+}
+
+// marketValue returns the value in dollars of a face amount at a price per 100, unrounded, for the reports
+func marketValue(originalFace int64, price float64) float64 {
+	return dollars(originalFace) * price / 100
+}
+
+// convertCents converts an amount in cents at an FX rate, rounded to the cent
+func convertCents(amount int64, rate float64) int64 {
+	return int64(math.Round(float64(amount) * rate))
+}
+
+// requireCashAgent returns an error unless the caller belongs to the cash agent organization
+func (s *SmartContract) requireCashAgent(ctx contractapi.TransactionContextInterface) error {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	config, err := s.GetCashAgent(ctx)
+	if err != nil {
+		return err
+	}
+	if mspID != config.MSPID {
+		return NewError(ErrUnauthorized, "only the cash agent %s can run this function", config.MSPID)
+	}
+
+	return nil
+}
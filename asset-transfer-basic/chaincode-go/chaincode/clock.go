@@ -0,0 +1,94 @@
+package chaincode
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// ClockSkewConfig bounds how far the timestamps clients pass to write functions may be from the transaction timestamp.
+// It applies to the whole channel, so strict and lenient deployments can run the same chaincode
+type ClockSkewConfig struct {
+	MaxSkewSeconds int       `json:"maxSkewSeconds"` // Zero disables the check
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+const (
+	configObjectType  = "config"
+	clockSkewConfigID = "clockskew"
+)
+
+// ⭐ Functions ⭐
+
+// SetClockSkewTolerance sets how many seconds client timestamps may be ahead of or behind the transaction timestamp.
+// Only the admin organization can change it. Zero turns the check off
+func (s *SmartContract) SetClockSkewTolerance(ctx contractapi.TransactionContextInterface, maxSkewSeconds int) (*WriteResponse, error) {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if maxSkewSeconds < 0 {
+		return nil, fmt.Errorf("clock skew tolerance cannot be negative: %d", maxSkewSeconds)
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config := ClockSkewConfig{
+		MaxSkewSeconds: maxSkewSeconds,
+		UpdatedAt:      timestamp,
+	}
+	err = s.putRecord(ctx, configObjectType, clockSkewConfigID, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// GetClockSkewTolerance returns the clock skew tolerance of the channel. A channel where it was never set has no tolerance check
+func (s *SmartContract) GetClockSkewTolerance(ctx contractapi.TransactionContextInterface) (*ClockSkewConfig, error) {
+	var config ClockSkewConfig
+	_, err := s.getRecord(ctx, configObjectType, clockSkewConfigID, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// checkClientTime converts timestamps received as arguments to UTC, like toUTC, and checks that each one is within
+// the channel's clock skew tolerance of the transaction timestamp. It is meant for timestamps that say when the call happens,
+// not for expiries or other dates the client picks freely
+func (s *SmartContract) checkClientTime(ctx contractapi.TransactionContextInterface, timestamps ...*time.Time) error {
+	toUTC(timestamps...)
+
+	config, err := s.GetClockSkewTolerance(ctx)
+	if err != nil {
+		return err
+	}
+	if config.MaxSkewSeconds == 0 {
+		return nil
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	tolerance := time.Duration(config.MaxSkewSeconds) * time.Second
+	for _, timestamp := range timestamps {
+		skew := timestamp.Sub(now)
+		if skew > tolerance || skew < -tolerance {
+			return fmt.Errorf("timestamp %v is more than %d seconds away from the transaction timestamp %v", timestamp.Format(time.RFC3339), config.MaxSkewSeconds, now.Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
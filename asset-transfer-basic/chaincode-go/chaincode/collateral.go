@@ -0,0 +1,113 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// CollateralChaincodeConfig names the collateral-management chaincode on the channel that haircuts are reserved and released in.
+// An empty name turns the integration off
+type CollateralChaincodeConfig struct {
+	ChaincodeName string    `json:"chaincodeName"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// HaircutRequest is the argument passed to the collateral chaincode when a haircut is reserved or released
+type HaircutRequest struct {
+	Reference    string  `json:"reference"` // Repo or direct trade ID
+	PartyHash    string  `json:"partyHash"` // Organization whose collateral the haircut applies to
+	UID          string  `json:"uid"`
+	Cusip        string  `json:"cusip"`
+	OriginalFace int64   `json:"originalFace"` // In cents
+	Haircut      float64 `json:"haircut"`      // Share of the collateral value, e.g. 0.02 for 2%. 0 on a release
+	CashAmount   float64 `json:"cashAmount"`   // Cash exchanged against the bond
+}
+
+const (
+	collateralChaincodeConfigID = "collateralchaincode"
+
+	// Functions of the collateral chaincode called by the hook
+	reserveHaircutFunction = "ReserveHaircut"
+	releaseHaircutFunction = "ReleaseHaircut"
+)
+
+// ⭐ Functions ⭐
+
+// SetCollateralChaincode sets the collateral-management chaincode that repos and direct trades reserve and release haircuts in.
+// It must be deployed on the same channel. An empty name stops calling it. Only the admin organization can change it
+func (s *SmartContract) SetCollateralChaincode(ctx contractapi.TransactionContextInterface, chaincodeName string) (*WriteResponse, error) {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config := CollateralChaincodeConfig{
+		ChaincodeName: chaincodeName,
+		UpdatedAt:     timestamp,
+	}
+	err = s.putRecord(ctx, configObjectType, collateralChaincodeConfigID, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// GetCollateralChaincode returns the collateral-management chaincode haircuts are reserved in. The name is empty when none is set
+func (s *SmartContract) GetCollateralChaincode(ctx contractapi.TransactionContextInterface) (*CollateralChaincodeConfig, error) {
+	var config CollateralChaincodeConfig
+	_, err := s.getRecord(ctx, configObjectType, collateralChaincodeConfigID, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// reserveHaircut asks the collateral chaincode to reserve a haircut, when one is set
+func (s *SmartContract) reserveHaircut(ctx contractapi.TransactionContextInterface, request HaircutRequest) error {
+	return s.invokeCollateral(ctx, reserveHaircutFunction, request)
+}
+
+// releaseHaircut asks the collateral chaincode to release the haircut reserved under a reference, when one is set
+func (s *SmartContract) releaseHaircut(ctx contractapi.TransactionContextInterface, request HaircutRequest) error {
+	return s.invokeCollateral(ctx, releaseHaircutFunction, request)
+}
+
+// invokeCollateral calls a function of the collateral chaincode with the request as JSON.
+// The call runs in the same transaction, so a rejection by the collateral chaincode fails the whole transaction
+func (s *SmartContract) invokeCollateral(ctx contractapi.TransactionContextInterface, function string, request HaircutRequest) error {
+	config, err := s.GetCollateralChaincode(ctx)
+	if err != nil {
+		return err
+	}
+	if config.ChaincodeName == "" {
+		return nil
+	}
+
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal haircut request: %v", err)
+	}
+
+	// An empty channel is the channel of the calling transaction
+	response := ctx.GetStub().InvokeChaincode(config.ChaincodeName, [][]byte{[]byte(function), requestJSON}, "")
+	if response.Status >= shim.ERRORTHRESHOLD {
+		return fmt.Errorf("collateral chaincode %s failed to %s for %s: %s", config.ChaincodeName, function, request.Reference, response.Message)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+// setUpRepo returns a world where Org2 proposes to repo its bond to Org1, who has cash to lend
+func setUpRepo(t *testing.T, contract *chaincode.SmartContract) *world {
+	w := setUp(t, contract)
+	createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+
+	_, err := contract.DepositCash(w.begin(org1), org1, "USD", 200000000)
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.ProposeRepo(w.begin(org2), "repo1", "uid1", org1, 900000, 0.05, 0.02, 30, testTime)
+	require.NoError(t, err)
+	w.commit()
+	return w
+}
+
+// haircutCall returns the function and request the transaction passed to the collateral chaincode
+func haircutCall(t *testing.T, stub *mocks.ChaincodeStub) (string, string, chaincode.HaircutRequest) {
+	require.Equal(t, 1, stub.InvokeChaincodeCallCount())
+	name, args, channel := stub.InvokeChaincodeArgsForCall(0)
+	require.Empty(t, channel)
+	require.Len(t, args, 2)
+
+	var request chaincode.HaircutRequest
+	require.NoError(t, json.Unmarshal(args[1], &request))
+	return name, string(args[0]), request
+}
+
+func TestRepoReservesAndReleasesHaircut(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpRepo(t, contract)
+
+	_, err := contract.SetCollateralChaincode(w.begin(org1), "collateral")
+	require.NoError(t, err)
+	w.commit()
+
+	ctx := w.begin(org1)
+	stub := ctx.GetStub().(*mocks.ChaincodeStub)
+	stub.InvokeChaincodeReturns(shim.Success(nil))
+	_, err = contract.AcceptRepo(ctx, "repo1", testTime)
+	require.NoError(t, err)
+	w.commit()
+
+	name, function, request := haircutCall(t, stub)
+	require.Equal(t, "collateral", name)
+	require.Equal(t, "ReserveHaircut", function)
+	require.Equal(t, chaincode.HaircutRequest{Reference: "repo1", PartyHash: org2, UID: "uid1", Cusip: testCusip, OriginalFace: tradeFace, Haircut: 0.02, CashAmount: 900000}, request)
+
+	ctx = w.begin(org2)
+	stub = ctx.GetStub().(*mocks.ChaincodeStub)
+	stub.InvokeChaincodeReturns(shim.Success(nil))
+	_, err = contract.CloseRepo(ctx, "repo1", testTime)
+	require.NoError(t, err)
+
+	_, function, request = haircutCall(t, stub)
+	require.Equal(t, "ReleaseHaircut", function)
+	require.Equal(t, "repo1", request.Reference)
+	require.Zero(t, request.Haircut)
+}
+
+func TestCollateralChaincodeRejects(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpRepo(t, contract)
+
+	_, err := contract.SetCollateralChaincode(w.begin(org1), "collateral")
+	require.NoError(t, err)
+	w.commit()
+
+	ctx := w.begin(org1)
+	ctx.GetStub().(*mocks.ChaincodeStub).InvokeChaincodeReturns(shim.Error("insufficient collateral"))
+	_, err = contract.AcceptRepo(ctx, "repo1", testTime)
+	require.EqualError(t, err, "collateral chaincode collateral failed to ReserveHaircut for repo1: insufficient collateral")
+}
+
+func TestCollateralChaincodeUnset(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpRepo(t, contract)
+
+	ctx := w.begin(org1)
+	_, err := contract.AcceptRepo(ctx, "repo1", testTime)
+	require.NoError(t, err)
+	require.Zero(t, ctx.GetStub().(*mocks.ChaincodeStub).InvokeChaincodeCallCount())
+
+	_, err = contract.SetCollateralChaincode(w.begin(org2), "collateral")
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "only Org1MSP can run this function"))
+}
+
+func TestDirectTradeReleasesHaircut(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpTrade(t, contract, 200000000)
+
+	_, err := contract.SetCollateralChaincode(w.begin(org1), "collateral")
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+	require.NoError(t, err)
+	w.commit()
+
+	ctx := w.begin(org1)
+	stub := ctx.GetStub().(*mocks.ChaincodeStub)
+	stub.InvokeChaincodeReturns(shim.Success(nil))
+	_, err = contract.AnswerTradeAsOwner(ctx, "trade1", org2, "done", testTime, "")
+	require.NoError(t, err)
+
+	_, function, request := haircutCall(t, stub)
+	require.Equal(t, "ReleaseHaircut", function)
+	require.Equal(t, chaincode.HaircutRequest{Reference: "trade1", PartyHash: org2, UID: "uid1", Cusip: testCusip, OriginalFace: tradeFace, CashAmount: 995000}, request)
+}
@@ -0,0 +1,333 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// ComplianceReport is an organization's end-of-day summary for the regulator
+type ComplianceReport struct {
+	MSPID          string             `json:"mspID"`
+	Date           string             `json:"date"` // YYYY-MM-DD, in UTC
+	Positions      []ReportedPosition `json:"positions"`
+	TotalFace      int64              `json:"totalFace"` // In cents
+	OpenOrders     []ReportedOrder    `json:"openOrders"`
+	ExecutedVolume ExecutedVolume     `json:"executedVolume"`
+	Fails          []ReportedFail     `json:"fails"`
+	GeneratedAt    time.Time          `json:"generatedAt"`
+	TxID           string             `json:"txId"`
+	Digest         string             `json:"digest"` // SHA-256 of the report JSON with an empty digest, in hex
+}
+
+// ReportedPosition is a bond held at the time of the report
+type ReportedPosition struct {
+	UID          string `json:"uid"`
+	Cusip        string `json:"cusip"`
+	OriginalFace int64  `json:"originalFace"` // In cents
+}
+
+// ReportedOrder is a direct trade or an offer still open at the time of the report
+type ReportedOrder struct {
+	Kind     string `json:"kind"` //"Trade" or "Offer"
+	ID       string `json:"id"`
+	Cusip    string `json:"cusip"`
+	OpenFace int64  `json:"openFace"` // In cents
+	Price    Price  `json:"price"`
+}
+
+// ExecutedVolume sums up the transactions an organization settled on the date of the report
+type ExecutedVolume struct {
+	Transactions int     `json:"transactions"`
+	BoughtFace   int64   `json:"boughtFace"` // In cents
+	SoldFace     int64   `json:"soldFace"`   // In cents
+	BoughtAmount float64 `json:"boughtAmount"`
+	SoldAmount   float64 `json:"soldAmount"`
+}
+
+// ReportedFail is an obligation the organization failed on the date of the report
+type ReportedFail struct {
+	Kind  string `json:"kind"` //"RepoDefault"
+	ID    string `json:"id"`
+	Cusip string `json:"cusip"`
+}
+
+// ComplianceFiling is the public proof that a report was filed. The report itself is only in the implicit collections
+// of the organization and of the regulator, and its digest can be checked against this record
+type ComplianceFiling struct {
+	MSPID        string    `json:"mspID"`
+	Date         string    `json:"date"`
+	RegulatorMSP string    `json:"regulatorMSP"`
+	Digest       string    `json:"digest"`
+	TxID         string    `json:"txId"`
+	FiledAt      time.Time `json:"filedAt"`
+}
+
+// RegulatorConfig names the organization compliance reports are filed with
+type RegulatorConfig struct {
+	MSPID     string    `json:"mspID"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+const (
+	regulatorConfigID          = "regulator"
+	complianceReportKeyType    = "compliancereport"
+	complianceFilingObjectType = "compliancefiling"
+)
+
+// ⭐ Functions ⭐
+
+// SetRegulator sets the organization compliance reports are filed with. Only the admin organization can change it
+func (s *SmartContract) SetRegulator(ctx contractapi.TransactionContextInterface, mspID string) (*WriteResponse, error) {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if mspID == "" {
+		return nil, fmt.Errorf("the regulator must have an MSP ID")
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config := RegulatorConfig{
+		MSPID:     mspID,
+		UpdatedAt: timestamp,
+	}
+	err = s.putRecord(ctx, configObjectType, regulatorConfigID, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// GenerateComplianceReport builds the caller's report for a date and files it with the regulator: the report goes to
+// the regulator's and the caller's implicit collections, and its digest to a public filing endorsed with the transaction.
+// A date is reported once
+func (s *SmartContract) GenerateComplianceReport(ctx contractapi.TransactionContextInterface, date string) (*WriteResponse, error) {
+	_, err := parseDate(date)
+	if err != nil {
+		return nil, err
+	}
+
+	var regulator RegulatorConfig
+	exists, err := s.getRecord(ctx, configObjectType, regulatorConfigID, &regulator)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("no regulator is set")
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	var existing ComplianceFiling
+	exists, err = s.getCompositeRecord(ctx, complianceFilingObjectType, []string{date, mspID}, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("the compliance report of %s for %s is already filed", mspID, date)
+	}
+
+	report, err := s.buildComplianceReport(ctx, mspID, date)
+	if err != nil {
+		return nil, err
+	}
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal compliance report: %v", err)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(complianceReportKeyType, []string{date, mspID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s key: %v", complianceReportKeyType, err)
+	}
+	for _, collection := range []string{implicitCollection(regulator.MSPID), implicitCollection(mspID)} {
+		err = ctx.GetStub().PutPrivateData(collection, key, reportJSON)
+		if err != nil {
+			return nil, fmt.Errorf("%s - failed to put compliance report: %v", collection, err)
+		}
+	}
+
+	filing := ComplianceFiling{
+		MSPID:        mspID,
+		Date:         date,
+		RegulatorMSP: regulator.MSPID,
+		Digest:       report.Digest,
+		TxID:         report.TxID,
+		FiledAt:      report.GeneratedAt,
+	}
+	err = s.putCompositeRecord(ctx, complianceFilingObjectType, []string{date, mspID}, filing)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, filing)
+}
+
+// GetComplianceReport returns a filed report from the caller's implicit collection, for export by the client application.
+// The regulator can read the report of every organization, the others only their own
+func (s *SmartContract) GetComplianceReport(ctx contractapi.TransactionContextInterface, date, mspID string) (*ComplianceReport, error) {
+	callerMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(complianceReportKeyType, []string{date, mspID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s key: %v", complianceReportKeyType, err)
+	}
+	reportJSON, err := ctx.GetStub().GetPrivateData(implicitCollection(callerMSP), key)
+	if err != nil {
+		return nil, fmt.Errorf("%s - failed to get compliance report: %v", implicitCollection(callerMSP), err)
+	}
+	if reportJSON == nil {
+		return nil, fmt.Errorf("no compliance report of %s for %s is available to %s", mspID, date, callerMSP)
+	}
+
+	var report ComplianceReport
+	err = json.Unmarshal(reportJSON, &report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal compliance report: %v", err)
+	}
+
+	return &report, nil
+}
+
+// GetComplianceFiling returns the public filing of an organization's report for a date
+func (s *SmartContract) GetComplianceFiling(ctx contractapi.TransactionContextInterface, date, mspID string) (*ComplianceFiling, error) {
+	var filing ComplianceFiling
+	exists, err := s.getCompositeRecord(ctx, complianceFilingObjectType, []string{date, mspID}, &filing)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the compliance report of %s for %s is not filed", mspID, date)
+	}
+
+	return &filing, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// buildComplianceReport collects the report of the caller's organization. Orders and transactions may name the organization
+// by its owner hash or by its MSP ID, so both count as the organization. Bonds may also be committed with its owner secret
+func (s *SmartContract) buildComplianceReport(ctx contractapi.TransactionContextInterface, mspID, date string) (*ComplianceReport, error) {
+	owner, err := s.callerOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	isCaller := func(hash string) bool {
+		return hash == owner.hash || hash == mspID
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &ComplianceReport{
+		MSPID:       mspID,
+		Date:        date,
+		Positions:   []ReportedPosition{},
+		OpenOrders:  []ReportedOrder{},
+		Fails:       []ReportedFail{},
+		GeneratedAt: timestamp,
+		TxID:        ctx.GetStub().GetTxID(),
+	}
+
+	ledger, err := s.GetLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, bond := range ledger.Bonds {
+		if isCaller(bond.OwnerHash) || owner.owns(&bond) {
+			report.Positions = append(report.Positions, ReportedPosition{UID: bond.UID, Cusip: bond.Cusip, OriginalFace: bond.OriginalFace})
+			report.TotalFace += bond.OriginalFace
+		}
+	}
+	for _, trade := range ledger.DirectTrades {
+		if trade.open() && isCaller(trade.BidderHash) {
+			report.OpenOrders = append(report.OpenOrders, ReportedOrder{Kind: "Trade", ID: trade.DirectTradeID, Cusip: trade.Cusip, OpenFace: trade.openFace(), Price: trade.BidPrice})
+		}
+	}
+	for _, transaction := range ledger.Transactions {
+		if transaction.Timestamp.UTC().Format(markDateLayout) != date {
+			continue
+		}
+		amount := marketValue(transaction.OriginalFace, transaction.BoughtPrice.value())
+		if isCaller(transaction.BuyerID) {
+			report.ExecutedVolume.Transactions++
+			report.ExecutedVolume.BoughtFace += transaction.OriginalFace
+			report.ExecutedVolume.BoughtAmount += amount
+		}
+		if isCaller(transaction.SellerID) {
+			report.ExecutedVolume.Transactions++
+			report.ExecutedVolume.SoldFace += transaction.OriginalFace
+			report.ExecutedVolume.SoldAmount += amount
+		}
+	}
+
+	offersIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(offerObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offers: %v", err)
+	}
+	defer offersIterator.Close()
+	for offersIterator.HasNext() {
+		queryResponse, err := offersIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over offers: %v", err)
+		}
+
+		var offer Offer
+		err = json.Unmarshal(queryResponse.Value, &offer)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling offer JSON: %v", err)
+		}
+		if offer.State == "Open" && isCaller(offer.SellerHash) {
+			report.OpenOrders = append(report.OpenOrders, ReportedOrder{Kind: "Offer", ID: offer.OfferID, Cusip: offer.Cusip, OpenFace: offer.openFace(), Price: offer.AskPrice})
+		}
+	}
+
+	reposIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(repoObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repos: %v", err)
+	}
+	defer reposIterator.Close()
+	for reposIterator.HasNext() {
+		queryResponse, err := reposIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over repos: %v", err)
+		}
+
+		var repo Repo
+		err = json.Unmarshal(queryResponse.Value, &repo)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling repo JSON: %v", err)
+		}
+		if repo.State == "Defaulted" && isCaller(repo.SellerHash) && repo.CloseDate.UTC().Format(markDateLayout) == date {
+			report.Fails = append(report.Fails, ReportedFail{Kind: "RepoDefault", ID: repo.RepoID, Cusip: repo.Cusip})
+		}
+	}
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal compliance report: %v", err)
+	}
+	digest := sha256.Sum256(reportJSON)
+	report.Digest = hex.EncodeToString(digest[:])
+
+	return report, nil
+}
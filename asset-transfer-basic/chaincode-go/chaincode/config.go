@@ -0,0 +1,201 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// ContractConfig holds the contract settings that used to be hard-coded. A channel where it was never updated uses the defaults
+type ContractConfig struct {
+	DefaultTradeExpiryHours int       `json:"defaultTradeExpiryHours"` // Expiry of direct trades created without one. 0 leaves them open until closed
+	AllowedCouponTypes      []string  `json:"allowedCouponTypes"`      // Coupon types a bond may have
+	SettlementCurrency      string    `json:"settlementCurrency"`      // Currency trades are priced in and FX rates are quoted against
+	AdminMSPs               []string  `json:"adminMSPs"`               // Organizations allowed to run admin functions
+	OracleMSPs              []string  `json:"oracleMSPs"`              // Organizations whose price feeds may submit marks. None by default
+	RequireInstructions     bool      `json:"requireInstructions"`     // Whether agreed direct trades wait for matching settlement instructions to settle. Off by default
+	PriceTick               Price     `json:"priceTick,omitempty"`     // Increment bid and counter prices must be a multiple of, e.g. "0.03125" for 32nds. Empty for any price
+	MinTradeFace            int64     `json:"minTradeFace"`            // Smallest face a direct trade may bid for, in cents. 0 for no minimum
+	MaxTradeFace            int64     `json:"maxTradeFace"`            // Largest face a direct trade may bid for, in cents. 0 for no maximum
+	UpdatedAt               time.Time `json:"updatedAt"`
+}
+
+const contractConfigID = "contract"
+
+// Three-letter ISO 4217 currency code
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ⭐ Functions ⭐
+
+// GetConfig returns the contract settings, or the defaults if they were never updated
+func (s *SmartContract) GetConfig(ctx contractapi.TransactionContextInterface) (*ContractConfig, error) {
+	config := defaultContractConfig()
+	_, err := s.getRecord(ctx, configObjectType, contractConfigID, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// UpdateConfig replaces the contract settings with the ones passed as JSON. Only an admin organization can update them,
+// and it must stay one, so that the settings can never be locked. The settlement currency cannot change while FX rates are
+// quoted against the current one
+func (s *SmartContract) UpdateConfig(ctx contractapi.TransactionContextInterface, configJSON string) (*WriteResponse, error) {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var config ContractConfig
+	err = json.Unmarshal([]byte(configJSON), &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config JSON: %v", err)
+	}
+
+	if config.DefaultTradeExpiryHours < 0 {
+		return nil, fmt.Errorf("default trade expiry cannot be negative: %d hours", config.DefaultTradeExpiryHours)
+	}
+	if len(config.AllowedCouponTypes) == 0 {
+		return nil, fmt.Errorf("at least one coupon type must be allowed")
+	}
+	for i, couponType := range config.AllowedCouponTypes {
+		config.AllowedCouponTypes[i] = strings.ToUpper(couponType)
+	}
+	if !currencyPattern.MatchString(config.SettlementCurrency) {
+		return nil, fmt.Errorf("settlement currency must be a three-letter currency code: %q", config.SettlementCurrency)
+	}
+	if config.OracleMSPs == nil {
+		config.OracleMSPs = []string{}
+	}
+	if config.PriceTick != "" && (!pricePattern.MatchString(string(config.PriceTick)) || config.PriceTick.value() <= 0) {
+		return nil, fmt.Errorf("the price tick must be a positive decimal string: %q", config.PriceTick)
+	}
+	if config.MinTradeFace < 0 || config.MaxTradeFace < 0 {
+		return nil, fmt.Errorf("trade face limits cannot be negative: minimum %d, maximum %d", config.MinTradeFace, config.MaxTradeFace)
+	}
+	if config.MaxTradeFace != 0 && config.MaxTradeFace < config.MinTradeFace {
+		return nil, fmt.Errorf("the maximum trade face %d is below the minimum %d", config.MaxTradeFace, config.MinTradeFace)
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	if !containsString(config.AdminMSPs, mspID) {
+		return nil, fmt.Errorf("the admin organizations must include %s", mspID)
+	}
+
+	current, err := s.GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if config.SettlementCurrency != current.SettlementCurrency {
+		rates, err := ctx.GetStub().GetStateByPartialCompositeKey(fxRateObjectType, []string{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get FX rates: %v", err)
+		}
+		quoted := rates.HasNext()
+		rates.Close()
+		if quoted {
+			return nil, fmt.Errorf("the settlement currency cannot change while FX rates are quoted against %s", current.SettlementCurrency)
+		}
+	}
+
+	config.UpdatedAt, err = txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = s.putRecord(ctx, configObjectType, contractConfigID, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, config)
+}
+
+// ⭐ Helper functions ⭐
+
+// defaultContractConfig returns the settings of a channel where they were never updated
+func defaultContractConfig() *ContractConfig {
+	return &ContractConfig{
+		AllowedCouponTypes: []string{"FIXED", "FLOATING", "ARM"},
+		SettlementCurrency: "USD",
+		AdminMSPs:          []string{"Org1MSP"},
+		OracleMSPs:         []string{},
+	}
+}
+
+// requireAdmin returns an error unless the caller belongs to one of the admin organizations of the contract settings
+func (s *SmartContract) requireAdmin(ctx contractapi.TransactionContextInterface) error {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	config, err := s.GetConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if !containsString(config.AdminMSPs, mspID) {
+		return NewError(ErrUnauthorized, "only %s can run this function", strings.Join(config.AdminMSPs, ", "))
+	}
+
+	return nil
+}
+
+// settlementCurrency returns the currency trades are priced in
+func (s *SmartContract) settlementCurrency(ctx contractapi.TransactionContextInterface) (string, error) {
+	config, err := s.GetConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return config.SettlementCurrency, nil
+}
+
+// requireTradeFace checks a direct trade's face against the minimum and maximum trade face of the contract settings
+func (s *SmartContract) requireTradeFace(ctx contractapi.TransactionContextInterface, face int64) error {
+	config, err := s.GetConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if face < config.MinTradeFace {
+		return NewError(ErrInvalidInput, "face %d is below the minimum trade face of %d", face, config.MinTradeFace)
+	}
+	if config.MaxTradeFace != 0 && face > config.MaxTradeFace {
+		return NewError(ErrInvalidInput, "face %d is above the maximum trade face of %d", face, config.MaxTradeFace)
+	}
+
+	return nil
+}
+
+// requirePriceTick checks that a bid or counter price of a direct trade is a multiple of the price tick of the contract
+// settings. Trades negotiated as a spread are quoted in basis points, which the tick does not apply to
+func (s *SmartContract) requirePriceTick(ctx contractapi.TransactionContextInterface, trade *DirectTrade, price Price) error {
+	if trade.Benchmark != "" {
+		return nil
+	}
+	config, err := s.GetConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if config.PriceTick == "" {
+		return nil
+	}
+
+	// Exact decimal arithmetic, so that 100.03125 is a multiple of 0.03125 however it rounds as a float
+	value, ok := new(big.Rat).SetString(string(price))
+	tick, tickOK := new(big.Rat).SetString(string(config.PriceTick))
+	if !ok || !tickOK || !new(big.Rat).Quo(value, tick).IsInt() {
+		return NewError(ErrInvalidInput, "price %s is not a multiple of the price tick of %s", price, config.PriceTick)
+	}
+
+	return nil
+}
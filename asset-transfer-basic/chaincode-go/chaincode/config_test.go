@@ -0,0 +1,134 @@
+package chaincode_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetConfigDefaults(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+
+	config, err := contract.GetConfig(w.begin(org2))
+	require.NoError(t, err)
+	require.Zero(t, config.DefaultTradeExpiryHours)
+	require.Equal(t, []string{"FIXED", "FLOATING", "ARM"}, config.AllowedCouponTypes)
+	require.Equal(t, "USD", config.SettlementCurrency)
+	require.Equal(t, []string{org1}, config.AdminMSPs)
+	require.Empty(t, config.OracleMSPs)
+}
+
+func TestUpdateConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		mspID  string
+		config string
+		err    string
+	}{
+		{name: "not an admin", mspID: org2, config: `{"allowedCouponTypes":["FIXED"],"settlementCurrency":"USD","adminMSPs":["Org2MSP"]}`, err: contractError(chaincode.ErrUnauthorized, "only Org1MSP can run this function")},
+		{name: "caller left out of the admins", mspID: org1, config: `{"allowedCouponTypes":["FIXED"],"settlementCurrency":"USD","adminMSPs":["Org2MSP"]}`, err: "the admin organizations must include Org1MSP"},
+		{name: "no coupon types", mspID: org1, config: `{"allowedCouponTypes":[],"settlementCurrency":"USD","adminMSPs":["Org1MSP"]}`, err: "at least one coupon type must be allowed"},
+		{name: "invalid currency", mspID: org1, config: `{"allowedCouponTypes":["FIXED"],"settlementCurrency":"usd","adminMSPs":["Org1MSP"]}`, err: `settlement currency must be a three-letter currency code: "usd"`},
+		{name: "negative expiry", mspID: org1, config: `{"defaultTradeExpiryHours":-1,"allowedCouponTypes":["FIXED"],"settlementCurrency":"USD","adminMSPs":["Org1MSP"]}`, err: "default trade expiry cannot be negative: -1 hours"},
+		{name: "invalid price tick", mspID: org1, config: `{"allowedCouponTypes":["FIXED"],"settlementCurrency":"USD","adminMSPs":["Org1MSP"],"priceTick":"1/32"}`, err: `the price tick must be a positive decimal string: "1/32"`},
+		{name: "maximum face below minimum", mspID: org1, config: `{"allowedCouponTypes":["FIXED"],"settlementCurrency":"USD","adminMSPs":["Org1MSP"],"minTradeFace":100000000,"maxTradeFace":50000000}`, err: "the maximum trade face 50000000 is below the minimum 100000000"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract := &chaincode.SmartContract{}
+			w := setUp(t, contract)
+
+			_, err := contract.UpdateConfig(w.begin(test.mspID), test.config)
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestConfigAdmins(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+
+	_, err := contract.SetPricePrecision(w.begin(org2), 4)
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "only Org1MSP can run this function"))
+
+	_, err = contract.UpdateConfig(w.begin(org1), `{"allowedCouponTypes":["FIXED","FLOATING","ARM"],"settlementCurrency":"USD","adminMSPs":["Org1MSP","Org2MSP"]}`)
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.SetPricePrecision(w.begin(org2), 4)
+	require.NoError(t, err)
+}
+
+func TestConfigCouponTypes(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+
+	_, err := contract.UpdateConfig(w.begin(org1), `{"allowedCouponTypes":["floating"],"settlementCurrency":"USD","adminMSPs":["Org1MSP"]}`)
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.AddToInventory(w.begin(org2), inventoryBond, false)
+	var validation *chaincode.ValidationError
+	require.True(t, errors.As(err, &validation), "expected a ValidationError, got %v", err)
+	require.Equal(t, "couponType", validation.Violations[0].Field)
+	require.Equal(t, `coupon type must be one of FLOATING: "FIXED"`, validation.Violations[0].Message)
+}
+
+func TestConfigDefaultTradeExpiry(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+
+	_, err := contract.UpdateConfig(w.begin(org1), `{"defaultTradeExpiryHours":24,"allowedCouponTypes":["FIXED"],"settlementCurrency":"USD","adminMSPs":["Org1MSP"]}`)
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.CreateTrade(w.begin(org1), "trade1", org1, testCusip, "2023-01-09T12:00:00Z", tradeFace, tradePrice, false, "")
+	require.NoError(t, err)
+	w.commit()
+
+	trades, err := contract.CheckDirectTrades(w.begin(org1), testCusip)
+	require.NoError(t, err)
+	require.Len(t, trades, 1)
+	require.Equal(t, testTime.Add(24*time.Hour), trades[0].ExpiresAt)
+}
+
+func TestConfigMarketRules(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+
+	_, err := contract.UpdateConfig(w.begin(org1), `{"allowedCouponTypes":["FIXED"],"settlementCurrency":"USD","adminMSPs":["Org1MSP"],"priceTick":"0.03125","minTradeFace":100000,"maxTradeFace":10000000}`)
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.CreateTrade(w.begin(org1), "trade1", org1, testCusip, "2023-01-09T12:00:00Z", 99999, tradePrice, false, "")
+	require.EqualError(t, err, contractError(chaincode.ErrInvalidInput, "face 99999 is below the minimum trade face of 100000"))
+	_, err = contract.CreateTrade(w.begin(org1), "trade1", org1, testCusip, "2023-01-09T12:00:00Z", 10000001, tradePrice, false, "")
+	require.EqualError(t, err, contractError(chaincode.ErrInvalidInput, "face 10000001 is above the maximum trade face of 10000000"))
+	_, err = contract.CreateTrade(w.begin(org1), "trade1", org1, testCusip, "2023-01-09T12:00:00Z", 100000, "100.01", false, "")
+	require.EqualError(t, err, contractError(chaincode.ErrInvalidInput, "price 100.01 is not a multiple of the price tick of 0.03125"))
+
+	_, err = contract.CreateTrade(w.begin(org1), "trade1", org1, testCusip, "2023-01-09T12:00:00Z", 100000, "100.03125", false, "")
+	require.NoError(t, err)
+	w.commit()
+
+	createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+	_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "counter", testTime, "100.1")
+	require.EqualError(t, err, contractError(chaincode.ErrInvalidInput, "price 100.1 is not a multiple of the price tick of 0.03125"))
+}
+
+func TestConfigSettlementCurrency(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+
+	_, err := contract.SetFXRate(w.begin(org1), "EUR", 0.9, testTime)
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.UpdateConfig(w.begin(org1), `{"allowedCouponTypes":["FIXED"],"settlementCurrency":"EUR","adminMSPs":["Org1MSP"]}`)
+	require.EqualError(t, err, "the settlement currency cannot change while FX rates are quoted against USD")
+}
@@ -0,0 +1,186 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// CorporateAction is a pool-level event submitted by the admin organization. Cleanup calls and dissolutions retire the pool
+type CorporateAction struct {
+	ActionID       string    `json:"actionID"`
+	Cusip          string    `json:"cusip"`
+	ActionType     string    `json:"actionType"` //"CleanupCall" or "Dissolution"
+	Factor         float64   `json:"factor"`     // Pool factor the final principal was computed at
+	EffectiveDate  string    `json:"effectiveDate"`
+	TotalPrincipal float64   `json:"totalPrincipal"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// CashObligation is an amount owed to an organization as the result of a corporate action
+type CashObligation struct {
+	ActionID  string  `json:"actionID"`
+	Cusip     string  `json:"cusip"`
+	PayeeHash string  `json:"payeeHash"`
+	Amount    float64 `json:"amount"`
+	Reason    string  `json:"reason"`
+	DueDate   string  `json:"dueDate"`
+	State     string  `json:"state"` //"Pending" or "Paid"
+}
+
+const (
+	corporateActionObjectType = "corporateaction"
+	cashObligationObjectType  = "obligation"
+)
+
+// ⭐ Functions ⭐
+
+// ProcessCorporateAction retires a pool through a cleanup call or a dissolution. Every holder of record is owed the final principal
+// of its positions, their original face at the current pool factor, and the positions stay on the ledger as matured bonds.
+// Retired positions are out of circulation and owed nothing
+func (s *SmartContract) ProcessCorporateAction(ctx contractapi.TransactionContextInterface, actionID, cusip, actionType, effectiveDate string, timestamp time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if actionType != "CleanupCall" && actionType != "Dissolution" {
+		return nil, fmt.Errorf("action type must be CleanupCall or Dissolution: %s", actionType)
+	}
+	_, err = parseDate(effectiveDate)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing CorporateAction
+	exists, err := s.getRecord(ctx, corporateActionObjectType, actionID, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, NewError(ErrAlreadyExists, "corporate action %s already exists", actionID)
+	}
+
+	pool, err := s.GetPool(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+	if pool.Status == "Retired" {
+		return nil, fmt.Errorf("pool %s is retired", cusip)
+	}
+
+	ledger, err := s.getCusipLedger(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	// Aggregate the final principal per holder, keeping the order holders appear in so the writes are deterministic
+	principals := map[string]float64{}
+	holders := []string{}
+	for i := range ledger.Bonds {
+		bond := &ledger.Bonds[i]
+		if bond.Cusip != cusip || bond.Status == BondRetired {
+			continue
+		}
+		if _, ok := principals[bond.OwnerHash]; !ok {
+			holders = append(holders, bond.OwnerHash)
+		}
+		principals[bond.OwnerHash] += dollars(bond.OriginalFace) * pool.Factor
+		bond.release()
+		// Bonds past their maturity date may have matured already
+		if bond.Status != BondMatured {
+			err = bondLifecycle.move(bond.UID, &bond.Status, BondMatured)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Bids on the retired pool can no longer be filled
+	for i := range ledger.DirectTrades {
+		trade := &ledger.DirectTrades[i]
+		if trade.Cusip == cusip && trade.open() {
+			err = directTradeLifecycle.move(trade.DirectTradeID, &trade.State, trade.closingState())
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	action := CorporateAction{
+		ActionID:      actionID,
+		Cusip:         cusip,
+		ActionType:    actionType,
+		Factor:        pool.Factor,
+		EffectiveDate: effectiveDate,
+		Timestamp:     timestamp,
+	}
+	for _, holder := range holders {
+		obligation := CashObligation{
+			ActionID:  actionID,
+			Cusip:     cusip,
+			PayeeHash: holder,
+			Amount:    principals[holder],
+			Reason:    actionType + " final principal",
+			DueDate:   effectiveDate,
+			State:     "Pending",
+		}
+		err = s.putCompositeRecord(ctx, cashObligationObjectType, []string{actionID, holder}, obligation)
+		if err != nil {
+			return nil, err
+		}
+		action.TotalPrincipal += obligation.Amount
+	}
+
+	err = s.putRecord(ctx, corporateActionObjectType, actionID, action)
+	if err != nil {
+		return nil, err
+	}
+
+	pool.Status = "Retired"
+	err = s.putRecord(ctx, poolObjectType, cusip, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, &action)
+}
+
+// GetCashObligations returns the cash obligations resulting from a corporate action
+func (s *SmartContract) GetCashObligations(ctx contractapi.TransactionContextInterface, actionID string) ([]CashObligation, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(cashObligationObjectType, []string{actionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cash obligations: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	obligations := []CashObligation{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over cash obligations: %v", err)
+		}
+
+		var obligation CashObligation
+		err = json.Unmarshal(queryResponse.Value, &obligation)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling cash obligation JSON: %v", err)
+		}
+		obligations = append(obligations, obligation)
+	}
+
+	return obligations, nil
+}
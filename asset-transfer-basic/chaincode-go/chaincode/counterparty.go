@@ -0,0 +1,158 @@
+package chaincode
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// Counterparty is the registry entry of an organization approved to trade
+type Counterparty struct {
+	MSPID        string    `json:"mspID"`
+	LEI          string    `json:"lei"` // Legal Entity Identifier, 20 alphanumeric characters
+	Name         string    `json:"name"`
+	Status       string    `json:"status"` // CounterpartyActive or CounterpartySuspended
+	RegisteredAt time.Time `json:"registeredAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// Statuses of a counterparty
+const (
+	CounterpartyActive    = "Active"
+	CounterpartySuspended = "Suspended"
+)
+
+const counterpartyObjectType = "counterparty"
+
+var leiPattern = regexp.MustCompile(`^[0-9A-Z]{18}[0-9]{2}$`)
+
+// ⭐ Functions ⭐
+
+// RegisterCounterparty approves an organization to trade, or reinstates a suspended one with its new details.
+// Only the admin organization can maintain the registry
+func (s *SmartContract) RegisterCounterparty(ctx contractapi.TransactionContextInterface, mspID, lei, name string) (*WriteResponse, error) {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if mspID == "" || name == "" {
+		return nil, fmt.Errorf("a counterparty must have an MSP ID and a name")
+	}
+	if !leiPattern.MatchString(lei) {
+		return nil, fmt.Errorf("invalid LEI %q: it must be 20 characters, 18 letters or digits then 2 check digits", lei)
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var counterparty Counterparty
+	exists, err := s.getRecord(ctx, counterpartyObjectType, mspID, &counterparty)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		counterparty.MSPID = mspID
+		counterparty.RegisteredAt = timestamp
+	}
+	counterparty.LEI = lei
+	counterparty.Name = name
+	counterparty.Status = CounterpartyActive
+	counterparty.UpdatedAt = timestamp
+
+	err = s.putRecord(ctx, counterpartyObjectType, mspID, counterparty)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, counterparty)
+}
+
+// SuspendCounterparty stops a registered organization from trading, whatever its channel membership.
+// Its open trades stay on the ledger, but it can no longer create or answer any. Only the admin organization can suspend
+func (s *SmartContract) SuspendCounterparty(ctx contractapi.TransactionContextInterface, mspID string) (*WriteResponse, error) {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var counterparty Counterparty
+	exists, err := s.getRecord(ctx, counterpartyObjectType, mspID, &counterparty)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%s is not a registered counterparty", mspID)
+	}
+	if counterparty.Status == CounterpartySuspended {
+		return nil, fmt.Errorf("%s is already suspended", mspID)
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counterparty.Status = CounterpartySuspended
+	counterparty.UpdatedAt = timestamp
+
+	err = s.putRecord(ctx, counterpartyObjectType, mspID, counterparty)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, counterparty)
+}
+
+// GetCounterparty returns the registry entry of an organization
+func (s *SmartContract) GetCounterparty(ctx contractapi.TransactionContextInterface, mspID string) (*Counterparty, error) {
+	var counterparty Counterparty
+	exists, err := s.getRecord(ctx, counterpartyObjectType, mspID, &counterparty)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%s is not a registered counterparty", mspID)
+	}
+
+	return &counterparty, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// requireActiveCounterparty checks that the caller's organization is active in the registry. Each party of a direct trade
+// acts through its own call, so checking the caller on every call checks both. A channel that never registered a counterparty
+// has no registry, and everyone trades as before
+func (s *SmartContract) requireActiveCounterparty(ctx contractapi.TransactionContextInterface) error {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	var counterparty Counterparty
+	exists, err := s.getRecord(ctx, counterpartyObjectType, mspID, &counterparty)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if counterparty.Status != CounterpartyActive {
+			return fmt.Errorf("%s is %s and cannot trade", mspID, counterparty.Status)
+		}
+		return nil
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(counterpartyObjectType, []string{})
+	if err != nil {
+		return fmt.Errorf("failed to get counterparties: %v", err)
+	}
+	defer resultsIterator.Close()
+	if resultsIterator.HasNext() {
+		return fmt.Errorf("%s is not a registered counterparty", mspID)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	org1LEI = "5493001KJTIIGC8Y1R12"
+	org2LEI = "529900T8BM49AURSDO55"
+)
+
+func TestSuspendedCounterpartyCannotTrade(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpTrade(t, contract, 200000000)
+
+	_, err := contract.RegisterCounterparty(w.begin(org1), org1, org1LEI, "Org1 Securities")
+	require.NoError(t, err)
+	w.commit()
+
+	// With a registry, unregistered organizations cannot trade
+	_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+	require.EqualError(t, err, "Org2MSP is not a registered counterparty")
+
+	_, err = contract.RegisterCounterparty(w.begin(org1), org2, org2LEI, "Org2 Capital")
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.SuspendCounterparty(w.begin(org1), org1)
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.AnswerTradeAsOwner(w.begin(org1), "trade1", org2, "done", testTime, "")
+	require.EqualError(t, err, "Org1MSP is Suspended and cannot trade")
+	_, err = contract.CreateTrade(w.begin(org1), "trade2", org1, testCusip, "2023-01-09T12:00:00Z", tradeFace, tradePrice, false, "")
+	require.EqualError(t, err, "Org1MSP is Suspended and cannot trade")
+
+	// Registering again reinstates it
+	_, err = contract.RegisterCounterparty(w.begin(org1), org1, org1LEI, "Org1 Securities")
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.AnswerTradeAsOwner(w.begin(org1), "trade1", org2, "done", testTime, "")
+	require.NoError(t, err)
+	require.Contains(t, w.events(), chaincode.TradeSettledEvent)
+
+	counterparty, err := contract.GetCounterparty(w.begin(org2), org1)
+	require.NoError(t, err)
+	require.Equal(t, chaincode.CounterpartyActive, counterparty.Status)
+}
+
+func TestRegisterCounterpartyErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		mspID string
+		msp   string
+		lei   string
+		err   string
+	}{
+		{name: "not an admin", mspID: org2, msp: org2, lei: org2LEI, err: contractError(chaincode.ErrUnauthorized, "only Org1MSP can run this function")},
+		{name: "invalid LEI", mspID: org1, msp: org2, lei: "ABC", err: `invalid LEI "ABC": it must be 20 characters, 18 letters or digits then 2 check digits`},
+		{name: "no MSP ID", mspID: org1, lei: org2LEI, err: "a counterparty must have an MSP ID and a name"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract := &chaincode.SmartContract{}
+			w := setUp(t, contract)
+
+			_, err := contract.RegisterCounterparty(w.begin(test.mspID), test.msp, test.lei, "Org2 Capital")
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
@@ -0,0 +1,376 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// DecliningOffer is an owner's public ask for one of its bonds whose price falls on a schedule, as in a Dutch auction.
+// The price starts at the start price and drops by the price step at the end of every step interval until it reaches the floor.
+// The first buyer to accept it gets the whole bond at the price of the moment
+type DecliningOffer struct {
+	OfferID      string    `json:"offerID"`
+	UID          string    `json:"uid"`
+	Cusip        string    `json:"cusip"`
+	OriginalFace int64     `json:"originalFace"` // In cents
+	StartPrice   Price     `json:"startPrice"`
+	FloorPrice   Price     `json:"floorPrice"`
+	PriceStep    Price     `json:"priceStep"`   // Drop of the price at the end of each interval
+	StepMinutes  int       `json:"stepMinutes"` // Length of the interval
+	SellerHash   string    `json:"sellerHash"`
+	State        string    `json:"state"` //"Open", "Filled" or "Cancelled"
+	CreatedAt    time.Time `json:"createdAt"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	BuyerHash    string    `json:"buyerHash,omitempty"`
+	FilledPrice  Price     `json:"filledPrice,omitempty"`
+	FilledAt     time.Time `json:"filledAt,omitempty"`
+}
+
+// OfferPrice is the live price of a declining offer at a point in time
+type OfferPrice struct {
+	OfferID    string    `json:"offerID"`
+	Price      Price     `json:"price"`
+	AsOf       time.Time `json:"asOf"`
+	NextPrice  Price     `json:"nextPrice"`  // Price after the next drop. Equal to the price once it reached the floor
+	NextDropAt time.Time `json:"nextDropAt"` // Zero once the price reached the floor
+	AtFloor    bool      `json:"atFloor"`
+	Expired    bool      `json:"expired"`
+}
+
+const decliningOfferObjectType = "decliningoffer"
+
+// ⭐ Functions ⭐
+
+// CreateDecliningOffer posts a declining-price ask for the caller's bond with the given UID. The price starts at startPrice when
+// the offer is created and drops by priceStep every stepMinutes, down to floorPrice. The bond is held for the offer until it is
+// accepted or cancelled
+func (s *SmartContract) CreateDecliningOffer(ctx contractapi.TransactionContextInterface, offerID, uid, startPrice, floorPrice, priceStep string, stepMinutes int, createdAt, expiresAt time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	toUTC(&expiresAt)
+
+	start, err := s.parsePrice(ctx, startPrice)
+	if err != nil {
+		return nil, err
+	}
+	floor, err := s.parsePrice(ctx, floorPrice)
+	if err != nil {
+		return nil, err
+	}
+	step, err := s.parsePrice(ctx, priceStep)
+	if err != nil {
+		return nil, err
+	}
+	if floor.value() <= 0 {
+		return nil, fmt.Errorf("floor price must be positive: %s", floor)
+	}
+	if start.value() < floor.value() {
+		return nil, fmt.Errorf("start price %s is below the floor price %s", start, floor)
+	}
+	if step.value() <= 0 {
+		return nil, fmt.Errorf("price step must be positive: %s", step)
+	}
+	if stepMinutes <= 0 {
+		return nil, fmt.Errorf("step interval must be positive: %d minutes", stepMinutes)
+	}
+	if !expiresAt.After(createdAt) {
+		return nil, fmt.Errorf("offer must expire after it is created")
+	}
+
+	var existing DecliningOffer
+	exists, err := s.getRecord(ctx, decliningOfferObjectType, offerID, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, NewError(ErrAlreadyExists, "offer %s already exists", offerID)
+	}
+
+	ledger, err := s.getBondLedger(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	bondIndex := findBondByUID(ledger, uid)
+	if bondIndex == -1 {
+		return nil, NewError(ErrNotFound, "bond with UID %s not found", uid)
+	}
+	bond := &ledger.Bonds[bondIndex]
+	owner, err := s.callerOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !owner.owns(bond) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of the bond")
+	}
+	if bond.ReservedFor != "" {
+		return nil, NewConflictError(bond.UID, bond.Cusip, bond.ReservedFor)
+	}
+
+	// Hold the bond so no trade can consume it while it is offered
+	err = bond.reserve(offerID)
+	if err != nil {
+		return nil, err
+	}
+
+	offer := DecliningOffer{
+		OfferID:      offerID,
+		UID:          bond.UID,
+		Cusip:        bond.Cusip,
+		OriginalFace: bond.OriginalFace,
+		StartPrice:   start,
+		FloorPrice:   floor,
+		PriceStep:    step,
+		StepMinutes:  stepMinutes,
+		SellerHash:   owner.hash,
+		State:        "Open",
+		CreatedAt:    createdAt,
+		ExpiresAt:    expiresAt,
+	}
+
+	err = s.putRecord(ctx, decliningOfferObjectType, offerID, offer)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	err = s.recordOrderEvents(ctx, OrderEvent{Action: "Create", OrderType: "Declining", OrderID: offerID, Cusip: offer.Cusip, Face: offer.OriginalFace, Price: start})
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, offerID)
+}
+
+// GetCurrentOfferPrice returns the live price of a declining offer at the timestamp of the transaction, with the next drop
+func (s *SmartContract) GetCurrentOfferPrice(ctx contractapi.TransactionContextInterface, offerID string) (*OfferPrice, error) {
+	offer, err := s.getDecliningOffer(ctx, offerID)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.offerPrice(ctx, offer, now)
+}
+
+// AcceptOffer buys an open declining offer at its live price, priced at the timestamp of the transaction rather than a client time
+// so that buyers cannot pick their price. The bond is transferred and the transaction settled in the same write, and the offer is filled,
+// so only the first buyer to accept it wins
+func (s *SmartContract) AcceptOffer(ctx contractapi.TransactionContextInterface, offerID, buyerHash string) (*WriteResponse, error) {
+	if !s.IsOwner(ctx, buyerHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of %s", buyerHash)
+	}
+
+	offer, err := s.getDecliningOffer(ctx, offerID)
+	if err != nil {
+		return nil, err
+	}
+	if offer.State != "Open" {
+		return nil, fmt.Errorf("offer %s is %s", offerID, offer.State)
+	}
+	if buyerHash == offer.SellerHash {
+		return nil, fmt.Errorf("you cannot accept your own offer")
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.offerPrice(ctx, offer, now)
+	if err != nil {
+		return nil, err
+	}
+	if current.Expired {
+		return nil, fmt.Errorf("offer %s expired at %v", offerID, offer.ExpiresAt)
+	}
+
+	ledger, err := s.getCusipLedger(ctx, offer.Cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	bondIndex := findBondByUID(ledger, offer.UID)
+	if bondIndex == -1 || ledger.Bonds[bondIndex].ReservedFor != offerID {
+		return nil, fmt.Errorf("the bond of offer %s is no longer available", offerID)
+	}
+
+	// Update bond owner and free it
+	err = ledger.Bonds[bondIndex].deliver(s.ownerHashFor(ctx, buyerHash, ledger.Bonds[bondIndex].UID))
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitBondTransferred(ctx, ledger.Bonds[bondIndex], offer.SellerHash, offerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate transaction
+	executed := len(ledger.Transactions)
+	transaction := s.GenerateTransactionObject(buyerHash, offer.SellerHash, offer.Cusip, offer.OriginalFace, string(current.Price), now)
+	err = s.settleTransaction(ctx, ledger, transaction, current.Price.value())
+	if err != nil {
+		return nil, err
+	}
+
+	err = offerLifecycle.move(offerID, &offer.State, "Filled")
+	if err != nil {
+		return nil, err
+	}
+	offer.BuyerHash = buyerHash
+	offer.FilledPrice = current.Price
+	offer.FilledAt = now
+	err = s.putRecord(ctx, decliningOfferObjectType, offerID, offer)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	err = s.recordOrderEvents(ctx, executionEvents(ledger, executed, "Declining", offerID)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// CancelDecliningOffer withdraws an open declining offer if the caller is the seller, freeing the bond
+func (s *SmartContract) CancelDecliningOffer(ctx contractapi.TransactionContextInterface, offerID string) (*WriteResponse, error) {
+	offer, err := s.getDecliningOffer(ctx, offerID)
+	if err != nil {
+		return nil, err
+	}
+	if offer.State != "Open" {
+		return nil, fmt.Errorf("offer %s is %s", offerID, offer.State)
+	}
+	if !s.IsOwner(ctx, offer.SellerHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of the offer")
+	}
+
+	ledger, err := s.getCusipLedger(ctx, offer.Cusip)
+	if err != nil {
+		return nil, err
+	}
+	releaseReservations(ledger, offerID, "")
+
+	err = offerLifecycle.move(offerID, &offer.State, "Cancelled")
+	if err != nil {
+		return nil, err
+	}
+	err = s.putRecord(ctx, decliningOfferObjectType, offerID, offer)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	err = s.recordOrderEvents(ctx, OrderEvent{Action: "Cancel", OrderType: "Declining", OrderID: offerID, Cusip: offer.Cusip, Face: offer.OriginalFace})
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// GetDecliningOffers returns the open declining offers for a given cusip. Expired offers stay open until their seller cancels them
+func (s *SmartContract) GetDecliningOffers(ctx contractapi.TransactionContextInterface, cusip string) ([]DecliningOffer, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(decliningOfferObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get declining offers: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	offers := []DecliningOffer{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over declining offers: %v", err)
+		}
+
+		var offer DecliningOffer
+		err = json.Unmarshal(queryResponse.Value, &offer)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling declining offer JSON: %v", err)
+		}
+		if offer.Cusip == cusip && offer.State == "Open" {
+			offers = append(offers, offer)
+		}
+	}
+
+	return offers, nil
+}
+
+// ⭐ Helper functions ⭐
+
+func (s *SmartContract) getDecliningOffer(ctx contractapi.TransactionContextInterface, offerID string) (*DecliningOffer, error) {
+	var offer DecliningOffer
+	exists, err := s.getRecord(ctx, decliningOfferObjectType, offerID, &offer)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, NewError(ErrNotFound, "offer %s not found", offerID)
+	}
+
+	return &offer, nil
+}
+
+// offerPrice computes the price of a declining offer at a point in time from its schedule.
+// Before the offer is created its price is the start price
+func (s *SmartContract) offerPrice(ctx contractapi.TransactionContextInterface, offer *DecliningOffer, now time.Time) (*OfferPrice, error) {
+	precision, err := s.GetPricePrecision(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(offer.StepMinutes) * time.Minute
+	var steps int64
+	if now.After(offer.CreatedAt) {
+		steps = int64(now.Sub(offer.CreatedAt) / interval)
+	}
+
+	floor := offer.FloorPrice.value()
+	priceAt := func(steps int64) float64 {
+		price := offer.StartPrice.value() - float64(steps)*offer.PriceStep.value()
+		if price < floor {
+			return floor
+		}
+		return price
+	}
+
+	price := priceAt(steps)
+	current := &OfferPrice{
+		OfferID:   offer.OfferID,
+		Price:     formatPrice(price, precision.MaxDecimals),
+		AsOf:      now,
+		NextPrice: formatPrice(priceAt(steps+1), precision.MaxDecimals),
+		AtFloor:   price <= floor,
+		Expired:   !now.Before(offer.ExpiresAt),
+	}
+	if !current.AtFloor {
+		current.NextDropAt = offer.CreatedAt.Add(time.Duration(steps+1) * interval)
+	}
+
+	return current, nil
+}
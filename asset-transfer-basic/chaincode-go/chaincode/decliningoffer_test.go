@@ -0,0 +1,122 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+// setUpDecliningOffer returns a trade world where Org2 offers its bond from 101 down to 99 by half a point an hour
+func setUpDecliningOffer(t *testing.T, contract *chaincode.SmartContract) *world {
+	w := setUpTrade(t, contract, 200000000)
+	_, err := contract.CreateDecliningOffer(w.begin(org2), "dutch1", "uid1", "101", "99", "0.5", 60, testTime, testTime.Add(24*time.Hour))
+	require.NoError(t, err)
+	w.commit()
+	return w
+}
+
+func TestDecliningOfferPrice(t *testing.T) {
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		price   chaincode.Price
+		next    chaincode.Price
+		floor   bool
+	}{
+		{name: "at creation", price: "101", next: "100.5"},
+		{name: "within the first step", elapsed: 59 * time.Minute, price: "101", next: "100.5"},
+		{name: "after steps", elapsed: 150 * time.Minute, price: "100", next: "99.5"},
+		{name: "at the floor", elapsed: 10 * time.Hour, price: "99", next: "99", floor: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract := &chaincode.SmartContract{}
+			w := setUpDecliningOffer(t, contract)
+
+			current, err := contract.GetCurrentOfferPrice(w.beginAt(org1, testTime.Add(test.elapsed)), "dutch1")
+			require.NoError(t, err)
+			require.Equal(t, test.price, current.Price)
+			require.Equal(t, test.next, current.NextPrice)
+			require.Equal(t, test.floor, current.AtFloor)
+			require.Equal(t, test.floor, current.NextDropAt.IsZero())
+		})
+	}
+}
+
+func TestAcceptOffer(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUpDecliningOffer(t, contract)
+
+			_, err := contract.AcceptOffer(w.beginAt(org1, testTime.Add(150*time.Minute)), "dutch1", org1)
+			require.NoError(t, err)
+			w.commit()
+
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Equal(t, org1, bonds[0].OwnerHash)
+			require.Empty(t, bonds[0].ReservedFor)
+
+			transactions, err := contract.GetAllTransactions(w.begin(org1))
+			require.NoError(t, err)
+			require.Len(t, transactions, 1)
+			require.Equal(t, chaincode.Price("100"), transactions[0].BoughtPrice)
+
+			buyer, err := contract.GetCashBalance(w.begin(org1), org1)
+			require.NoError(t, err)
+			require.Equal(t, int64(100000000), buyer.Balance)
+
+			// The first buyer wins
+			_, err = contract.AcceptOffer(w.beginAt(org1, testTime.Add(3*time.Hour)), "dutch1", org1)
+			require.EqualError(t, err, "offer dutch1 is Filled")
+		})
+	}
+}
+
+func TestAcceptOfferErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		mspID     string
+		buyerHash string
+		elapsed   time.Duration
+		err       string
+	}{
+		{name: "own offer", mspID: org2, buyerHash: org2, err: "you cannot accept your own offer"},
+		{name: "for another organization", mspID: org1, buyerHash: org2, err: contractError(chaincode.ErrUnauthorized, "you are not the owner of "+org2)},
+		{name: "expired", mspID: org1, buyerHash: org1, elapsed: 24 * time.Hour, err: "offer dutch1 expired at 2023-01-10 12:00:00 +0000 UTC"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract := &chaincode.SmartContract{}
+			w := setUpDecliningOffer(t, contract)
+
+			_, err := contract.AcceptOffer(w.beginAt(test.mspID, testTime.Add(test.elapsed)), "dutch1", test.buyerHash)
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestCancelDecliningOffer(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpDecliningOffer(t, contract)
+
+	_, err := contract.CancelDecliningOffer(w.begin(org1), "dutch1")
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "you are not the owner of the offer"))
+
+	_, err = contract.CancelDecliningOffer(w.begin(org2), "dutch1")
+	require.NoError(t, err)
+	w.commit()
+
+	bonds, err := contract.GetAllBonds(w.begin(org1), false)
+	require.NoError(t, err)
+	require.Empty(t, bonds[0].ReservedFor)
+
+	offers, err := contract.GetDecliningOffers(w.begin(org1), testCusip)
+	require.NoError(t, err)
+	require.Empty(t, offers)
+}
@@ -0,0 +1,67 @@
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// BondEndorsementPolicy is the key-level endorsement policy of a bond. In the per-key layout a bond that is created
+// or changes hands can only be updated with the endorsement of its owner's organization
+type BondEndorsementPolicy struct {
+	UID       string   `json:"uid"`
+	OwnerHash string   `json:"ownerHash"`
+	Endorsers []string `json:"endorsers"` // MSP IDs whose peers must endorse. Empty while the chaincode endorsement policy applies
+}
+
+// ⭐ Functions ⭐
+
+// GetBondEndorsementPolicy returns the organizations that must endorse updates of the bond with the given UID
+func (s *SmartContract) GetBondEndorsementPolicy(ctx contractapi.TransactionContextInterface, uid string) (*BondEndorsementPolicy, error) {
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bond, err := stores.Bonds.GetBond(uid)
+	if err != nil {
+		return nil, err
+	}
+	if bond == nil {
+		return nil, NewError(ErrNotFound, "bond with UID %s not found", uid)
+	}
+
+	endorsers, err := stores.Bonds.GetBondEndorsers(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BondEndorsementPolicy{
+		UID:       uid,
+		OwnerHash: bond.OwnerHash,
+		Endorsers: endorsers,
+	}, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// ownerEndorsementPolicy returns a policy satisfied by a peer of the owner's organization. The party hash of an organization
+// is its MSP ID, the encryption key every organization is bootstrapped with
+func ownerEndorsementPolicy(ownerHash string) ([]byte, error) {
+	endorsementPolicy, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create endorsement policy: %v", err)
+	}
+	err = endorsementPolicy.AddOrgs(statebased.RoleTypePeer, ownerHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add %s to endorsement policy: %v", ownerHash, err)
+	}
+
+	policy, err := endorsementPolicy.Policy()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal endorsement policy: %v", err)
+	}
+	return policy, nil
+}
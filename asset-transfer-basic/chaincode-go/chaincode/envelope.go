@@ -0,0 +1,176 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ⭐ Data Structures ⭐
+
+// Envelope is what every function of the chaincode answers with. A successful call carries the function's return value in Data.
+// A failed call carries Error instead, as the JSON error message of the failed transaction, so that it still cannot commit
+type Envelope struct {
+	Data          json.RawMessage `json:"data"`
+	Error         *EnvelopeError  `json:"error"`
+	TxID          string          `json:"txId"`
+	SchemaVersion string          `json:"schemaVersion"`
+}
+
+// EnvelopeError is the error of a failed call. Code is the prefix of coded errors like ConflictError, or ERROR for the others
+type EnvelopeError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// EnvelopeChaincode serves a contract chaincode with every response wrapped in an Envelope
+type EnvelopeChaincode struct {
+	contract *contractapi.ContractChaincode
+}
+
+// EnvelopeSchemaVersion is the version of the layout of the data of the envelopes.
+// It changes whenever a function's return value changes shape
+const EnvelopeSchemaVersion = "3"
+
+const genericErrorCode = "ERROR"
+
+// Coded errors have messages like "CONFLICT: {...}"
+var codedErrorPattern = regexp.MustCompile(`^([A-Z_]+): (.*)$`)
+
+// ⭐ Functions ⭐
+
+// NewEnvelopeChaincode creates the contract chaincode of the given contracts and wraps it
+func NewEnvelopeChaincode(contracts ...contractapi.ContractInterface) (*EnvelopeChaincode, error) {
+	contract, err := contractapi.NewChaincode(contracts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EnvelopeChaincode{contract: contract}, nil
+}
+
+// Init handles the instantiation of the chaincode like the contract chaincode does, and wraps the response
+func (c *EnvelopeChaincode) Init(stub shim.ChaincodeStubInterface) peer.Response {
+	return envelop(stub, c.contract.Init(stub))
+}
+
+// Invoke calls the requested function of the contract chaincode and wraps the response.
+// The events the function raised are set once it succeeded
+func (c *EnvelopeChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	events := &eventStub{ChaincodeStubInterface: stub}
+	response := c.contract.Invoke(events)
+	if response.Status < shim.ERRORTHRESHOLD {
+		err := events.flush()
+		if err != nil {
+			response = shim.Error(fmt.Sprintf("failed to set events: %v", err))
+		}
+	}
+
+	return envelop(stub, response)
+}
+
+// Start starts the chaincode in the fabric shim. Like the contract chaincode, it runs as a chaincode server
+// when CHAINCODE_SERVER_ADDRESS and CORE_CHAINCODE_ID_NAME are set
+func (c *EnvelopeChaincode) Start() error {
+	address := os.Getenv("CHAINCODE_SERVER_ADDRESS")
+	ccid := os.Getenv("CORE_CHAINCODE_ID_NAME")
+	if address == "" || ccid == "" {
+		return shim.Start(c)
+	}
+
+	tlsProps, err := loadTLSProperties()
+	if err != nil {
+		return err
+	}
+	server := &shim.ChaincodeServer{
+		CCID:     ccid,
+		Address:  address,
+		CC:       c,
+		TLSProps: *tlsProps,
+	}
+
+	return server.Start()
+}
+
+// ⭐ Helper functions ⭐
+
+// envelop wraps the payload of a successful response, or the message of a failed one, in an Envelope.
+// Return values that are not JSON, such as plain strings, are carried as JSON strings
+func envelop(stub shim.ChaincodeStubInterface, response peer.Response) peer.Response {
+	envelope := Envelope{
+		TxID:          stub.GetTxID(),
+		SchemaVersion: EnvelopeSchemaVersion,
+	}
+
+	if response.Status >= shim.ERRORTHRESHOLD {
+		envelope.Error = &EnvelopeError{Code: genericErrorCode, Message: response.Message}
+		if match := codedErrorPattern.FindStringSubmatch(response.Message); match != nil {
+			envelope.Error = &EnvelopeError{Code: match[1], Message: match[2]}
+		}
+
+		envelopeJSON, err := json.Marshal(envelope)
+		if err != nil {
+			return response
+		}
+		return shim.Error(string(envelopeJSON))
+	}
+
+	switch {
+	case len(response.Payload) == 0:
+		envelope.Data = json.RawMessage("null")
+	case json.Valid(response.Payload):
+		envelope.Data = response.Payload
+	default:
+		data, err := json.Marshal(string(response.Payload))
+		if err != nil {
+			return shim.Error(fmt.Sprintf("failed to marshal response data: %v", err))
+		}
+		envelope.Data = data
+	}
+
+	envelopeJSON, err := json.Marshal(envelope)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("failed to marshal response envelope: %v", err))
+	}
+
+	return shim.Success(envelopeJSON)
+}
+
+// loadTLSProperties reads the TLS material of the chaincode server from the same variables as the contract chaincode
+func loadTLSProperties() (*shim.TLSProperties, error) {
+	tlsEnabled, _ := strconv.ParseBool(os.Getenv("CORE_PEER_TLS_ENABLED"))
+	if !tlsEnabled {
+		return &shim.TLSProperties{Disabled: true}, nil
+	}
+
+	keyBytes, err := ioutil.ReadFile(os.Getenv("CORE_TLS_CLIENT_KEY_FILE"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the TLS key: %v", err)
+	}
+	certBytes, err := ioutil.ReadFile(os.Getenv("CORE_TLS_CLIENT_CERT_FILE"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the TLS certificate: %v", err)
+	}
+
+	var rootBytes []byte
+	if root := os.Getenv("CORE_PEER_TLS_ROOTCERT_FILE"); root != "" {
+		rootBytes, err = ioutil.ReadFile(root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the TLS root certificate: %v", err)
+		}
+	}
+
+	return &shim.TLSProperties{
+		Disabled:      false,
+		Key:           keyBytes,
+		Cert:          certBytes,
+		ClientCACerts: rootBytes,
+	}, nil
+}
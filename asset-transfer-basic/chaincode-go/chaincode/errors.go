@@ -0,0 +1,206 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ErrorCategory is a class of errors clients can branch on instead of matching messages. Every error of a category reports
+// its Code as the prefix of its message and in its JSON details, along with GRPCCode, the matching gRPC status code.
+// Test an error against a category with errors.Is
+type ErrorCategory struct {
+	Code     string
+	GRPCCode int
+}
+
+// Error categories
+var (
+	ErrNotFound      = &ErrorCategory{Code: "NOT_FOUND", GRPCCode: 5}
+	ErrAlreadyExists = &ErrorCategory{Code: "ALREADY_EXISTS", GRPCCode: 6}
+	ErrUnauthorized  = &ErrorCategory{Code: "UNAUTHORIZED", GRPCCode: 7}  // gRPC PERMISSION_DENIED
+	ErrInvalidInput  = &ErrorCategory{Code: "INVALID_INPUT", GRPCCode: 3} // gRPC INVALID_ARGUMENT
+	ErrConflict      = &ErrorCategory{Code: "CONFLICT", GRPCCode: 10}     // gRPC ABORTED
+)
+
+func (c *ErrorCategory) Error() string {
+	return c.Code
+}
+
+// ContractError is an error of a category with a readable message.
+// Its message is prefixed with the code of the category and carries the details as JSON so clients can parse it.
+type ContractError struct {
+	Code     string `json:"code"`
+	GRPCCode int    `json:"grpcCode"`
+	Message  string `json:"message"`
+	category *ErrorCategory
+}
+
+// NewError creates a ContractError of the category, with a message formatted like fmt.Errorf does
+func NewError(category *ErrorCategory, format string, args ...interface{}) *ContractError {
+	return &ContractError{
+		Code:     category.Code,
+		GRPCCode: category.GRPCCode,
+		Message:  fmt.Sprintf(format, args...),
+		category: category,
+	}
+}
+
+func (e *ContractError) Error() string {
+	details, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Sprintf("%s: %s", e.Code, e.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", e.Code, details)
+}
+
+// Is reports whether the error belongs to the category
+func (e *ContractError) Is(target error) bool {
+	return target == e.category
+}
+
+// ConflictError reports that a position is already committed to another pending trade.
+// Its message is prefixed with CONFLICT and carries the details as JSON so clients can parse it.
+type ConflictError struct {
+	Code            string `json:"code"`
+	GRPCCode        int    `json:"grpcCode"`
+	UID             string `json:"uid"`
+	Cusip           string `json:"cusip"`
+	BlockingTradeID string `json:"blockingTradeID"`
+}
+
+// NewConflictError creates a ConflictError for the bond held by the blocking trade
+func NewConflictError(uid, cusip, blockingTradeID string) *ConflictError {
+	return &ConflictError{
+		Code:            ErrConflict.Code,
+		GRPCCode:        ErrConflict.GRPCCode,
+		UID:             uid,
+		Cusip:           cusip,
+		BlockingTradeID: blockingTradeID,
+	}
+}
+
+func (e *ConflictError) Error() string {
+	details, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Sprintf("CONFLICT: bond %s is reserved by pending trade %s", e.UID, e.BlockingTradeID)
+	}
+
+	return fmt.Sprintf("CONFLICT: %s", details)
+}
+
+// Is reports whether the category is ErrConflict
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// DuplicateInventoryError reports that a bond is added to an inventory that already holds its CUSIP.
+// Its message is prefixed with DUPLICATE and carries the item already there as JSON so clients can parse it.
+type DuplicateInventoryError struct {
+	Code     string        `json:"code"`
+	GRPCCode int           `json:"grpcCode"`
+	Cusip    string        `json:"cusip"`
+	Metadata AssetMetadata `json:"metadata"` // Metadata of the item already in the inventory
+	Status   string        `json:"status"`   // Listing status of the item already in the inventory
+}
+
+// NewDuplicateInventoryError creates a DuplicateInventoryError for the item already in the inventory
+func NewDuplicateInventoryError(cusip string, metadata AssetMetadata, status string) *DuplicateInventoryError {
+	return &DuplicateInventoryError{
+		Code:     "DUPLICATE",
+		GRPCCode: ErrAlreadyExists.GRPCCode,
+		Cusip:    cusip,
+		Metadata: metadata,
+		Status:   status,
+	}
+}
+
+func (e *DuplicateInventoryError) Error() string {
+	details, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Sprintf("DUPLICATE: the bond with Cusip %s is already in the inventory", e.Cusip)
+	}
+
+	return fmt.Sprintf("DUPLICATE: %s", details)
+}
+
+// Is reports whether the category is ErrAlreadyExists, which a duplicate inventory item belongs to
+func (e *DuplicateInventoryError) Is(target error) bool {
+	return target == ErrAlreadyExists
+}
+
+// ValidationError reports that a bond passed as JSON breaks one or more field rules, so that clients can tell bad input
+// from ledger errors. Its message is prefixed with VALIDATION and carries every violation as JSON so clients can parse it.
+type ValidationError struct {
+	Code       string           `json:"code"`
+	GRPCCode   int              `json:"grpcCode"`
+	Violations []FieldViolation `json:"violations"`
+}
+
+// FieldViolation is one broken rule: the JSON field, a machine-readable code such as INVALID_CUSIP, and a readable reason
+type FieldViolation struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewValidationError creates a ValidationError for the violations found
+func NewValidationError(violations []FieldViolation) *ValidationError {
+	return &ValidationError{
+		Code:       "VALIDATION",
+		GRPCCode:   ErrInvalidInput.GRPCCode,
+		Violations: violations,
+	}
+}
+
+func (e *ValidationError) Error() string {
+	details, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Sprintf("VALIDATION: the bond has %d invalid fields", len(e.Violations))
+	}
+
+	return fmt.Sprintf("VALIDATION: %s", details)
+}
+
+// Is reports whether the category is ErrInvalidInput, which a bond breaking field rules belongs to
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidInput
+}
+
+// TransitionError is returned when a record cannot move from its state to another, with the reason
+type TransitionError struct {
+	Code     string `json:"code"`
+	GRPCCode int    `json:"grpcCode"`
+	Record   string `json:"record"` // "bond", "direct trade" or "offer"
+	ID       string `json:"id"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Reason   string `json:"reason"`
+}
+
+// NewTransitionError creates a TransitionError for a record that cannot move from one state to another
+func NewTransitionError(record, id, from, to, reason string) *TransitionError {
+	return &TransitionError{
+		Code:     "INVALID_TRANSITION",
+		GRPCCode: ErrConflict.GRPCCode,
+		Record:   record,
+		ID:       id,
+		From:     from,
+		To:       to,
+		Reason:   reason,
+	}
+}
+
+func (e *TransitionError) Error() string {
+	details, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Sprintf("INVALID_TRANSITION: %s %s cannot move from %s to %s", e.Record, e.ID, e.From, e.To)
+	}
+
+	return fmt.Sprintf("INVALID_TRANSITION: %s", details)
+}
+
+// Is reports whether the category is ErrConflict, which a transition the state of the record does not allow belongs to
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrConflict
+}
@@ -0,0 +1,33 @@
+package chaincode_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+// contractError returns the message of an error of a category
+func contractError(category *chaincode.ErrorCategory, message string) string {
+	return chaincode.NewError(category, "%s", message).Error()
+}
+
+func TestErrorCategories(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpTrade(t, contract, 200000000)
+
+	_, err := contract.CloseDirectTrade(w.begin(org1), "trade2")
+	require.True(t, errors.Is(err, chaincode.ErrNotFound))
+	require.False(t, errors.Is(err, chaincode.ErrUnauthorized))
+	require.EqualError(t, err, `NOT_FOUND: {"code":"NOT_FOUND","grpcCode":5,"message":"direct trade not found"}`)
+
+	_, err = contract.UpdateConfig(w.begin(org2), `{"allowedCouponTypes":["FIXED"],"settlementCurrency":"USD","adminMSPs":["Org2MSP"]}`)
+	require.True(t, errors.Is(err, chaincode.ErrUnauthorized))
+	var contractErr *chaincode.ContractError
+	require.True(t, errors.As(err, &contractErr))
+	require.Equal(t, 7, contractErr.GRPCCode)
+	require.Equal(t, "only Org1MSP can run this function", contractErr.Message)
+
+	require.True(t, errors.Is(chaincode.NewConflictError("uid1", testCusip, "trade1"), chaincode.ErrConflict))
+}
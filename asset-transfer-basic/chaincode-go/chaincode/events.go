@@ -0,0 +1,173 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// TradeEvent is the payload of the events raised as a direct trade goes from creation to settlement
+type TradeEvent struct {
+	DirectTradeID string `json:"directTradeID"`
+	Reference     string `json:"reference,omitempty"`
+	Cusip         string `json:"cusip"`
+	BidderHash    string `json:"bidderHash"`
+	SellerHash    string `json:"sellerHash,omitempty"` // The seller who answered, or who sold into the trade
+	Face          int64  `json:"face"`                 // In cents
+	Price         Price  `json:"price,omitempty"`      // A spread in basis points for trades bid over a benchmark
+	Answer        string `json:"answer,omitempty"`     // The answer given, for TradeAnswered
+}
+
+// BondTransferEvent is the payload of BondTransferred, raised whenever a public bond changes hands
+type BondTransferEvent struct {
+	UID      string `json:"uid"`
+	Cusip    string `json:"cusip"`
+	FromHash string `json:"fromHash"`
+	ToHash   string `json:"toHash"`
+	Face     int64  `json:"face"`    // In cents
+	OrderID  string `json:"orderID"` // The trade, offer, RFM, loan or repo the bond moved for. Empty for a TransferBond
+}
+
+// BondMaturityEvent is the payload of BondMatured, raised when ProcessMaturities finds a bond has paid off
+type BondMaturityEvent struct {
+	UID             string   `json:"uid"`
+	Cusip           string   `json:"cusip"`
+	OwnerHash       string   `json:"ownerHash"`
+	Face            int64    `json:"face"`            // In cents
+	MaturedOn       string   `json:"maturedOn"`       // YYYY-MM-DD
+	CancelledOrders []string `json:"cancelledOrders"` // The direct trades and offers cancelled because they referenced the bond
+}
+
+// RepoEvent is the payload of the events raised as a repo goes from proposal to close or default
+type RepoEvent struct {
+	RepoID     string  `json:"repoID"`
+	UID        string  `json:"uid"`
+	Cusip      string  `json:"cusip"`
+	SellerHash string  `json:"sellerHash"`
+	BuyerHash  string  `json:"buyerHash"`
+	Face       int64   `json:"face"` // In cents
+	CashAmount float64 `json:"cashAmount"`
+	Interest   float64 `json:"interest,omitempty"` // Repo interest paid, for RepoClosed
+}
+
+// ChaincodeEvent is one of the events of a transaction that raised several, as carried by an EventBatch
+type ChaincodeEvent struct {
+	EventName string          `json:"eventName"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// Event names
+const (
+	TradeCreatedEvent           = "TradeCreated"
+	TradeAnsweredEvent          = "TradeAnswered"
+	TradeClosedEvent            = "TradeClosed"
+	TradeExpiredEvent           = "TradeExpired"
+	TradeSettledEvent           = "TradeSettled"
+	TradeAgreedEvent            = "TradeAgreed"
+	TradeMatchedEvent           = "TradeMatched"
+	SettlementMismatchedEvent   = "SettlementMismatched"
+	SettlementPacketSharedEvent = "SettlementPacketShared"
+	TradeAmendmentProposedEvent = "TradeAmendmentProposed"
+	TradeAmendedEvent           = "TradeAmended"
+	TradeAmendmentRejectedEvent = "TradeAmendmentRejected"
+	BondTransferredEvent        = "BondTransferred"
+	BondMaturedEvent            = "BondMatured"
+	RepoProposedEvent           = "RepoProposed"
+	RepoOpenedEvent             = "RepoOpened"
+	RepoClosedEvent             = "RepoClosed"
+	RepoCancelledEvent          = "RepoCancelled"
+	RepoDefaultedEvent          = "RepoDefaulted"
+
+	// A transaction can only set one chaincode event. One that raised several sets an EventBatch instead,
+	// with the events in the order they were raised
+	EventBatchEvent = "EventBatch"
+)
+
+// eventStub collects the events a transaction raises, so that they can all be set once it succeeded
+type eventStub struct {
+	shim.ChaincodeStubInterface
+	events []ChaincodeEvent
+}
+
+// ⭐ Helper functions ⭐
+
+// SetEvent records an event to be set when the transaction succeeds
+func (e *eventStub) SetEvent(name string, payload []byte) error {
+	if name == "" {
+		return fmt.Errorf("event name can not be empty string")
+	}
+
+	e.events = append(e.events, ChaincodeEvent{EventName: name, Payload: payload})
+	return nil
+}
+
+// flush sets the recorded events on the transaction: the only one as is, or all of them in an EventBatch
+func (e *eventStub) flush() error {
+	switch len(e.events) {
+	case 0:
+		return nil
+	case 1:
+		return e.ChaincodeStubInterface.SetEvent(e.events[0].EventName, e.events[0].Payload)
+	}
+
+	batchJSON, err := json.Marshal(e.events)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event batch: %v", err)
+	}
+
+	return e.ChaincodeStubInterface.SetEvent(EventBatchEvent, batchJSON)
+}
+
+// emitEvent raises a chaincode event with a JSON payload
+func emitEvent(ctx contractapi.TransactionContextInterface, name string, payload interface{}) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %v", name, err)
+	}
+
+	return ctx.GetStub().SetEvent(name, payloadJSON)
+}
+
+// emitTradeEvent raises one of the trade lifecycle events for a direct trade
+func emitTradeEvent(ctx contractapi.TransactionContextInterface, name string, trade *DirectTrade, sellerHash string, price Price, answer string) error {
+	return emitEvent(ctx, name, TradeEvent{
+		DirectTradeID: trade.DirectTradeID,
+		Reference:     trade.Reference,
+		Cusip:         trade.Cusip,
+		BidderHash:    trade.BidderHash,
+		SellerHash:    sellerHash,
+		Face:          trade.OriginalFace,
+		Price:         price,
+		Answer:        answer,
+	})
+}
+
+// emitBondTransferred raises BondTransferred for a bond that moved from one owner to another
+func emitBondTransferred(ctx contractapi.TransactionContextInterface, bond AgencyMBSPassthrough, fromHash, orderID string) error {
+	return emitEvent(ctx, BondTransferredEvent, BondTransferEvent{
+		UID:      bond.UID,
+		Cusip:    bond.Cusip,
+		FromHash: fromHash,
+		ToHash:   bond.OwnerHash,
+		Face:     bond.OriginalFace,
+		OrderID:  orderID,
+	})
+}
+
+// emitRepoEvent raises one of the repo lifecycle events
+func emitRepoEvent(ctx contractapi.TransactionContextInterface, name string, repo *Repo) error {
+	return emitEvent(ctx, name, RepoEvent{
+		RepoID:     repo.RepoID,
+		UID:        repo.UID,
+		Cusip:      repo.Cusip,
+		SellerHash: repo.SellerHash,
+		BuyerHash:  repo.BuyerHash,
+		Face:       repo.OriginalFace,
+		CashAmount: repo.CashAmount,
+		Interest:   repo.Interest,
+	})
+}
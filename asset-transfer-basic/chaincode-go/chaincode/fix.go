@@ -0,0 +1,165 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// fixField is one tag=value pair of a FIX message
+type fixField struct {
+	tag   int
+	value string
+}
+
+const (
+	fixBeginString = "FIX.4.4"
+	// Sender of the exported messages. The caller's MSP ID is the target
+	fixSenderCompID = "BONDLEDGER"
+	fixDelimiter    = "\x01"
+
+	fixTimestampLayout = "20060102-15:04:05.000"
+	fixDateLayout      = "20060102"
+)
+
+// ⭐ Functions ⭐
+
+// ExportTransactionsFIX returns the caller's settled trades between fromDate and toDate (YYYY-MM-DD), both included,
+// as FIX 4.4 ExecutionReport (35=8) messages, one fill each, in timestamp order. Fields are separated by SOH as on the wire.
+// The CUSIP is the security ID (48, with 22=1), quantities are original face in dollars and prices are percent of par.
+// The counterparty is the contra firm (452=17). Bonds given away with TransferBond are not fills and are left out
+func (s *SmartContract) ExportTransactionsFIX(ctx contractapi.TransactionContextInterface, fromDate, toDate string) ([]string, error) {
+	from, to, err := parseDateRange(fromDate, toDate)
+	if err != nil {
+		return nil, err
+	}
+
+	partyHash, err := s.GenerateOrgHash(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate owner hash: %v", err)
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	sendingTime, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	currency, err := s.settlementCurrency(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	transactions, _, err := stores.Trades.QueryTransactions(transactionPartyIndexType, partyHash, from, to, 0, "")
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []string{}
+	for _, transaction := range transactions {
+		if transaction.Type == "Transfer" {
+			continue
+		}
+		message, err := fixExecutionReport(transaction, partyHash, mspID, currency, len(messages)+1, sendingTime)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// fixExecutionReport renders a transaction priced in currency as a filled ExecutionReport from the side of partyHash, with the given sequence number
+func fixExecutionReport(transaction Transaction, partyHash, targetCompID, currency string, sequence int, sendingTime time.Time) (string, error) {
+	execID, err := transactionID(transaction)
+	if err != nil {
+		return "", err
+	}
+
+	side := "1" // Buy
+	counterparty := transaction.SellerID
+	if transaction.BuyerID != partyHash {
+		side = "2" // Sell
+		counterparty = transaction.BuyerID
+	}
+	quantity := strconv.FormatFloat(dollars(transaction.OriginalFace), 'f', -1, 64)
+	price := string(transaction.BoughtPrice)
+	tradeTime := transaction.Timestamp.UTC()
+
+	fields := []fixField{
+		{35, "8"},
+		{49, fixSenderCompID},
+		{56, targetCompID},
+		{34, strconv.Itoa(sequence)},
+		{52, sendingTime.UTC().Format(fixTimestampLayout)},
+		{37, "NONE"}, // Trades on the ledger have no order ID of the OMS
+		{17, execID},
+		{150, "F"}, // Trade
+		{39, "2"},  // Filled
+		{55, transaction.Cusip},
+		{48, transaction.Cusip},
+		{22, "1"}, // CUSIP
+		{54, side},
+		{453, "1"},
+		{448, counterparty},
+		{447, "D"},  // Proprietary/custom code
+		{452, "17"}, // Contra firm
+		{38, quantity},
+		{15, currency},
+		{32, quantity},
+		{31, price},
+		{423, "1"}, // Percentage of par
+		{151, "0"},
+		{14, quantity},
+		{6, price},
+		{75, tradeTime.Format(fixDateLayout)},
+		{60, tradeTime.Format(fixTimestampLayout)},
+	}
+
+	return fixMessage(fields), nil
+}
+
+// fixMessage frames the fields of a message with the begin string, the body length and the checksum
+func fixMessage(fields []fixField) string {
+	var body strings.Builder
+	for _, field := range fields {
+		body.WriteString(strconv.Itoa(field.tag) + "=" + field.value + fixDelimiter)
+	}
+
+	message := "8=" + fixBeginString + fixDelimiter + "9=" + strconv.Itoa(body.Len()) + fixDelimiter + body.String()
+	checksum := 0
+	for i := 0; i < len(message); i++ {
+		checksum += int(message[i])
+	}
+
+	return message + fmt.Sprintf("10=%03d", checksum%256) + fixDelimiter
+}
+
+// transactionID derives a stable ID from the content of a transaction, which has no ID of its own. It is the ExecID of
+// the exported fill, so that exporting the same fill again gives the same ExecID and the OMS can drop the duplicate,
+// and the ID allocations refer to the transaction by
+func transactionID(transaction Transaction) (string, error) {
+	transactionJSON, err := json.Marshal(transaction)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal transaction: %v", err)
+	}
+
+	hash := sha256.Sum256(transactionJSON)
+	return strings.ToUpper(hex.EncodeToString(hash[:10])), nil
+}
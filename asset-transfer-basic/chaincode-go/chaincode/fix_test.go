@@ -0,0 +1,71 @@
+package chaincode_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+// fixFields splits a FIX message into its fields after checking its body length and checksum
+func fixFields(t *testing.T, message string) map[string]string {
+	require.True(t, strings.HasSuffix(message, "\x01"))
+	checksumAt := strings.LastIndex(message, "10=")
+	sum := 0
+	for i := 0; i < checksumAt; i++ {
+		sum += int(message[i])
+	}
+	require.Equal(t, fmt.Sprintf("10=%03d\x01", sum%256), message[checksumAt:])
+
+	fields := map[string]string{}
+	for _, field := range strings.Split(strings.TrimSuffix(message, "\x01"), "\x01") {
+		tagValue := strings.SplitN(field, "=", 2)
+		require.Len(t, tagValue, 2)
+		fields[tagValue[0]] = tagValue[1]
+	}
+
+	bodyStart := strings.Index(message, "\x0135=") + 1
+	require.Equal(t, fmt.Sprint(checksumAt-bodyStart), fields["9"])
+	return fields
+}
+
+func TestExportTransactionsFIX(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUp(t, contract)
+			recordTransactions(t, w, contract, testCusip, [][2]string{{org1, org2}, {org2, org1}, {org2, "Org3MSP"}})
+
+			messages, err := contract.ExportTransactionsFIX(w.begin(org1), "2023-01-01", "2023-01-31")
+			require.NoError(t, err)
+			require.Len(t, messages, 2)
+
+			buy := fixFields(t, messages[0])
+			require.Equal(t, "FIX.4.4", buy["8"])
+			require.Equal(t, "8", buy["35"])
+			require.Equal(t, "Org1MSP", buy["56"])
+			require.Equal(t, "1", buy["34"])
+			require.Equal(t, testCusip, buy["48"])
+			require.Equal(t, "1", buy["22"])
+			require.Equal(t, "1", buy["54"])
+			require.Equal(t, org2, buy["448"])
+			require.Equal(t, "1000000", buy["32"])
+			require.Equal(t, tradePrice, buy["31"])
+			require.Equal(t, "20230101", buy["75"])
+			require.Equal(t, "20230101-15:00:00.000", buy["60"])
+
+			sell := fixFields(t, messages[1])
+			require.Equal(t, "2", sell["34"])
+			require.Equal(t, "2", sell["54"])
+			require.Equal(t, "20230102", sell["75"])
+			require.NotEqual(t, buy["17"], sell["17"])
+
+			again, err := contract.ExportTransactionsFIX(w.begin(org1), "2023-01-02", "")
+			require.NoError(t, err)
+			require.Len(t, again, 1)
+			require.Equal(t, sell["17"], fixFields(t, again[0])["17"])
+		})
+	}
+}
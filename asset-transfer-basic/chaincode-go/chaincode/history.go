@@ -0,0 +1,203 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// BondHistoryEntry is one change to a bond: the transaction that made it, when it was committed, and the bond it left behind
+type BondHistoryEntry struct {
+	UID       string                `json:"uid"`
+	TxID      string                `json:"txID"`
+	Timestamp time.Time             `json:"timestamp"`
+	IsDelete  bool                  `json:"isDelete"`
+	Value     *AgencyMBSPassthrough `json:"value,omitempty"` // Nil when the transaction deleted the bond
+}
+
+// ⭐ Functions ⭐
+
+// GetBondHistory returns every change committed to the bonds of a cusip, oldest first, so that auditors can follow how
+// their owners, factors and coupons changed. Changes made while the ledger was a single key are read from the history
+// of that key, later ones from the history of each bond's own key
+func (s *SmartContract) GetBondHistory(ctx contractapi.TransactionContextInterface, cusip string) ([]BondHistoryEntry, error) {
+	return s.bondHistory(ctx, cusip)
+}
+
+// GetBondAsOf returns the bonds of a cusip as they were on the ledger at the given time, rebuilt from their history.
+// It lets disputes about what a buyer saw at trade time be settled from the ledger itself
+func (s *SmartContract) GetBondAsOf(ctx contractapi.TransactionContextInterface, cusip string, timestamp time.Time) ([]AgencyMBSPassthrough, error) {
+	history, err := s.bondHistory(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replay the changes up to the time, keeping the order the bonds first appeared in
+	latest := map[string]*AgencyMBSPassthrough{}
+	uids := []string{}
+	for _, entry := range history {
+		if entry.Timestamp.After(timestamp) {
+			break
+		}
+		if _, ok := latest[entry.UID]; !ok {
+			uids = append(uids, entry.UID)
+		}
+		latest[entry.UID] = entry.Value
+	}
+
+	bonds := []AgencyMBSPassthrough{}
+	for _, uid := range uids {
+		if latest[uid] != nil {
+			bonds = append(bonds, *latest[uid])
+		}
+	}
+
+	return bonds, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// ledgerVersion is one value the legacy ledger key had
+type ledgerVersion struct {
+	txID      string
+	timestamp time.Time
+	isDelete  bool
+	value     []byte
+}
+
+// bondHistory returns the changes to the bonds of a cusip in commit order
+func (s *SmartContract) bondHistory(ctx contractapi.TransactionContextInterface, cusip string) ([]BondHistoryEntry, error) {
+	history, err := s.legacyBondHistory(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bonds stored under their own keys stay in the index of their cusip after they are deleted
+	perKey := &perKeyStore{ctx: ctx}
+	uids, err := perKey.indexedIDs(bondCusipIndexType, cusip)
+	if err != nil {
+		return nil, err
+	}
+	for _, uid := range uids {
+		key, err := ctx.GetStub().CreateCompositeKey(bondKeyType, []string{uid})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s key: %v", bondKeyType, err)
+		}
+		resultsIterator, err := ctx.GetStub().GetHistoryForKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get history of bond %s: %v", uid, err)
+		}
+
+		for resultsIterator.HasNext() {
+			modification, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				return nil, fmt.Errorf("error iterating over history of bond %s: %v", uid, err)
+			}
+
+			entry := BondHistoryEntry{
+				UID:       uid,
+				TxID:      modification.TxId,
+				Timestamp: protoTime(modification.Timestamp),
+				IsDelete:  modification.IsDelete,
+			}
+			if !modification.IsDelete {
+				var bond AgencyMBSPassthrough
+				err = json.Unmarshal(modification.Value, &bond)
+				if err != nil {
+					resultsIterator.Close()
+					return nil, fmt.Errorf("failed to unmarshal bond %s: %v", uid, err)
+				}
+				entry.Value = &bond
+			}
+			history = append(history, entry)
+		}
+		resultsIterator.Close()
+	}
+
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Timestamp.Before(history[j].Timestamp)
+	})
+
+	return history, nil
+}
+
+// legacyBondHistory returns the changes to the bonds of a cusip made while the public ledger was a single key.
+// Each version of the key is compared with the one before, so that only the bonds a transaction changed are reported.
+// The history is not assumed to be in any order
+func (s *SmartContract) legacyBondHistory(ctx contractapi.TransactionContextInterface, cusip string) ([]BondHistoryEntry, error) {
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey("ledger")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ledger history: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	versions := []ledgerVersion{}
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over ledger history: %v", err)
+		}
+		versions = append(versions, ledgerVersion{
+			txID:      modification.TxId,
+			timestamp: protoTime(modification.Timestamp),
+			isDelete:  modification.IsDelete,
+			value:     modification.Value,
+		})
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].timestamp.Before(versions[j].timestamp)
+	})
+
+	history := []BondHistoryEntry{}
+	previous := map[string][]byte{}
+	previousUIDs := []string{}
+	for _, version := range versions {
+		// The key is only deleted when the ledger moves to the per-key layout, where the history of the bonds goes on
+		if version.isDelete {
+			break
+		}
+
+		var ledger Ledger
+		err = json.Unmarshal(version.value, &ledger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal ledger: %v", err)
+		}
+
+		current := map[string][]byte{}
+		currentUIDs := []string{}
+		for _, bond := range ledger.Bonds {
+			if bond.Cusip != cusip {
+				continue
+			}
+			bondBytes, err := json.Marshal(bond)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal bond: %v", err)
+			}
+			current[bond.UID] = bondBytes
+			currentUIDs = append(currentUIDs, bond.UID)
+
+			if bytes.Equal(previous[bond.UID], bondBytes) {
+				continue
+			}
+			changed := bond
+			history = append(history, BondHistoryEntry{UID: bond.UID, TxID: version.txID, Timestamp: version.timestamp, Value: &changed})
+		}
+		for _, uid := range previousUIDs {
+			if _, ok := current[uid]; !ok {
+				history = append(history, BondHistoryEntry{UID: uid, TxID: version.txID, Timestamp: version.timestamp, IsDelete: true})
+			}
+		}
+
+		previous = current
+		previousUIDs = currentUIDs
+	}
+
+	return history, nil
+}
@@ -3,11 +3,13 @@ package chaincode
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // ⭐ Data Structures ⭐
@@ -15,41 +17,111 @@ import (
 // SmartContract provides functions for managing an Asset
 type SmartContract struct {
 	contractapi.Contract
+	StorageLayout string // LegacyBlobLayout or PerKeyLayout. Empty means the layouts the ledger and each inventory migrated to, LegacyBlobLayout until then
 }
 
 // AgencyMBSPassthrough represents a pool of Agency Mortgage-Backed Securities (MBS) passthrough.
 type AgencyMBSPassthrough struct {
 	UID          string `json:"uid"`
-	Bond         string `json:"bond"`         // Bond represents the bond associated with the MBS pool.
-	Cusip        string `json:"cusip"`        // Cusip represents the CUSIP number of the MBS pool.
-	OriginalFace int    `json:"originalFace"` // The amount of the bond
-	OwnerHash    string `json:"ownerHash"`    // Owner of the Bond
-	Class1       string `json:"class1"`       // Class1 represents the first class associated with the MBS pool.
+	Bond         string `json:"bond"`             // Bond represents the bond associated with the MBS pool.
+	Cusip        string `json:"cusip"`            // Cusip represents the CUSIP number of the MBS pool.
+	OriginalFace int64  `json:"originalFace"`     // The amount of the bond, in cents
+	OwnerHash    string `json:"ownerHash"`        // Owner of the Bond
+	Class1       string `json:"class1"`           // Class1 represents the first class associated with the MBS pool.
+	ReservedFor  string `json:"reservedFor"`      // ID of the pending trade holding the bond. Empty when the bond is free
+	Status       string `json:"status,omitempty"` // One of the Bond statuses. Empty on bonds created before statuses, which are listed
+	// Face each holder owns, in cents of current face, by party hash, once TransferShare syndicated the bond. Empty while OwnerHash owns it whole
+	OwnershipShares map[string]int64 `json:"ownershipShares,omitempty"`
+	// Party hash holding a lien on the bond while it is pledged as repo collateral. Empty when no lien is recorded
+	LienHolder string `json:"lienHolder,omitempty"`
+
+	// Pool characteristics. Bonds created on the ledger with CreateBondPublic leave them empty,
+	// bonds listed from a private inventory carry them over
+	Class2                          string  `json:"class2,omitempty"`                          // Class2 represents the second class associated with the MBS pool.
+	Class3                          string  `json:"class3,omitempty"`                          // Class3 represents the third class associated with the MBS pool.
+	Class4                          string  `json:"class4,omitempty"`                          // Class4 represents the fourth class associated with the MBS pool.
+	Coupon                          float64 `json:"coupon,omitempty"`                          // Coupon represents the coupon rate of the MBS pool.
+	CouponType                      string  `json:"couponType,omitempty"`                      // CouponType represents the type of coupon (e.g., Fixed or Floating) of the MBS pool.
+	IssueYear                       int     `json:"issueYear,omitempty"`                       // IssueYear represents the year of issuance of the MBS pool.
+	IssueDate                       string  `json:"issueDate,omitempty"`                       // IssueDate represents the date of issuance of the MBS pool.
+	MaturityDate                    string  `json:"maturityDate,omitempty"`                    // Date the pool pays off, like IssueDate. When empty, FactorDate plus WeightedAverageMaturity months
+	OriginationAmount               int64   `json:"originationAmount,omitempty"`               // OriginationAmount represents the original amount of the MBS pool, in cents.
+	Factor                          float64 `json:"factor,omitempty"`                          // Factor represents the factor of the MBS pool.
+	CurrentFace                     int64   `json:"currentFace,omitempty"`                     // OriginalFace times Factor, in cents. Kept up to date by factor updates.
+	FactorDate                      string  `json:"factorDate,omitempty"`                      // FactorDate represents the date of factor calculation of the MBS pool.
+	WeightedAverageCoupon           float64 `json:"weightedAverageCoupon,omitempty"`           // WeightedAverageCoupon represents the weighted average coupon of the MBS pool.
+	WeightedAverageLoanAge          float64 `json:"weightedAverageLoanAge,omitempty"`          // WeightedAverageLoanAge represents the weighted average loan age of the MBS pool.
+	WeightedAverageMaturity         float64 `json:"weightedAverageMaturity,omitempty"`         // WeightedAverageMaturity represents the weighted average maturity of the MBS pool.
+	WeightedAverageOriginalMaturity float64 `json:"weightedAverageOriginalMaturity,omitempty"` // WeightedAverageOriginalMaturity represents the weighted average original maturity of the MBS pool.
+	LoanSize                        float64 `json:"loanSize,omitempty"`                        // LoanSize represents the loan size of the MBS pool.
+	LoanToValue                     float64 `json:"loanToValue,omitempty"`                     // LoanToValue represents the loan-to-value ratio of the MBS pool.
+	Fico                            float64 `json:"fico,omitempty"`                            // Fico represents the FICO score of the MBS pool.
+	Cpr1m                           float64 `json:"cpr1m,omitempty"`                           // Cpr1m represents the CPR (Constant Prepayment Rate) for 1 month of the MBS pool.
+	Cpr3m                           float64 `json:"cpr3m,omitempty"`                           // Cpr3m represents the CPR for 3 months of the MBS pool.
+	Cpr6m                           float64 `json:"cpr6m,omitempty"`                           // Cpr6m represents the CPR for 6 months of the MBS pool.
+	Cpr12m                          float64 `json:"cpr12m,omitempty"`                          // Cpr12m represents the CPR for 12 months of the MBS pool.
+	Servicer                        string  `json:"servicer,omitempty"`                        // Servicer represents the servicer associated with the MBS pool.
+	Geography                       string  `json:"geography,omitempty"`                       // Geography represents the geographic location of the MBS pool.
+	PurchasePercent                 float64 `json:"purchasePercent,omitempty"`                 // PurchasePercent represents the percentage of purchases in the MBS pool.
+	RefinancePercent                float64 `json:"refinancePercent,omitempty"`                // RefinancePercent represents the percentage of refinances in the MBS pool.
+	ThirdpartyOriginationPercent    float64 `json:"thirdpartyOriginationPercent,omitempty"`    // ThirdpartyOriginationPercent represents the percentage of third-party originations in the MBS pool.
+	LoanCount                       int     `json:"loanCount,omitempty"`                       // LoanCount represents the number of loans in the MBS pool.
 }
 
+// Lifecycle statuses of a bond on the ledger. Draft, retired and matured bonds stay on the ledger, but cannot trade
+const (
+	BondDraft    = "Draft"    // Imported, and not yet listed by an admin
+	BondListed   = "Listed"   // Free to trade
+	BondReserved = "Reserved" // Held for a trade, offer, pledge, loan, repo or auction, which ReservedFor names
+	BondTraded   = "Traded"   // Held for a direct trade both sides agreed on, until it settles
+	BondPledged  = "Pledged"  // Collateral of an open repo, under the lien of LienHolder, until the repo closes or defaults
+	BondSettled  = "Settled"  // Delivered to its current owner, and free to trade again
+	BondRetired  = "Retired"  // Taken out of circulation by its owner. An admin can restore it
+	BondMatured  = "Matured"  // Paid off, or its pool was retired by a corporate action
+	BondActive   = "Active"   // Listed, on bonds stored before the trading statuses
+)
+
 // The private bond values of an Organization
 type PrivateBond struct {
-	UID          string  `json:"uid"`
-	ReservePrice float64 `json:"reservePrice"`
+	UID          string    `json:"uid"`
+	ReservePrice Price     `json:"reservePrice"`
+	ValidFrom    time.Time `json:"validFrom"`  // Start of the window the reserve price applies in. Zero leaves it open
+	ValidUntil   time.Time `json:"validUntil"` // End of the window the reserve price applies in. Zero leaves it open
+	MinPiece     int64     `json:"minPiece"`   // Smallest face, in cents, the holding may be sold in or left at. Zero for no minimum
+}
+
+// BondPosition is a bond of the ledger with the caller's private values for it
+type BondPosition struct {
+	Public  AgencyMBSPassthrough `json:"public"`
+	Private PrivateBond          `json:"private"`
 }
 
 // The direct trade objects.
 type DirectTrade struct {
-	DirectTradeID string    `json:"directTradeID"`
-	Cusip         string    `json:"cusip"`
-	OriginalFace  int       `json:"originalFace"`
-	BidPrice      float64   `json:"bidPrice"`
-	BidderHash    string    `json:"BidderHash"`
-	State         string    `json:"state"` //"Open" or "Closed"
-	Answers       []Answer  `json:"answers"`
-	CreatedAt     time.Time `json:"createdAt"`
+	DirectTradeID string             `json:"directTradeID"`
+	Reference     string             `json:"reference"` // Human-readable number, e.g. DT-20240115-000123
+	Cusip         string             `json:"cusip"`
+	OriginalFace  int64              `json:"originalFace"` // In cents
+	BidPrice      Price              `json:"bidPrice"`
+	BidderHash    string             `json:"BidderHash"`
+	State         string             `json:"state"` //"Open", "Answered", "Agreed", "Matched", "Settled", "Closed", "Cancelled" or "Expired"
+	Answers       []Answer           `json:"answers"`
+	CreatedAt     time.Time          `json:"createdAt"`
+	AllowPartial  bool               `json:"allowPartial"`         // Whether the bid may be filled in several pieces
+	RemainingFace int64              `json:"remainingFace"`        // Face still to be bought, in cents
+	Benchmark     string             `json:"benchmark"`            // Set when the trade is negotiated as a spread to this benchmark. BidPrice and counter prices are then spreads in basis points
+	ExpiresAt     time.Time          `json:"expiresAt"`            // No answers are taken from then on. Zero for trades that stay open until closed
+	Amendments    []TradeAmendment   `json:"amendments,omitempty"` // Proposed changes to the terms, oldest first
+	Currency      string             `json:"currency,omitempty"`   // Currency the trade is priced and paid in. Empty for the settlement currency
+	Comments      []TradeCommentLink `json:"comments,omitempty"`   // Hash chain of the private comments of each negotiation, oldest first
 }
 
 // AnswerResponse represents the response value, timestamp, and optional counter price for an answer.
 type AnswerResponse struct {
-	Value        string    `json:"value"`
-	Timestamp    time.Time `json:"timestamp"`
-	CounterPrice float64   `json:"counterPrice"`
+	Value            string    `json:"value"`
+	Timestamp        time.Time `json:"timestamp"`
+	CounterPrice     Price     `json:"counterPrice"`     // Empty until a price is countered or agreed
+	CounterOfferHash string    `json:"counterOfferHash"` // Hash of a private counter offer, set in place of CounterPrice until it is accepted
 }
 
 // Answer for Direct Trade
@@ -57,6 +129,17 @@ type Answer struct {
 	SellerIDHash   string         `json:"sellerIDHash"`
 	SellerResponse AnswerResponse `json:"sellerResponse"`
 	BuyerResponse  AnswerResponse `json:"buyerResponse"`
+	BondUID        string         `json:"bondUID"` // Bond the seller delivers, pinned by its "done" answer. Empty while the seller has not said yes
+}
+
+// AnswerableTrade is an open direct trade on a cusip the caller owns, with the face still to be bought and where its answers stand
+type AnswerableTrade struct {
+	DirectTrade   DirectTrade `json:"directTrade"`
+	RemainingFace int64       `json:"remainingFace"` // Face still to be bought, in cents
+	OwnedFace     int64       `json:"ownedFace"`     // Face of the cusip the caller owns on the ledger, in cents
+	YourAnswer    string      `json:"yourAnswer"`    // The caller's answer. Empty while it has not answered
+	BestAnswer    string      `json:"bestAnswer"`    //"done" or "counter" for the best priced answer. Empty while no seller quoted a price
+	BestPrice     Price       `json:"bestPrice"`     // Lowest price answered, or widest spread for trades negotiated as a spread
 }
 
 // Trade Record
@@ -64,9 +147,22 @@ type Transaction struct {
 	BuyerID      string    `json:"buyerID"`
 	SellerID     string    `json:"sellerID"`
 	Cusip        string    `json:"cusip"`
-	OriginalFace int       `json:"originalFace"`
-	BoughtPrice  string    `json:"boughtPrice"`
+	OriginalFace int64     `json:"originalFace"` // In cents
+	BoughtPrice  Price     `json:"boughtPrice"`
 	Timestamp    time.Time `json:"timestamp"`
+	Type         string    `json:"type,omitempty"`  // "Transfer" for a bond given away with TransferBond, without price or cash. Empty for trades
+	TxnID        string    `json:"txnID,omitempty"` // ID of the Fabric transaction that recorded it, suffixed with -1, -2... for the further ones it recorded. Empty for transactions from before
+	// Currencies of the cash accounts the transaction settled against and the FX rates applied from USD
+	BuyerCurrency  string  `json:"buyerCurrency"`
+	BuyerFXRate    float64 `json:"buyerFXRate"`
+	SellerCurrency string  `json:"sellerCurrency"`
+	SellerFXRate   float64 `json:"sellerFXRate"`
+	// Yield implied by the price and spread in basis points to the benchmark curve point, when the pool is registered.
+	// For trades negotiated as a spread, the benchmark level the price was fixed at is kept too
+	Yield          float64 `json:"yield"`
+	Benchmark      string  `json:"benchmark"`
+	BenchmarkLevel float64 `json:"benchmarkLevel"`
+	Spread         float64 `json:"spread"`
 }
 
 // The Open Ledger
@@ -74,12 +170,19 @@ type Ledger struct {
 	Bonds        []AgencyMBSPassthrough `json:"bonds"`
 	DirectTrades []DirectTrade          `json:"directTrades"`
 	Transactions []Transaction          `json:"transactions"`
+
+	// Set when only the bonds and direct trades of this cusip were read, and none of the transactions.
+	// updateLedger then writes back only that cusip and adds the transactions appended to it
+	cusip string
+	// Cash accounts changed by the settlements on the ledger, which updateLedger stores as well
+	cash *cashBook
 }
 
 // ⭐ Functions ⭐
 
-// CreateBondPublic creates a new bond and adds it to the ledger as a public bond
-func (s *SmartContract) CreateBondPublic(ctx contractapi.TransactionContextInterface, uid, ownerHash, bondID, cusip, class1 string, originalFace int) (string, error) {
+// CreateBondPublic creates a new bond and adds it to the ledger as a public bond. A bond already on the ledger, by its UID or
+// as the same holding of its owner, is refused, so replaying a creation does not duplicate the bond
+func (s *SmartContract) CreateBondPublic(ctx contractapi.TransactionContextInterface, uid, ownerHash, bondID, cusip, class1 string, originalFace int64) (*WriteResponse, error) {
 	// Generating UID for bond. This part should be done manually and inputed in the args. In the front-end, you can manage this properly
 	// uid := generateUID()
 	//TODO: Add validation for uid
@@ -91,9 +194,9 @@ func (s *SmartContract) CreateBondPublic(ctx contractapi.TransactionContextInter
 	// 	return "", fmt.Errorf("failed to generate encryption key: %v", err)
 	// }
 
-	ledger, err := s.GetLedger(ctx)
+	ledger, err := s.getCusipLedger(ctx, cusip)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// Storing bond in ledger
@@ -102,44 +205,117 @@ func (s *SmartContract) CreateBondPublic(ctx contractapi.TransactionContextInter
 		Bond:         bondID,
 		Cusip:        cusip,
 		OriginalFace: originalFace,
-		OwnerHash:    ownerHash,
+		OwnerHash:    s.ownerHashFor(ctx, ownerHash, uid),
 		Class1:       class1,
+		Status:       BondListed,
+	}
+	err = s.requireNewBond(ctx, &bond, ledger.Bonds)
+	if err != nil {
+		return nil, err
+	}
+	err = s.registerBondUID(ctx, &bond)
+	if err != nil {
+		return nil, err
 	}
 	ledger.Bonds = append(ledger.Bonds, bond)
 	err = s.updateLedger(ctx, ledger)
 	if err != nil {
-		return "", fmt.Errorf("failed to store bond: %v", err)
+		return nil, fmt.Errorf("failed to store bond: %v", err)
 	}
 
-	return uid, nil
+	return newWriteResponse(ctx, uid)
 }
 
-// CreateBondPrivate stores the bond in the private collection with the specified UID and reserve price
-func (s *SmartContract) CreateBondPrivate(ctx contractapi.TransactionContextInterface, uid string, reservePrice float64) error {
+// CreateBondPrivate stores the bond in the private collection with the specified UID and reserve price.
+// The reserve price only applies between validFrom and validUntil. Either can be empty to leave that side open
+func (s *SmartContract) CreateBondPrivate(ctx contractapi.TransactionContextInterface, uid, reservePrice, validFrom, validUntil string) (*WriteResponse, error) {
+	price, err := s.parsePrice(ctx, reservePrice)
+	if err != nil {
+		return nil, err
+	}
+
 	// Storing bond in private collection
 	privateBond := PrivateBond{
 		UID:          uid,
-		ReservePrice: reservePrice,
+		ReservePrice: price,
+	}
+
+	if validFrom != "" {
+		privateBond.ValidFrom, err = parseTimestamp(validFrom)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if validUntil != "" {
+		privateBond.ValidUntil, err = parseTimestamp(validUntil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !privateBond.ValidFrom.IsZero() && !privateBond.ValidUntil.IsZero() && !privateBond.ValidUntil.After(privateBond.ValidFrom) {
+		return nil, fmt.Errorf("the reserve price must be valid until after %s", validFrom)
 	}
-	err := s.storePrivateBond(ctx, privateBond)
+
+	err = s.storePrivateBond(ctx, privateBond)
 	if err != nil {
-		return fmt.Errorf("failed to store private bond: %v", err)
+		return nil, fmt.Errorf("failed to store private bond: %v", err)
 	}
 
-	return nil
+	return newWriteResponse(ctx, nil)
+}
+
+// SetMinimumPiece records the smallest face the caller's holding with the given UID may be sold in or left at.
+// Offers posted afterwards for the holding refuse partial fills that would break it. Zero removes the minimum
+func (s *SmartContract) SetMinimumPiece(ctx contractapi.TransactionContextInterface, uid string, minPiece int64) (*WriteResponse, error) {
+	if minPiece < 0 {
+		return nil, fmt.Errorf("minimum piece cannot be negative: %v", minPiece)
+	}
+
+	privateBonds, err := s.getPrivateBonds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	found := false
+	for i := range privateBonds {
+		if privateBonds[i].UID == uid {
+			privateBonds[i].MinPiece = minPiece
+			found = true
+			break
+		}
+	}
+	if !found {
+		privateBonds = append(privateBonds, PrivateBond{UID: uid, MinPiece: minPiece})
+	}
+
+	err = s.putPrivateBonds(ctx, privateBonds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store private bond: %v", err)
+	}
+
+	return newWriteResponse(ctx, nil)
 }
 
 // CheckDirectTrades checks if there are any open direct trades for a given cusip
 func (s *SmartContract) CheckDirectTrades(ctx contractapi.TransactionContextInterface, cusip string) ([]DirectTrade, error) {
 	var trades []DirectTrade
 
-	ledger, err := s.GetLedger(ctx)
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cusipTrades, err := stores.Trades.GetDirectTradesByCusip(cusip)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, trade := range ledger.DirectTrades {
-		if trade.Cusip == cusip && trade.State == "Open" {
+	// Trades past their expiry are left out even before ExpireStaleTrades marks them
+	for _, trade := range cusipTrades {
+		if trade.open() && !trade.expiredAt(now) {
 			trades = append(trades, trade)
 		}
 	}
@@ -147,35 +323,97 @@ func (s *SmartContract) CheckDirectTrades(ctx contractapi.TransactionContextInte
 	return trades, nil
 }
 
-// CloseDirectTrade closes a direct trade by DirectTradeID if the caller is the owner
-func (s *SmartContract) CloseDirectTrade(ctx contractapi.TransactionContextInterface, tradeID string) error {
-	ledger, err := s.GetLedger(ctx)
+// CloseDirectTrade closes a direct trade by DirectTradeID if the caller is the owner. The trade ends Cancelled, or Closed
+// when part of it was already filled. An agreed trade whose settlement instructions never matched can be closed too,
+// which frees the bond the seller pinned to it
+func (s *SmartContract) CloseDirectTrade(ctx contractapi.TransactionContextInterface, tradeID string) (*WriteResponse, error) {
+	ledger, err := s.getTradeLedger(ctx, tradeID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	for i, trade := range ledger.DirectTrades {
 		if trade.DirectTradeID == tradeID {
 			if s.IsOwner(ctx, trade.BidderHash) {
-				ledger.DirectTrades[i].State = "Closed"
-				return s.updateLedger(ctx, ledger)
+				err = directTradeLifecycle.move(tradeID, &ledger.DirectTrades[i].State, trade.closingState())
+				if err != nil {
+					return nil, err
+				}
+				releaseReservations(ledger, tradeID, "")
+				err = s.updateLedger(ctx, ledger)
+				if err != nil {
+					return nil, err
+				}
+				err = emitTradeEvent(ctx, TradeClosedEvent, &ledger.DirectTrades[i], "", trade.BidPrice, "")
+				if err != nil {
+					return nil, err
+				}
+				err = s.recordOrderEvents(ctx, OrderEvent{Action: "Cancel", OrderType: "Trade", OrderID: tradeID, Cusip: trade.Cusip, Face: trade.openFace(), Price: trade.BidPrice})
+				if err != nil {
+					return nil, err
+				}
+				return newWriteResponse(ctx, nil)
 			}
-			return fmt.Errorf("you are not the owner of the trade")
+			return nil, NewError(ErrUnauthorized, "you are not the owner of the trade")
 		}
 	}
 
-	return fmt.Errorf("direct trade not found")
+	return nil, NewError(ErrNotFound, "direct trade not found")
+}
+
+// ExpireStaleTrades marks the direct trades whose expiry has passed before they settled as Expired, agreed ones included,
+// and frees the bonds sellers held for them. It is housekeeping any organization can run, and it raises TradeExpired for every trade it expires
+func (s *SmartContract) ExpireStaleTrades(ctx contractapi.TransactionContextInterface) (*WriteResponse, error) {
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The stale trades can span several cusips, so they are all changed on one ledger
+	ledger, err := s.GetLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	expired := []string{}
+	for i := range ledger.DirectTrades {
+		trade := &ledger.DirectTrades[i]
+		if !(trade.open() || trade.awaitingSettlement()) || !trade.expiredAt(now) {
+			continue
+		}
+		err = directTradeLifecycle.move(trade.DirectTradeID, &trade.State, "Expired")
+		if err != nil {
+			return nil, err
+		}
+		releaseReservations(ledger, trade.DirectTradeID, "")
+		expired = append(expired, trade.DirectTradeID)
+
+		err = emitTradeEvent(ctx, TradeExpiredEvent, trade, "", trade.BidPrice, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(expired) == 0 {
+		return newWriteResponse(ctx, expired)
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, expired)
 }
 
 // GenerateTransactionObject creates a new Transaction object
-func (s *SmartContract) GenerateTransactionObject(buyerID, sellerID, cusip string, originalFace int, boughtPrice string, timestamp time.Time) Transaction {
+func (s *SmartContract) GenerateTransactionObject(buyerID, sellerID, cusip string, originalFace int64, boughtPrice string, timestamp time.Time) Transaction {
 	return Transaction{
 		BuyerID:      buyerID,
 		SellerID:     sellerID,
 		Cusip:        cusip,
 		OriginalFace: originalFace,
-		BoughtPrice:  boughtPrice,
-		Timestamp:    timestamp,
+		BoughtPrice:  Price(boughtPrice),
+		Timestamp:    timestamp.UTC(),
 	}
 }
 
@@ -189,7 +427,8 @@ func (s *SmartContract) GenerateOrgHash(ctx contractapi.TransactionContextInterf
 	return encryptionKey, nil
 }
 
-// IsOwner checks if the caller is the owner by comparing with the encryption key
+// IsOwner checks if the caller is the party the hash names by comparing with the encryption key.
+// The owner hash of a bond may be a commitment instead, which ownsBond verifies
 func (s *SmartContract) IsOwner(ctx contractapi.TransactionContextInterface, ownerHash string) bool {
 	encryptionKey, err := s.getEncryptionKey(ctx)
 	if err != nil {
@@ -199,38 +438,32 @@ func (s *SmartContract) IsOwner(ctx contractapi.TransactionContextInterface, own
 	return ownerHash == encryptionKey
 }
 
-// GetBond returns all bonds from the ledger that have the given cusip and their corresponding private bonds
-func (s *SmartContract) GetBond(ctx contractapi.TransactionContextInterface, cusip string) ([]struct {
-	Public  AgencyMBSPassthrough
-	Private PrivateBond
-}, error) {
-	var result []struct {
-		Public  AgencyMBSPassthrough
-		Private PrivateBond
-	}
+// GetBond returns all bonds from the ledger that have the given cusip and their corresponding private bonds.
+// Retired bonds are left out unless includeRetired is set
+func (s *SmartContract) GetBond(ctx contractapi.TransactionContextInterface, cusip string, includeRetired bool) ([]BondPosition, error) {
+	var result []BondPosition
 
-	// Retrieve bonds from ledger
-	bonds, err := s.getAllBonds(ctx)
+	// Retrieve the bonds of the cusip from ledger
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bonds, err := stores.Bonds.GetBondsByCusip(cusip)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, bond := range bonds {
-		if bond.Cusip == cusip {
-			// Get corresponding private bond
-			privateBond, err := s.getPrivateBond(ctx, bond.UID)
-			if err != nil {
-				return nil, err
-			}
-
-			result = append(result, struct {
-				Public  AgencyMBSPassthrough
-				Private PrivateBond
-			}{
-				Public:  bond,
-				Private: privateBond,
-			})
+	for _, bond := range filterRetired(bonds, includeRetired) {
+		// Get corresponding private bond
+		privateBond, err := s.getPrivateBond(ctx, bond.UID)
+		if err != nil {
+			return nil, err
 		}
+
+		result = append(result, BondPosition{
+			Public:  bond,
+			Private: privateBond,
+		})
 	}
 
 	if len(result) == 0 {
@@ -240,9 +473,14 @@ func (s *SmartContract) GetBond(ctx contractapi.TransactionContextInterface, cus
 	return result, nil
 }
 
-// GetAllBonds returns all bonds from the ledger
-func (s *SmartContract) GetAllBonds(ctx contractapi.TransactionContextInterface) ([]AgencyMBSPassthrough, error) {
-	return s.getAllBonds(ctx)
+// GetAllBonds returns all bonds from the ledger. Retired bonds are left out unless includeRetired is set
+func (s *SmartContract) GetAllBonds(ctx contractapi.TransactionContextInterface, includeRetired bool) ([]AgencyMBSPassthrough, error) {
+	bonds, err := s.getAllBonds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return filterRetired(bonds, includeRetired), nil
 }
 
 // GetAllTransactions returns all transactions from the ledger
@@ -252,9 +490,9 @@ func (s *SmartContract) GetAllTransactions(ctx contractapi.TransactionContextInt
 
 // GetAllYourBonds returns all bonds from the ledger that the caller is the owner of,
 // along with their corresponding private bonds.
-func (s *SmartContract) GetAllYourBonds(ctx contractapi.TransactionContextInterface) ([][]interface{}, error) {
-	// Get bidder hash
-	bidderHash, err := s.GenerateOrgHash(ctx)
+func (s *SmartContract) GetAllYourBonds(ctx contractapi.TransactionContextInterface) ([]BondPosition, error) {
+	// Get the caller as an owner, with the secret its bonds may be committed with
+	owner, err := s.callerOwner(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate bidder hash: %v", err)
 	}
@@ -265,12 +503,12 @@ func (s *SmartContract) GetAllYourBonds(ctx contractapi.TransactionContextInterf
 		return nil, err
 	}
 
-	var yourBonds [][]interface{}
+	yourBonds := []BondPosition{}
 
 	// Iterate through all bonds
 	for _, bond := range allBonds {
 		// Check if the bond owner is the caller
-		if bond.OwnerHash == bidderHash {
+		if owner.owns(&bond) {
 			// Retrieve the corresponding private bond
 			privateBond, err := s.getPrivateBond(ctx, bond.UID)
 			if err != nil {
@@ -278,7 +516,7 @@ func (s *SmartContract) GetAllYourBonds(ctx contractapi.TransactionContextInterf
 			}
 
 			// Append the bond and its corresponding private bond to the result
-			yourBonds = append(yourBonds, []interface{}{bond, privateBond})
+			yourBonds = append(yourBonds, BondPosition{Public: bond, Private: privateBond})
 		}
 	}
 
@@ -310,97 +548,206 @@ func (s *SmartContract) GetYourDirectTrades(ctx contractapi.TransactionContextIn
 	return yourTrades, nil
 }
 
+// GetOpenTradesForMyBonds returns the open direct trades the caller can answer as a seller: those on a cusip it owns bonds of,
+// other than its own bids. Private counter offers carry no public price, so they are left out of the best answer
+func (s *SmartContract) GetOpenTradesForMyBonds(ctx contractapi.TransactionContextInterface) ([]AnswerableTrade, error) {
+	owner, err := s.callerOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger, err := s.GetLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ownedFaces := map[string]int64{}
+	for i, bond := range ledger.Bonds {
+		if owner.owns(&ledger.Bonds[i]) {
+			ownedFaces[bond.Cusip] += bond.OriginalFace
+		}
+	}
+
+	trades := []AnswerableTrade{}
+	for i, trade := range ledger.DirectTrades {
+		ownedFace, ok := ownedFaces[trade.Cusip]
+		if !ok || !trade.open() || trade.expiredAt(now) || trade.BidderHash == owner.hash {
+			continue
+		}
+
+		answerable := AnswerableTrade{
+			DirectTrade:   trade,
+			RemainingFace: ledger.DirectTrades[i].openFace(),
+			OwnedFace:     ownedFace,
+		}
+		for _, answer := range trade.Answers {
+			if answer.SellerIDHash == owner.hash {
+				answerable.YourAnswer = answer.SellerResponse.Value
+			}
+			value, price := answer.SellerResponse.Value, answer.SellerResponse.CounterPrice
+			if (value != "done" && value != "counter") || price == "" {
+				continue
+			}
+			if answerable.BestPrice == "" || (trade.Benchmark == "" && price.value() < answerable.BestPrice.value()) ||
+				(trade.Benchmark != "" && price.value() > answerable.BestPrice.value()) {
+				answerable.BestAnswer, answerable.BestPrice = value, price
+			}
+		}
+		trades = append(trades, answerable)
+	}
+
+	return trades, nil
+}
+
 // This is temporary. In the future, it should be an actual encryption procedure. SetEncryptionKey stores the MSPID of the organization invoking the function in the private collection
-func (s *SmartContract) SetEncryptionKey(ctx contractapi.TransactionContextInterface) error {
+func (s *SmartContract) SetEncryptionKey(ctx contractapi.TransactionContextInterface) (*WriteResponse, error) {
 	mspID, err := ctx.GetClientIdentity().GetMSPID()
 	if err != nil {
-		return fmt.Errorf("failed to get MSP ID: %v", err)
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
 	}
 
 	err = ctx.GetStub().PutPrivateData("_implicit_org_"+mspID, "encryption_key", []byte(mspID))
 	if err != nil {
-		return fmt.Errorf("failed to store encryption key: %v", err)
+		return nil, fmt.Errorf("failed to store encryption key: %v", err)
 	}
 
-	return nil
+	return newWriteResponse(ctx, nil)
 }
 
+// GetLedger returns the bonds, direct trades and transactions, read through the stores of the contract's storage layout
 func (s *SmartContract) GetLedger(ctx contractapi.TransactionContextInterface) (*Ledger, error) {
-	ledgerBytes, err := ctx.GetStub().GetState("ledger")
+	stores, err := s.stores(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read ledger from world state: %v", err)
-	}
-	if ledgerBytes == nil {
-		return &Ledger{
-			Bonds:        []AgencyMBSPassthrough{},
-			DirectTrades: []DirectTrade{},
-			Transactions: []Transaction{},
-		}, nil
+		return nil, err
 	}
 
-	var ledger Ledger
-	err = json.Unmarshal(ledgerBytes, &ledger)
+	bonds, err := stores.Bonds.GetBonds()
+	if err != nil {
+		return nil, err
+	}
+	trades, err := stores.Trades.GetDirectTrades()
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := stores.Trades.GetTransactions()
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal ledger: %v", err)
+		return nil, err
 	}
 
-	return &ledger, nil
+	return &Ledger{
+		Bonds:        bonds,
+		DirectTrades: trades,
+		Transactions: transactions,
+	}, nil
 }
 
-// CreateTrade initiates a new direct trade
-func (s *SmartContract) CreateTrade(ctx contractapi.TransactionContextInterface, directTradeID, bidderHash, cusip, createdAtString string, originalFace int, bidPrice float64) (string, error) {
-	// Generating UID for direct trade. This part should be done manually and inputed in the args. In the front-end, you can manage this properly
-	// directTradeID := generateUID()
-	// TODO: Add validation here.
+// getCusipLedger returns a ledger with the bonds and direct trades of one cusip and no transactions.
+// Transactions that read and write only one cusip do not conflict with those on other cusips
+func (s *SmartContract) getCusipLedger(ctx contractapi.TransactionContextInterface, cusip string) (*Ledger, error) {
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bonds, err := stores.Bonds.GetBondsByCusip(cusip)
+	if err != nil {
+		return nil, err
+	}
+	trades, err := stores.Trades.GetDirectTradesByCusip(cusip)
+	if err != nil {
+		return nil, err
+	}
 
-	// Define the layout of the time string
-	layout := "2006-01-02T15:04:05Z"
+	return &Ledger{
+		Bonds:        bonds,
+		DirectTrades: trades,
+		Transactions: []Transaction{},
+		cusip:        cusip,
+	}, nil
+}
 
-	// Parse the time string into a time.Time type
-	parsedTime, err := time.Parse(layout, createdAtString)
+// getBondLedger returns the ledger of the cusip of the bond with the given UID
+func (s *SmartContract) getBondLedger(ctx contractapi.TransactionContextInterface, uid string) (*Ledger, error) {
+	stores, err := s.stores(ctx)
 	if err != nil {
-		return "", fmt.Errorf("error parsing time: %v", err)
+		return nil, err
 	}
 
-	// Generating BidderHash
-	// bidderHash, err := s.GenerateOrgHash(ctx)
-	// if err != nil {
-	// 	return "", fmt.Errorf("failed to generate bidder hash: %v", err)
-	// }
-	// TODO: see if it's possible to get the mspid of the one executing the chaincode, but still get the endorsers to work properly
+	bond, err := stores.Bonds.GetBond(uid)
+	if err != nil {
+		return nil, err
+	}
+	if bond == nil {
+		return nil, NewError(ErrNotFound, "bond with UID %s not found", uid)
+	}
 
-	// Creating new direct trade object
-	trade := DirectTrade{
-		DirectTradeID: directTradeID,
-		Cusip:         cusip,
-		OriginalFace:  originalFace,
-		BidPrice:      bidPrice,
-		BidderHash:    bidderHash,
-		State:         "Open",
-		Answers:       []Answer{},
-		CreatedAt:     parsedTime,
+	return s.getCusipLedger(ctx, bond.Cusip)
+}
+
+// getTradeLedger returns the ledger of the cusip of the direct trade with the given ID
+func (s *SmartContract) getTradeLedger(ctx contractapi.TransactionContextInterface, directTradeID string) (*Ledger, error) {
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	// Storing direct trade in ledger
-	ledger, err := s.GetLedger(ctx)
+	trade, err := stores.Trades.GetDirectTrade(directTradeID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	ledger.DirectTrades = append(ledger.DirectTrades, trade)
-	err = s.updateLedger(ctx, ledger)
+	if trade == nil {
+		return nil, NewError(ErrNotFound, "direct trade not found")
+	}
+
+	return s.getCusipLedger(ctx, trade.Cusip)
+}
+
+// CreateTrade initiates a new direct trade. Once the channel has a counterparty registry, only active counterparties can bid
+// A bid at or above a resting offer is executed against it right away, at the offer's price.
+// The trade expires at expiresAtString or, if it is empty, after the default trade expiry of the contract settings.
+// Its face and bid price must keep to the trade face limits and the price tick of the contract settings
+func (s *SmartContract) CreateTrade(ctx contractapi.TransactionContextInterface, directTradeID, bidderHash, cusip, createdAtString string, originalFace int64, bidPrice string, allowPartial bool, expiresAtString string) (*WriteResponse, error) {
+	return s.createTrade(ctx, directTradeID, bidderHash, cusip, "", createdAtString, originalFace, bidPrice, allowPartial, expiresAtString)
+}
+
+// CreateTradeInCurrency creates a direct trade like CreateTrade, priced and paid in another currency than the settlement currency,
+// which must have an FX rate. The bidder pays from its balance in that currency and the seller is paid into its own.
+// Offers are priced in the settlement currency, so the trade never executes against them
+func (s *SmartContract) CreateTradeInCurrency(ctx contractapi.TransactionContextInterface, directTradeID, bidderHash, cusip, currency, createdAtString string, originalFace int64, bidPrice string, allowPartial bool, expiresAtString string) (*WriteResponse, error) {
+	settlementCurrency, err := s.settlementCurrency(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if currency == settlementCurrency {
+		return nil, NewError(ErrInvalidInput, "%s is the settlement currency: create the trade with CreateTrade", currency)
+	}
+	_, err = s.GetFXRate(ctx, currency)
 	if err != nil {
-		return "", fmt.Errorf("failed to store direct trade: %v", err)
+		return nil, err
 	}
 
-	return directTradeID, nil
+	return s.createTrade(ctx, directTradeID, bidderHash, cusip, currency, createdAtString, originalFace, bidPrice, allowPartial, expiresAtString)
 }
 
-// AnswerTrade updates the answer for a direct trade
-func (s *SmartContract) AnswerTrade(ctx contractapi.TransactionContextInterface, directTradeID, sellerIDHash, answerValue string, timestamp time.Time, counterPrice float64) error {
-	// Retrieve ledger
-	ledger, err := s.GetLedger(ctx)
+// AnswerTrade updates the answer for a direct trade. Once the channel has a counterparty registry, only active counterparties can answer
+func (s *SmartContract) AnswerTrade(ctx contractapi.TransactionContextInterface, directTradeID, sellerIDHash, answerValue string, timestamp time.Time, counterPrice string) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &timestamp)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	err = s.requireActiveCounterparty(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Retrieve the ledger of the trade's cusip
+	ledger, err := s.getTradeLedger(ctx, directTradeID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Find the direct trade
@@ -412,8 +759,23 @@ func (s *SmartContract) AnswerTrade(ctx contractapi.TransactionContextInterface,
 		}
 	}
 	if foundTrade == nil {
-		return fmt.Errorf("direct trade not found")
+		return nil, NewError(ErrNotFound, "direct trade not found")
+	}
+	if !foundTrade.open() {
+		return nil, fmt.Errorf("direct trade is closed")
 	}
+	err = requireUnexpiredTrade(ctx, foundTrade)
+	if err != nil {
+		return nil, err
+	}
+	if sellerIDHash == foundTrade.BidderHash {
+		return nil, fmt.Errorf("you cannot answer your own trade")
+	}
+	if !s.IsOwner(ctx, sellerIDHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of %s", sellerIDHash)
+	}
+
+	executed := len(ledger.Transactions)
 
 	// Find or create answer object
 	var foundAnswer *Answer
@@ -430,93 +792,130 @@ func (s *SmartContract) AnswerTrade(ctx contractapi.TransactionContextInterface,
 			SellerResponse: AnswerResponse{
 				Value:        "",
 				Timestamp:    time.Time{},
-				CounterPrice: 0.0,
+				CounterPrice: "",
 			},
 			BuyerResponse: AnswerResponse{
 				Value:        "",
 				Timestamp:    time.Time{},
-				CounterPrice: 0.0,
+				CounterPrice: "",
 			},
 		}
 		foundTrade.Answers = append(foundTrade.Answers, newAnswer)
 		foundAnswer = &foundTrade.Answers[len(foundTrade.Answers)-1]
 	}
 
+	// A seller can only commit to a trade for a Cusip they actually hold
+	seller := s.ownerFor(ctx, sellerIDHash)
+	if answerValue == "done" || answerValue == "counter" {
+		if !holdsPosition(ledger, seller, foundTrade.Cusip) {
+			return nil, fmt.Errorf("the seller does not own a position in Cusip %s", foundTrade.Cusip)
+		}
+	}
+
+	// Saying yes holds one of the seller's bonds with the face of the trade and pins its UID to the answer,
+	// so that settlement delivers exactly that bond. Any other answer frees it.
+	// Holds left by trades that closed or expired are dropped first, so that they never block the seller
+	if answerValue == "done" {
+		now, err := txTimestamp(ctx)
+		if err != nil {
+			return nil, err
+		}
+		releaseStaleReservations(ledger, now)
+
+		bondIndex, err := reserveBond(ledger, seller, foundTrade.Cusip, foundTrade.DirectTradeID, foundTrade.openFace())
+		if err != nil {
+			return nil, err
+		}
+		foundAnswer.BondUID = ledger.Bonds[bondIndex].UID
+	} else {
+		releaseAnswer(ledger, foundTrade.DirectTradeID, foundAnswer)
+		foundAnswer.BondUID = ""
+	}
+
 	// Update SellerResponse
 	foundAnswer.SellerResponse.Value = answerValue
 	foundAnswer.SellerResponse.Timestamp = timestamp
+	foundAnswer.SellerResponse.CounterOfferHash = ""
+	err = foundTrade.markAnswered()
+	if err != nil {
+		return nil, err
+	}
 
 	// If the buyer or seller says no, can you keep negotiating? Or is it over?
 
+	settle := false
 	if answerValue == "done" || answerValue == "no" {
 		if foundAnswer.BuyerResponse.Value == "" {
 			foundAnswer.SellerResponse.CounterPrice = foundTrade.BidPrice
 
 		} else {
 			foundAnswer.SellerResponse.CounterPrice = foundAnswer.BuyerResponse.CounterPrice
-
-			if foundAnswer.BuyerResponse.Value == "done" {
-				//transaction Creation Here
-				// Get bond from ledger
-				bonds, err := s.getAllBonds(ctx)
+			if answerValue == "done" && foundAnswer.BuyerResponse.CounterOfferHash != "" {
+				// Accepting a private counter offer makes its price public
+				foundAnswer.SellerResponse.CounterPrice, err = s.privateCounterPrice(ctx, directTradeID, sellerIDHash, foundAnswer.BuyerResponse.CounterOfferHash)
 				if err != nil {
-					return fmt.Errorf("failed to get bond: %v", err)
-				}
-
-				// Find the bond owned by the caller
-				var ownedBond *AgencyMBSPassthrough
-				for _, bond := range bonds {
-					if bond.OwnerHash == sellerIDHash {
-						ownedBond = &bond
-						break
-					}
+					return nil, err
 				}
-				if ownedBond == nil {
-					return fmt.Errorf("the seller does not own any bonds for this trade")
-				}
-
-				// Update bond owner
-				for i, bond := range ledger.Bonds {
-					if bond.OwnerHash == sellerIDHash {
-						ownedBond = &bond
-						ledger.Bonds[i].OwnerHash = foundTrade.BidderHash
-						break
-					}
-				}
-
-				// Close the Trade
-				foundTrade.State = "Closed"
-
-				// Generate transaction
-				transaction := s.GenerateTransactionObject(foundTrade.BidderHash, foundAnswer.SellerIDHash, foundTrade.Cusip, foundTrade.OriginalFace, fmt.Sprintf("%.2f", foundAnswer.BuyerResponse.CounterPrice), timestamp)
-
-				// Add transaction to ledger
-				ledger.Transactions = append(ledger.Transactions, transaction)
 			}
+
+			settle = foundAnswer.BuyerResponse.Value == "done"
 		}
 
 	} else if answerValue == "counter" {
 		if foundAnswer.BuyerResponse.Value != "done" {
-			foundAnswer.SellerResponse.CounterPrice = counterPrice
+			foundAnswer.SellerResponse.CounterPrice, err = s.parsePrice(ctx, counterPrice)
+			if err != nil {
+				return nil, err
+			}
+			err = s.requirePriceTick(ctx, foundTrade, foundAnswer.SellerResponse.CounterPrice)
+			if err != nil {
+				return nil, err
+			}
 		} else {
-			return fmt.Errorf("the buyer accepted the price. You cannot counter it: %v", foundAnswer.BuyerResponse.CounterPrice)
+			return nil, fmt.Errorf("the buyer accepted the price. You cannot counter it: %v", foundAnswer.BuyerResponse.CounterPrice)
+		}
+	}
+
+	err = emitTradeEvent(ctx, TradeAnsweredEvent, foundTrade, sellerIDHash, foundAnswer.SellerResponse.CounterPrice, answerValue)
+	if err != nil {
+		return nil, err
+	}
+	if settle {
+		//transaction Creation Here
+		err = s.settleOrAgree(ctx, ledger, foundTrade, foundAnswer, timestamp)
+		if err != nil {
+			return nil, err
 		}
 	}
 
 	// Update ledger
 	err = s.updateLedger(ctx, ledger)
 	if err != nil {
-		return fmt.Errorf("failed to update ledger: %v", err)
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
 	}
 
-	return nil
-}
+	answered := OrderEvent{Action: "Answer", OrderType: "Trade", OrderID: directTradeID, Cusip: foundTrade.Cusip, Face: foundTrade.OriginalFace, Price: foundAnswer.SellerResponse.CounterPrice, Detail: answerValue}
+	err = s.recordOrderEvents(ctx, append([]OrderEvent{answered}, executionEvents(ledger, executed, "Trade", directTradeID)...)...)
+	if err != nil {
+		return nil, err
+	}
 
-func (s *SmartContract) AnswerTradeAsOwner(ctx contractapi.TransactionContextInterface, directTradeID, sellerIDHash, answerValue string, timestamp time.Time, counterPrice float64) error {
+	return newWriteResponse(ctx, nil)
+}
 
-	ledger, err := s.GetLedger(ctx)
+func (s *SmartContract) AnswerTradeAsOwner(ctx contractapi.TransactionContextInterface, directTradeID, sellerIDHash, answerValue string, timestamp time.Time, counterPrice string) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &timestamp)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	err = s.requireActiveCounterparty(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger, err := s.getTradeLedger(ctx, directTradeID)
+	if err != nil {
+		return nil, err
 	}
 
 	var foundTrade *DirectTrade
@@ -527,19 +926,28 @@ func (s *SmartContract) AnswerTradeAsOwner(ctx contractapi.TransactionContextInt
 		}
 	}
 	if foundTrade == nil {
-		return fmt.Errorf("direct trade not found")
+		return nil, NewError(ErrNotFound, "direct trade not found")
+	}
+	if !foundTrade.open() {
+		return nil, fmt.Errorf("direct trade is closed")
 	}
-	if foundTrade.State != "Open" {
-		return fmt.Errorf("direct trade is closed")
+	err = requireUnexpiredTrade(ctx, foundTrade)
+	if err != nil {
+		return nil, err
 	}
 	// Compare MSP ID with BidderHash
 	mspID, err := ctx.GetClientIdentity().GetMSPID()
 	if err != nil {
-		return fmt.Errorf("failed to get MSP ID: %v", err)
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
 	}
 	if foundTrade.BidderHash != mspID {
-		return fmt.Errorf("you are not the owner of the trade")
+		return nil, NewError(ErrUnauthorized, "you are not the owner of the trade")
 	}
+	if sellerIDHash == foundTrade.BidderHash {
+		return nil, fmt.Errorf("you cannot trade with yourself")
+	}
+
+	executed := len(ledger.Transactions)
 
 	// Find or create answer object
 	var foundAnswer *Answer
@@ -550,122 +958,357 @@ func (s *SmartContract) AnswerTradeAsOwner(ctx contractapi.TransactionContextInt
 		}
 	}
 	if foundAnswer == nil {
-		return fmt.Errorf("there is not an answer for this identifier: %v", sellerIDHash)
+		return nil, fmt.Errorf("there is not an answer for this identifier: %v", sellerIDHash)
 	}
 
 	// Update BuyerResponse
 	foundAnswer.BuyerResponse.Value = answerValue
 	foundAnswer.BuyerResponse.Timestamp = timestamp
+	foundAnswer.BuyerResponse.CounterOfferHash = ""
 
 	if foundAnswer.SellerResponse.Value == "out" {
-		return fmt.Errorf("seller refused trade, you cannot answer it")
+		return nil, fmt.Errorf("seller refused trade, you cannot answer it")
 	}
 
+	settle := false
 	if answerValue == "counter" {
 		if foundAnswer.SellerResponse.Value == "done" {
-			return fmt.Errorf("seller already accepted the BidPrice: %v", foundTrade.BidPrice)
+			return nil, fmt.Errorf("seller already accepted the BidPrice: %v", foundTrade.BidPrice)
+		}
+		foundAnswer.BuyerResponse.CounterPrice, err = s.parsePrice(ctx, counterPrice)
+		if err != nil {
+			return nil, err
+		}
+		err = s.requirePriceTick(ctx, foundTrade, foundAnswer.BuyerResponse.CounterPrice)
+		if err != nil {
+			return nil, err
 		}
-		foundAnswer.BuyerResponse.CounterPrice = counterPrice
 	} else if answerValue == "done" {
 		foundAnswer.BuyerResponse.CounterPrice = foundAnswer.SellerResponse.CounterPrice
-
-		// If seller answers with counter, it still needs their confirmation
-		if foundAnswer.SellerResponse.Value == "done" {
-			// Create transaction
-
-			// Get bond from ledger
-			bonds, err := s.getAllBonds(ctx)
+		if foundAnswer.SellerResponse.CounterOfferHash != "" {
+			// Accepting a private counter offer makes its price public
+			foundAnswer.BuyerResponse.CounterPrice, err = s.privateCounterPrice(ctx, directTradeID, sellerIDHash, foundAnswer.SellerResponse.CounterOfferHash)
 			if err != nil {
-				return fmt.Errorf("failed to get bond: %v", err)
-			}
-
-			// Find the bond owned by the caller
-			var ownedBond *AgencyMBSPassthrough
-			for _, bond := range bonds {
-				if bond.OwnerHash == sellerIDHash {
-					ownedBond = &bond
-					break
-				}
-			}
-			if ownedBond == nil {
-				return fmt.Errorf("the seller does not own any bonds for this trade")
-			}
-
-			// Update bond owner
-			for i, bond := range ledger.Bonds {
-				if bond.OwnerHash == sellerIDHash {
-					ownedBond = &bond
-					ledger.Bonds[i].OwnerHash = foundTrade.BidderHash
-					break
-				}
+				return nil, err
 			}
+		}
 
-			// Close the Trade
-			foundTrade.State = "Closed"
-
-			// Generate transaction
-			transaction := s.GenerateTransactionObject(foundTrade.BidderHash, foundAnswer.SellerIDHash, foundTrade.Cusip, foundTrade.OriginalFace, fmt.Sprintf("%.2f", foundAnswer.BuyerResponse.CounterPrice), timestamp)
+		// If seller answers with counter, it still needs their confirmation
+		settle = foundAnswer.SellerResponse.Value == "done"
+	} else if answerValue == "no" || answerValue == "out" {
+		// The buyer turned this seller down, so their bond is no longer held
+		releaseAnswer(ledger, foundTrade.DirectTradeID, foundAnswer)
+		foundAnswer.BondUID = ""
+	}
 
-			// Add transaction to ledger
-			ledger.Transactions = append(ledger.Transactions, transaction)
+	err = emitTradeEvent(ctx, TradeAnsweredEvent, foundTrade, sellerIDHash, foundAnswer.BuyerResponse.CounterPrice, answerValue)
+	if err != nil {
+		return nil, err
+	}
+	if settle {
+		// Create transaction
+		err = s.settleOrAgree(ctx, ledger, foundTrade, foundAnswer, timestamp)
+		if err != nil {
+			return nil, err
 		}
 	}
 
 	// Update ledger
 	err = s.updateLedger(ctx, ledger)
 	if err != nil {
-		return fmt.Errorf("failed to update ledger: %v", err)
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
 	}
 
-	return nil
+	answered := OrderEvent{Action: "Answer", OrderType: "Trade", OrderID: directTradeID, Cusip: foundTrade.Cusip, Face: foundTrade.OriginalFace, Price: foundAnswer.BuyerResponse.CounterPrice, Detail: answerValue}
+	err = s.recordOrderEvents(ctx, append([]OrderEvent{answered}, executionEvents(ledger, executed, "Trade", directTradeID)...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
 }
 
 // CreateTransaction generates a new transaction and adds it to the ledger
-func (s *SmartContract) CreateTransaction(ctx contractapi.TransactionContextInterface, buyerID, sellerID, cusip string, originalFace int, boughtPrice float64, timestamp time.Time) error {
+func (s *SmartContract) CreateTransaction(ctx contractapi.TransactionContextInterface, buyerID, sellerID, cusip string, originalFace int64, boughtPrice string, timestamp time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &timestamp)
+	if err != nil {
+		return nil, err
+	}
+	price, err := s.parsePrice(ctx, boughtPrice)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create transaction object
 	transaction := Transaction{
 		BuyerID:      buyerID,
 		SellerID:     sellerID,
 		Cusip:        cusip,
 		OriginalFace: originalFace,
-		BoughtPrice:  fmt.Sprintf("%.2f", boughtPrice),
+		BoughtPrice:  price,
 		Timestamp:    timestamp,
 	}
 
-	// Retrieve ledger
-	ledger, err := s.GetLedger(ctx)
+	// Retrieve the ledger of the cusip
+	ledger, err := s.getCusipLedger(ctx, cusip)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Add transaction to ledger
-	ledger.Transactions = append(ledger.Transactions, transaction)
+	appendTransaction(ctx, ledger, transaction)
 
 	// Update ledger
 	err = s.updateLedger(ctx, ledger)
 	if err != nil {
-		return fmt.Errorf("failed to update ledger: %v", err)
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
 	}
 
-	return nil
+	return newWriteResponse(ctx, nil)
+}
+
+// TransferBond moves the caller's bond with the given UID to a new owner outside any trade. No cash changes hands:
+// the move is recorded as a Transaction of type Transfer and raises BondTransferred
+func (s *SmartContract) TransferBond(ctx contractapi.TransactionContextInterface, uid, newOwnerHash string) (*WriteResponse, error) {
+	if newOwnerHash == "" {
+		return nil, fmt.Errorf("the new owner cannot be empty")
+	}
+
+	ledger, err := s.getBondLedger(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	bondIndex := findBondByUID(ledger, uid)
+	if bondIndex == -1 {
+		return nil, NewError(ErrNotFound, "bond with UID %s not found", uid)
+	}
+	bond := &ledger.Bonds[bondIndex]
+	if !s.ownsBond(ctx, bond) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of the bond")
+	}
+	previousOwner, err := s.GenerateOrgHash(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate owner hash: %v", err)
+	}
+	if previousOwner == newOwnerHash {
+		return nil, fmt.Errorf("you already own the bond")
+	}
+	// A bond held for a trade, offer, pledge, loan or repo must be freed first
+	if bond.ReservedFor != "" {
+		return nil, NewConflictError(bond.UID, bond.Cusip, bond.ReservedFor)
+	}
+	if !bond.tradable() {
+		return nil, fmt.Errorf("bond %s is %s", bond.UID, bond.Status)
+	}
+	err = s.requireActivePool(ctx, bond.Cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = bond.deliver(newOwnerHash)
+	if err != nil {
+		return nil, err
+	}
+
+	transaction := s.GenerateTransactionObject(newOwnerHash, previousOwner, bond.Cusip, bond.OriginalFace, "", timestamp)
+	transaction.Type = "Transfer"
+	appendTransaction(ctx, ledger, transaction)
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	err = emitBondTransferred(ctx, *bond, previousOwner, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
 }
 
 // ⭐ Helper functions for accessing ledger and private collection ⭐
 
+// updateLedger writes the bonds, direct trades and transactions through the stores of the contract's storage layout.
+// A ledger read for one cusip writes back only that cusip, and adds its transactions to the stored ones.
+// A transaction does not read its own writes, so a function changing records of several cusips reads them all with
+// GetLedger, changes them on that one ledger and writes it once
 func (s *SmartContract) updateLedger(ctx contractapi.TransactionContextInterface, ledger *Ledger) error {
-	ledgerBytes, err := json.Marshal(ledger)
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return err
+	}
+	if ledger.cash != nil {
+		err = s.saveCash(ctx, ledger.cash)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ledger.cusip != "" {
+		for _, bond := range ledger.Bonds {
+			if bond.Cusip != ledger.cusip {
+				return fmt.Errorf("bond %s of Cusip %s cannot be stored with the bonds of Cusip %s", bond.UID, bond.Cusip, ledger.cusip)
+			}
+		}
+		for _, trade := range ledger.DirectTrades {
+			if trade.Cusip != ledger.cusip {
+				return fmt.Errorf("direct trade %s of Cusip %s cannot be stored with the trades of Cusip %s", trade.DirectTradeID, trade.Cusip, ledger.cusip)
+			}
+		}
+		err = stores.Bonds.PutCusipBonds(ledger.cusip, ledger.Bonds)
+		if err != nil {
+			return err
+		}
+		err = stores.Trades.PutCusipDirectTrades(ledger.cusip, ledger.DirectTrades)
+		if err != nil {
+			return err
+		}
+		return stores.Trades.AddTransactions(ledger.Transactions)
+	}
+
+	err = stores.Bonds.PutBonds(ledger.Bonds)
+	if err != nil {
+		return err
+	}
+	err = stores.Trades.PutDirectTrades(ledger.DirectTrades)
 	if err != nil {
-		return fmt.Errorf("failed to marshal ledger: %v", err)
+		return err
+	}
+
+	return stores.Trades.PutTransactions(ledger.Transactions)
+}
+
+// appendTransaction adds a transaction to the ledger with its TxnID: the ID of the Fabric transaction,
+// suffixed with -1, -2 and so on when the Fabric transaction recorded transactions before it
+func appendTransaction(ctx contractapi.TransactionContextInterface, ledger *Ledger, transaction Transaction) {
+	txID := ctx.GetStub().GetTxID()
+	recorded := 0
+	for _, previous := range ledger.Transactions {
+		if previous.TxnID == txID || strings.HasPrefix(previous.TxnID, txID+"-") {
+			recorded++
+		}
+	}
+
+	transaction.TxnID = txID
+	if recorded > 0 {
+		transaction.TxnID = fmt.Sprintf("%s-%d", txID, recorded)
 	}
+	ledger.Transactions = append(ledger.Transactions, transaction)
+}
 
-	err = ctx.GetStub().PutState("ledger", ledgerBytes)
+// putRecord marshals a record and stores it in the world state under the composite key objectType~id
+func (s *SmartContract) putRecord(ctx contractapi.TransactionContextInterface, objectType, id string, record interface{}) error {
+	return s.putCompositeRecord(ctx, objectType, []string{id}, record)
+}
+
+// getRecord reads the record stored under the composite key objectType~id into record. It returns false when there is none
+func (s *SmartContract) getRecord(ctx contractapi.TransactionContextInterface, objectType, id string, record interface{}) (bool, error) {
+	return s.getCompositeRecord(ctx, objectType, []string{id}, record)
+}
+
+// putCompositeRecord marshals a record and stores it in the world state under the composite key built from objectType and attributes
+func (s *SmartContract) putCompositeRecord(ctx contractapi.TransactionContextInterface, objectType string, attributes []string, record interface{}) error {
+	key, err := ctx.GetStub().CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return fmt.Errorf("failed to create %s key: %v", objectType, err)
+	}
+
+	recordBytes, err := json.Marshal(record)
 	if err != nil {
-		return fmt.Errorf("failed to update ledger: %v", err)
+		return fmt.Errorf("failed to marshal %s: %v", objectType, err)
+	}
+
+	err = ctx.GetStub().PutState(key, recordBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put %s %s: %v", objectType, strings.Join(attributes, "~"), err)
 	}
 
 	return nil
 }
 
+// getCompositeRecord reads the record stored under the composite key built from objectType and attributes into record.
+// It returns false when there is none
+func (s *SmartContract) getCompositeRecord(ctx contractapi.TransactionContextInterface, objectType string, attributes []string, record interface{}) (bool, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return false, fmt.Errorf("failed to create %s key: %v", objectType, err)
+	}
+
+	recordBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s %s: %v", objectType, strings.Join(attributes, "~"), err)
+	}
+	if recordBytes == nil {
+		return false, nil
+	}
+
+	err = json.Unmarshal(recordBytes, record)
+	if err != nil {
+		return false, fmt.Errorf("failed to unmarshal %s %s: %v", objectType, strings.Join(attributes, "~"), err)
+	}
+
+	return true, nil
+}
+
+// deleteRecord removes the record stored under the composite key objectType~id
+func (s *SmartContract) deleteRecord(ctx contractapi.TransactionContextInterface, objectType, id string) error {
+	key, err := ctx.GetStub().CreateCompositeKey(objectType, []string{id})
+	if err != nil {
+		return fmt.Errorf("failed to create %s key: %v", objectType, err)
+	}
+
+	err = ctx.GetStub().DelState(key)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s %s: %v", objectType, id, err)
+	}
+
+	return nil
+}
+
+// parseTimestamp parses an RFC3339 timestamp, with or without fractional seconds and with any offset, and returns it in UTC
+func parseTimestamp(value string) (time.Time, error) {
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timestamp must be in the RFC3339 format, e.g. 2023-01-09T12:00:00Z: %v", err)
+	}
+
+	return parsed.UTC(), nil
+}
+
+// toUTC converts timestamps received as arguments to UTC, so every record stores them in the same zone
+func toUTC(timestamps ...*time.Time) {
+	for _, timestamp := range timestamps {
+		*timestamp = timestamp.UTC()
+	}
+}
+
+// txTimestamp returns the timestamp of the transaction in UTC, which is the same on every endorsing peer
+func txTimestamp(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	return protoTime(timestamp), nil
+}
+
+// protoTime converts a protobuf timestamp, such as those of transactions and of key modifications, to a time in UTC
+func protoTime(timestamp *timestamppb.Timestamp) time.Time {
+	return time.Unix(timestamp.GetSeconds(), int64(timestamp.GetNanos())).UTC()
+}
+
+// implicitCollection returns the name of the implicit private data collection of an organization
+func implicitCollection(mspID string) string {
+	return "_implicit_org_" + mspID
+}
+
 func (s *SmartContract) getEncryptionKey(ctx contractapi.TransactionContextInterface) (string, error) {
 	mspID, err := ctx.GetClientIdentity().GetMSPID()
 	if err != nil {
@@ -677,7 +1320,7 @@ func (s *SmartContract) getEncryptionKey(ctx contractapi.TransactionContextInter
 		return "", fmt.Errorf("_implicit_org_%s - failed to get encryption key: %v", mspID, err)
 	}
 	if privateCollectionBytes == nil {
-		return "", fmt.Errorf("_implicit_org_%s - encryption key not found", mspID)
+		return "", NewError(ErrNotFound, "_implicit_org_%s - encryption key not found", mspID)
 	}
 
 	encryptionKey := string(privateCollectionBytes)
@@ -694,6 +1337,10 @@ func (s *SmartContract) storePrivateBond(ctx contractapi.TransactionContextInter
 	// Adding new private bond
 	privateBonds = append(privateBonds, privateBond)
 
+	return s.putPrivateBonds(ctx, privateBonds)
+}
+
+func (s *SmartContract) putPrivateBonds(ctx contractapi.TransactionContextInterface, privateBonds []PrivateBond) error {
 	// Storing updated private bonds
 	privateBondsBytes, err := json.Marshal(privateBonds)
 	if err != nil {
@@ -725,7 +1372,7 @@ func (s *SmartContract) getPrivateBond(ctx contractapi.TransactionContextInterfa
 		}
 	}
 
-	return PrivateBond{}, fmt.Errorf("private bond with UID %s not found", uid)
+	return PrivateBond{}, NewError(ErrNotFound, "private bond with UID %s not found", uid)
 }
 
 func (s *SmartContract) getPrivateBonds(ctx contractapi.TransactionContextInterface) ([]PrivateBond, error) {
@@ -752,21 +1399,447 @@ func (s *SmartContract) getPrivateBonds(ctx contractapi.TransactionContextInterf
 }
 
 func (s *SmartContract) getAllBonds(ctx contractapi.TransactionContextInterface) ([]AgencyMBSPassthrough, error) {
-	ledger, err := s.GetLedger(ctx)
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return stores.Bonds.GetBonds()
+}
+
+// createTrade creates a direct trade priced in a currency, empty for the settlement currency, and executes it against
+// the resting offers it reaches when it is in the settlement currency
+func (s *SmartContract) createTrade(ctx contractapi.TransactionContextInterface, directTradeID, bidderHash, cusip, currency, createdAtString string, originalFace int64, bidPrice string, allowPartial bool, expiresAtString string) (*WriteResponse, error) {
+	// Generating UID for direct trade. This part should be done manually and inputed in the args. In the front-end, you can manage this properly
+	// directTradeID := generateUID()
+	err := s.requireNewDirectTradeID(ctx, directTradeID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the time string into a time.Time type
+	parsedTime, err := parseTimestamp(createdAtString)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkClientTime(ctx, &parsedTime)
+	if err != nil {
+		return nil, err
+	}
+	err = s.requireActiveCounterparty(ctx)
 	if err != nil {
 		return nil, err
 	}
+	// The bidder pays for every fill, so only the bidder can bid
+	if !s.IsOwner(ctx, bidderHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of %s", bidderHash)
+	}
+	price, err := s.parsePrice(ctx, bidPrice)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt, err := s.parseTradeExpiry(ctx, expiresAtString, parsedTime)
+	if err != nil {
+		return nil, err
+	}
+	err = s.requireTradeFace(ctx, originalFace)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.requireActivePool(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	reference, err := s.nextTradeReference(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Creating new direct trade object
+	trade := DirectTrade{
+		DirectTradeID: directTradeID,
+		Reference:     reference,
+		Cusip:         cusip,
+		OriginalFace:  originalFace,
+		BidPrice:      price,
+		BidderHash:    bidderHash,
+		State:         "Open",
+		Answers:       []Answer{},
+		CreatedAt:     parsedTime,
+		AllowPartial:  allowPartial,
+		RemainingFace: originalFace,
+		ExpiresAt:     expiresAt,
+		Currency:      currency,
+	}
+	err = s.requirePriceTick(ctx, &trade, price)
+	if err != nil {
+		return nil, err
+	}
+
+	// Storing direct trade in ledger
+	ledger, err := s.getCusipLedger(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitTradeEvent(ctx, TradeCreatedEvent, &trade, "", price, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Execute against resting offers before the bid rests itself
+	executed := len(ledger.Transactions)
+	if currency == "" {
+		err = s.crossBid(ctx, ledger, &trade)
+		if err != nil {
+			return nil, fmt.Errorf("failed to cross bid with offers: %v", err)
+		}
+	}
+
+	ledger.DirectTrades = append(ledger.DirectTrades, trade)
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store direct trade: %v", err)
+	}
+
+	err = s.notifyWatchers(ctx, "Trade", directTradeID, cusip, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	created := OrderEvent{Action: "Create", OrderType: "Trade", OrderID: directTradeID, Cusip: cusip, Face: originalFace, Price: price}
+	err = s.recordOrderEvents(ctx, append([]OrderEvent{created}, executionEvents(ledger, executed, "Trade", directTradeID)...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, directTradeID)
+}
+
+// requireNewDirectTradeID checks that a direct trade ID chosen by the client is set and not taken by another trade
+func (s *SmartContract) requireNewDirectTradeID(ctx contractapi.TransactionContextInterface, directTradeID string) error {
+	if directTradeID == "" {
+		return NewError(ErrInvalidInput, "the direct trade ID cannot be empty")
+	}
+
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return err
+	}
+	existing, err := stores.Trades.GetDirectTrade(directTradeID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return NewError(ErrAlreadyExists, "direct trade %s already exists", directTradeID)
+	}
+
+	return nil
+}
+
+// settleDirectTrade transfers the bond the seller reserved for the trade to the bidder, settles the trade and records the transaction
+func (s *SmartContract) settleDirectTrade(ctx contractapi.TransactionContextInterface, ledger *Ledger, trade *DirectTrade, answer *Answer, timestamp time.Time) error {
+	// Generate transaction, paid from the bidder's cash account
+	transaction, price, err := s.directTradeTransaction(ctx, trade, answer, timestamp)
+	if err != nil {
+		return err
+	}
+	err = s.settleTransactionIn(ctx, ledger, transaction, price.value(), trade.Currency)
+	if err != nil {
+		return err
+	}
+
+	return s.deliverDirectTrade(ctx, ledger, trade, answer, price)
+}
+
+// directTradeTransaction returns the transaction of a direct trade at the price of the agreed answer, and that price.
+// A price agreed as a spread is fixed at the benchmark level at execution
+func (s *SmartContract) directTradeTransaction(ctx contractapi.TransactionContextInterface, trade *DirectTrade, answer *Answer, timestamp time.Time) (Transaction, Price, error) {
+	price := answer.BuyerResponse.CounterPrice
+	var level float64
+	if trade.Benchmark != "" {
+		spread := price.value()
+		var err error
+		price, level, err = s.priceFromSpread(ctx, trade.Cusip, trade.Benchmark, spread)
+		if err != nil {
+			return Transaction{}, "", err
+		}
+	}
+	transaction := s.GenerateTransactionObject(trade.BidderHash, answer.SellerIDHash, trade.Cusip, trade.openFace(), string(price), timestamp)
+	if trade.Benchmark != "" {
+		transaction.Benchmark = trade.Benchmark
+		transaction.BenchmarkLevel = level
+		transaction.Spread = answer.BuyerResponse.CounterPrice.value()
+	}
+
+	return transaction, price, nil
+}
+
+// deliverDirectTrade delivers the bond the seller pinned to the agreed answer of a direct trade, once it was paid at the price,
+// and settles the trade
+func (s *SmartContract) deliverDirectTrade(ctx contractapi.TransactionContextInterface, ledger *Ledger, trade *DirectTrade, answer *Answer, price Price) error {
+	// Find the bond the seller pinned to the answer
+	bondIndex, err := pinnedBond(ledger, trade, answer, s.ownerFor(ctx, answer.SellerIDHash))
+	if err != nil {
+		return err
+	}
+	face := trade.openFace()
+
+	// Delivery against payment: the bond leaves the seller, so no haircut can stay reserved on it in the collateral chaincode
+	err = s.releaseHaircut(ctx, HaircutRequest{
+		Reference:    trade.DirectTradeID,
+		PartyHash:    answer.SellerIDHash,
+		UID:          ledger.Bonds[bondIndex].UID,
+		Cusip:        trade.Cusip,
+		OriginalFace: ledger.Bonds[bondIndex].OriginalFace,
+		CashAmount:   marketValue(face, price.value()),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Update bond owner. Only the traded share of a syndicated bond moves
+	bond := &ledger.Bonds[bondIndex]
+	if bond.syndicated() {
+		err = bond.moveShare(answer.SellerIDHash, trade.BidderHash, face)
+		if err != nil {
+			return err
+		}
+	} else {
+		err = bond.deliver(s.ownerHashFor(ctx, trade.BidderHash, bond.UID))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Settle the Trade and free whatever other sellers were holding for it
+	err = directTradeLifecycle.move(trade.DirectTradeID, &trade.State, "Settled")
+	if err != nil {
+		return err
+	}
+	trade.RemainingFace = 0
+	releaseReservations(ledger, trade.DirectTradeID, "")
 
-	return ledger.Bonds, nil
+	err = emitTradeEvent(ctx, TradeSettledEvent, trade, answer.SellerIDHash, price, "")
+	if err != nil {
+		return err
+	}
+	if bond.syndicated() {
+		return emitShareTransferred(ctx, bond, answer.SellerIDHash, trade.BidderHash, face, trade.DirectTradeID)
+	}
+	return emitBondTransferred(ctx, *bond, answer.SellerIDHash, trade.DirectTradeID)
+}
+
+// reserveBond holds a bond of the given cusip owned by ownerHash for the trade and returns its index in the ledger.
+// When face is not 0 only bonds with that original face qualify, or syndicated bonds the owner holds a share of at least face of.
+// A bond already held for the same trade is reused, and bonds held for other trades are never taken:
+// if those are all the owner has, a ConflictError naming the blocking trade is returned.
+func reserveBond(ledger *Ledger, owner bondOwner, cusip, tradeID string, face int64) (int, error) {
+	free := -1
+	blocked := -1
+	owned := false
+	for i, bond := range ledger.Bonds {
+		held := owner.share(&ledger.Bonds[i])
+		if held == 0 || bond.Cusip != cusip || !bond.tradable() {
+			continue
+		}
+		owned = true
+		if !bond.deliverable(held, face) {
+			continue
+		}
+		if bond.ReservedFor == tradeID {
+			return i, nil
+		}
+		if bond.ReservedFor == "" && free == -1 {
+			free = i
+		} else if bond.ReservedFor != "" && blocked == -1 {
+			blocked = i
+		}
+	}
+	if free == -1 {
+		if blocked != -1 {
+			return -1, NewConflictError(ledger.Bonds[blocked].UID, cusip, ledger.Bonds[blocked].ReservedFor)
+		}
+		if owned {
+			return -1, fmt.Errorf("the seller has no bond of Cusip %s with an original face of %d", cusip, face)
+		}
+		return -1, fmt.Errorf("the seller does not own a position in Cusip %s", cusip)
+	}
+
+	err := ledger.Bonds[free].reserve(tradeID)
+	if err != nil {
+		return -1, err
+	}
+	return free, nil
+}
+
+// pinnedBond returns the index in the ledger of the bond the seller pinned to an answer, after checking that it is still
+// the seller's, held for the trade, and of the face being bought. Answers stored before bonds were pinned hold one by owner
+func pinnedBond(ledger *Ledger, trade *DirectTrade, answer *Answer, seller bondOwner) (int, error) {
+	if answer.BondUID == "" {
+		bondIndex, err := reserveBond(ledger, seller, trade.Cusip, trade.DirectTradeID, trade.openFace())
+		if err != nil {
+			return -1, err
+		}
+		answer.BondUID = ledger.Bonds[bondIndex].UID
+		return bondIndex, nil
+	}
+
+	bondIndex := findBondByUID(ledger, answer.BondUID)
+	if bondIndex == -1 {
+		return -1, fmt.Errorf("bond %s pinned to the answer of %s is no longer on the ledger", answer.BondUID, answer.SellerIDHash)
+	}
+	bond := ledger.Bonds[bondIndex]
+	if !seller.mayOwn(&bond) || bond.Cusip != trade.Cusip || bond.ReservedFor != trade.DirectTradeID {
+		return -1, fmt.Errorf("bond %s is no longer held by %s for direct trade %s", bond.UID, answer.SellerIDHash, trade.DirectTradeID)
+	}
+	if !bond.deliverable(seller.share(&bond), trade.openFace()) {
+		return -1, fmt.Errorf("bond %s has an original face of %d, direct trade %s is for %d", bond.UID, bond.OriginalFace, trade.DirectTradeID, trade.openFace())
+	}
+
+	return bondIndex, nil
+}
+
+// releaseReservations frees every bond held for the trade. When ownerHash is not empty only that owner's bonds are freed
+func releaseReservations(ledger *Ledger, tradeID, ownerHash string) {
+	for i, bond := range ledger.Bonds {
+		if bond.ReservedFor == tradeID && (ownerHash == "" || bond.OwnerHash == ownerHash) {
+			ledger.Bonds[i].release()
+		}
+	}
+}
+
+// releaseAnswer frees the bond the seller of an answer holds for the trade. Answers stored before bonds were pinned
+// hold one by owner
+func releaseAnswer(ledger *Ledger, tradeID string, answer *Answer) {
+	if answer.BondUID == "" {
+		releaseReservations(ledger, tradeID, answer.SellerIDHash)
+		return
+	}
+
+	bondIndex := findBondByUID(ledger, answer.BondUID)
+	if bondIndex != -1 && ledger.Bonds[bondIndex].ReservedFor == tradeID {
+		ledger.Bonds[bondIndex].release()
+	}
+}
+
+// releaseStaleReservations frees the bonds held for direct trades of the ledger that are no longer open or are past their expiry
+func releaseStaleReservations(ledger *Ledger, now time.Time) {
+	for _, trade := range ledger.DirectTrades {
+		if trade.awaitingSettlement() {
+			continue
+		}
+		if !trade.open() || trade.expiredAt(now) {
+			releaseReservations(ledger, trade.DirectTradeID, "")
+		}
+	}
+}
+
+// expiredAt reports whether the trade's expiry has passed at the given time. Trades without an expiry never expire
+func (t *DirectTrade) expiredAt(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+// requireUnexpiredTrade returns an error if the trade expired, whether or not ExpireStaleTrades already marked it
+func requireUnexpiredTrade(ctx contractapi.TransactionContextInterface, trade *DirectTrade) error {
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	if trade.State == "Expired" || trade.expiredAt(now) {
+		return fmt.Errorf("direct trade %s expired at %v", trade.DirectTradeID, trade.ExpiresAt)
+	}
+
+	return nil
+}
+
+// parseTradeExpiry parses the expiry passed to a new trade, which must come after its creation.
+// An empty one falls back on the default trade expiry of the contract settings, and is zero when there is none
+func (s *SmartContract) parseTradeExpiry(ctx contractapi.TransactionContextInterface, expiresAtString string, createdAt time.Time) (time.Time, error) {
+	if expiresAtString == "" {
+		config, err := s.GetConfig(ctx)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if config.DefaultTradeExpiryHours == 0 {
+			return time.Time{}, nil
+		}
+		return createdAt.Add(time.Duration(config.DefaultTradeExpiryHours) * time.Hour), nil
+	}
+
+	expiresAt, err := parseTimestamp(expiresAtString)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !expiresAt.After(createdAt) {
+		return time.Time{}, fmt.Errorf("trade must expire after it is created")
+	}
+
+	return expiresAt, nil
+}
+
+// openFace returns the face of the trade still to be bought. Trades stored before partial fills existed are fully open
+func (t *DirectTrade) openFace() int64 {
+	if t.RemainingFace == 0 && t.open() {
+		return t.OriginalFace
+	}
+	return t.RemainingFace
+}
+
+// open reports whether the trade still takes answers and fills: it is open or answered
+func (t *DirectTrade) open() bool {
+	return t.State == "Open" || t.State == "Answered"
+}
+
+// markAnswered moves an open trade to Answered once a seller answered it. Later answers leave it Answered
+func (t *DirectTrade) markAnswered() error {
+	if t.State != "Open" {
+		return nil
+	}
+	return directTradeLifecycle.move(t.DirectTradeID, &t.State, "Answered")
+}
+
+// closingState returns the state of a trade closed before it settled: Closed when part of it was already filled, Cancelled otherwise
+func (t *DirectTrade) closingState() string {
+	if t.openFace() < t.OriginalFace {
+		return "Closed"
+	}
+	return "Cancelled"
+}
+
+// reserveAt returns the reserve price of a private bond, or an error when it does not apply at the given time.
+// Matching and negotiation code must go through it, so that an expired reserve is never acted on
+func (p PrivateBond) reserveAt(at time.Time) (Price, error) {
+	if !p.ValidFrom.IsZero() && at.Before(p.ValidFrom) {
+		return "", fmt.Errorf("the reserve price of bond %s only applies from %v", p.UID, p.ValidFrom)
+	}
+	if !p.ValidUntil.IsZero() && !at.Before(p.ValidUntil) {
+		return "", fmt.Errorf("the reserve price of bond %s expired at %v", p.UID, p.ValidUntil)
+	}
+
+	return p.ReservePrice, nil
+}
+
+// findOwnedBond returns the index in the ledger of the first bond with the given cusip the owner holds, or -1 if there is none
+func findOwnedBond(ledger *Ledger, owner bondOwner, cusip string) int {
+	for i, bond := range ledger.Bonds {
+		if owner.owns(&ledger.Bonds[i]) && bond.Cusip == cusip && bond.tradable() {
+			return i
+		}
+	}
+
+	return -1
 }
 
 func (s *SmartContract) getAllTransactions(ctx contractapi.TransactionContextInterface) ([]Transaction, error) {
-	ledger, err := s.GetLedger(ctx)
+	stores, err := s.stores(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	return ledger.Transactions, nil
+	return stores.Trades.GetTransactions()
 }
 
 func generateUID() string {
@@ -774,7 +1847,7 @@ func generateUID() string {
 }
 
 // ⚠️ Debugger function: ClearLedger resets the ledger by making it empty
-func (s *SmartContract) ClearLedger(ctx contractapi.TransactionContextInterface) error {
+func (s *SmartContract) ClearLedger(ctx contractapi.TransactionContextInterface) (*WriteResponse, error) {
 	// Create an empty ledger
 	emptyLedger := &Ledger{
 		Bonds:        []AgencyMBSPassthrough{},
@@ -785,8 +1858,8 @@ func (s *SmartContract) ClearLedger(ctx contractapi.TransactionContextInterface)
 	// Update the ledger
 	err := s.updateLedger(ctx, emptyLedger)
 	if err != nil {
-		return fmt.Errorf("failed to clear ledger: %v", err)
+		return nil, fmt.Errorf("failed to clear ledger: %v", err)
 	}
 
-	return nil
+	return newWriteResponse(ctx, nil)
 }
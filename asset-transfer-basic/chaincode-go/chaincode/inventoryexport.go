@@ -0,0 +1,151 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// InventoryExport is a copy of an organization's inventory that can be imported back into its collection,
+// for instance in another environment or after the collection is reconfigured
+type InventoryExport struct {
+	Owner      string                         `json:"owner"` // MSP ID of the organization that exported the inventory
+	ExportedAt time.Time                      `json:"exportedAt"`
+	Assets     []*PrivateAgencyMBSPassthrough `json:"assets"`
+	ItemHashes []string                       `json:"itemHashes"` // SHA-256 of each item JSON, in hex, in the order of Assets
+	Hash       string                         `json:"hash"`       // SHA-256 of the owner and the item hashes, in hex
+}
+
+// ⭐ Functions ⭐
+
+// ExportInventory returns the caller's whole inventory along with the hashes ImportInventory checks it against
+func (s *SmartContract) ExportInventory(ctx contractapi.TransactionContextInterface) (*InventoryExport, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	inventory, err := s.GetInventory(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory: %v", err)
+	}
+
+	exportedAt, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &InventoryExport{
+		Owner:      mspID,
+		ExportedAt: exportedAt,
+		Assets:     []*PrivateAgencyMBSPassthrough{},
+		ItemHashes: []string{},
+	}
+	if inventory != nil {
+		export.Assets = inventory.Assets
+	}
+	for _, asset := range export.Assets {
+		itemHash, err := inventoryItemHash(asset)
+		if err != nil {
+			return nil, err
+		}
+		export.ItemHashes = append(export.ItemHashes, itemHash)
+	}
+	export.Hash = inventoryExportHash(export.Owner, export.ItemHashes)
+
+	return export, nil
+}
+
+// ImportInventory adds the items of an export made by ExportInventory to the caller's inventory, keeping their metadata and status.
+// The export is rejected if it was made by another organization, if any hash does not match,
+// or if any of its CUSIPs is already in the inventory
+func (s *SmartContract) ImportInventory(ctx contractapi.TransactionContextInterface, payload string) (*WriteResponse, error) {
+	var export InventoryExport
+	err := json.Unmarshal([]byte(payload), &export)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal inventory export: %v", err)
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	if export.Owner != mspID {
+		return nil, fmt.Errorf("the inventory export belongs to %s and cannot be imported by %s", export.Owner, mspID)
+	}
+
+	// Check the hashes before touching the inventory
+	if len(export.ItemHashes) != len(export.Assets) {
+		return nil, fmt.Errorf("the inventory export has %d items but %d item hashes", len(export.Assets), len(export.ItemHashes))
+	}
+	if inventoryExportHash(export.Owner, export.ItemHashes) != export.Hash {
+		return nil, fmt.Errorf("the inventory export hash does not match its items")
+	}
+	for i, asset := range export.Assets {
+		if asset == nil || asset.Content == nil {
+			return nil, fmt.Errorf("item %d of the inventory export is empty", i)
+		}
+		itemHash, err := inventoryItemHash(asset)
+		if err != nil {
+			return nil, err
+		}
+		if itemHash != export.ItemHashes[i] {
+			return nil, fmt.Errorf("the item with Cusip %s does not match its hash", asset.Content.Cusip)
+		}
+	}
+
+	inventory, err := s.GetInventory(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory: %v", err)
+	}
+	if inventory == nil {
+		inventory = &Inventory{
+			Assets: []*PrivateAgencyMBSPassthrough{},
+		}
+	}
+
+	for _, asset := range export.Assets {
+		existing := findInventoryItem(inventory, asset.Content.Cusip)
+		if existing != nil {
+			return nil, NewDuplicateInventoryError(asset.Content.Cusip, existing.Metadata, existing.listingStatus())
+		}
+		inventory.Assets = append(inventory.Assets, asset)
+	}
+
+	err = s.putInventory(ctx, inventory)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// ⭐ Helper functions ⭐
+
+// inventoryItemHash returns the SHA-256 of an inventory item JSON, in hex
+func inventoryItemHash(asset *PrivateAgencyMBSPassthrough) (string, error) {
+	assetJSON, err := json.Marshal(asset)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal inventory item: %v", err)
+	}
+	hash := sha256.Sum256(assetJSON)
+
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// inventoryExportHash returns the SHA-256 of the owner and the item hashes of an inventory export, in hex
+func inventoryExportHash(owner string, itemHashes []string) string {
+	hash := sha256.New()
+	hash.Write([]byte(owner))
+	for _, itemHash := range itemHashes {
+		hash.Write([]byte(itemHash))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
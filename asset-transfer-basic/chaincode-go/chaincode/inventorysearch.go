@@ -0,0 +1,140 @@
+package chaincode
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// InventoryPage is one page of an organization's inventory
+type InventoryPage struct {
+	Assets   []*PrivateAgencyMBSPassthrough `json:"assets"`
+	Bookmark string                         `json:"bookmark"` // Pass it back to get the next page. Empty on the last page
+}
+
+// InventoryFilter selects inventory items. Empty fields match everything
+type InventoryFilter struct {
+	Cusip  string `json:"cusip"`
+	Class  string `json:"class"` // Matches any of Class1 to Class4
+	Status string `json:"status"`
+}
+
+// Largest page GetInventoryPage returns
+const maxInventoryPageSize = 100
+
+// ⭐ Functions ⭐
+
+// GetInventoryPage returns up to pageSize items of the caller's inventory matching the filters, starting at the bookmark of the previous page.
+// The inventory is still stored as one private blob, so the page is cut after reading it whole
+func (s *SmartContract) GetInventoryPage(ctx contractapi.TransactionContextInterface, pageSize int, bookmark, cusip, class, status string) (*InventoryPage, error) {
+	if pageSize <= 0 || pageSize > maxInventoryPageSize {
+		return nil, fmt.Errorf("page size must be between 1 and %d: %d", maxInventoryPageSize, pageSize)
+	}
+	if status != "" && !isListingStatus(status) {
+		return nil, fmt.Errorf("unknown listing status %s", status)
+	}
+
+	start := 0
+	if bookmark != "" {
+		var err error
+		start, err = strconv.Atoi(bookmark)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid bookmark %s", bookmark)
+		}
+	}
+
+	inventory, err := s.GetInventory(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory: %v", err)
+	}
+
+	page := &InventoryPage{
+		Assets: []*PrivateAgencyMBSPassthrough{},
+	}
+	if inventory == nil {
+		return page, nil
+	}
+
+	filter := InventoryFilter{Cusip: cusip, Class: class, Status: status}
+	for i := start; i < len(inventory.Assets); i++ {
+		if len(page.Assets) == pageSize {
+			page.Bookmark = strconv.Itoa(i)
+			break
+		}
+		if filter.matches(inventory.Assets[i]) {
+			page.Assets = append(page.Assets, inventory.Assets[i])
+		}
+	}
+
+	return page, nil
+}
+
+// FindInInventory returns the items of the caller's inventory with the given CUSIP
+func (s *SmartContract) FindInInventory(ctx contractapi.TransactionContextInterface, cusip string) ([]*PrivateAgencyMBSPassthrough, error) {
+	return s.searchInventory(ctx, func(asset *PrivateAgencyMBSPassthrough) bool {
+		return asset.Content.Cusip == cusip
+	})
+}
+
+// SearchInventory returns the items of the caller's inventory whose coupon and vintage (issue year) fall in the given ranges, bounds included.
+// A zero maximum coupon or vintage bound leaves that side of the range open
+func (s *SmartContract) SearchInventory(ctx contractapi.TransactionContextInterface, minCoupon, maxCoupon float64, minVintage, maxVintage int) ([]*PrivateAgencyMBSPassthrough, error) {
+	if maxCoupon != 0 && maxCoupon < minCoupon {
+		return nil, fmt.Errorf("coupon range is empty: %v to %v", minCoupon, maxCoupon)
+	}
+	if maxVintage != 0 && maxVintage < minVintage {
+		return nil, fmt.Errorf("vintage range is empty: %d to %d", minVintage, maxVintage)
+	}
+
+	return s.searchInventory(ctx, func(asset *PrivateAgencyMBSPassthrough) bool {
+		bond := asset.Content
+		if bond.Coupon < minCoupon || (maxCoupon != 0 && bond.Coupon > maxCoupon) {
+			return false
+		}
+		return bond.IssueYear >= minVintage && (maxVintage == 0 || bond.IssueYear <= maxVintage)
+	})
+}
+
+// ⭐ Helper functions ⭐
+
+// searchInventory returns the items of the caller's inventory the match function accepts. Items without content are skipped
+func (s *SmartContract) searchInventory(ctx contractapi.TransactionContextInterface, match func(*PrivateAgencyMBSPassthrough) bool) ([]*PrivateAgencyMBSPassthrough, error) {
+	inventory, err := s.GetInventory(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory: %v", err)
+	}
+
+	assets := []*PrivateAgencyMBSPassthrough{}
+	if inventory == nil {
+		return assets, nil
+	}
+	for _, asset := range inventory.Assets {
+		if asset.Content != nil && match(asset) {
+			assets = append(assets, asset)
+		}
+	}
+
+	return assets, nil
+}
+
+// matches returns true when the inventory item passes every filter that is set
+func (f InventoryFilter) matches(asset *PrivateAgencyMBSPassthrough) bool {
+	if asset.Content == nil {
+		return false
+	}
+	bond := asset.Content
+	if f.Cusip != "" && bond.Cusip != f.Cusip {
+		return false
+	}
+	if f.Class != "" && bond.Class1 != f.Class && bond.Class2 != f.Class && bond.Class3 != f.Class && bond.Class4 != f.Class {
+		return false
+	}
+	if f.Status != "" && asset.listingStatus() != f.Status {
+		return false
+	}
+
+	return true
+}
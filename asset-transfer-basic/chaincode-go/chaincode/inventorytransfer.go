@@ -0,0 +1,334 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// InventoryTransfer is the public record of an inventory item moving from one organization's collection to another's.
+// Only the hash of the item is public, the item itself travels between the two implicit collections
+type InventoryTransfer struct {
+	TransferID   string    `json:"transferID"`
+	Cusip        string    `json:"cusip"`
+	SenderMSP    string    `json:"senderMSP"`
+	RecipientMSP string    `json:"recipientMSP"`
+	ItemHash     string    `json:"itemHash"` // SHA-256 of the item JSON, in hex
+	State        string    `json:"state"`    //"Offered", "Accepted" or "Cancelled"
+	CreatedAt    time.Time `json:"createdAt"`
+	ClosedAt     time.Time `json:"closedAt"`
+}
+
+// Object type of the composite keys of the transfer records
+const inventoryTransferObjectType = "inventorytransfer"
+
+// ⭐ Functions ⭐
+
+// OfferInventoryTransfer offers an item of the caller's inventory to another organization. The item leaves the inventory and is kept in escrow
+// in the sender's collection, and a copy is handed to the recipient's collection, so both organizations' peers must endorse
+func (s *SmartContract) OfferInventoryTransfer(ctx contractapi.TransactionContextInterface, transferID, cusip, recipientMSP string) (*WriteResponse, error) {
+	senderMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	if recipientMSP == senderMSP {
+		return nil, fmt.Errorf("cannot transfer an inventory item to the organization that holds it")
+	}
+
+	existing, err := s.getInventoryTransfer(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, NewError(ErrAlreadyExists, "inventory transfer %s already exists", transferID)
+	}
+
+	inventory, err := s.GetInventory(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory: %v", err)
+	}
+
+	// Find the item and take it out of the inventory
+	index := -1
+	if inventory != nil {
+		for i, asset := range inventory.Assets {
+			if asset.Content != nil && asset.Content.Cusip == cusip {
+				index = i
+				break
+			}
+		}
+	}
+	if index == -1 {
+		return nil, NewError(ErrNotFound, "bond with CUSIP %s not found in the inventory", cusip)
+	}
+	item := inventory.Assets[index]
+	if item.listingStatus() != StatusHeld {
+		return nil, fmt.Errorf("the bond with Cusip %s cannot be transferred while %s", cusip, item.listingStatus())
+	}
+	inventory.Assets = append(inventory.Assets[:index], inventory.Assets[index+1:]...)
+
+	itemJSON, err := json.Marshal(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal inventory item: %v", err)
+	}
+	itemHash := sha256.Sum256(itemJSON)
+
+	err = ctx.GetStub().PutPrivateData(implicitCollection(senderMSP), transferKey(transferID), itemJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to put escrow of transfer %s: %v", transferID, err)
+	}
+	err = ctx.GetStub().PutPrivateData(implicitCollection(recipientMSP), transferKey(transferID), itemJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hand transfer %s to %s: %v", transferID, recipientMSP, err)
+	}
+
+	createdAt, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := InventoryTransfer{
+		TransferID:   transferID,
+		Cusip:        cusip,
+		SenderMSP:    senderMSP,
+		RecipientMSP: recipientMSP,
+		ItemHash:     hex.EncodeToString(itemHash[:]),
+		State:        "Offered",
+		CreatedAt:    createdAt,
+	}
+	err = s.putRecord(ctx, inventoryTransferObjectType, transferID, &transfer)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.putInventory(ctx, inventory)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// AcceptInventoryTransfer accepts an inventory transfer offered to the caller's organization. The handed item is checked against the public hash,
+// added to the recipient's inventory and purged from both collections, so both organizations' peers must endorse
+func (s *SmartContract) AcceptInventoryTransfer(ctx contractapi.TransactionContextInterface, transferID string) (*WriteResponse, error) {
+	transfer, err := s.openInventoryTransfer(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	if mspID != transfer.RecipientMSP {
+		return nil, fmt.Errorf("inventory transfer %s is not offered to %s", transferID, mspID)
+	}
+
+	itemJSON, err := ctx.GetStub().GetPrivateData(implicitCollection(mspID), transferKey(transferID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transfer %s: %v", transferID, err)
+	}
+	if itemJSON == nil {
+		return nil, fmt.Errorf("transfer %s was not handed to %s", transferID, mspID)
+	}
+	itemHash := sha256.Sum256(itemJSON)
+	if hex.EncodeToString(itemHash[:]) != transfer.ItemHash {
+		return nil, fmt.Errorf("the item of transfer %s does not match its hash on the ledger", transferID)
+	}
+
+	var item PrivateAgencyMBSPassthrough
+	err = json.Unmarshal(itemJSON, &item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal inventory item: %v", err)
+	}
+
+	err = s.addToInventory(ctx, item.Content, StatusHeld)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.purgeTransferItems(ctx, transfer)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.closeInventoryTransfer(ctx, transfer, "Accepted")
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// CancelInventoryTransfer cancels an inventory transfer the caller's organization offered and puts the item back into its inventory.
+// The copy handed to the recipient is purged, so both organizations' peers must endorse
+func (s *SmartContract) CancelInventoryTransfer(ctx contractapi.TransactionContextInterface, transferID string) (*WriteResponse, error) {
+	transfer, err := s.openInventoryTransfer(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	if mspID != transfer.SenderMSP {
+		return nil, fmt.Errorf("inventory transfer %s was not offered by %s", transferID, mspID)
+	}
+
+	itemJSON, err := ctx.GetStub().GetPrivateData(implicitCollection(mspID), transferKey(transferID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get escrow of transfer %s: %v", transferID, err)
+	}
+	if itemJSON == nil {
+		return nil, NewError(ErrNotFound, "escrow of transfer %s not found", transferID)
+	}
+
+	var item PrivateAgencyMBSPassthrough
+	err = json.Unmarshal(itemJSON, &item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal inventory item: %v", err)
+	}
+
+	inventory, err := s.GetInventory(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory: %v", err)
+	}
+	if inventory == nil {
+		inventory = &Inventory{
+			Assets: []*PrivateAgencyMBSPassthrough{},
+		}
+	}
+
+	// The item goes back with its original metadata
+	existing := findInventoryItem(inventory, transfer.Cusip)
+	if existing != nil {
+		return nil, NewDuplicateInventoryError(transfer.Cusip, existing.Metadata, existing.listingStatus())
+	}
+	inventory.Assets = append(inventory.Assets, &item)
+
+	err = s.putInventory(ctx, inventory)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.purgeTransferItems(ctx, transfer)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.closeInventoryTransfer(ctx, transfer, "Cancelled")
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// GetInventoryTransfer returns the public record of an inventory transfer
+func (s *SmartContract) GetInventoryTransfer(ctx contractapi.TransactionContextInterface, transferID string) (*InventoryTransfer, error) {
+	transfer, err := s.getInventoryTransfer(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer == nil {
+		return nil, NewError(ErrNotFound, "inventory transfer %s not found", transferID)
+	}
+
+	return transfer, nil
+}
+
+// GetIncomingInventoryTransfers returns the inventory transfers offered to the caller's organization that are waiting for its answer
+func (s *SmartContract) GetIncomingInventoryTransfers(ctx contractapi.TransactionContextInterface) ([]*InventoryTransfer, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(inventoryTransferObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory transfers: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	transfers := []*InventoryTransfer{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over inventory transfers: %v", err)
+		}
+
+		var transfer InventoryTransfer
+		err = json.Unmarshal(queryResponse.Value, &transfer)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling inventory transfer JSON: %v", err)
+		}
+		if transfer.RecipientMSP == mspID && transfer.State == "Offered" {
+			transfers = append(transfers, &transfer)
+		}
+	}
+
+	return transfers, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// getInventoryTransfer returns the inventory transfer with the given ID, or nil if there is none
+func (s *SmartContract) getInventoryTransfer(ctx contractapi.TransactionContextInterface, transferID string) (*InventoryTransfer, error) {
+	var transfer InventoryTransfer
+	exists, err := s.getRecord(ctx, inventoryTransferObjectType, transferID, &transfer)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &transfer, nil
+}
+
+// openInventoryTransfer returns the inventory transfer with the given ID if it is still waiting for an answer
+func (s *SmartContract) openInventoryTransfer(ctx contractapi.TransactionContextInterface, transferID string) (*InventoryTransfer, error) {
+	transfer, err := s.GetInventoryTransfer(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.State != "Offered" {
+		return nil, fmt.Errorf("inventory transfer %s is %s", transferID, transfer.State)
+	}
+
+	return transfer, nil
+}
+
+// closeInventoryTransfer sets the final state of an inventory transfer
+func (s *SmartContract) closeInventoryTransfer(ctx contractapi.TransactionContextInterface, transfer *InventoryTransfer, state string) error {
+	closedAt, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	transfer.State = state
+	transfer.ClosedAt = closedAt
+	return s.putRecord(ctx, inventoryTransferObjectType, transfer.TransferID, transfer)
+}
+
+// purgeTransferItems purges the escrow in the sender's collection and the copy handed to the recipient, so no history of the item is left behind
+func (s *SmartContract) purgeTransferItems(ctx contractapi.TransactionContextInterface, transfer *InventoryTransfer) error {
+	for _, mspID := range []string{transfer.SenderMSP, transfer.RecipientMSP} {
+		err := ctx.GetStub().PurgePrivateData(implicitCollection(mspID), transferKey(transfer.TransferID))
+		if err != nil {
+			return fmt.Errorf("failed to purge transfer %s from %s: %v", transfer.TransferID, mspID, err)
+		}
+	}
+
+	return nil
+}
+
+// transferKey returns the private data key under which the item of a transfer is kept
+func transferKey(transferID string) string {
+	return "transfer-" + transferID
+}
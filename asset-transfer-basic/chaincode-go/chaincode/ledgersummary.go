@@ -0,0 +1,79 @@
+package chaincode
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// LedgerSummary counts what the public ledger holds, for dashboards that do not need the records themselves
+type LedgerSummary struct {
+	Bonds               int       `json:"bonds"`
+	ActiveBonds         int       `json:"activeBonds"`
+	OpenTrades          int       `json:"openTrades"`          // Direct trades not yet final: Open, Answered, Agreed or Matched
+	ClosedTrades        int       `json:"closedTrades"`        // Direct trades in a final state: Settled, Closed, Cancelled or Expired
+	SettledTransactions int       `json:"settledTransactions"` // Transactions recorded by settlements and transfers
+	OutstandingFace     int64     `json:"outstandingFace"`     // Current face of the active bonds, in cents
+	LastActivity        time.Time `json:"lastActivity"`        // Latest direct trade creation or transaction. Zero on an empty ledger
+}
+
+// ⭐ Functions ⭐
+
+// GetLedgerSummary returns the counts of bonds, direct trades and transactions on the ledger, the outstanding face
+// and the time of the last activity. Records are visited one at a time rather than loaded all at once as GetLedger does
+func (s *SmartContract) GetLedgerSummary(ctx contractapi.TransactionContextInterface) (*LedgerSummary, error) {
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &LedgerSummary{}
+	err = stores.Bonds.ForEachBond(func(bond AgencyMBSPassthrough) {
+		summary.Bonds++
+		if !bond.tradable() {
+			return
+		}
+		summary.ActiveBonds++
+		if bond.CurrentFace != 0 {
+			summary.OutstandingFace += bond.CurrentFace
+		} else {
+			summary.OutstandingFace += bond.derivedCurrentFace()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = stores.Trades.ForEachDirectTrade(func(trade DirectTrade) {
+		if directTradeLifecycle.final(trade.State) {
+			summary.ClosedTrades++
+		} else {
+			summary.OpenTrades++
+		}
+		summary.recordActivity(trade.CreatedAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = stores.Trades.ForEachTransaction(func(transaction Transaction) {
+		summary.SettledTransactions++
+		summary.recordActivity(transaction.Timestamp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// recordActivity moves the last activity of the summary to a later time
+func (summary *LedgerSummary) recordActivity(at time.Time) {
+	if at.After(summary.LastActivity) {
+		summary.LastActivity = at
+	}
+}
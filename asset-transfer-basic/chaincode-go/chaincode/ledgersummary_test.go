@@ -0,0 +1,54 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetLedgerSummary(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUp(t, contract)
+
+			summary, err := contract.GetLedgerSummary(w.begin(org1))
+			require.NoError(t, err)
+			require.Equal(t, chaincode.LedgerSummary{}, *summary)
+
+			w = setUpTrade(t, contract, 200000000)
+			createBond(t, w, contract, "uid2", org2, otherCusip, tradeFace)
+			_, err = contract.RetireBond(w.begin(org2), "uid2")
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+
+			// Open until the bidder agrees too
+			summary, err = contract.GetLedgerSummary(w.begin(org1))
+			require.NoError(t, err)
+			require.Equal(t, 1, summary.OpenTrades)
+			require.Zero(t, summary.SettledTransactions)
+
+			settledAt := testTime.Add(time.Hour)
+			_, err = contract.AnswerTradeAsOwner(w.beginAt(org1, settledAt), "trade1", org2, "done", settledAt, "")
+			require.NoError(t, err)
+			w.commit()
+
+			summary, err = contract.GetLedgerSummary(w.begin(org1))
+			require.NoError(t, err)
+			require.Equal(t, chaincode.LedgerSummary{
+				Bonds:               2,
+				ActiveBonds:         1,
+				OpenTrades:          0,
+				ClosedTrades:        1,
+				SettledTransactions: 1,
+				OutstandingFace:     tradeFace,
+				LastActivity:        settledAt,
+			}, *summary)
+		})
+	}
+}
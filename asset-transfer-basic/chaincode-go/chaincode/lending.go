@@ -0,0 +1,363 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// Loan is a securities loan: the lender delivers a bond to the borrower against cash collateral and earns a fee,
+// keeping the economic ownership of the position. It is not a sale and records no transaction
+type Loan struct {
+	LoanID           string    `json:"loanID"`
+	UID              string    `json:"uid"` // Bond delivered to the borrower
+	Cusip            string    `json:"cusip"`
+	OriginalFace     int64     `json:"originalFace"` // In cents
+	LenderHash       string    `json:"lenderHash"`
+	BorrowerHash     string    `json:"borrowerHash"`
+	CollateralAmount float64   `json:"collateralAmount"` // Cash collateral posted by the borrower
+	FeeRate          float64   `json:"feeRate"`          // Annual lending fee on the collateral, e.g. 0.004 for 40bp
+	State            string    `json:"state"`            //"Proposed", "Open", "Recalled", "Returned" or "Cancelled"
+	CreatedAt        time.Time `json:"createdAt"`
+	StartDate        time.Time `json:"startDate"`
+	RecallDate       time.Time `json:"recallDate"`
+	ReturnDate       time.Time `json:"returnDate"`
+	Fee              float64   `json:"fee"` // Lending fee earned, set when the loan is returned
+}
+
+const loanObjectType = "loan"
+
+// ⭐ Functions ⭐
+
+// ProposeLoan offers to lend the caller's bond with the given UID to the borrower. The bond is held for the loan until it is accepted or cancelled
+func (s *SmartContract) ProposeLoan(ctx contractapi.TransactionContextInterface, loanID, uid, borrowerHash string, collateralAmount, feeRate float64, createdAt time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if collateralAmount <= 0 {
+		return nil, fmt.Errorf("collateral amount must be positive: %v", collateralAmount)
+	}
+	if feeRate < 0 {
+		return nil, fmt.Errorf("fee rate cannot be negative: %v", feeRate)
+	}
+
+	var existing Loan
+	exists, err := s.getRecord(ctx, loanObjectType, loanID, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, NewError(ErrAlreadyExists, "loan %s already exists", loanID)
+	}
+
+	ledger, err := s.getBondLedger(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	bondIndex := findBondByUID(ledger, uid)
+	if bondIndex == -1 {
+		return nil, NewError(ErrNotFound, "bond with UID %s not found", uid)
+	}
+	bond := &ledger.Bonds[bondIndex]
+	owner, err := s.callerOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !owner.owns(bond) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of the bond")
+	}
+	if owner.hash == borrowerHash {
+		return nil, fmt.Errorf("you cannot lend to yourself")
+	}
+	if bond.ReservedFor != "" {
+		return nil, NewConflictError(bond.UID, bond.Cusip, bond.ReservedFor)
+	}
+
+	err = bond.reserve(loanID)
+	if err != nil {
+		return nil, err
+	}
+
+	loan := Loan{
+		LoanID:           loanID,
+		UID:              bond.UID,
+		Cusip:            bond.Cusip,
+		OriginalFace:     bond.OriginalFace,
+		LenderHash:       owner.hash,
+		BorrowerHash:     borrowerHash,
+		CollateralAmount: collateralAmount,
+		FeeRate:          feeRate,
+		State:            "Proposed",
+		CreatedAt:        createdAt,
+	}
+
+	err = s.putRecord(ctx, loanObjectType, loanID, loan)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, loanID)
+}
+
+// AcceptLoan delivers the bond of a proposed loan to the borrower, who is free to use it until the loan is returned
+func (s *SmartContract) AcceptLoan(ctx contractapi.TransactionContextInterface, loanID string, startDate time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &startDate)
+	if err != nil {
+		return nil, err
+	}
+
+	loan, err := s.getLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	if loan.State != "Proposed" {
+		return nil, fmt.Errorf("loan %s is %s", loanID, loan.State)
+	}
+	if !s.IsOwner(ctx, loan.BorrowerHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the borrower of the loan")
+	}
+
+	ledger, err := s.getCusipLedger(ctx, loan.Cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	bondIndex := findBondByUID(ledger, loan.UID)
+	if bondIndex == -1 || ledger.Bonds[bondIndex].ReservedFor != loanID {
+		return nil, fmt.Errorf("the bond of loan %s is no longer available", loanID)
+	}
+	// The borrower posts the cash collateral
+	_, _, err = s.transferCash(ctx, ledger.cashBook(), loan.BorrowerHash, loan.LenderHash, cents(loan.CollateralAmount))
+	if err != nil {
+		return nil, err
+	}
+	err = ledger.Bonds[bondIndex].deliver(s.ownerHashFor(ctx, loan.BorrowerHash, loan.UID))
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitBondTransferred(ctx, ledger.Bonds[bondIndex], loan.LenderHash, loanID)
+	if err != nil {
+		return nil, err
+	}
+
+	loan.State = "Open"
+	loan.StartDate = startDate
+	err = s.putRecord(ctx, loanObjectType, loanID, loan)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// RecallLoan asks the borrower of an open loan to return the position
+func (s *SmartContract) RecallLoan(ctx contractapi.TransactionContextInterface, loanID string, recallDate time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &recallDate)
+	if err != nil {
+		return nil, err
+	}
+
+	loan, err := s.getLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	if loan.State != "Open" {
+		return nil, fmt.Errorf("loan %s is %s", loanID, loan.State)
+	}
+	if !s.IsOwner(ctx, loan.LenderHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the lender of the loan")
+	}
+
+	loan.State = "Recalled"
+	loan.RecallDate = recallDate
+	err = s.putRecord(ctx, loanObjectType, loanID, loan)
+	if err != nil {
+		return nil, err
+	}
+	return newWriteResponse(ctx, nil)
+}
+
+// ReturnLoan gives the lender back a position equivalent to the one lent: a free bond of the borrower with the same
+// Cusip and face, identified by its UID. The lending fee accrues on the collateral up to the return date
+func (s *SmartContract) ReturnLoan(ctx contractapi.TransactionContextInterface, loanID, uid string, returnDate time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &returnDate)
+	if err != nil {
+		return nil, err
+	}
+
+	loan, err := s.getLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	if loan.State != "Open" && loan.State != "Recalled" {
+		return nil, fmt.Errorf("loan %s is %s", loanID, loan.State)
+	}
+	if !s.IsOwner(ctx, loan.BorrowerHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the borrower of the loan")
+	}
+	if returnDate.Before(loan.StartDate) {
+		return nil, fmt.Errorf("loan %s cannot be returned before it started on %v", loanID, loan.StartDate)
+	}
+
+	ledger, err := s.getBondLedger(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	bondIndex := findBondByUID(ledger, uid)
+	if bondIndex == -1 {
+		return nil, NewError(ErrNotFound, "bond with UID %s not found", uid)
+	}
+	bond := &ledger.Bonds[bondIndex]
+	if !s.ownsBond(ctx, bond) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of the bond")
+	}
+	if bond.Cusip != loan.Cusip || bond.OriginalFace != loan.OriginalFace {
+		return nil, fmt.Errorf("the bond must be %d of Cusip %s", loan.OriginalFace, loan.Cusip)
+	}
+	if bond.ReservedFor != "" {
+		return nil, NewConflictError(bond.UID, bond.Cusip, bond.ReservedFor)
+	}
+	err = bond.deliver(s.ownerHashFor(ctx, loan.LenderHash, bond.UID))
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitBondTransferred(ctx, *bond, loan.BorrowerHash, loanID)
+	if err != nil {
+		return nil, err
+	}
+
+	loan.State = "Returned"
+	loan.ReturnDate = returnDate
+	loan.Fee = loan.accruedFee(returnDate)
+
+	// The lender gives the collateral back, keeping its fee
+	_, _, err = s.transferCash(ctx, ledger.cashBook(), loan.LenderHash, loan.BorrowerHash, cents(loan.CollateralAmount-loan.Fee))
+	if err != nil {
+		return nil, err
+	}
+	err = s.putRecord(ctx, loanObjectType, loanID, loan)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// CancelLoan withdraws a proposed loan if the caller is the lender, freeing the bond
+func (s *SmartContract) CancelLoan(ctx contractapi.TransactionContextInterface, loanID string) (*WriteResponse, error) {
+	loan, err := s.getLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	if loan.State != "Proposed" {
+		return nil, fmt.Errorf("loan %s is %s", loanID, loan.State)
+	}
+	if !s.IsOwner(ctx, loan.LenderHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the lender of the loan")
+	}
+
+	ledger, err := s.getCusipLedger(ctx, loan.Cusip)
+	if err != nil {
+		return nil, err
+	}
+	releaseReservations(ledger, loanID, "")
+
+	loan.State = "Cancelled"
+	err = s.putRecord(ctx, loanObjectType, loanID, loan)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// GetYourOpenLoans returns the proposed, open and recalled loans where the caller is the lender or the borrower
+func (s *SmartContract) GetYourOpenLoans(ctx contractapi.TransactionContextInterface) ([]Loan, error) {
+	orgHash, err := s.GenerateOrgHash(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate org hash: %v", err)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(loanObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loans: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	loans := []Loan{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over loans: %v", err)
+		}
+
+		var loan Loan
+		err = json.Unmarshal(queryResponse.Value, &loan)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling loan JSON: %v", err)
+		}
+		if loan.State == "Returned" || loan.State == "Cancelled" {
+			continue
+		}
+		if loan.LenderHash == orgHash || loan.BorrowerHash == orgHash {
+			loans = append(loans, loan)
+		}
+	}
+
+	return loans, nil
+}
+
+// ⭐ Helper functions ⭐
+
+func (s *SmartContract) getLoan(ctx contractapi.TransactionContextInterface, loanID string) (*Loan, error) {
+	var loan Loan
+	exists, err := s.getRecord(ctx, loanObjectType, loanID, &loan)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, NewError(ErrNotFound, "loan %s not found", loanID)
+	}
+
+	return &loan, nil
+}
+
+// accruedFee returns the actual/360 lending fee on the collateral from the start of the loan up to asOf
+func (l *Loan) accruedFee(asOf time.Time) float64 {
+	if !asOf.After(l.StartDate) {
+		return 0
+	}
+	days := float64(int(asOf.Sub(l.StartDate).Hours() / 24))
+
+	return l.CollateralAmount * l.FeeRate * days / repoDayCountBasis
+}
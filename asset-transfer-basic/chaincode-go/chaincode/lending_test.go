@@ -0,0 +1,106 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+// setUpLoan returns a world where Org2 proposes to lend uid1 to Org1 against $1,000,000 of cash collateral at a 3.6% fee
+func setUpLoan(t *testing.T, contract *chaincode.SmartContract) *world {
+	w := setUp(t, contract)
+	createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+	_, err := contract.DepositCash(w.begin(org1), org1, "USD", 200000000)
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.ProposeLoan(w.begin(org2), "loan1", "uid1", org1, 1000000, 0.036, testTime)
+	require.NoError(t, err)
+	w.commit()
+	return w
+}
+
+// requireLoanedBond checks the owner and status of uid1
+func requireLoanedBond(t *testing.T, w *world, contract *chaincode.SmartContract, ownerHash, status string) {
+	bonds, err := contract.GetAllBonds(w.begin(org1), false)
+	require.NoError(t, err)
+	require.Len(t, bonds, 1)
+	require.Equal(t, ownerHash, bonds[0].OwnerHash)
+	require.Equal(t, status, bonds[0].Status)
+}
+
+func TestSecuritiesLoan(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUpLoan(t, contract)
+			requireLoanedBond(t, w, contract, org2, chaincode.BondReserved)
+
+			// The borrower posts the collateral and receives the bond
+			_, err := contract.AcceptLoan(w.begin(org1), "loan1", testTime)
+			require.NoError(t, err)
+			w.commit()
+			requireLoanedBond(t, w, contract, org1, chaincode.BondSettled)
+			requireCash(t, w, contract, 100000000, 100000000)
+
+			_, err = contract.RecallLoan(w.begin(org1), "loan1", testTime)
+			require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "you are not the lender of the loan"))
+			_, err = contract.RecallLoan(w.begin(org2), "loan1", testTime)
+			require.NoError(t, err)
+			w.commit()
+			for _, mspID := range []string{org1, org2} {
+				loans, err := contract.GetYourOpenLoans(w.begin(mspID))
+				require.NoError(t, err)
+				require.Len(t, loans, 1)
+				require.Equal(t, "Recalled", loans[0].State)
+			}
+
+			// 10 days of a 3.6% fee on $1,000,000 is $1,000, kept out of the collateral returned
+			returned := testTime.Add(10 * 24 * time.Hour)
+			_, err = contract.ReturnLoan(w.beginAt(org1, returned), "loan1", "uid1", returned)
+			require.NoError(t, err)
+			w.commit()
+			requireLoanedBond(t, w, contract, org2, chaincode.BondSettled)
+			requireCash(t, w, contract, 199900000, 100000)
+
+			loans, err := contract.GetYourOpenLoans(w.begin(org2))
+			require.NoError(t, err)
+			require.Empty(t, loans)
+		})
+	}
+}
+
+func TestCancelLoan(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpLoan(t, contract)
+
+	_, err := contract.CancelLoan(w.begin(org1), "loan1")
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "you are not the lender of the loan"))
+	_, err = contract.CancelLoan(w.begin(org2), "loan1")
+	require.NoError(t, err)
+	w.commit()
+
+	requireLoanedBond(t, w, contract, org2, chaincode.BondListed)
+	_, err = contract.AcceptLoan(w.begin(org1), "loan1", testTime)
+	require.EqualError(t, err, "loan loan1 is Cancelled")
+}
+
+func TestReturnLoanErrors(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpLoan(t, contract)
+	createBond(t, w, contract, "uid2", org1, otherCusip, tradeFace)
+
+	_, err := contract.RecallLoan(w.begin(org2), "loan1", testTime)
+	require.EqualError(t, err, "loan loan1 is Proposed")
+	_, err = contract.AcceptLoan(w.begin(org1), "loan1", testTime)
+	require.NoError(t, err)
+	w.commit()
+
+	// Only an equivalent position returns the loan
+	_, err = contract.ReturnLoan(w.begin(org1), "loan1", "uid2", testTime)
+	require.EqualError(t, err, "the bond must be 100000000 of Cusip 3132DWAR4")
+	_, err = contract.ReturnLoan(w.begin(org2), "loan1", "uid1", testTime)
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "you are not the borrower of the loan"))
+}
@@ -0,0 +1,111 @@
+package chaincode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ⭐ Data Structures ⭐
+
+// lifecycle is the state machine of a kind of record: the states each state can move to. States missing from the table are final
+type lifecycle struct {
+	record      string // Names the record in errors, e.g. "bond"
+	initial     string // The state of records stored without one
+	transitions map[string][]string
+}
+
+// A bond is created listed, or imported as a draft an admin lists. A listed bond is reserved while held for a trade, an offer,
+// a pledge, a loan, a repo or an auction, traded once both sides of a direct trade agree on it, and settled when it is
+// delivered. A bond reserved for a repo is pledged once the repo opens, with a lien for the cash lender, and is listed again
+// when the repo closes or settled to the lender on a default. A settled bond can be reserved again by its new owner, and
+// a bond freed before delivery is listed again.
+// Its owner retires a free bond, and an admin can list a retired one again. A bond matures whatever it is held for.
+// Bonds stored before these statuses are Active, which moves like Listed
+var bondLifecycle = lifecycle{
+	record:  "bond",
+	initial: BondListed,
+	transitions: map[string][]string{
+		BondDraft:    {BondListed, BondMatured},
+		BondListed:   {BondReserved, BondSettled, BondRetired, BondMatured},
+		BondActive:   {BondReserved, BondSettled, BondRetired, BondMatured},
+		BondReserved: {BondListed, BondTraded, BondPledged, BondSettled, BondMatured},
+		BondTraded:   {BondListed, BondSettled, BondMatured},
+		BondPledged:  {BondListed, BondSettled, BondMatured},
+		BondSettled:  {BondReserved, BondSettled, BondRetired, BondMatured},
+		BondRetired:  {BondListed},
+	},
+}
+
+// A direct trade is answered once a seller answers it, and settles when the bidder and a seller agree or an offer fills it.
+// When the settings require settlement instructions, an agreed trade waits for both sides' instructions to match before it
+// settles. An accepted amendment clears the answers, which opens the trade again. Until it settles, agreed or not, it can expire,
+// or be cancelled by its bidder or along with its bond: it is then Cancelled, or Closed when part of it was already filled
+var directTradeLifecycle = lifecycle{
+	record:  "direct trade",
+	initial: "Open",
+	transitions: map[string][]string{
+		"Open":     {"Answered", "Agreed", "Settled", "Closed", "Cancelled", "Expired"},
+		"Answered": {"Open", "Agreed", "Settled", "Closed", "Cancelled", "Expired"},
+		"Agreed":   {"Matched", "Cancelled", "Expired"},
+		"Matched":  {"Settled", "Cancelled", "Expired"},
+	},
+}
+
+// An offer, plain or declining, is filled or cancelled once
+var offerLifecycle = lifecycle{
+	record:  "offer",
+	initial: "Open",
+	transitions: map[string][]string{
+		"Open": {"Filled", "Cancelled"},
+	},
+}
+
+// A TBA trade is allocated pools by its seller, and can be allocated again until it settles
+var tbaLifecycle = lifecycle{
+	record:  "TBA trade",
+	initial: "Open",
+	transitions: map[string][]string{
+		"Open":      {"Allocated"},
+		"Allocated": {"Allocated", "Settled"},
+	},
+}
+
+// ⭐ Helper functions ⭐
+
+// move sets the state of a record to another state, or returns a TransitionError when the lifecycle does not allow it.
+// An empty state is the initial state
+func (l *lifecycle) move(id string, state *string, to string) error {
+	err := l.check(id, *state, to)
+	if err != nil {
+		return err
+	}
+	*state = to
+
+	return nil
+}
+
+// final reports whether a record in a state can no longer change state
+func (l *lifecycle) final(state string) bool {
+	if state == "" {
+		state = l.initial
+	}
+	_, ok := l.transitions[state]
+
+	return !ok
+}
+
+// check returns a TransitionError when a record in a state cannot move to another
+func (l *lifecycle) check(id, from, to string) error {
+	if from == "" {
+		from = l.initial
+	}
+	next, ok := l.transitions[from]
+	if !ok {
+		return NewTransitionError(l.record, id, from, to, fmt.Sprintf("%s is a final state", from))
+	}
+	if !containsString(next, to) {
+		return NewTransitionError(l.record, id, from, to, fmt.Sprintf("a %s that is %s can only become %s", l.record, from, strings.Join(next, " or ")))
+	}
+
+	return nil
+}
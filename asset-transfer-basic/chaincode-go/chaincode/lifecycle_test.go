@@ -0,0 +1,35 @@
+package chaincode_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycleTransitions(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpTrade(t, contract, 200000000)
+
+	_, err := contract.CloseDirectTrade(w.begin(org1), "trade1")
+	require.NoError(t, err)
+	w.commit()
+
+	// A cancelled trade stays cancelled
+	_, err = contract.CloseDirectTrade(w.begin(org1), "trade1")
+	require.EqualError(t, err, chaincode.NewTransitionError("direct trade", "trade1", "Cancelled", "Cancelled", "Cancelled is a final state").Error())
+	var transition *chaincode.TransitionError
+	require.ErrorAs(t, err, &transition)
+	require.Equal(t, "Cancelled", transition.From)
+	require.True(t, errors.Is(err, chaincode.ErrConflict))
+
+	// A retired bond can only be restored
+	_, err = contract.RetireBond(w.begin(org2), "uid1")
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.RetireBond(w.begin(org2), "uid1")
+	require.EqualError(t, err, chaincode.NewTransitionError("bond", "uid1", chaincode.BondRetired, chaincode.BondRetired, "a bond that is Retired can only become Listed").Error())
+	_, err = contract.RestoreBond(w.begin(org1), "uid1")
+	require.NoError(t, err)
+}
@@ -0,0 +1,63 @@
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Functions ⭐
+
+// GetDirectTrade returns the direct trade with the given ID. Only that trade is read, not the ledger
+func (s *SmartContract) GetDirectTrade(ctx contractapi.TransactionContextInterface, tradeID string) (*DirectTrade, error) {
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	trade, err := stores.Trades.GetDirectTrade(tradeID)
+	if err != nil {
+		return nil, err
+	}
+	if trade == nil {
+		return nil, NewError(ErrNotFound, "direct trade %s not found", tradeID)
+	}
+
+	return trade, nil
+}
+
+// GetTransaction returns the transaction with the given ID: its TxnID or, for transactions recorded before TxnID,
+// the ID derived from its content that FIX exports and allocations use. Only the buyer, the seller and the auditors can read it
+func (s *SmartContract) GetTransaction(ctx contractapi.TransactionContextInterface, txnID string) (*Transaction, error) {
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *Transaction
+	var idErr error
+	err = stores.Trades.ForEachTransaction(func(transaction Transaction) {
+		if found != nil || idErr != nil {
+			return
+		}
+		id := transaction.TxnID
+		if id == "" {
+			id, idErr = transactionID(transaction)
+		}
+		if id == txnID {
+			found = &transaction
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if idErr != nil {
+		return nil, idErr
+	}
+	if found == nil {
+		return nil, NewError(ErrNotFound, "transaction %s not found", txnID)
+	}
+
+	if !s.IsOwner(ctx, found.BuyerID) && !s.IsOwner(ctx, found.SellerID) && s.requireAuditor(ctx) != nil {
+		return nil, NewError(ErrUnauthorized, "you are not a party to transaction %s", txnID)
+	}
+
+	return found, nil
+}
@@ -0,0 +1,43 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDirectTradeAndTransaction(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUpTrade(t, contract, 200000000)
+
+			trade, err := contract.GetDirectTrade(w.begin(org2), "trade1")
+			require.NoError(t, err)
+			require.Equal(t, "trade1", trade.DirectTradeID)
+			require.Equal(t, "Open", trade.State)
+
+			_, err = contract.GetDirectTrade(w.begin(org2), "trade2")
+			require.EqualError(t, err, contractError(chaincode.ErrNotFound, "direct trade trade2 not found"))
+
+			_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+			settleCtx := w.beginAt(org1, testTime.Add(time.Hour))
+			txnID := settleCtx.GetStub().GetTxID()
+			_, err = contract.AnswerTradeAsOwner(settleCtx, "trade1", org2, "done", testTime.Add(time.Hour), "")
+			require.NoError(t, err)
+			w.commit()
+
+			transaction, err := contract.GetTransaction(w.begin(org2), txnID)
+			require.NoError(t, err)
+			require.Equal(t, txnID, transaction.TxnID)
+			require.Equal(t, testCusip, transaction.Cusip)
+
+			_, err = contract.GetTransaction(w.begin(org2), "tx0")
+			require.EqualError(t, err, contractError(chaincode.ErrNotFound, "transaction tx0 not found"))
+		})
+	}
+}
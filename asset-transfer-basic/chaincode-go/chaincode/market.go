@@ -0,0 +1,263 @@
+package chaincode
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// MarketLevel is one resting order on either side of a market
+type MarketLevel struct {
+	OrderID   string    `json:"orderID"` // DirectTradeID for bids, OfferID for offers
+	Side      string    `json:"side"`    //"Bid" or "Offer"
+	Price     Price     `json:"price"`
+	Face      int64     `json:"face"` // Face still open, in cents
+	OwnerHash string    `json:"ownerHash"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Market is the two-sided view of a cusip, fed by open direct trades (bids) and open offers
+type Market struct {
+	Cusip  string        `json:"cusip"`
+	Bids   []MarketLevel `json:"bids"`   // Highest price first
+	Offers []MarketLevel `json:"offers"` // Lowest price first
+}
+
+// ⭐ Functions ⭐
+
+// GetMarket returns both sides of the market for a cusip, sorted by price and then by time
+func (s *SmartContract) GetMarket(ctx contractapi.TransactionContextInterface, cusip string) (*Market, error) {
+	trades, err := s.CheckDirectTrades(ctx, cusip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get direct trades: %v", err)
+	}
+
+	offers, err := s.GetOffers(ctx, cusip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offers: %v", err)
+	}
+
+	market := &Market{
+		Cusip:  cusip,
+		Bids:   []MarketLevel{},
+		Offers: []MarketLevel{},
+	}
+	for _, trade := range trades {
+		// Spread bids and bids in another currency have no dollar price to rank them by
+		if trade.Benchmark == "" && trade.Currency == "" {
+			market.Bids = append(market.Bids, bidLevel(trade))
+		}
+	}
+	for _, offer := range offers {
+		market.Offers = append(market.Offers, offerLevel(offer))
+	}
+	sortMarket(market)
+
+	return market, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// bidLevel converts an open direct trade into a bid of the market
+func bidLevel(trade DirectTrade) MarketLevel {
+	return MarketLevel{
+		OrderID:   trade.DirectTradeID,
+		Side:      "Bid",
+		Price:     trade.BidPrice,
+		Face:      trade.openFace(),
+		OwnerHash: trade.BidderHash,
+		CreatedAt: trade.CreatedAt,
+	}
+}
+
+// offerLevel converts an open offer into an offer of the market
+func offerLevel(offer Offer) MarketLevel {
+	return MarketLevel{
+		OrderID:   offer.OfferID,
+		Side:      "Offer",
+		Price:     offer.AskPrice,
+		Face:      offer.openFace(),
+		OwnerHash: offer.SellerHash,
+		CreatedAt: offer.CreatedAt,
+	}
+}
+
+// sortMarket puts the best price of each side first, with older orders first at the same price
+func sortMarket(market *Market) {
+	sort.SliceStable(market.Bids, func(i, j int) bool {
+		if market.Bids[i].Price.value() != market.Bids[j].Price.value() {
+			return market.Bids[i].Price.value() > market.Bids[j].Price.value()
+		}
+		return market.Bids[i].CreatedAt.Before(market.Bids[j].CreatedAt)
+	})
+	sort.SliceStable(market.Offers, func(i, j int) bool {
+		if market.Offers[i].Price.value() != market.Offers[j].Price.value() {
+			return market.Offers[i].Price.value() < market.Offers[j].Price.value()
+		}
+		return market.Offers[i].CreatedAt.Before(market.Offers[j].CreatedAt)
+	})
+}
+
+// crossBid executes a new bid against the resting offers it reaches, best and oldest offer first, at each offer's price
+func (s *SmartContract) crossBid(ctx contractapi.TransactionContextInterface, ledger *Ledger, trade *DirectTrade) error {
+	offers, err := s.GetOffers(ctx, trade.Cusip)
+	if err != nil {
+		return err
+	}
+
+	market := &Market{Offers: []MarketLevel{}}
+	offersByID := map[string]*Offer{}
+	for i := range offers {
+		offersByID[offers[i].OfferID] = &offers[i]
+		market.Offers = append(market.Offers, offerLevel(offers[i]))
+	}
+	sortMarket(market)
+
+	for _, level := range market.Offers {
+		if !trade.open() || level.Price.value() > trade.BidPrice.value() {
+			break
+		}
+		offer := offersByID[level.OrderID]
+		if offer.SellerHash == trade.BidderHash || !trade.CreatedAt.Before(offer.ExpiresAt) {
+			continue
+		}
+
+		fill, ok := crossFill(trade.openFace(), trade.AllowPartial, offer.openFace(), offer.AllowPartial, offer.MinPiece)
+		if !ok {
+			continue
+		}
+		err = s.executeCross(ctx, ledger, trade, offer, fill, offer.AskPrice, trade.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// crossOffer executes a new offer against the resting bids it reaches, best and oldest bid first, at each bid's price
+func (s *SmartContract) crossOffer(ctx contractapi.TransactionContextInterface, ledger *Ledger, offer *Offer) error {
+	market := &Market{Bids: []MarketLevel{}}
+	tradesByID := map[string]*DirectTrade{}
+	for i, trade := range ledger.DirectTrades {
+		if trade.Cusip == offer.Cusip && trade.open() && trade.Benchmark == "" && trade.Currency == "" {
+			tradesByID[trade.DirectTradeID] = &ledger.DirectTrades[i]
+			market.Bids = append(market.Bids, bidLevel(trade))
+		}
+	}
+	sortMarket(market)
+
+	for _, level := range market.Bids {
+		if offer.State != "Open" || level.Price.value() < offer.AskPrice.value() {
+			break
+		}
+		trade := tradesByID[level.OrderID]
+		if trade.BidderHash == offer.SellerHash || trade.expiredAt(offer.CreatedAt) {
+			continue
+		}
+
+		fill, ok := crossFill(trade.openFace(), trade.AllowPartial, offer.openFace(), offer.AllowPartial, offer.MinPiece)
+		if !ok {
+			continue
+		}
+		err := s.executeCross(ctx, ledger, trade, offer, fill, trade.BidPrice, offer.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// crossFill returns the face a bid and an offer can trade with each other. A side that does not allow partial fills
+// must be filled completely, so the pair cannot trade when that is impossible.
+// Neither the piece sold nor what is left of the offered bond may fall below the offer's minimum piece
+func crossFill(bidFace int64, bidPartial bool, offerFace int64, offerPartial bool, minPiece int64) (int64, bool) {
+	fill := bidFace
+	if offerFace < fill {
+		fill = offerFace
+	}
+	if fill <= 0 || (fill < bidFace && !bidPartial) || (fill < offerFace && !offerPartial) {
+		return 0, false
+	}
+	if fill < minPiece || (fill < offerFace && offerFace-fill < minPiece) {
+		return 0, false
+	}
+
+	return fill, true
+}
+
+// executeCross moves fill face of the offered bond to the bidder at the given price and records the transaction.
+// When only part of the bond is sold it is split, and the buyer receives a new bond for the part it bought.
+func (s *SmartContract) executeCross(ctx contractapi.TransactionContextInterface, ledger *Ledger, trade *DirectTrade, offer *Offer, fill int64, price Price, timestamp time.Time) error {
+	bondIndex := findBondByUID(ledger, offer.UID)
+	if bondIndex == -1 || ledger.Bonds[bondIndex].ReservedFor != offer.OfferID {
+		return fmt.Errorf("the bond of offer %s is no longer available", offer.OfferID)
+	}
+
+	var delivered AgencyMBSPassthrough
+	if fill == ledger.Bonds[bondIndex].OriginalFace {
+		err := ledger.Bonds[bondIndex].deliver(s.ownerHashFor(ctx, trade.BidderHash, offer.UID))
+		if err != nil {
+			return err
+		}
+		delivered = ledger.Bonds[bondIndex]
+	} else {
+		// The piece is a new bond, so its UID goes through the registry like any other. Storing it gives it the buyer's endorsement policy
+		piece := ledger.Bonds[bondIndex].splitPiece(offer.UID+"-"+trade.DirectTradeID, fill)
+		err := s.requireFreeBondUID(ctx, piece.UID)
+		if err != nil {
+			return err
+		}
+		err = s.registerBondUID(ctx, &piece)
+		if err != nil {
+			return err
+		}
+		err = piece.deliver(s.ownerHashFor(ctx, trade.BidderHash, piece.UID))
+		if err != nil {
+			return err
+		}
+		ledger.Bonds = append(ledger.Bonds, piece)
+		delivered = piece
+	}
+
+	transaction := s.GenerateTransactionObject(trade.BidderHash, offer.SellerHash, offer.Cusip, fill, string(price), timestamp)
+	err := s.settleTransaction(ctx, ledger, transaction, price.value())
+	if err != nil {
+		return err
+	}
+
+	trade.RemainingFace = trade.openFace() - fill
+	if trade.RemainingFace == 0 {
+		err = directTradeLifecycle.move(trade.DirectTradeID, &trade.State, "Settled")
+		if err != nil {
+			return err
+		}
+		releaseReservations(ledger, trade.DirectTradeID, "")
+	}
+
+	offer.RemainingFace = offer.openFace() - fill
+	if offer.RemainingFace == 0 {
+		err = offerLifecycle.move(offer.OfferID, &offer.State, "Filled")
+		if err != nil {
+			return err
+		}
+	}
+
+	settled := *trade
+	settled.OriginalFace = fill
+	err = emitTradeEvent(ctx, TradeSettledEvent, &settled, offer.SellerHash, price, "")
+	if err != nil {
+		return err
+	}
+	err = emitBondTransferred(ctx, delivered, offer.SellerHash, trade.DirectTradeID)
+	if err != nil {
+		return err
+	}
+
+	return s.putRecord(ctx, offerObjectType, offer.OfferID, offer)
+}
@@ -0,0 +1,190 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+// requireCash checks the cash balances, in cents, of the buyer Org1 and the seller Org2
+func requireCash(t *testing.T, w *world, contract *chaincode.SmartContract, buyerBalance, sellerBalance int64) {
+	buyer, err := contract.GetCashBalance(w.begin(org1), org1)
+	require.NoError(t, err)
+	require.Equal(t, buyerBalance, buyer.Balance)
+	seller, err := contract.GetCashBalance(w.begin(org2), org2)
+	require.NoError(t, err)
+	require.Equal(t, sellerBalance, seller.Balance)
+}
+
+func TestBidCrossesSeveralOffers(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUp(t, contract)
+			createBond(t, w, contract, "uid1", org2, testCusip, tradeFace/2)
+			createBond(t, w, contract, "uid2", org2, testCusip, tradeFace/2)
+			for _, offer := range [][2]string{{"offer1", "uid1"}, {"offer2", "uid2"}} {
+				_, err := contract.CreateOffer(w.begin(org2), offer[0], offer[1], tradePrice, testTime, testTime.AddDate(0, 0, 1), false)
+				require.NoError(t, err)
+				w.commit()
+			}
+			_, err := contract.DepositCash(w.begin(org1), org1, "USD", 200000000)
+			require.NoError(t, err)
+			w.commit()
+
+			_, err = contract.CreateTrade(w.begin(org1), "trade1", org1, testCusip, "2023-01-09T12:00:00Z", tradeFace, "100", true, "")
+			require.NoError(t, err)
+			w.commit()
+
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Len(t, bonds, 2)
+			for _, bond := range bonds {
+				require.Equal(t, org1, bond.OwnerHash)
+			}
+			// Both fills are paid, at the price of each offer
+			requireCash(t, w, contract, 100500000, 99500000)
+
+			transactions, err := contract.GetAllTransactions(w.begin(org1))
+			require.NoError(t, err)
+			require.Len(t, transactions, 2)
+		})
+	}
+}
+
+func TestOfferCrossesSeveralBids(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUp(t, contract)
+			createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+			_, err := contract.DepositCash(w.begin(org1), org1, "USD", 200000000)
+			require.NoError(t, err)
+			w.commit()
+			for _, tradeID := range []string{"trade1", "trade2"} {
+				_, err = contract.CreateTrade(w.begin(org1), tradeID, org1, testCusip, "2023-01-09T12:00:00Z", tradeFace/2, tradePrice, false, "")
+				require.NoError(t, err)
+				w.commit()
+			}
+
+			_, err = contract.CreateOffer(w.begin(org2), "offer1", "uid1", tradePrice, testTime, testTime.AddDate(0, 0, 1), true)
+			require.NoError(t, err)
+			w.commit()
+
+			faces := map[string]int64{}
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			for _, bond := range bonds {
+				faces[bond.OwnerHash] += bond.OriginalFace
+			}
+			require.Equal(t, map[string]int64{org1: tradeFace}, faces)
+			// The seller is credited for both fills
+			requireCash(t, w, contract, 100500000, 99500000)
+		})
+	}
+}
+
+func TestCrossSplitsBond(t *testing.T) {
+	contract := &chaincode.SmartContract{StorageLayout: chaincode.PerKeyLayout}
+	w := setUp(t, contract)
+	_, err := contract.RegisterPool(w.begin(org1), testCusip, "", 5.5, 1, "2023-01-01", 360)
+	require.NoError(t, err)
+	w.commit()
+	createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+	_, err = contract.UpdatePoolFactor(w.begin(org1), testCusip, 0.8, "2023-01-05")
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.DepositCash(w.begin(org1), org1, "USD", 200000000)
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.CreateOffer(w.begin(org2), "offer1", "uid1", tradePrice, testTime, testTime.AddDate(0, 0, 1), true)
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.CreateTrade(w.begin(org1), "trade1", org1, testCusip, "2023-01-09T12:00:00Z", tradeFace/4, tradePrice, false, "")
+	require.NoError(t, err)
+	w.commit()
+
+	// Both halves keep their share of the current face
+	bonds, err := contract.GetAllBonds(w.begin(org1), false)
+	require.NoError(t, err)
+	require.Len(t, bonds, 2)
+	type holding struct {
+		owner                     string
+		originalFace, currentFace int64
+	}
+	holdings := map[string]holding{}
+	for _, bond := range bonds {
+		holdings[bond.UID] = holding{bond.OwnerHash, bond.OriginalFace, bond.CurrentFace}
+	}
+	require.Equal(t, map[string]holding{
+		"uid1":        {org2, tradeFace * 3 / 4, tradeFace * 3 / 5},
+		"uid1-trade1": {org1, tradeFace / 4, tradeFace / 5},
+	}, holdings)
+
+	// The piece is registered and endorsed by its owner like any new bond
+	_, err = contract.CreateBondPublic(w.begin(org1), "uid1-trade1", org1, "FR uid1", testCusip, "passthrough", tradeFace)
+	require.EqualError(t, err, contractError(chaincode.ErrAlreadyExists, "bond with UID uid1-trade1 already exists"))
+	policy, err := contract.GetBondEndorsementPolicy(w.begin(org1), "uid1-trade1")
+	require.NoError(t, err)
+	require.Equal(t, []string{org1}, policy.Endorsers)
+}
+
+func TestClosePartlyFilledBid(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+	createBond(t, w, contract, "uid1", org2, testCusip, tradeFace/2)
+	_, err := contract.CreateOffer(w.begin(org2), "offer1", "uid1", tradePrice, testTime, testTime.AddDate(0, 0, 1), false)
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.DepositCash(w.begin(org1), org1, "USD", 200000000)
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.CreateTrade(w.begin(org1), "trade1", org1, testCusip, "2023-01-09T12:00:00Z", tradeFace, tradePrice, true, "")
+	require.NoError(t, err)
+	w.commit()
+	trade, err := contract.GetDirectTrade(w.begin(org1), "trade1")
+	require.NoError(t, err)
+	require.Equal(t, "Open", trade.State)
+	require.Equal(t, int64(tradeFace/2), trade.RemainingFace)
+
+	// The filled half stays traded, so the trade ends Closed rather than Cancelled
+	_, err = contract.CloseDirectTrade(w.begin(org1), "trade1")
+	require.NoError(t, err)
+	w.commit()
+	trade, err = contract.GetDirectTrade(w.begin(org1), "trade1")
+	require.NoError(t, err)
+	require.Equal(t, "Closed", trade.State)
+}
+
+func TestBuyOnlyAsYourself(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+	createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+	_, err := contract.DepositCash(w.begin(org1), org1, "USD", 200000000)
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.CreateOffer(w.begin(org2), "offer1", "uid1", tradePrice, testTime, testTime.AddDate(0, 0, 1), false)
+	require.NoError(t, err)
+	w.commit()
+
+	// Org2 can neither bid nor lift an offer with the cash of Org1
+	_, err = contract.CreateTrade(w.begin(org2), "trade2", org1, testCusip, "2023-01-09T12:00:00Z", tradeFace, tradePrice, false, "")
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "you are not the owner of Org1MSP"))
+	_, err = contract.LiftOffer(w.begin(org2), "offer1", org1, testTime)
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "you are not the owner of Org1MSP"))
+	requireCash(t, w, contract, 200000000, 0)
+}
+
+func TestAnswerOwnTrade(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpTrade(t, contract, 200000000)
+	createBond(t, w, contract, "uid2", org1, testCusip, tradeFace)
+
+	_, err := contract.AnswerTrade(w.begin(org1), "trade1", org1, "done", testTime, "")
+	require.EqualError(t, err, "you cannot answer your own trade")
+	_, err = contract.AnswerTradeAsOwner(w.begin(org1), "trade1", org1, "done", testTime, "")
+	require.EqualError(t, err, "you cannot trade with yourself")
+}
@@ -0,0 +1,328 @@
+package chaincode
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// Mark is an organization's private end-of-day price for a cusip
+type Mark struct {
+	Cusip          string `json:"cusip"`
+	Date           string `json:"date"` // YYYY-MM-DD
+	Price          Price  `json:"price"`
+	ContributorMSP string `json:"contributorMSP"`
+}
+
+// MarkContribution is the public trace of a contributed mark. The price stays in the contributor's collection
+type MarkContribution struct {
+	Cusip          string    `json:"cusip"`
+	Date           string    `json:"date"`
+	ContributorMSP string    `json:"contributorMSP"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// ConsensusPrice is the published consensus of the marks contributed for a cusip on a date
+type ConsensusPrice struct {
+	Cusip        string    `json:"cusip"`
+	Date         string    `json:"date"`
+	Median       Price     `json:"median"`
+	TrimmedMean  Price     `json:"trimmedMean"`
+	Contributors []string  `json:"contributors"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+const (
+	markKeyType              = "mark"
+	markContributionType     = "markcontribution"
+	consensusPriceObjectType = "consensus"
+	markDateLayout           = "2006-01-02"
+
+	// Number of verified marks needed before a consensus price can be published
+	consensusQuorum = 2
+	// Share of the lowest and of the highest marks left out of the trimmed mean
+	consensusTrimFraction = 0.2
+)
+
+// ⭐ Functions ⭐
+
+// ContributeMark stores the caller's mark, passed in the transient field "mark", in its implicit collection
+// and records the contribution publicly
+func (s *SmartContract) ContributeMark(ctx contractapi.TransactionContextInterface, cusip, date string, timestamp time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = parseDate(date)
+	if err != nil {
+		return nil, err
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("error getting transient: %v", err)
+	}
+	markJSON, ok := transientMap["mark"]
+	if !ok {
+		return nil, NewError(ErrInvalidInput, "mark key not found in the transient map")
+	}
+
+	mark, err := parseMark(markJSON)
+	if err != nil {
+		return nil, err
+	}
+	err = s.validatePrice(ctx, mark.Price)
+	if err != nil {
+		return nil, err
+	}
+	if mark.Cusip != cusip || mark.Date != date || mark.ContributorMSP != mspID {
+		return nil, fmt.Errorf("the mark must be for Cusip %s on %s from %s", cusip, date, mspID)
+	}
+
+	markKey, err := ctx.GetStub().CreateCompositeKey(markKeyType, []string{cusip, date})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	// The mark hash is verified when publishing, so the mark bytes are stored as they were passed
+	err = ctx.GetStub().PutPrivateData(implicitCollection(mspID), markKey, markJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to put mark: %v", err)
+	}
+
+	contribution := MarkContribution{
+		Cusip:          cusip,
+		Date:           date,
+		ContributorMSP: mspID,
+		Timestamp:      timestamp,
+	}
+	err = s.putCompositeRecord(ctx, markContributionType, []string{cusip, date, mspID}, contribution)
+	if err != nil {
+		return nil, err
+	}
+	return newWriteResponse(ctx, nil)
+}
+
+// PublishConsensusPrice computes the median and trimmed mean of the marks contributed for a cusip on a date and publishes them.
+// The marks are revealed in the transient field "marks", a JSON object from contributor MSP ID to the exact mark JSON it stored,
+// and each one is checked against the hash in its contributor's collection. A quorum of verified marks is required.
+func (s *SmartContract) PublishConsensusPrice(ctx contractapi.TransactionContextInterface, cusip, date string, timestamp time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing ConsensusPrice
+	exists, err := s.getCompositeRecord(ctx, consensusPriceObjectType, []string{cusip, date}, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("the consensus price for Cusip %s on %s is already published", cusip, date)
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("error getting transient: %v", err)
+	}
+	revealedJSON, ok := transientMap["marks"]
+	if !ok {
+		return nil, NewError(ErrInvalidInput, "marks key not found in the transient map")
+	}
+	var revealed map[string]string
+	err = json.Unmarshal(revealedJSON, &revealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal revealed marks: %v", err)
+	}
+
+	contributors, err := s.getMarkContributors(ctx, cusip, date)
+	if err != nil {
+		return nil, err
+	}
+
+	markKey, err := ctx.GetStub().CreateCompositeKey(markKeyType, []string{cusip, date})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	consensus := ConsensusPrice{
+		Cusip:        cusip,
+		Date:         date,
+		Contributors: []string{},
+		Timestamp:    timestamp,
+	}
+	prices := []float64{}
+	for _, mspID := range contributors {
+		markJSON, ok := revealed[mspID]
+		if !ok {
+			continue
+		}
+
+		onChainHash, err := ctx.GetStub().GetPrivateDataHash(implicitCollection(mspID), markKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mark hash of %s: %v", mspID, err)
+		}
+		hash := sha256.Sum256([]byte(markJSON))
+		if !bytes.Equal(onChainHash, hash[:]) {
+			return nil, fmt.Errorf("the mark revealed for %s does not match its hash on the ledger", mspID)
+		}
+
+		mark, err := parseMark([]byte(markJSON))
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, mark.Price.value())
+		consensus.Contributors = append(consensus.Contributors, mspID)
+	}
+	if len(revealed) > len(consensus.Contributors) {
+		return nil, fmt.Errorf("marks were revealed for organizations that did not contribute")
+	}
+	if len(prices) < consensusQuorum {
+		return nil, fmt.Errorf("%d verified marks, a quorum of %d is needed", len(prices), consensusQuorum)
+	}
+
+	precision, err := s.GetPricePrecision(ctx)
+	if err != nil {
+		return nil, err
+	}
+	median, trimmedMean := medianAndTrimmedMean(prices)
+	consensus.Median = formatPrice(median, precision.MaxDecimals)
+	consensus.TrimmedMean = formatPrice(trimmedMean, precision.MaxDecimals)
+
+	err = s.putCompositeRecord(ctx, consensusPriceObjectType, []string{cusip, date}, consensus)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, &consensus)
+}
+
+// GetConsensusPrice returns the consensus price published for a cusip on a date
+func (s *SmartContract) GetConsensusPrice(ctx contractapi.TransactionContextInterface, cusip, date string) (*ConsensusPrice, error) {
+	var consensus ConsensusPrice
+	exists, err := s.getCompositeRecord(ctx, consensusPriceObjectType, []string{cusip, date}, &consensus)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("no consensus price for Cusip %s on %s", cusip, date)
+	}
+
+	return &consensus, nil
+}
+
+// GetLatestConsensusPrice returns the most recent consensus price published for a cusip
+func (s *SmartContract) GetLatestConsensusPrice(ctx contractapi.TransactionContextInterface, cusip string) (*ConsensusPrice, error) {
+	consensus, err := s.latestConsensusPrice(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+	if consensus == nil {
+		return nil, fmt.Errorf("no consensus price for Cusip %s", cusip)
+	}
+
+	return consensus, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// latestConsensusPrice returns the most recent consensus price of a cusip, or nil if none was published.
+// Dates are YYYY-MM-DD, so the last key in the range is the latest
+func (s *SmartContract) latestConsensusPrice(ctx contractapi.TransactionContextInterface, cusip string) (*ConsensusPrice, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(consensusPriceObjectType, []string{cusip})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get consensus prices: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var latest *ConsensusPrice
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over consensus prices: %v", err)
+		}
+
+		var consensus ConsensusPrice
+		err = json.Unmarshal(queryResponse.Value, &consensus)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling consensus price JSON: %v", err)
+		}
+		latest = &consensus
+	}
+
+	return latest, nil
+}
+
+// getMarkContributors returns the MSP IDs of the organizations that contributed a mark for a cusip on a date
+func (s *SmartContract) getMarkContributors(ctx contractapi.TransactionContextInterface, cusip, date string) ([]string, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(markContributionType, []string{cusip, date})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mark contributions: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	contributors := []string{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over mark contributions: %v", err)
+		}
+
+		var contribution MarkContribution
+		err = json.Unmarshal(queryResponse.Value, &contribution)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling mark contribution JSON: %v", err)
+		}
+		contributors = append(contributors, contribution.ContributorMSP)
+	}
+
+	return contributors, nil
+}
+
+func parseMark(markJSON []byte) (*Mark, error) {
+	var mark Mark
+	err := json.Unmarshal(markJSON, &mark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mark JSON: %v", err)
+	}
+	if mark.Price.value() <= 0 {
+		return nil, fmt.Errorf("mark price must be positive: %s", mark.Price)
+	}
+
+	return &mark, nil
+}
+
+// medianAndTrimmedMean returns the median of prices and their mean once the lowest and highest
+// consensusTrimFraction of them are left out
+func medianAndTrimmedMean(prices []float64) (float64, float64) {
+	sorted := append([]float64{}, prices...)
+	sort.Float64s(sorted)
+
+	n := len(sorted)
+	median := sorted[n/2]
+	if n%2 == 0 {
+		median = (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+
+	trim := int(float64(n) * consensusTrimFraction)
+	kept := sorted[trim : n-trim]
+	sum := 0.0
+	for _, price := range kept {
+		sum += price
+	}
+
+	return median, sum / float64(len(kept))
+}
@@ -0,0 +1,162 @@
+package chaincode
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Functions ⭐
+
+// ProcessMaturities marks the active bonds that have paid off by the transaction time as Matured, and returns their UIDs.
+// A bond pays off on its maturity date or, without one, its weighted average maturity after its factor date. The open direct
+// trades and offers referencing a matured bond are cancelled, and BondMatured is raised for each bond. Bonds held for a pledge,
+// loan or repo stay held until it is unwound. It is housekeeping any organization can run, and running it again changes nothing
+func (s *SmartContract) ProcessMaturities(ctx contractapi.TransactionContextInterface) (*WriteResponse, error) {
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The matured bonds can span several cusips, so they are all changed on one ledger
+	ledger, err := s.GetLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matured := []string{}
+	for i := range ledger.Bonds {
+		bond := &ledger.Bonds[i]
+		if !bond.tradable() {
+			continue
+		}
+		maturity, ok := bond.maturity()
+		if !ok || now.Before(maturity) {
+			continue
+		}
+		err = bondLifecycle.move(bond.UID, &bond.Status, BondMatured)
+		if err != nil {
+			return nil, err
+		}
+		matured = append(matured, bond.UID)
+
+		cancelled, err := s.cancelOrdersOfBond(ctx, ledger, bond)
+		if err != nil {
+			return nil, err
+		}
+
+		err = emitEvent(ctx, BondMaturedEvent, BondMaturityEvent{
+			UID:             bond.UID,
+			Cusip:           bond.Cusip,
+			OwnerHash:       bond.OwnerHash,
+			Face:            bond.OriginalFace,
+			MaturedOn:       maturity.Format(markDateLayout),
+			CancelledOrders: cancelled,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(matured) == 0 {
+		return newWriteResponse(ctx, matured)
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, matured)
+}
+
+// ⭐ Helper functions ⭐
+
+// maturity returns the day a bond pays off: its maturity date or, without one, its factor date plus its weighted average
+// maturity in months, rounded up. It reports false when the bond has neither
+func (bond *AgencyMBSPassthrough) maturity() (time.Time, bool) {
+	if bond.MaturityDate != "" {
+		maturity, err := parseIssueDate(bond.MaturityDate)
+		return maturity, err == nil
+	}
+	if bond.FactorDate == "" || bond.WeightedAverageMaturity <= 0 {
+		return time.Time{}, false
+	}
+	factorDate, err := parseIssueDate(bond.FactorDate)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return factorDate.AddDate(0, int(math.Ceil(bond.WeightedAverageMaturity)), 0), true
+}
+
+// cancelOrdersOfBond cancels the open direct trades and offers referencing a bond, frees the bonds held for them
+// and returns their IDs. A direct trade references the bond when the bond is held for it or an answer pinned it
+func (s *SmartContract) cancelOrdersOfBond(ctx contractapi.TransactionContextInterface, ledger *Ledger, bond *AgencyMBSPassthrough) ([]string, error) {
+	cancelled := []string{}
+	for i := range ledger.DirectTrades {
+		trade := &ledger.DirectTrades[i]
+		if !trade.open() || !trade.references(bond) {
+			continue
+		}
+		err := directTradeLifecycle.move(trade.DirectTradeID, &trade.State, trade.closingState())
+		if err != nil {
+			return nil, err
+		}
+		releaseReservations(ledger, trade.DirectTradeID, "")
+		cancelled = append(cancelled, trade.DirectTradeID)
+
+		err = emitTradeEvent(ctx, TradeClosedEvent, trade, "", trade.BidPrice, "")
+		if err != nil {
+			return nil, err
+		}
+		err = s.recordOrderEvents(ctx, OrderEvent{Action: "Cancel", OrderType: "Trade", OrderID: trade.DirectTradeID, Cusip: trade.Cusip, Face: trade.openFace(), Price: trade.BidPrice})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if bond.ReservedFor == "" {
+		return cancelled, nil
+	}
+	var offer Offer
+	exists, err := s.getRecord(ctx, offerObjectType, bond.ReservedFor, &offer)
+	if err != nil {
+		return nil, err
+	}
+	if !exists || offer.State != "Open" || offer.UID != bond.UID {
+		return cancelled, nil
+	}
+	err = offerLifecycle.move(offer.OfferID, &offer.State, "Cancelled")
+	if err != nil {
+		return nil, err
+	}
+	releaseReservations(ledger, offer.OfferID, "")
+	err = s.putRecord(ctx, offerObjectType, offer.OfferID, offer)
+	if err != nil {
+		return nil, err
+	}
+	cancelled = append(cancelled, offer.OfferID)
+
+	err = s.recordOrderEvents(ctx, OrderEvent{Action: "Cancel", OrderType: "Offer", OrderID: offer.OfferID, Cusip: offer.Cusip, Face: offer.openFace(), Price: offer.AskPrice})
+	if err != nil {
+		return nil, err
+	}
+
+	return cancelled, nil
+}
+
+// references reports whether a direct trade holds a bond or has an answer pinning it
+func (trade *DirectTrade) references(bond *AgencyMBSPassthrough) bool {
+	if bond.ReservedFor == trade.DirectTradeID {
+		return true
+	}
+	for _, answer := range trade.Answers {
+		if answer.BondUID == bond.UID {
+			return true
+		}
+	}
+
+	return false
+}
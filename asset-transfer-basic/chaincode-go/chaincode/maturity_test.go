@@ -0,0 +1,72 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessMaturities(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUp(t, contract)
+
+			// uid1 pays off on the day of the transaction, uid2 13 months after its factor date,
+			// and uid3 the day after the transaction
+			_, err := contract.ImportBonds(w.begin(org1), `[
+				{"uid":"uid1","ownerHash":"Org2MSP","cusip":"3132DWAR4","originalFace":100000000,"coupon":5.5,"couponType":"FIXED","factor":1,"maturityDate":"2023-01-09"},
+				{"uid":"uid2","ownerHash":"Org2MSP","cusip":"3133KR5L4","originalFace":50000000,"coupon":5.5,"couponType":"FIXED","factor":1,"factorDate":"2021-12-01","weightedAverageMaturity":12.5},
+				{"uid":"uid3","ownerHash":"Org2MSP","cusip":"3133KR5L4","originalFace":50000000,"coupon":5.5,"couponType":"FIXED","factor":1,"maturityDate":"2023-01-10"}
+			]`)
+			require.NoError(t, err)
+			w.commit()
+			for _, uid := range []string{"uid1", "uid2", "uid3"} {
+				_, err = contract.ListBond(w.begin(org1), uid)
+				require.NoError(t, err)
+				w.commit()
+			}
+
+			// Org2 answers a bid with the bond that matures
+			_, err = contract.DepositCash(w.begin(org1), org1, "USD", 200000000)
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.CreateTrade(w.begin(org1), "trade1", org1, testCusip, "2023-01-09T12:00:00Z", tradeFace, tradePrice, false, "")
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+
+			response, err := contract.ProcessMaturities(w.begin(org2))
+			require.NoError(t, err)
+			require.ElementsMatch(t, []string{"uid1", "uid2"}, response.Result)
+			require.Contains(t, w.events(), chaincode.BondMaturedEvent)
+			w.commit()
+
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Len(t, bonds, 3)
+			for _, bond := range bonds {
+				require.Empty(t, bond.ReservedFor)
+				if bond.UID == "uid3" {
+					require.Equal(t, chaincode.BondListed, bond.Status)
+				} else {
+					require.Equal(t, chaincode.BondMatured, bond.Status)
+				}
+			}
+			trades, err := contract.GetYourDirectTrades(w.begin(org1))
+			require.NoError(t, err)
+			require.Len(t, trades, 1)
+			require.Equal(t, "Cancelled", trades[0].State)
+
+			// A matured bond cannot trade, and running again matures nothing more
+			_, err = contract.TransferBond(w.begin(org2), "uid2", org1)
+			require.EqualError(t, err, "bond uid2 is Matured")
+			response, err = contract.ProcessMaturities(w.begin(org2))
+			require.NoError(t, err)
+			require.Empty(t, response.Result)
+		})
+	}
+}
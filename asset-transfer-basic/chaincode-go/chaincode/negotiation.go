@@ -0,0 +1,275 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// PrivateCounterOffer is a counter price on an answer to a direct trade that only the bidder and that seller see.
+// It is kept in both their implicit collections, and the public answer only carries its hash until the other side accepts it
+type PrivateCounterOffer struct {
+	DirectTradeID string `json:"directTradeID"`
+	SellerIDHash  string `json:"sellerIDHash"` // Seller of the answer negotiated on
+	FromMSP       string `json:"fromMSP"`
+	ToMSP         string `json:"toMSP"`
+	Price         Price  `json:"price"`
+	Salt          string `json:"salt"` // Keeps the hash from being matched against likely prices
+}
+
+const counterOfferKeyType = "counteroffer"
+
+// ⭐ Functions ⭐
+
+// SubmitPrivateCounterOffer counters an answer to a direct trade with the price passed in the "counteroffer" transient field.
+// The seller of the answer counters the bid, or the bidder counters that seller. The answer records a "counter" with the hash
+// of the offer in place of a price, and the other side accepts it by answering "done" as usual, which makes the price public
+func (s *SmartContract) SubmitPrivateCounterOffer(ctx contractapi.TransactionContextInterface, directTradeID, sellerIDHash string, timestamp time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger, err := s.getTradeLedger(ctx, directTradeID)
+	if err != nil {
+		return nil, err
+	}
+
+	var foundTrade *DirectTrade
+	for i, trade := range ledger.DirectTrades {
+		if trade.DirectTradeID == directTradeID {
+			foundTrade = &ledger.DirectTrades[i]
+			break
+		}
+	}
+	if foundTrade == nil {
+		return nil, NewError(ErrNotFound, "direct trade not found")
+	}
+	if !foundTrade.open() {
+		return nil, fmt.Errorf("direct trade is closed")
+	}
+	err = requireUnexpiredTrade(ctx, foundTrade)
+	if err != nil {
+		return nil, err
+	}
+
+	isSeller := s.IsOwner(ctx, sellerIDHash)
+	if !isSeller && !s.IsOwner(ctx, foundTrade.BidderHash) {
+		return nil, NewError(ErrUnauthorized, "you are not a counterparty of the answer")
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("error getting transient: %v", err)
+	}
+	offerJSON, ok := transientMap["counteroffer"]
+	if !ok {
+		return nil, NewError(ErrInvalidInput, "counteroffer key not found in the transient map")
+	}
+
+	var offer PrivateCounterOffer
+	err = json.Unmarshal(offerJSON, &offer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal counter offer JSON: %v", err)
+	}
+	if offer.DirectTradeID != directTradeID || offer.SellerIDHash != sellerIDHash || offer.FromMSP != mspID {
+		return nil, fmt.Errorf("the counter offer must be for the answer of %s to direct trade %s from %s", sellerIDHash, directTradeID, mspID)
+	}
+	if offer.ToMSP == "" || offer.ToMSP == mspID {
+		return nil, fmt.Errorf("the counter offer must be sent to the other counterparty")
+	}
+	if offer.Salt == "" {
+		return nil, fmt.Errorf("the counter offer must have a salt")
+	}
+	err = s.validatePrice(ctx, offer.Price)
+	if err != nil {
+		return nil, err
+	}
+	err = s.requirePriceTick(ctx, foundTrade, offer.Price)
+	if err != nil {
+		return nil, err
+	}
+
+	var foundAnswer *Answer
+	for i, ans := range foundTrade.Answers {
+		if ans.SellerIDHash == sellerIDHash {
+			foundAnswer = &foundTrade.Answers[i]
+			break
+		}
+	}
+
+	// The same rules as a public counter apply to each side
+	var response *AnswerResponse
+	if isSeller {
+		if !holdsPosition(ledger, s.ownerFor(ctx, sellerIDHash), foundTrade.Cusip) {
+			return nil, fmt.Errorf("the seller does not own a position in Cusip %s", foundTrade.Cusip)
+		}
+		if foundAnswer == nil {
+			foundTrade.Answers = append(foundTrade.Answers, Answer{SellerIDHash: sellerIDHash})
+			foundAnswer = &foundTrade.Answers[len(foundTrade.Answers)-1]
+		}
+		if foundAnswer.BuyerResponse.Value == "done" {
+			return nil, fmt.Errorf("the buyer accepted the price. You cannot counter it: %v", foundAnswer.BuyerResponse.CounterPrice)
+		}
+		// Countering withdraws a "done", so the seller's bond is no longer held
+		releaseAnswer(ledger, directTradeID, foundAnswer)
+		foundAnswer.BondUID = ""
+		response = &foundAnswer.SellerResponse
+		err = foundTrade.markAnswered()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if foundAnswer == nil {
+			return nil, fmt.Errorf("there is not an answer for this identifier: %v", sellerIDHash)
+		}
+		if foundAnswer.SellerResponse.Value == "out" {
+			return nil, fmt.Errorf("seller refused trade, you cannot answer it")
+		}
+		if foundAnswer.SellerResponse.Value == "done" {
+			return nil, fmt.Errorf("seller already accepted the BidPrice: %v", foundTrade.BidPrice)
+		}
+		response = &foundAnswer.BuyerResponse
+	}
+
+	offerKey, err := ctx.GetStub().CreateCompositeKey(counterOfferKeyType, []string{directTradeID, sellerIDHash, mspID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	// The offer hash is verified on acceptance, so the offer bytes are stored as they were passed
+	for _, collection := range []string{implicitCollection(mspID), implicitCollection(offer.ToMSP)} {
+		err = ctx.GetStub().PutPrivateData(collection, offerKey, offerJSON)
+		if err != nil {
+			return nil, fmt.Errorf("%s - failed to put counter offer: %v", collection, err)
+		}
+	}
+
+	hash := sha256.Sum256(offerJSON)
+	*response = AnswerResponse{
+		Value:            "counter",
+		Timestamp:        timestamp,
+		CounterOfferHash: hex.EncodeToString(hash[:]),
+	}
+
+	err = emitTradeEvent(ctx, TradeAnsweredEvent, foundTrade, sellerIDHash, "", "counter")
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	// The price is private, so only the offer hash goes into the public event
+	err = s.recordOrderEvents(ctx, OrderEvent{Action: "Answer", OrderType: "Trade", OrderID: directTradeID, Cusip: foundTrade.Cusip, Face: foundTrade.OriginalFace, Detail: response.CounterOfferHash})
+	if err != nil {
+		return nil, err
+	}
+	return newWriteResponse(ctx, nil)
+}
+
+// ReadCounterOffers returns the private counter offers on a direct trade that the caller sent or received.
+// Only the bidder and the sellers who answered the trade can read them, each from its own implicit collection
+func (s *SmartContract) ReadCounterOffers(ctx contractapi.TransactionContextInterface, directTradeID string) ([]PrivateCounterOffer, error) {
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	trade, err := stores.Trades.GetDirectTrade(directTradeID)
+	if err != nil {
+		return nil, err
+	}
+	if trade == nil {
+		return nil, NewError(ErrNotFound, "direct trade not found")
+	}
+
+	counterparty := s.IsOwner(ctx, trade.BidderHash)
+	for _, answer := range trade.Answers {
+		if counterparty {
+			break
+		}
+		counterparty = s.IsOwner(ctx, answer.SellerIDHash)
+	}
+	if !counterparty {
+		return nil, NewError(ErrUnauthorized, "you are not a counterparty of direct trade %s", directTradeID)
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(implicitCollection(mspID), counterOfferKeyType, []string{directTradeID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get counter offers: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	offers := []PrivateCounterOffer{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over counter offers: %v", err)
+		}
+
+		var offer PrivateCounterOffer
+		err = json.Unmarshal(queryResponse.Value, &offer)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling counter offer JSON: %v", err)
+		}
+		offers = append(offers, offer)
+	}
+
+	return offers, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// privateCounterPrice returns the price of the private counter offer with the given hash on an answer,
+// read from the caller's implicit collection. The caller holds it as the counterparty the offer was sent to
+func (s *SmartContract) privateCounterPrice(ctx contractapi.TransactionContextInterface, directTradeID, sellerIDHash, offerHash string) (Price, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(implicitCollection(mspID), counterOfferKeyType, []string{directTradeID, sellerIDHash})
+	if err != nil {
+		return "", fmt.Errorf("failed to get counter offers: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return "", fmt.Errorf("error iterating over counter offers: %v", err)
+		}
+
+		hash := sha256.Sum256(queryResponse.Value)
+		if hex.EncodeToString(hash[:]) != offerHash {
+			continue
+		}
+
+		var offer PrivateCounterOffer
+		err = json.Unmarshal(queryResponse.Value, &offer)
+		if err != nil {
+			return "", fmt.Errorf("error unmarshalling counter offer JSON: %v", err)
+		}
+		return offer.Price, nil
+	}
+
+	return "", fmt.Errorf("the private counter offer %s is not in your collection", offerHash)
+}
@@ -0,0 +1,340 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// NetObligation is what two counterparties owe each other on a settlement date once their pending deliveries are netted:
+// the bonds each side delivers, and a single cash payment in place of one per bond
+type NetObligation struct {
+	PairID         string        `json:"pairID"`         // PartyA and PartyB, joined by "|"
+	SettlementDate string        `json:"settlementDate"` // YYYY-MM-DD
+	PartyA         string        `json:"partyA"`         // The lower of the two party hashes
+	PartyB         string        `json:"partyB"`
+	NetCash        int64         `json:"netCash"`   // Cash PartyA pays PartyB, in cents of the settlement currency. Negative when PartyB pays
+	GrossCash      int64         `json:"grossCash"` // Cash that would change hands settling bond by bond, in cents
+	TBAIDs         []string      `json:"tbaIDs"`
+	TradeIDs       []string      `json:"tradeIDs"` // Direct trades whose settlement instructions matched on the date
+	Deliveries     []NetDelivery `json:"deliveries"`
+}
+
+// NetDelivery is a bond delivered in a net settlement, and the cash it accounts for
+type NetDelivery struct {
+	TBAID    string `json:"tbaID,omitempty"`
+	TradeID  string `json:"tradeID,omitempty"`
+	UID      string `json:"uid"`
+	Cusip    string `json:"cusip"`
+	Face     int64  `json:"face"` // In cents
+	Price    Price  `json:"price"`
+	FromHash string `json:"fromHash"`
+	ToHash   string `json:"toHash"`
+	Amount   int64  `json:"amount"` // Cash the receiver owes for the bond, in cents
+}
+
+const pairSeparator = "|"
+
+// ⭐ Functions ⭐
+
+// ComputeNetObligations nets the pending delivery-versus-payment obligations of each pair of counterparties on a settlement
+// date (YYYY-MM-DD). Pending obligations are the TBA trades allocated for the month of the date and the direct trades whose
+// settlement instructions matched on the date. Pairs are in PairID order
+func (s *SmartContract) ComputeNetObligations(ctx contractapi.TransactionContextInterface, settlementDate string) ([]NetObligation, error) {
+	_, err := parseDate(settlementDate)
+	if err != nil {
+		return nil, err
+	}
+	ledger, err := s.GetLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.netObligations(ctx, ledger, settlementDate)
+}
+
+// SettleNet settles the net obligation of a pair of counterparties on a settlement date, from that date on. Every bond is
+// delivered with its own transaction at its trade price, while the cash moves once, for the net amount. Either counterparty
+// can settle. The payer only needs cash for the net amount
+func (s *SmartContract) SettleNet(ctx contractapi.TransactionContextInterface, settlementDate, pairID string) (*WriteResponse, error) {
+	_, err := parseDate(settlementDate)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if now.Format(markDateLayout) < settlementDate {
+		return nil, fmt.Errorf("the obligations of %s settle on %s", pairID, settlementDate)
+	}
+
+	ledger, err := s.GetLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+	obligations, err := s.netObligations(ctx, ledger, settlementDate)
+	if err != nil {
+		return nil, err
+	}
+	var obligation *NetObligation
+	for i := range obligations {
+		if obligations[i].PairID == pairID {
+			obligation = &obligations[i]
+			break
+		}
+	}
+	if obligation == nil {
+		return nil, fmt.Errorf("no pending obligations between %s on %s", pairID, settlementDate)
+	}
+	if !s.IsOwner(ctx, obligation.PartyA) && !s.IsOwner(ctx, obligation.PartyB) {
+		return nil, NewError(ErrUnauthorized, "you are not a counterparty of %s", pairID)
+	}
+
+	// One payment for the net amount. Nothing moves when the pair is flat, but the rates still apply to the transactions
+	payer, payee, amount := obligation.PartyA, obligation.PartyB, obligation.NetCash
+	if amount < 0 {
+		payer, payee, amount = payee, payer, -amount
+	}
+	payerRate, payeeRate, err := s.transferCash(ctx, ledger.cashBook(), payer, payee, amount)
+	if err != nil {
+		return nil, err
+	}
+	rate := func(partyHash string) float64 {
+		if partyHash == payer {
+			return payerRate
+		}
+		return payeeRate
+	}
+	events := []OrderEvent{}
+	for _, tbaID := range obligation.TBAIDs {
+		executed := len(ledger.Transactions)
+		for _, delivery := range obligation.Deliveries {
+			if delivery.TBAID != tbaID {
+				continue
+			}
+			bondIndex := findBondByUID(ledger, delivery.UID)
+			if bondIndex == -1 || ledger.Bonds[bondIndex].ReservedFor != tbaID {
+				return nil, fmt.Errorf("bond %s is no longer allocated to TBA trade %s", delivery.UID, tbaID)
+			}
+			bond := &ledger.Bonds[bondIndex]
+			err = bond.deliver(s.ownerHashFor(ctx, delivery.ToHash, bond.UID))
+			if err != nil {
+				return nil, err
+			}
+
+			transaction := s.GenerateTransactionObject(delivery.ToHash, delivery.FromHash, bond.Cusip, bond.OriginalFace, string(delivery.Price), now)
+			err = s.recordTransaction(ctx, ledger, transaction, delivery.Price.value(), rate(delivery.ToHash), rate(delivery.FromHash))
+			if err != nil {
+				return nil, err
+			}
+
+			err = emitBondTransferred(ctx, *bond, delivery.FromHash, tbaID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, executionEvents(ledger, executed, "TBA", tbaID)...)
+
+		trade, err := s.GetTBATrade(ctx, tbaID)
+		if err != nil {
+			return nil, err
+		}
+		err = tbaLifecycle.move(tbaID, &trade.State, "Settled")
+		if err != nil {
+			return nil, err
+		}
+		err = s.putRecord(ctx, tbaObjectType, tbaID, trade)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, tradeID := range obligation.TradeIDs {
+		trade, answer, err := matchedTrade(ledger, tradeID)
+		if err != nil {
+			return nil, err
+		}
+		executed := len(ledger.Transactions)
+		transaction, price, err := s.directTradeTransaction(ctx, trade, answer, now)
+		if err != nil {
+			return nil, err
+		}
+		err = s.recordTransaction(ctx, ledger, transaction, price.value(), rate(trade.BidderHash), rate(answer.SellerIDHash))
+		if err != nil {
+			return nil, err
+		}
+		err = s.deliverDirectTrade(ctx, ledger, trade, answer, price)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, executionEvents(ledger, executed, "Trade", tradeID)...)
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	err = s.recordOrderEvents(ctx, events...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, obligation)
+}
+
+// ⭐ Helper functions ⭐
+
+// netObligations returns the net obligation of each pair of counterparties with TBA trades allocated for the month
+// of a settlement date, or direct trades whose settlement instructions matched on it, in PairID order.
+// Direct trades priced in another currency than the settlement currency are left to SettleTradeDvP
+func (s *SmartContract) netObligations(ctx contractapi.TransactionContextInterface, ledger *Ledger, settlementDate string) ([]NetObligation, error) {
+	month := settlementDate[:len(tbaMonthLayout)]
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(tbaObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TBA trades: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	obligations := map[string]*NetObligation{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over TBA trades: %v", err)
+		}
+
+		var trade TBATrade
+		err = json.Unmarshal(queryResponse.Value, &trade)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling TBA trade JSON: %v", err)
+		}
+		if trade.State != "Allocated" || trade.SettlementMonth != month {
+			continue
+		}
+
+		obligation := pairObligation(obligations, settlementDate, trade.BuyerHash, trade.SellerHash)
+		obligation.TBAIDs = append(obligation.TBAIDs, trade.TBAID)
+		for _, allocation := range trade.Allocations {
+			obligation.add(NetDelivery{
+				TBAID:    trade.TBAID,
+				UID:      allocation.UID,
+				Cusip:    allocation.Cusip,
+				Face:     allocation.Face,
+				Price:    trade.Price,
+				FromHash: trade.SellerHash,
+				ToHash:   trade.BuyerHash,
+				Amount:   settlementAmount(allocation.Face, trade.Price.value()),
+			})
+		}
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ledger.DirectTrades {
+		trade := &ledger.DirectTrades[i]
+		if trade.State != "Matched" || trade.Currency != "" {
+			continue
+		}
+		match, err := s.GetSettlementStatus(ctx, trade.DirectTradeID)
+		if err != nil {
+			return nil, err
+		}
+		if match.SettleDate != settlementDate {
+			continue
+		}
+
+		answer := trade.agreedAnswer()
+		if answer == nil {
+			return nil, fmt.Errorf("direct trade %s has no agreed answer", trade.DirectTradeID)
+		}
+		_, price, err := s.directTradeTransaction(ctx, trade, answer, now)
+		if err != nil {
+			return nil, err
+		}
+		obligation := pairObligation(obligations, settlementDate, trade.BidderHash, answer.SellerIDHash)
+		obligation.TradeIDs = append(obligation.TradeIDs, trade.DirectTradeID)
+		obligation.add(NetDelivery{
+			TradeID:  trade.DirectTradeID,
+			UID:      answer.BondUID,
+			Cusip:    trade.Cusip,
+			Face:     trade.openFace(),
+			Price:    price,
+			FromHash: answer.SellerIDHash,
+			ToHash:   trade.BidderHash,
+			Amount:   settlementAmount(trade.openFace(), price.value()),
+		})
+	}
+
+	pairIDs := []string{}
+	for pairID := range obligations {
+		pairIDs = append(pairIDs, pairID)
+	}
+	sort.Strings(pairIDs)
+
+	netted := []NetObligation{}
+	for _, pairID := range pairIDs {
+		netted = append(netted, *obligations[pairID])
+	}
+
+	return netted, nil
+}
+
+// pairObligation returns the obligation between a buyer and a seller on a settlement date, adding it when there is none yet
+func pairObligation(obligations map[string]*NetObligation, settlementDate, buyerHash, sellerHash string) *NetObligation {
+	partyA, partyB := buyerHash, sellerHash
+	if partyB < partyA {
+		partyA, partyB = partyB, partyA
+	}
+	pairID := partyA + pairSeparator + partyB
+	obligation, ok := obligations[pairID]
+	if !ok {
+		obligation = &NetObligation{
+			PairID:         pairID,
+			SettlementDate: settlementDate,
+			PartyA:         partyA,
+			PartyB:         partyB,
+			TBAIDs:         []string{},
+			TradeIDs:       []string{},
+			Deliveries:     []NetDelivery{},
+		}
+		obligations[pairID] = obligation
+	}
+
+	return obligation
+}
+
+// add nets a delivery into the obligation
+func (o *NetObligation) add(delivery NetDelivery) {
+	o.Deliveries = append(o.Deliveries, delivery)
+	o.GrossCash += delivery.Amount
+	if delivery.ToHash == o.PartyA {
+		o.NetCash += delivery.Amount
+	} else {
+		o.NetCash -= delivery.Amount
+	}
+}
+
+// matchedTrade returns a direct trade of the ledger whose settlement instructions matched, and the answer both sides agreed on
+func matchedTrade(ledger *Ledger, tradeID string) (*DirectTrade, *Answer, error) {
+	for i := range ledger.DirectTrades {
+		trade := &ledger.DirectTrades[i]
+		if trade.DirectTradeID != tradeID {
+			continue
+		}
+		answer := trade.agreedAnswer()
+		if trade.State != "Matched" || answer == nil {
+			return nil, nil, fmt.Errorf("the settlement instructions of direct trade %s have not matched", tradeID)
+		}
+		return trade, answer, nil
+	}
+
+	return nil, nil, NewError(ErrNotFound, "direct trade not found")
+}
@@ -0,0 +1,183 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+// setUpNetting returns a world where Org1 buys a pool of testCusip from Org2 with TBA trade "tba1", and sells
+// a pool of otherCusip to Org2 with TBA trade "tba2", both allocated for January 2023
+func setUpNetting(t *testing.T, contract *chaincode.SmartContract) *world {
+	w := setUp(t, contract)
+	createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+	createBond(t, w, contract, "uid2", org1, otherCusip, tradeFace/2)
+	for _, cusip := range []string{testCusip, otherCusip} {
+		_, err := contract.RegisterPool(w.begin(org1), cusip, "", 5.5, 1, "2023-01-01", 360)
+		require.NoError(t, err)
+		w.commit()
+	}
+
+	for _, trade := range []struct {
+		tbaID, buyer, seller, cusip, price string
+		face                               int64
+	}{
+		{"tba1", org1, org2, testCusip, tradePrice, tradeFace},
+		{"tba2", org2, org1, otherCusip, "100", tradeFace / 2},
+	} {
+		_, err := contract.CreateTBATrade(w.begin(trade.buyer), trade.tbaID, trade.buyer, trade.seller, "FNMA", 5.5, 30, "2023-01", trade.face, trade.price, testTime)
+		require.NoError(t, err)
+		w.commit()
+		_, err = contract.AllocatePools(w.begin(trade.seller), trade.tbaID, []string{trade.cusip})
+		require.NoError(t, err)
+		w.commit()
+	}
+	return w
+}
+
+func TestSettleNet(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUpNetting(t, contract)
+
+			obligations, err := contract.ComputeNetObligations(w.begin(org1), "2023-01-09")
+			require.NoError(t, err)
+			require.Len(t, obligations, 1)
+			obligation := obligations[0]
+			require.Equal(t, org1+"|"+org2, obligation.PairID)
+			require.Equal(t, []string{"tba1", "tba2"}, obligation.TBAIDs)
+			require.Len(t, obligation.Deliveries, 2)
+			require.Equal(t, int64(149500000), obligation.GrossCash)
+			require.Equal(t, int64(49500000), obligation.NetCash)
+
+			// Org1 only needs cash for the net amount
+			_, err = contract.DepositCash(w.begin(org1), org1, "USD", 49500000)
+			require.NoError(t, err)
+			w.commit()
+
+			_, err = contract.SettleNet(w.begin(org2), "2023-01-09", obligation.PairID)
+			require.NoError(t, err)
+			w.commit()
+
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			owners := map[string]string{}
+			for _, bond := range bonds {
+				require.Empty(t, bond.ReservedFor)
+				owners[bond.UID] = bond.OwnerHash
+			}
+			require.Equal(t, map[string]string{"uid1": org1, "uid2": org2}, owners)
+
+			buyer, err := contract.GetCashBalance(w.begin(org1), org1)
+			require.NoError(t, err)
+			require.Equal(t, int64(0), buyer.Balance)
+			seller, err := contract.GetCashBalance(w.begin(org2), org2)
+			require.NoError(t, err)
+			require.Equal(t, int64(49500000), seller.Balance)
+
+			transactions, err := contract.GetAllTransactions(w.begin(org1))
+			require.NoError(t, err)
+			require.Len(t, transactions, 2)
+
+			trade, err := contract.GetTBATrade(w.begin(org1), "tba2")
+			require.NoError(t, err)
+			require.Equal(t, "Settled", trade.State)
+
+			obligations, err = contract.ComputeNetObligations(w.begin(org1), "2023-01-09")
+			require.NoError(t, err)
+			require.Empty(t, obligations)
+		})
+	}
+}
+
+func TestSettleNetErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		date   string
+		pairID string
+		err    string
+	}{
+		{name: "before the settlement date", date: "2023-01-10", pairID: org1 + "|" + org2, err: "the obligations of Org1MSP|Org2MSP settle on 2023-01-10"},
+		{name: "no obligations on the date", date: "2022-12-09", pairID: org1 + "|" + org2, err: "no pending obligations between Org1MSP|Org2MSP on 2022-12-09"},
+		{name: "pair reversed", date: "2023-01-09", pairID: org2 + "|" + org1, err: "no pending obligations between Org2MSP|Org1MSP on 2023-01-09"},
+		{name: "payer short of cash", date: "2023-01-09", pairID: org1 + "|" + org2, err: "insufficient cash for Org1MSP: balance 0.00, needed 495000.00"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract := &chaincode.SmartContract{}
+			w := setUpNetting(t, contract)
+
+			_, err := contract.SettleNet(w.begin(org2), test.date, test.pairID)
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestSettleNetMatchedTrade(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUpNetting(t, contract)
+			_, err := contract.UpdateConfig(w.begin(org1), `{"allowedCouponTypes":["FIXED"],"settlementCurrency":"USD","adminMSPs":["Org1MSP"],"requireInstructions":true}`)
+			require.NoError(t, err)
+			w.commit()
+
+			// Org2 also buys a bond of testCusip from Org1 with a direct trade whose instructions match for 2023-01-10
+			createBond(t, w, contract, "uid3", org1, testCusip, tradeFace)
+			_, err = contract.CreateTrade(w.begin(org2), "trade1", org2, testCusip, "2023-01-09T12:00:00Z", tradeFace, tradePrice, false, "")
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.AnswerTrade(w.begin(org1), "trade1", org1, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.AnswerTradeAsOwner(w.begin(org2), "trade1", org1, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+			for _, party := range []struct{ msp, instructions string }{{org2, buyerInstructions}, {org1, sellerInstructions}} {
+				_, err = contract.SubmitSettlementInstructions(w.begin(party.msp), "trade1", party.instructions)
+				require.NoError(t, err)
+				w.commit()
+			}
+
+			obligations, err := contract.ComputeNetObligations(w.begin(org1), "2023-01-10")
+			require.NoError(t, err)
+			require.Len(t, obligations, 1)
+			obligation := obligations[0]
+			require.Equal(t, []string{"tba1", "tba2"}, obligation.TBAIDs)
+			require.Equal(t, []string{"trade1"}, obligation.TradeIDs)
+			require.Len(t, obligation.Deliveries, 3)
+			require.Equal(t, int64(249000000), obligation.GrossCash)
+			require.Equal(t, int64(-50000000), obligation.NetCash)
+
+			// Org2 only needs cash for the net amount
+			_, err = contract.DepositCash(w.begin(org1), org2, "USD", 50000000)
+			require.NoError(t, err)
+			w.commit()
+
+			_, err = contract.SettleNet(w.beginAt(org1, testTime.Add(24*time.Hour)), "2023-01-10", obligation.PairID)
+			require.NoError(t, err)
+			w.commit()
+
+			owners := map[string]string{}
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			for _, bond := range bonds {
+				require.Empty(t, bond.ReservedFor)
+				owners[bond.UID] = bond.OwnerHash
+			}
+			require.Equal(t, map[string]string{"uid1": org1, "uid2": org2, "uid3": org2}, owners)
+			requireCash(t, w, contract, 50000000, 0)
+
+			trade, err := contract.GetDirectTrade(w.begin(org1), "trade1")
+			require.NoError(t, err)
+			require.Equal(t, "Settled", trade.State)
+			transactions, err := contract.GetAllTransactions(w.begin(org1))
+			require.NoError(t, err)
+			require.Len(t, transactions, 3)
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// Offer is an owner's public ask for one of its bonds, which any buyer can lift at the ask price
+type Offer struct {
+	OfferID       string    `json:"offerID"`
+	UID           string    `json:"uid"`
+	Cusip         string    `json:"cusip"`
+	OriginalFace  int64     `json:"originalFace"` // In cents
+	AskPrice      Price     `json:"askPrice"`
+	SellerHash    string    `json:"sellerHash"`
+	State         string    `json:"state"` //"Open", "Filled" or "Cancelled"
+	CreatedAt     time.Time `json:"createdAt"`
+	ExpiresAt     time.Time `json:"expiresAt"`
+	AllowPartial  bool      `json:"allowPartial"`  // Whether the offer may be filled in several pieces
+	RemainingFace int64     `json:"remainingFace"` // Face still to be sold, in cents
+	MinPiece      int64     `json:"minPiece"`      // Minimum piece of the seller's holding, copied from its private collection when the offer is posted
+}
+
+const offerObjectType = "offer"
+
+// ⭐ Functions ⭐
+
+// CreateOffer posts an ask for the caller's bond with the given UID. The bond is held for the offer until it is lifted or cancelled.
+// An offer at or below a resting bid is executed against it right away, at the bid's price.
+func (s *SmartContract) CreateOffer(ctx contractapi.TransactionContextInterface, offerID, uid, askPrice string, createdAt, expiresAt time.Time, allowPartial bool) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	toUTC(&expiresAt)
+
+	price, err := s.parsePrice(ctx, askPrice)
+	if err != nil {
+		return nil, err
+	}
+	if price.value() <= 0 {
+		return nil, fmt.Errorf("ask price must be positive: %s", price)
+	}
+	if !expiresAt.After(createdAt) {
+		return nil, fmt.Errorf("offer must expire after it is created")
+	}
+
+	var existing Offer
+	exists, err := s.getRecord(ctx, offerObjectType, offerID, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, NewError(ErrAlreadyExists, "offer %s already exists", offerID)
+	}
+
+	ledger, err := s.getBondLedger(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	bondIndex := findBondByUID(ledger, uid)
+	if bondIndex == -1 {
+		return nil, NewError(ErrNotFound, "bond with UID %s not found", uid)
+	}
+	bond := &ledger.Bonds[bondIndex]
+	owner, err := s.callerOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !owner.owns(bond) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of the bond")
+	}
+	if bond.ReservedFor != "" {
+		return nil, NewConflictError(bond.UID, bond.Cusip, bond.ReservedFor)
+	}
+
+	// Buyers cross the offer without access to the seller's collection, so the minimum piece travels with the offer
+	privateBonds, err := s.getPrivateBonds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var minPiece int64
+	for _, privateBond := range privateBonds {
+		if privateBond.UID == uid {
+			minPiece = privateBond.MinPiece
+			break
+		}
+	}
+	if minPiece > bond.OriginalFace {
+		return nil, fmt.Errorf("the minimum piece %d is larger than the bond's face %d", minPiece, bond.OriginalFace)
+	}
+
+	// Hold the bond so no trade can consume it while it is offered
+	err = bond.reserve(offerID)
+	if err != nil {
+		return nil, err
+	}
+
+	offer := Offer{
+		OfferID:       offerID,
+		UID:           bond.UID,
+		Cusip:         bond.Cusip,
+		OriginalFace:  bond.OriginalFace,
+		AskPrice:      price,
+		SellerHash:    owner.hash,
+		State:         "Open",
+		CreatedAt:     createdAt,
+		ExpiresAt:     expiresAt,
+		AllowPartial:  allowPartial,
+		RemainingFace: bond.OriginalFace,
+		MinPiece:      minPiece,
+	}
+
+	// Execute against resting bids before the offer rests itself
+	executed := len(ledger.Transactions)
+	err = s.crossOffer(ctx, ledger, &offer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cross offer with bids: %v", err)
+	}
+
+	err = s.putRecord(ctx, offerObjectType, offerID, offer)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	err = s.notifyWatchers(ctx, "Offer", offerID, offer.Cusip, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	created := OrderEvent{Action: "Create", OrderType: "Offer", OrderID: offerID, Cusip: offer.Cusip, Face: offer.OriginalFace, Price: price}
+	err = s.recordOrderEvents(ctx, append([]OrderEvent{created}, executionEvents(ledger, executed, "Offer", offerID)...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, offerID)
+}
+
+// LiftOffer buys an open offer at its ask price, transferring the bond to the buyer and recording the transaction
+func (s *SmartContract) LiftOffer(ctx contractapi.TransactionContextInterface, offerID, buyerHash string, timestamp time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	offer, err := s.getOpenOffer(ctx, offerID)
+	if err != nil {
+		return nil, err
+	}
+	if !timestamp.Before(offer.ExpiresAt) {
+		return nil, fmt.Errorf("offer %s expired at %v", offerID, offer.ExpiresAt)
+	}
+	if buyerHash == offer.SellerHash {
+		return nil, fmt.Errorf("you cannot lift your own offer")
+	}
+	if !s.IsOwner(ctx, buyerHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of %s", buyerHash)
+	}
+
+	ledger, err := s.getCusipLedger(ctx, offer.Cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	bondIndex := findBondByUID(ledger, offer.UID)
+	if bondIndex == -1 || ledger.Bonds[bondIndex].ReservedFor != offerID {
+		return nil, fmt.Errorf("the bond of offer %s is no longer available", offerID)
+	}
+
+	// Update bond owner and free it
+	err = ledger.Bonds[bondIndex].deliver(s.ownerHashFor(ctx, buyerHash, ledger.Bonds[bondIndex].UID))
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitBondTransferred(ctx, ledger.Bonds[bondIndex], offer.SellerHash, offerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate transaction
+	executed := len(ledger.Transactions)
+	transaction := s.GenerateTransactionObject(buyerHash, offer.SellerHash, offer.Cusip, offer.openFace(), string(offer.AskPrice), timestamp)
+	err = s.settleTransaction(ctx, ledger, transaction, offer.AskPrice.value())
+	if err != nil {
+		return nil, err
+	}
+
+	err = offerLifecycle.move(offerID, &offer.State, "Filled")
+	if err != nil {
+		return nil, err
+	}
+	offer.RemainingFace = 0
+	err = s.putRecord(ctx, offerObjectType, offerID, offer)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	err = s.recordOrderEvents(ctx, executionEvents(ledger, executed, "Offer", offerID)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// CancelOffer withdraws an open offer if the caller is the seller, freeing the bond
+func (s *SmartContract) CancelOffer(ctx contractapi.TransactionContextInterface, offerID string) (*WriteResponse, error) {
+	offer, err := s.getOpenOffer(ctx, offerID)
+	if err != nil {
+		return nil, err
+	}
+	if !s.IsOwner(ctx, offer.SellerHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of the offer")
+	}
+
+	ledger, err := s.getCusipLedger(ctx, offer.Cusip)
+	if err != nil {
+		return nil, err
+	}
+	releaseReservations(ledger, offerID, "")
+
+	err = offerLifecycle.move(offerID, &offer.State, "Cancelled")
+	if err != nil {
+		return nil, err
+	}
+	err = s.putRecord(ctx, offerObjectType, offerID, offer)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	err = s.recordOrderEvents(ctx, OrderEvent{Action: "Cancel", OrderType: "Offer", OrderID: offerID, Cusip: offer.Cusip, Face: offer.openFace(), Price: offer.AskPrice})
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// GetOffers returns the open offers for a given cusip. Expired offers stay open until their seller cancels them
+func (s *SmartContract) GetOffers(ctx contractapi.TransactionContextInterface, cusip string) ([]Offer, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(offerObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offers: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	offers := []Offer{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over offers: %v", err)
+		}
+
+		var offer Offer
+		err = json.Unmarshal(queryResponse.Value, &offer)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling offer JSON: %v", err)
+		}
+		if offer.Cusip == cusip && offer.State == "Open" {
+			offers = append(offers, offer)
+		}
+	}
+
+	return offers, nil
+}
+
+// ⭐ Helper functions ⭐
+
+func (s *SmartContract) getOpenOffer(ctx contractapi.TransactionContextInterface, offerID string) (*Offer, error) {
+	var offer Offer
+	exists, err := s.getRecord(ctx, offerObjectType, offerID, &offer)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, NewError(ErrNotFound, "offer %s not found", offerID)
+	}
+	if offer.State != "Open" {
+		return nil, fmt.Errorf("offer %s is %s", offerID, offer.State)
+	}
+
+	return &offer, nil
+}
+
+// openFace returns the face of the offer still to be sold. Offers stored before partial fills existed are fully open
+func (o *Offer) openFace() int64 {
+	if o.RemainingFace == 0 && o.State == "Open" {
+		return o.OriginalFace
+	}
+	return o.RemainingFace
+}
+
+// findBondByUID returns the index in the ledger of the bond with the given UID, or -1 if there is none
+func findBondByUID(ledger *Ledger, uid string) int {
+	for i, bond := range ledger.Bonds {
+		if bond.UID == uid {
+			return i
+		}
+	}
+
+	return -1
+}
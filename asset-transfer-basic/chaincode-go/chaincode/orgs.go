@@ -0,0 +1,116 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// OrgProfile is the public registry entry of an organization
+type OrgProfile struct {
+	MSPID        string    `json:"mspID"`
+	Name         string    `json:"name"`
+	Contact      string    `json:"contact"`
+	RegisteredAt time.Time `json:"registeredAt"`
+}
+
+const orgObjectType = "org"
+
+// ⭐ Functions ⭐
+
+// BootstrapOrg sets up everything the caller's organization needs before it can trade, in one transaction:
+// its encryption key, an empty inventory, an empty private bond store and its registry entry.
+// Pieces an organization already has, from before bootstrapping existed, are kept as they are
+func (s *SmartContract) BootstrapOrg(ctx contractapi.TransactionContextInterface, profileJSON string) (*WriteResponse, error) {
+	var profile OrgProfile
+	err := json.Unmarshal([]byte(profileJSON), &profile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal profile JSON: %v", err)
+	}
+	if profile.Name == "" {
+		return nil, fmt.Errorf("the profile must have a name")
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	var existing OrgProfile
+	exists, err := s.getRecord(ctx, orgObjectType, mspID, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, NewError(ErrAlreadyExists, "%s is already bootstrapped", mspID)
+	}
+
+	// The encryption key is the pseudonym the organization owns bonds under
+	encryptionKey, err := ctx.GetStub().GetPrivateData(implicitCollection(mspID), "encryption_key")
+	if err != nil {
+		return nil, fmt.Errorf("%s - failed to get encryption key: %v", implicitCollection(mspID), err)
+	}
+	if encryptionKey == nil {
+		err = ctx.GetStub().PutPrivateData(implicitCollection(mspID), "encryption_key", []byte(mspID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to store encryption key: %v", err)
+		}
+	}
+
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inventory, err := stores.Inventory.GetInventory(mspID)
+	if err != nil {
+		return nil, err
+	}
+	if inventory == nil {
+		err = stores.Inventory.PutInventory(mspID, &Inventory{Assets: []*PrivateAgencyMBSPassthrough{}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to store inventory: %v", err)
+		}
+	}
+
+	privateBonds, err := ctx.GetStub().GetPrivateData(implicitCollection(mspID), "private_bonds_information")
+	if err != nil {
+		return nil, fmt.Errorf("%s - failed to get private bonds: %v", implicitCollection(mspID), err)
+	}
+	if privateBonds == nil {
+		err = s.putPrivateBonds(ctx, []PrivateBond{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	profile.MSPID = mspID
+	profile.RegisteredAt = timestamp
+	err = s.putRecord(ctx, orgObjectType, mspID, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, profile)
+}
+
+// GetOrgProfile returns the registry entry of an organization
+func (s *SmartContract) GetOrgProfile(ctx contractapi.TransactionContextInterface, mspID string) (*OrgProfile, error) {
+	var profile OrgProfile
+	exists, err := s.getRecord(ctx, orgObjectType, mspID, &profile)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%s is not bootstrapped", mspID)
+	}
+
+	return &profile, nil
+}
@@ -0,0 +1,196 @@
+package chaincode
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// bondOwner identifies an organization when matching the owner hashes of bonds: its party hash, the encryption key it trades
+// and holds cash under, and the owner secret its bonds are committed with. The secret is only known for the caller's organization
+type bondOwner struct {
+	hash   string
+	secret []byte
+}
+
+// OwnerSecretRotation is what RotateOwnerSecret did with the caller's bonds
+type OwnerSecretRotation struct {
+	Committed int `json:"committed"` // Bonds of the caller now committed with the new secret
+}
+
+const (
+	// Private data key, and transient key, of an organization's owner secret
+	ownerSecretKey = "owner_secret"
+	// An owner secret is 32 random bytes. The client draws them, since the peers endorsing a transaction must agree on every write
+	ownerSecretSize = 32
+)
+
+// ⭐ Functions ⭐
+
+// RotateOwnerSecret stores a new owner secret, passed in the transient map under "owner_secret", in the caller's implicit
+// collection and re-commits every bond the caller owns with it, whether it was committed with the previous secret or held
+// under the caller's party hash. The owner hash of a committed bond is HMAC-SHA256(secret, UID), so the public ledger no
+// longer tells which organization holds it, nor that two bonds have the same holder. The first rotation sets the secret.
+// Bonds the caller acquires afterwards in transactions it submits itself are committed as they change hands
+func (s *SmartContract) RotateOwnerSecret(ctx contractapi.TransactionContextInterface) (*WriteResponse, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transient data: %v", err)
+	}
+	secret, ok := transientMap[ownerSecretKey]
+	if !ok {
+		return nil, fmt.Errorf("the new owner secret must be passed in the transient map under %q", ownerSecretKey)
+	}
+	if len(secret) != ownerSecretSize {
+		return nil, fmt.Errorf("the owner secret must be %d bytes: %d", ownerSecretSize, len(secret))
+	}
+
+	owner, err := s.callerOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if owner.secret != nil && hmac.Equal(owner.secret, secret) {
+		return nil, fmt.Errorf("the new owner secret must differ from the current one")
+	}
+	rotated := bondOwner{hash: owner.hash, secret: secret}
+
+	ledger, err := s.GetLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rotation := OwnerSecretRotation{}
+	for i := range ledger.Bonds {
+		bond := &ledger.Bonds[i]
+		if !owner.owns(bond) {
+			continue
+		}
+		bond.OwnerHash = rotated.commit(bond.UID)
+		rotation.Committed++
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	err = ctx.GetStub().PutPrivateData(implicitCollection(mspID), ownerSecretKey, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store owner secret: %v", err)
+	}
+
+	if rotation.Committed > 0 {
+		err = s.updateLedger(ctx, ledger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update ledger: %v", err)
+		}
+	}
+
+	return newWriteResponse(ctx, rotation)
+}
+
+// ⭐ Helper functions ⭐
+
+// callerOwner returns the caller's organization as a bond owner, with its owner secret if it has set one
+func (s *SmartContract) callerOwner(ctx contractapi.TransactionContextInterface) (bondOwner, error) {
+	hash, err := s.getEncryptionKey(ctx)
+	if err != nil {
+		return bondOwner{}, err
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return bondOwner{}, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	secret, err := ctx.GetStub().GetPrivateData(implicitCollection(mspID), ownerSecretKey)
+	if err != nil {
+		return bondOwner{}, fmt.Errorf("%s - failed to get owner secret: %v", implicitCollection(mspID), err)
+	}
+
+	return bondOwner{hash: hash, secret: secret}, nil
+}
+
+// partyOwner returns another organization as a bond owner. Without its secret, only the bonds it holds under its party hash match
+func partyOwner(hash string) bondOwner {
+	return bondOwner{hash: hash}
+}
+
+// ownerFor returns the party as a bond owner: with its owner secret when it is the caller, else by its party hash alone
+func (s *SmartContract) ownerFor(ctx contractapi.TransactionContextInterface, partyHash string) bondOwner {
+	owner, err := s.callerOwner(ctx)
+	if err != nil || owner.hash != partyHash {
+		return partyOwner(partyHash)
+	}
+
+	return owner
+}
+
+// ownsBond checks if the caller owns a bond, under its party hash or under a commitment of its owner secret
+func (s *SmartContract) ownsBond(ctx contractapi.TransactionContextInterface, bond *AgencyMBSPassthrough) bool {
+	owner, err := s.callerOwner(ctx)
+	if err != nil {
+		return false
+	}
+
+	return owner.owns(bond)
+}
+
+// ownerHashFor returns the owner hash a bond acquired by the party is stored under. A bond the caller acquires is committed
+// with its owner secret, if it has one. Any other party gets the bond under its party hash, since its secret is out of reach
+func (s *SmartContract) ownerHashFor(ctx contractapi.TransactionContextInterface, partyHash, uid string) string {
+	owner, err := s.callerOwner(ctx)
+	if err != nil || owner.hash != partyHash {
+		return partyHash
+	}
+
+	return owner.commit(uid)
+}
+
+// owns checks if the owner hash of a bond is the organization's party hash or its commitment to the bond.
+// No one owns a syndicated bond whole
+func (o bondOwner) owns(bond *AgencyMBSPassthrough) bool {
+	if bond.syndicated() {
+		return false
+	}
+	if bond.OwnerHash == o.hash {
+		return true
+	}
+
+	return o.secret != nil && hmac.Equal([]byte(bond.OwnerHash), []byte(ownerCommitment(o.secret, bond.UID)))
+}
+
+// mayOwn checks if a bond can be the organization's: it owns it, or the bond is committed with a secret out of reach.
+// A hold the owner placed on such a bond then stands as the proof of ownership
+func (o bondOwner) mayOwn(bond *AgencyMBSPassthrough) bool {
+	if bond.syndicated() {
+		return o.share(bond) > 0
+	}
+	return o.owns(bond) || (o.secret == nil && isOwnerCommitment(bond.OwnerHash))
+}
+
+// commit returns the owner hash of a bond held by the organization: its commitment when it has a secret, else its party hash
+func (o bondOwner) commit(uid string) string {
+	if o.secret == nil {
+		return o.hash
+	}
+
+	return ownerCommitment(o.secret, uid)
+}
+
+// ownerCommitment returns the commitment of an owner secret to a bond, HMAC-SHA256(secret, UID) in hex
+func ownerCommitment(secret []byte, uid string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(uid))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// isOwnerCommitment checks if an owner hash is a commitment rather than a party hash
+func isOwnerCommitment(ownerHash string) bool {
+	if len(ownerHash) != hex.EncodedLen(sha256.Size) {
+		return false
+	}
+	_, err := hex.DecodeString(ownerHash)
+	return err == nil
+}
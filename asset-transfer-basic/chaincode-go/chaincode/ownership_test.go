@@ -0,0 +1,203 @@
+package chaincode_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+var ownerCommitment = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// rotateOwnerSecret sets the owner secret of an organization to 32 bytes of fill
+func rotateOwnerSecret(t *testing.T, w *world, contract *chaincode.SmartContract, mspID string, fill byte) chaincode.OwnerSecretRotation {
+	w.transient = map[string][]byte{"owner_secret": bytes.Repeat([]byte{fill}, 32)}
+	response, err := contract.RotateOwnerSecret(w.begin(mspID))
+	require.NoError(t, err)
+	w.commit()
+	return response.Result.(chaincode.OwnerSecretRotation)
+}
+
+func TestRotateOwnerSecret(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUp(t, contract)
+			createBond(t, w, contract, "uid1", org2, testCusip, 100000000)
+			createBond(t, w, contract, "uid2", org1, otherCusip, 50000000)
+
+			rotation := rotateOwnerSecret(t, w, contract, org2, 1)
+			require.Equal(t, 1, rotation.Committed)
+
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			committed := bonds[0].OwnerHash
+			require.Regexp(t, ownerCommitment, committed)
+			require.Equal(t, org1, bonds[1].OwnerHash)
+
+			report, err := contract.GetPositionReport(w.begin(org2))
+			require.NoError(t, err)
+			require.Len(t, report.Positions, 1)
+			require.Equal(t, testCusip, report.Positions[0].Cusip)
+
+			report, err = contract.GetPositionReport(w.begin(org1))
+			require.NoError(t, err)
+			require.Len(t, report.Positions, 1)
+			require.Equal(t, otherCusip, report.Positions[0].Cusip)
+
+			// A new secret re-commits the bond
+			rotation = rotateOwnerSecret(t, w, contract, org2, 2)
+			require.Equal(t, 1, rotation.Committed)
+			bonds, err = contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Regexp(t, ownerCommitment, bonds[0].OwnerHash)
+			require.NotEqual(t, committed, bonds[0].OwnerHash)
+
+			// Only the owner can move a committed bond
+			_, err = contract.TransferBond(w.begin(org1), "uid1", org1)
+			require.Error(t, err)
+			_, err = contract.TransferBond(w.begin(org2), "uid1", org1)
+			require.NoError(t, err)
+			w.commit()
+
+			bonds, err = contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Equal(t, org1, bonds[0].OwnerHash)
+
+			transactions, err := contract.GetAllTransactions(w.begin(org1))
+			require.NoError(t, err)
+			require.Equal(t, org2, transactions[len(transactions)-1].SellerID)
+		})
+	}
+}
+
+func TestRotateOwnerSecretErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		transient map[string][]byte
+		err       string
+	}{
+		{name: "no secret", err: `the new owner secret must be passed in the transient map under "owner_secret"`},
+		{name: "wrong size", transient: map[string][]byte{"owner_secret": []byte("short")}, err: "the owner secret must be 32 bytes: 5"},
+		{name: "current secret", transient: map[string][]byte{"owner_secret": bytes.Repeat([]byte{1}, 32)}, err: "the new owner secret must differ from the current one"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract := &chaincode.SmartContract{}
+			w := setUp(t, contract)
+			rotateOwnerSecret(t, w, contract, org2, 1)
+
+			w.transient = test.transient
+			_, err := contract.RotateOwnerSecret(w.begin(org2))
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestOwnerSecretAcquisition(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUpDecliningOffer(t, contract)
+			rotation := rotateOwnerSecret(t, w, contract, org1, 1)
+			require.Zero(t, rotation.Committed)
+
+			_, err := contract.AcceptOffer(w.beginAt(org1, testTime.Add(time.Hour)), "dutch1", org1)
+			require.NoError(t, err)
+			w.commit()
+
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Regexp(t, ownerCommitment, bonds[0].OwnerHash)
+
+			report, err := contract.GetPositionReport(w.begin(org1))
+			require.NoError(t, err)
+			require.Len(t, report.Positions, 1)
+			require.Equal(t, 1, report.Positions[0].Bonds)
+
+			// The committed bond can be offered again, under the party hash of its owner
+			_, err = contract.CreateDecliningOffer(w.begin(org1), "dutch2", "uid1", "101", "99", "0.5", 60, testTime, testTime.Add(24*time.Hour))
+			require.NoError(t, err)
+			w.commit()
+
+			offers, err := contract.GetDecliningOffers(w.begin(org2), testCusip)
+			require.NoError(t, err)
+			require.Equal(t, org1, offers[len(offers)-1].SellerHash)
+		})
+	}
+}
+
+func TestOwnerSecretDirectTrade(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUpTrade(t, contract, 200000000)
+			rotateOwnerSecret(t, w, contract, org2, 1)
+
+			// The seller holds its committed bond for the trade, and the buyer settles without the seller's secret
+			_, err := contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+
+			_, err = contract.AnswerTradeAsOwner(w.begin(org1), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Equal(t, org1, bonds[0].OwnerHash)
+			require.Empty(t, bonds[0].ReservedFor)
+		})
+	}
+}
+
+func TestProveOwnership(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+	createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+
+	_, err := contract.ProveOwnership(w.begin(org2), "uid1", "challenge1")
+	require.EqualError(t, err, "set an owner secret with RotateOwnerSecret before proving ownership")
+
+	rotateOwnerSecret(t, w, contract, org2, 1)
+	_, err = contract.ProveOwnership(w.begin(org2), "uid2", "challenge1")
+	require.EqualError(t, err, contractError(chaincode.ErrNotFound, "bond with UID uid2 not found"))
+	rotateOwnerSecret(t, w, contract, org1, 2)
+	_, err = contract.ProveOwnership(w.begin(org1), "uid1", "challenge1")
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "you do not own bond uid1 under a commitment of your owner secret"))
+
+	response, err := contract.ProveOwnership(w.begin(org2), "uid1", "challenge1")
+	require.NoError(t, err)
+	w.commit()
+	proof := response.Result.(chaincode.OwnershipProof)
+	mac := hmac.New(sha256.New, bytes.Repeat([]byte{1}, 32))
+	mac.Write([]byte{0, 0, 0, 0, 0, 0, 0, 4})
+	mac.Write([]byte("uid1challenge1"))
+	require.Equal(t, hex.EncodeToString(mac.Sum(nil)), proof.Proof)
+
+	_, err = contract.ProveOwnership(w.begin(org2), "uid1", "challenge1")
+	require.EqualError(t, err, contractError(chaincode.ErrAlreadyExists, "bond uid1 was already proved with nonce challenge1"))
+
+	valid, err := contract.VerifyOwnershipProof(w.begin(org1), "uid1", "challenge1", proof.OwnerHash)
+	require.NoError(t, err)
+	require.True(t, valid)
+	valid, err = contract.VerifyOwnershipProof(w.begin(org1), "uid1", "challenge2", proof.OwnerHash)
+	require.NoError(t, err)
+	require.False(t, valid)
+	valid, err = contract.VerifyOwnershipProof(w.begin(org1), "uid1", "challenge1", org2)
+	require.NoError(t, err)
+	require.False(t, valid)
+
+	// A proof no longer holds once the bond is committed anew
+	rotateOwnerSecret(t, w, contract, org2, 3)
+	valid, err = contract.VerifyOwnershipProof(w.begin(org1), "uid1", "challenge1", proof.OwnerHash)
+	require.NoError(t, err)
+	require.False(t, valid)
+}
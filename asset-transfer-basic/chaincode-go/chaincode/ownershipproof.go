@@ -0,0 +1,129 @@
+package chaincode
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// OwnershipProof answers a challenge to prove ownership of a bond committed with an owner secret:
+// HMAC-SHA256(secret, len(UID) || UID || nonce) in hex, with the length as 8 big-endian bytes. Only the holder of the secret
+// can compute it, and the peers endorsing ProveOwnership checked the bond commitment with it
+type OwnershipProof struct {
+	UID       string    `json:"uid"`
+	Nonce     string    `json:"nonce"`     // Chosen by the challenger, so that a proof cannot be replayed
+	OwnerHash string    `json:"ownerHash"` // Owner hash of the bond when it was proved
+	Proof     string    `json:"proof"`
+	TxID      string    `json:"txID"`
+	ProvedAt  time.Time `json:"provedAt"`
+}
+
+const ownershipProofObjectType = "ownershipproof"
+
+// ⭐ Functions ⭐
+
+// ProveOwnership answers a counterparty's or an auditor's challenge with a nonce by recording a proof that the caller owns
+// a bond, without the owner secret leaving its implicit collection. The bond must be committed with the caller's owner secret,
+// and each nonce proves a bond once
+func (s *SmartContract) ProveOwnership(ctx contractapi.TransactionContextInterface, uid, nonce string) (*WriteResponse, error) {
+	if nonce == "" {
+		return nil, NewError(ErrInvalidInput, "the nonce of an ownership proof cannot be empty")
+	}
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bond, err := stores.Bonds.GetBond(uid)
+	if err != nil {
+		return nil, err
+	}
+	if bond == nil {
+		return nil, NewError(ErrNotFound, "bond with UID %s not found", uid)
+	}
+
+	owner, err := s.callerOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if owner.secret == nil {
+		return nil, fmt.Errorf("set an owner secret with RotateOwnerSecret before proving ownership")
+	}
+	if !owner.owns(bond) || bond.OwnerHash != owner.commit(uid) {
+		return nil, NewError(ErrUnauthorized, "you do not own bond %s under a commitment of your owner secret", uid)
+	}
+
+	var existing OwnershipProof
+	exists, err := s.getCompositeRecord(ctx, ownershipProofObjectType, []string{uid, nonce}, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, NewError(ErrAlreadyExists, "bond %s was already proved with nonce %s", uid, nonce)
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	proof := OwnershipProof{
+		UID:       uid,
+		Nonce:     nonce,
+		OwnerHash: bond.OwnerHash,
+		Proof:     ownershipProof(owner.secret, uid, nonce),
+		TxID:      ctx.GetStub().GetTxID(),
+		ProvedAt:  timestamp,
+	}
+	err = s.putCompositeRecord(ctx, ownershipProofObjectType, []string{uid, nonce}, proof)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, proof)
+}
+
+// VerifyOwnershipProof reports whether the owner of a bond answered the challenge with the nonce under ownerHash.
+// The check is the endorsement of ProveOwnership: the peers that endorsed it verified the commitment with the owner secret
+// before recording the proof, so a recorded proof is enough and the chaincode cannot recompute it without the secret.
+// It holds while the bond is still under the owner hash it was proved with
+func (s *SmartContract) VerifyOwnershipProof(ctx contractapi.TransactionContextInterface, uid, nonce, ownerHash string) (bool, error) {
+	var recorded OwnershipProof
+	exists, err := s.getCompositeRecord(ctx, ownershipProofObjectType, []string{uid, nonce}, &recorded)
+	if err != nil {
+		return false, err
+	}
+	if !exists || recorded.OwnerHash != ownerHash {
+		return false, nil
+	}
+
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return false, err
+	}
+	bond, err := stores.Bonds.GetBond(uid)
+	if err != nil {
+		return false, err
+	}
+
+	return bond != nil && bond.OwnerHash == ownerHash, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// ownershipProof returns the proof of an owner secret for a bond and a nonce, HMAC-SHA256(secret, len(UID) || UID || nonce)
+// in hex. The length prefix keeps a UID and nonce pair from proving another pair that concatenates to the same bytes
+func ownershipProof(secret []byte, uid, nonce string) string {
+	mac := hmac.New(sha256.New, secret)
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(uid)))
+	mac.Write(length[:])
+	mac.Write([]byte(uid))
+	mac.Write([]byte(nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
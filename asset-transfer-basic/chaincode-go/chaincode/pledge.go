@@ -0,0 +1,159 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// Pledge encumbers a position in favor of another organization. The bond is held for the pledge, so it cannot be sold or transferred until released
+type Pledge struct {
+	PledgeID     string    `json:"pledgeID"`
+	UID          string    `json:"uid"`
+	Cusip        string    `json:"cusip"`
+	OriginalFace int64     `json:"originalFace"` // In cents
+	OwnerHash    string    `json:"ownerHash"`
+	PledgeeHash  string    `json:"pledgeeHash"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+const pledgeObjectType = "pledge"
+
+// ⭐ Functions ⭐
+
+// PledgePosition encumbers the caller's bond with the given UID in favor of the pledgee organization
+func (s *SmartContract) PledgePosition(ctx contractapi.TransactionContextInterface, uid, pledgeeOrg string, createdAt time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger, err := s.getBondLedger(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	bondIndex := findBondByUID(ledger, uid)
+	if bondIndex == -1 {
+		return nil, NewError(ErrNotFound, "bond with UID %s not found", uid)
+	}
+	bond := &ledger.Bonds[bondIndex]
+	owner, err := s.callerOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !owner.owns(bond) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of the bond")
+	}
+	if owner.hash == pledgeeOrg {
+		return nil, fmt.Errorf("you cannot pledge a position to yourself")
+	}
+	if bond.ReservedFor != "" {
+		return nil, NewConflictError(bond.UID, bond.Cusip, bond.ReservedFor)
+	}
+
+	// The pledge holds the bond like a pending trade does, which blocks every sale or transfer path
+	pledgeID := pledgeIDFor(uid)
+	err = bond.reserve(pledgeID)
+	if err != nil {
+		return nil, err
+	}
+
+	pledge := Pledge{
+		PledgeID:     pledgeID,
+		UID:          bond.UID,
+		Cusip:        bond.Cusip,
+		OriginalFace: bond.OriginalFace,
+		OwnerHash:    owner.hash,
+		PledgeeHash:  pledgeeOrg,
+		CreatedAt:    createdAt,
+	}
+	err = s.putRecord(ctx, pledgeObjectType, uid, pledge)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, pledgeID)
+}
+
+// ReleasePledge frees a pledged position. Only the pledgee can release its claim
+func (s *SmartContract) ReleasePledge(ctx contractapi.TransactionContextInterface, uid string) (*WriteResponse, error) {
+	var pledge Pledge
+	exists, err := s.getRecord(ctx, pledgeObjectType, uid, &pledge)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("bond with UID %s is not pledged", uid)
+	}
+	if !s.IsOwner(ctx, pledge.PledgeeHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the pledgee of the position")
+	}
+
+	ledger, err := s.getCusipLedger(ctx, pledge.Cusip)
+	if err != nil {
+		return nil, err
+	}
+	releaseReservations(ledger, pledge.PledgeID, "")
+
+	err = s.deleteRecord(ctx, pledgeObjectType, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// GetYourPledges returns the pledges the caller granted or holds as pledgee
+func (s *SmartContract) GetYourPledges(ctx contractapi.TransactionContextInterface) ([]Pledge, error) {
+	orgHash, err := s.GenerateOrgHash(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate org hash: %v", err)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(pledgeObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pledges: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	pledges := []Pledge{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over pledges: %v", err)
+		}
+
+		var pledge Pledge
+		err = json.Unmarshal(queryResponse.Value, &pledge)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling pledge JSON: %v", err)
+		}
+		if pledge.OwnerHash == orgHash || pledge.PledgeeHash == orgHash {
+			pledges = append(pledges, pledge)
+		}
+	}
+
+	return pledges, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// pledgeIDFor returns the ID a pledge of the bond holds it under. A bond has at most one pledge
+func pledgeIDFor(uid string) string {
+	return "pledge-" + uid
+}
@@ -0,0 +1,73 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPledgePosition(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUp(t, contract)
+			createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+
+			response, err := contract.PledgePosition(w.begin(org2), "uid1", org1, testTime)
+			require.NoError(t, err)
+			require.Equal(t, "pledge-uid1", response.Result)
+			w.commit()
+
+			// The pledgee sees the pledge, and the position can no longer be sold or transferred
+			pledges, err := contract.GetYourPledges(w.begin(org1))
+			require.NoError(t, err)
+			require.Len(t, pledges, 1)
+			require.Equal(t, chaincode.Pledge{PledgeID: "pledge-uid1", UID: "uid1", Cusip: testCusip, OriginalFace: tradeFace, OwnerHash: org2, PledgeeHash: org1, CreatedAt: testTime}, pledges[0])
+
+			var conflict *chaincode.ConflictError
+			_, err = contract.TransferBond(w.begin(org2), "uid1", org1)
+			require.ErrorAs(t, err, &conflict)
+			require.Equal(t, "pledge-uid1", conflict.BlockingTradeID)
+			_, err = contract.CreateOffer(w.begin(org2), "offer1", "uid1", tradePrice, testTime, testTime.Add(time.Hour), false)
+			require.ErrorAs(t, err, &conflict)
+
+			_, err = contract.ReleasePledge(w.begin(org2), "uid1")
+			require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "you are not the pledgee of the position"))
+			_, err = contract.ReleasePledge(w.begin(org1), "uid1")
+			require.NoError(t, err)
+			w.commit()
+
+			pledges, err = contract.GetYourPledges(w.begin(org2))
+			require.NoError(t, err)
+			require.Empty(t, pledges)
+			_, err = contract.TransferBond(w.begin(org2), "uid1", org1)
+			require.NoError(t, err)
+			w.commit()
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Equal(t, org1, bonds[0].OwnerHash)
+		})
+	}
+}
+
+func TestPledgePositionErrors(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+	createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+
+	_, err := contract.PledgePosition(w.begin(org1), "uid1", org2, testTime)
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "you are not the owner of the bond"))
+	_, err = contract.PledgePosition(w.begin(org2), "uid1", org2, testTime)
+	require.EqualError(t, err, "you cannot pledge a position to yourself")
+	_, err = contract.ReleasePledge(w.begin(org1), "uid1")
+	require.EqualError(t, err, "bond with UID uid1 is not pledged")
+
+	_, err = contract.PledgePosition(w.begin(org2), "uid1", org1, testTime)
+	require.NoError(t, err)
+	w.commit()
+	var conflict *chaincode.ConflictError
+	_, err = contract.PledgePosition(w.begin(org2), "uid1", org1, testTime)
+	require.ErrorAs(t, err, &conflict)
+}
@@ -0,0 +1,382 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// Pool holds the pool-level data shared by every position of a cusip
+type Pool struct {
+	Cusip      string  `json:"cusip"`
+	Bond       string  `json:"bond"`
+	Coupon     float64 `json:"coupon"`     // Annual coupon rate in percent, e.g. 6 for 6%
+	Factor     float64 `json:"factor"`     // Share of the original face still outstanding
+	FactorDate string  `json:"factorDate"` // YYYY-MM-DD
+	WAM        int     `json:"wam"`        // Weighted average maturity in months
+	Status     string  `json:"status"`     //"Active" or "Retired"
+}
+
+// Distribution is the monthly interest and principal a holder receives on its positions of a pool
+type Distribution struct {
+	Cusip        string  `json:"cusip"`
+	OwnerHash    string  `json:"ownerHash"`
+	Month        string  `json:"month"`        // YYYY-MM of the factor date
+	OriginalFace int64   `json:"originalFace"` // Face held when the factor was updated, in cents
+	PriorFactor  float64 `json:"priorFactor"`
+	Factor       float64 `json:"factor"`
+	Interest     float64 `json:"interest"`  // One month of coupon on the face outstanding at the prior factor
+	Principal    float64 `json:"principal"` // Share of the paydown between the two factors
+}
+
+// FactorUpdate is one line of a factor file: the new factor of a pool and the date it applies from
+type FactorUpdate struct {
+	Cusip      string  `json:"cusip"`
+	Factor     float64 `json:"factor"`
+	FactorDate string  `json:"factorDate"` // YYYY-MM-DD
+}
+
+// FactorHistoryEntry records a factor applied to a bond, and the face it left outstanding
+type FactorHistoryEntry struct {
+	UID         string  `json:"uid"`
+	Cusip       string  `json:"cusip"`
+	PriorFactor float64 `json:"priorFactor"`
+	Factor      float64 `json:"factor"`
+	FactorDate  string  `json:"factorDate"`
+	CurrentFace int64   `json:"currentFace"` // OriginalFace times Factor, in cents
+	TxID        string  `json:"txID"`
+}
+
+const (
+	poolObjectType         = "pool"
+	distributionObjectType = "distribution"
+	factorHistoryKeyType   = "factorhistory"
+)
+
+// ⭐ Functions ⭐
+
+// RegisterPool stores the pool data of a cusip. Only the admin organization can register pools
+func (s *SmartContract) RegisterPool(ctx contractapi.TransactionContextInterface, cusip, bondID string, coupon, factor float64, factorDate string, wam int) (*WriteResponse, error) {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if factor < 0 || factor > 1 {
+		return nil, fmt.Errorf("factor must be between 0 and 1: %v", factor)
+	}
+	_, err = parseDate(factorDate)
+	if err != nil {
+		return nil, err
+	}
+	if wam <= 0 {
+		return nil, fmt.Errorf("weighted average maturity must be at least one month: %d", wam)
+	}
+
+	var existing Pool
+	exists, err := s.getRecord(ctx, poolObjectType, cusip, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, NewError(ErrAlreadyExists, "pool %s already exists", cusip)
+	}
+
+	pool := Pool{
+		Cusip:      cusip,
+		Bond:       bondID,
+		Coupon:     coupon,
+		Factor:     factor,
+		FactorDate: factorDate,
+		WAM:        wam,
+		Status:     "Active",
+	}
+	err = s.putRecord(ctx, poolObjectType, cusip, pool)
+	if err != nil {
+		return nil, err
+	}
+	return newWriteResponse(ctx, nil)
+}
+
+// GetPool returns the pool data of a cusip
+func (s *SmartContract) GetPool(ctx contractapi.TransactionContextInterface, cusip string) (*Pool, error) {
+	var pool Pool
+	exists, err := s.getRecord(ctx, poolObjectType, cusip, &pool)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, NewError(ErrNotFound, "pool %s not found", cusip)
+	}
+
+	return &pool, nil
+}
+
+// UpdatePoolFactor publishes a new factor for a pool and records the distribution of each holder for the month of the factor date.
+// Only the admin organization can update factors
+func (s *SmartContract) UpdatePoolFactor(ctx contractapi.TransactionContextInterface, cusip string, factor float64, factorDate string) (*WriteResponse, error) {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger, err := s.getCusipLedger(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	distributions, err := s.applyPoolFactor(ctx, ledger, FactorUpdate{Cusip: cusip, Factor: factor, FactorDate: factorDate})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, distributions)
+}
+
+// UpdateFactors applies the monthly factor file, a JSON array of FactorUpdate, to many pools in one transaction.
+// Each pool is updated as UpdatePoolFactor does, and a single invalid line rejects the whole file.
+// Only the admin organization can update factors
+func (s *SmartContract) UpdateFactors(ctx contractapi.TransactionContextInterface, factorFileJSON string) (*WriteResponse, error) {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []FactorUpdate
+	err = json.Unmarshal([]byte(factorFileJSON), &updates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal factor file JSON: %v", err)
+	}
+	if len(updates) == 0 {
+		return nil, fmt.Errorf("the factor file is empty")
+	}
+
+	// The pools of every cusip are changed on one ledger
+	ledger, err := s.GetLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	distributions := []Distribution{}
+	for _, update := range updates {
+		if seen[update.Cusip] {
+			return nil, fmt.Errorf("the factor file has more than one factor for Cusip %s", update.Cusip)
+		}
+		seen[update.Cusip] = true
+
+		poolDistributions, err := s.applyPoolFactor(ctx, ledger, update)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply the factor of Cusip %s: %v", update.Cusip, err)
+		}
+		distributions = append(distributions, poolDistributions...)
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, distributions)
+}
+
+// GetFactorHistory returns the factors applied to a bond, oldest first
+func (s *SmartContract) GetFactorHistory(ctx contractapi.TransactionContextInterface, uid string) ([]FactorHistoryEntry, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(factorHistoryKeyType, []string{uid})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get factor history: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	history := []FactorHistoryEntry{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over factor history: %v", err)
+		}
+
+		var entry FactorHistoryEntry
+		err = json.Unmarshal(queryResponse.Value, &entry)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling factor history JSON: %v", err)
+		}
+		history = append(history, entry)
+	}
+
+	return history, nil
+}
+
+// GetYourDistributions returns the caller's distributions for a month (YYYY-MM), or for every month when month is empty.
+// Bonds committed with the caller's owner secret are holders of their own, so the distributions of the committed bonds
+// it still owns are included
+func (s *SmartContract) GetYourDistributions(ctx contractapi.TransactionContextInterface, month string) ([]Distribution, error) {
+	owner, err := s.callerOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ledger, err := s.GetLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+	holders := []string{owner.hash}
+	for i, bond := range ledger.Bonds {
+		if bond.OwnerHash != owner.hash && owner.owns(&ledger.Bonds[i]) {
+			holders = append(holders, bond.OwnerHash)
+		}
+	}
+
+	distributions := []Distribution{}
+	for _, holder := range holders {
+		attributes := []string{holder}
+		if month != "" {
+			attributes = append(attributes, month)
+		}
+		resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(distributionObjectType, attributes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get distributions: %v", err)
+		}
+
+		for resultsIterator.HasNext() {
+			queryResponse, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				return nil, fmt.Errorf("error iterating over distributions: %v", err)
+			}
+
+			var distribution Distribution
+			err = json.Unmarshal(queryResponse.Value, &distribution)
+			if err != nil {
+				resultsIterator.Close()
+				return nil, fmt.Errorf("error unmarshalling distribution JSON: %v", err)
+			}
+			distributions = append(distributions, distribution)
+		}
+		resultsIterator.Close()
+	}
+
+	return distributions, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// applyPoolFactor publishes a new factor for the pool of a cusip, records the distribution of each holder for the month
+// of the factor date, and brings the factor and current face of the cusip's bonds on the ledger up to date,
+// with a FactorHistoryEntry for each. Factors cannot go up and factor dates cannot go back
+func (s *SmartContract) applyPoolFactor(ctx contractapi.TransactionContextInterface, ledger *Ledger, update FactorUpdate) ([]Distribution, error) {
+	pool, err := s.GetPool(ctx, update.Cusip)
+	if err != nil {
+		return nil, err
+	}
+	if pool.Status == "Retired" {
+		return nil, fmt.Errorf("pool %s is retired", update.Cusip)
+	}
+	if update.Factor < 0 || update.Factor > pool.Factor {
+		return nil, fmt.Errorf("factor must be between 0 and the current factor %v: %v", pool.Factor, update.Factor)
+	}
+	parsedDate, err := parseDate(update.FactorDate)
+	if err != nil {
+		return nil, err
+	}
+	if update.FactorDate <= pool.FactorDate {
+		return nil, fmt.Errorf("factor date must be after the current factor date %s", pool.FactorDate)
+	}
+
+	// Aggregate the face per holder, keeping the order holders appear in so the writes are deterministic
+	faces := map[string]int64{}
+	holders := []string{}
+	for i := range ledger.Bonds {
+		bond := &ledger.Bonds[i]
+		if bond.Cusip != update.Cusip {
+			continue
+		}
+		bondHolders, bondFaces := bond.holdings()
+		for _, holder := range bondHolders {
+			if _, ok := faces[holder]; !ok {
+				holders = append(holders, holder)
+			}
+			faces[holder] += bondFaces[holder]
+		}
+
+		priorFace := bond.shareFace()
+		bond.Factor = update.Factor
+		bond.FactorDate = update.FactorDate
+		bond.CurrentFace = int64(math.Round(float64(bond.OriginalFace) * update.Factor))
+		bond.rescaleShares(priorFace)
+		entry := FactorHistoryEntry{
+			UID:         bond.UID,
+			Cusip:       bond.Cusip,
+			PriorFactor: pool.Factor,
+			Factor:      update.Factor,
+			FactorDate:  update.FactorDate,
+			CurrentFace: bond.CurrentFace,
+			TxID:        ctx.GetStub().GetTxID(),
+		}
+		err = s.putCompositeRecord(ctx, factorHistoryKeyType, []string{bond.UID, update.FactorDate}, entry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	month := parsedDate.Format("2006-01")
+	distributions := []Distribution{}
+	for _, holder := range holders {
+		face := dollars(faces[holder])
+		distribution := Distribution{
+			Cusip:        update.Cusip,
+			OwnerHash:    holder,
+			Month:        month,
+			OriginalFace: faces[holder],
+			PriorFactor:  pool.Factor,
+			Factor:       update.Factor,
+			Interest:     face * pool.Factor * pool.Coupon / 100 / 12,
+			Principal:    face * (pool.Factor - update.Factor),
+		}
+		err = s.putCompositeRecord(ctx, distributionObjectType, []string{holder, month, update.Cusip}, distribution)
+		if err != nil {
+			return nil, err
+		}
+		distributions = append(distributions, distribution)
+	}
+
+	pool.Factor = update.Factor
+	pool.FactorDate = update.FactorDate
+	err = s.putRecord(ctx, poolObjectType, update.Cusip, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	return distributions, nil
+}
+
+// requireActivePool returns an error if the cusip belongs to a retired pool. Cusips without pool data are not restricted
+func (s *SmartContract) requireActivePool(ctx contractapi.TransactionContextInterface, cusip string) error {
+	var pool Pool
+	exists, err := s.getRecord(ctx, poolObjectType, cusip, &pool)
+	if err != nil {
+		return err
+	}
+	if exists && pool.Status == "Retired" {
+		return fmt.Errorf("pool %s is retired", cusip)
+	}
+
+	return nil
+}
+
+// parseDate parses a YYYY-MM-DD date
+func parseDate(date string) (time.Time, error) {
+	parsed, err := time.Parse(markDateLayout, date)
+	if err != nil {
+		return time.Time{}, NewError(ErrInvalidInput, "date must be in the YYYY-MM-DD format: %v", err)
+	}
+
+	return parsed, nil
+}
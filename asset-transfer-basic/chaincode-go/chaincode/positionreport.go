@@ -0,0 +1,189 @@
+package chaincode
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// PositionReport is an organization's holdings on the ledger by cusip, with their cost and profit and loss.
+// Money amounts are in dollars
+type PositionReport struct {
+	OwnerHash     string          `json:"ownerHash"`
+	AsOf          time.Time       `json:"asOf"`
+	Positions     []CusipPosition `json:"positions"`
+	OriginalFace  int64           `json:"originalFace"` // In cents
+	CurrentFace   int64           `json:"currentFace"`  // In cents
+	CostBasis     float64         `json:"costBasis"`
+	MarketValue   float64         `json:"marketValue"`
+	UnrealizedPnL float64         `json:"unrealizedPnL"`
+	RealizedPnL   float64         `json:"realizedPnL"`
+}
+
+// CusipPosition is what an organization holds of one cusip. Prices are percent of par and apply to the current face.
+// The average price is the face-weighted price of the organization's purchases, carried at average cost through its sales.
+// A cusip the organization sold out of is kept for its realized profit and loss
+type CusipPosition struct {
+	Cusip         string  `json:"cusip"`
+	Bonds         int     `json:"bonds"`        // Bonds of the cusip the organization owns
+	OriginalFace  int64   `json:"originalFace"` // In cents
+	CurrentFace   int64   `json:"currentFace"`  // In cents
+	Factor        float64 `json:"factor"`
+	AveragePrice  Price   `json:"averagePrice"` // Empty when none of the face held was bought on the ledger
+	CostBasis     float64 `json:"costBasis"`    // Current face at the average price
+	MarketPrice   Price   `json:"marketPrice"`  // Latest consensus median. Empty when the cusip has none
+	MarketValue   float64 `json:"marketValue"`
+	UnrealizedPnL float64 `json:"unrealizedPnL"` // Market value less cost basis, when both are known
+	RealizedPnL   float64 `json:"realizedPnL"`   // Sales against the average price at the time of each sale
+}
+
+// costTracker follows the average cost of the face bought of a cusip through purchases and sales
+type costTracker struct {
+	face     int64   // Face bought and not yet sold, in cents
+	cost     float64 // Average price times face of that face
+	realized float64 // In dollars
+}
+
+// ⭐ Functions ⭐
+
+// GetPositionReport returns the caller's positions by cusip: the bonds it owns on the ledger with their original and current face,
+// the average price it bought them at, their value at the latest consensus price, and the profit and loss of its trades.
+// Prices are derived from the caller's transactions in timestamp order at average cost. Transfers carry no price and are left out
+func (s *SmartContract) GetPositionReport(ctx contractapi.TransactionContextInterface) (*PositionReport, error) {
+	owner, err := s.callerOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ownerHash := owner.hash
+	asOf, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bonds, err := stores.Bonds.GetBonds()
+	if err != nil {
+		return nil, err
+	}
+	transactions, _, err := stores.Trades.QueryTransactions(transactionPartyIndexType, ownerHash, time.Time{}, time.Time{}, 0, "")
+	if err != nil {
+		return nil, err
+	}
+
+	positions := map[string]*CusipPosition{}
+	position := func(cusip string) *CusipPosition {
+		if positions[cusip] == nil {
+			positions[cusip] = &CusipPosition{Cusip: cusip}
+		}
+		return positions[cusip]
+	}
+
+	for _, bond := range bonds {
+		if !owner.owns(&bond) {
+			continue
+		}
+		cusipPosition := position(bond.Cusip)
+		cusipPosition.Bonds++
+		cusipPosition.OriginalFace += bond.OriginalFace
+		if cusipPosition.Factor == 0 {
+			cusipPosition.Factor = bond.Factor
+		}
+	}
+
+	costs := map[string]*costTracker{}
+	for _, transaction := range transactions {
+		if transaction.Type == "Transfer" || transaction.BuyerID == transaction.SellerID {
+			continue
+		}
+		tracker := costs[transaction.Cusip]
+		if tracker == nil {
+			tracker = &costTracker{}
+			costs[transaction.Cusip] = tracker
+		}
+
+		price := transaction.BoughtPrice.value()
+		if transaction.BuyerID == ownerHash {
+			tracker.face += transaction.OriginalFace
+			tracker.cost += price * float64(transaction.OriginalFace)
+			continue
+		}
+		if tracker.face == 0 {
+			// Sold face that was never bought on the ledger has no cost to realize against
+			continue
+		}
+		sold := transaction.OriginalFace
+		if sold > tracker.face {
+			sold = tracker.face
+		}
+		average := tracker.cost / float64(tracker.face)
+		tracker.realized += marketValue(sold, price-average)
+		tracker.cost -= average * float64(sold)
+		tracker.face -= sold
+		position(transaction.Cusip)
+	}
+
+	precision, err := s.GetPricePrecision(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PositionReport{OwnerHash: ownerHash, AsOf: asOf, Positions: []CusipPosition{}}
+	for cusip, cusipPosition := range positions {
+		// Pool data is the reference for the factor. Bonds without it fall back on their own
+		var pool Pool
+		exists, err := s.getRecord(ctx, poolObjectType, cusip, &pool)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			cusipPosition.Factor = pool.Factor
+		}
+		if cusipPosition.Factor == 0 {
+			cusipPosition.Factor = 1
+		}
+		cusipPosition.CurrentFace = int64(math.Round(float64(cusipPosition.OriginalFace) * cusipPosition.Factor))
+
+		if tracker := costs[cusip]; tracker != nil {
+			cusipPosition.RealizedPnL = tracker.realized
+			if tracker.face > 0 && cusipPosition.OriginalFace > 0 {
+				average := tracker.cost / float64(tracker.face)
+				cusipPosition.AveragePrice = formatPrice(average, precision.MaxDecimals)
+				cusipPosition.CostBasis = marketValue(cusipPosition.CurrentFace, average)
+			}
+		}
+
+		consensus, err := s.latestConsensusPrice(ctx, cusip)
+		if err != nil {
+			return nil, err
+		}
+		if consensus != nil && cusipPosition.OriginalFace > 0 {
+			cusipPosition.MarketPrice = consensus.Median
+			cusipPosition.MarketValue = marketValue(cusipPosition.CurrentFace, consensus.Median.value())
+			if cusipPosition.AveragePrice != "" {
+				cusipPosition.UnrealizedPnL = cusipPosition.MarketValue - cusipPosition.CostBasis
+			}
+		}
+
+		report.OriginalFace += cusipPosition.OriginalFace
+		report.CurrentFace += cusipPosition.CurrentFace
+		report.CostBasis += cusipPosition.CostBasis
+		report.MarketValue += cusipPosition.MarketValue
+		report.UnrealizedPnL += cusipPosition.UnrealizedPnL
+		report.RealizedPnL += cusipPosition.RealizedPnL
+		report.Positions = append(report.Positions, *cusipPosition)
+	}
+
+	// Ordered by cusip, so that the report does not depend on map iteration order
+	sort.Slice(report.Positions, func(i, j int) bool {
+		return report.Positions[i].Cusip < report.Positions[j].Cusip
+	})
+
+	return report, nil
+}
@@ -0,0 +1,62 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPositionReport(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUp(t, contract)
+			createBond(t, w, contract, "uid1", org1, testCusip, tradeFace)
+			createBond(t, w, contract, "uid2", org2, testCusip, tradeFace)
+
+			trades := []struct {
+				buyer, seller, cusip string
+				face                 int64
+				price                string
+			}{
+				{org1, org2, testCusip, tradeFace, "99"},
+				{org1, org2, testCusip, tradeFace, "101"},
+				{org2, org1, testCusip, tradeFace, "102"},
+				{org1, org2, otherCusip, tradeFace / 2, "98"},
+				{org2, org1, otherCusip, tradeFace / 2, "97"},
+			}
+			for i, trade := range trades {
+				timestamp := time.Date(2023, 1, 1+i, 15, 0, 0, 0, time.UTC)
+				_, err := contract.CreateTransaction(w.begin(org1), trade.buyer, trade.seller, trade.cusip, trade.face, trade.price, timestamp)
+				require.NoError(t, err)
+				w.commit()
+			}
+
+			report, err := contract.GetPositionReport(w.begin(org1))
+			require.NoError(t, err)
+			require.Equal(t, org1, report.OwnerHash)
+			require.Len(t, report.Positions, 2)
+
+			held := report.Positions[0]
+			require.Equal(t, testCusip, held.Cusip)
+			require.Equal(t, 1, held.Bonds)
+			require.Equal(t, int64(tradeFace), held.OriginalFace)
+			require.Equal(t, int64(tradeFace), held.CurrentFace)
+			require.Equal(t, chaincode.Price("100"), held.AveragePrice)
+			require.InDelta(t, 1000000, held.CostBasis, 0.001)
+			require.InDelta(t, 20000, held.RealizedPnL, 0.001)
+			require.Empty(t, held.MarketPrice)
+
+			soldOut := report.Positions[1]
+			require.Equal(t, otherCusip, soldOut.Cusip)
+			require.Zero(t, soldOut.Bonds)
+			require.Empty(t, soldOut.AveragePrice)
+			require.InDelta(t, -5000, soldOut.RealizedPnL, 0.001)
+
+			require.InDelta(t, 15000, report.RealizedPnL, 0.001)
+			require.Equal(t, int64(tradeFace), report.CurrentFace)
+		})
+	}
+}
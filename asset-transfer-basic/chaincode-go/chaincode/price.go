@@ -0,0 +1,150 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// Price is a price, or a spread in basis points, as a decimal string such as "101.265625".
+// Prices are passed, stored and returned as strings, and only turned into numbers to compute amounts
+type Price string
+
+// PricePrecisionConfig bounds the number of decimals a price passed to the chaincode may have
+type PricePrecisionConfig struct {
+	MaxDecimals int       `json:"maxDecimals"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+const (
+	pricePrecisionConfigID = "priceprecision"
+
+	// Decimals allowed on a channel where the precision was never set. Enough for prices in 256ths
+	defaultPriceDecimals = 8
+	// Beyond this, decimal strings no longer survive the conversion to float64
+	maxPriceDecimals = 12
+)
+
+// Plain decimals only: an optional minus sign, digits, and optionally a point followed by digits
+var pricePattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// ⭐ Functions ⭐
+
+// SetPricePrecision sets the number of decimals prices passed to the chaincode may have.
+// Only the admin organization can change it
+func (s *SmartContract) SetPricePrecision(ctx contractapi.TransactionContextInterface, maxDecimals int) (*WriteResponse, error) {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if maxDecimals < 0 || maxDecimals > maxPriceDecimals {
+		return nil, fmt.Errorf("the price precision must be between 0 and %d decimals: %d", maxPriceDecimals, maxDecimals)
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config := PricePrecisionConfig{
+		MaxDecimals: maxDecimals,
+		UpdatedAt:   timestamp,
+	}
+	err = s.putRecord(ctx, configObjectType, pricePrecisionConfigID, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// GetPricePrecision returns the price precision of the channel, or the default one if it was never set
+func (s *SmartContract) GetPricePrecision(ctx contractapi.TransactionContextInterface) (*PricePrecisionConfig, error) {
+	config := PricePrecisionConfig{MaxDecimals: defaultPriceDecimals}
+	_, err := s.getRecord(ctx, configObjectType, pricePrecisionConfigID, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// UnmarshalJSON reads a price from a JSON string. Records written before prices were strings hold JSON numbers, which are read too
+func (p *Price) UnmarshalJSON(data []byte) error {
+	var text string
+	if len(data) > 0 && data[0] == '"' {
+		err := json.Unmarshal(data, &text)
+		if err != nil {
+			return err
+		}
+		*p = Price(text)
+		return nil
+	}
+
+	var number json.Number
+	err := json.Unmarshal(data, &number)
+	if err != nil {
+		return fmt.Errorf("a price must be a decimal string: %s", data)
+	}
+	value, err := number.Float64()
+	if err != nil {
+		return NewError(ErrInvalidInput, "invalid price %s: %v", number, err)
+	}
+	*p = Price(strconv.FormatFloat(value, 'f', -1, 64))
+	return nil
+}
+
+// ⭐ Helper functions ⭐
+
+// parsePrice validates a price passed as an argument and returns it as a Price
+func (s *SmartContract) parsePrice(ctx contractapi.TransactionContextInterface, input string) (Price, error) {
+	price := Price(strings.TrimSpace(input))
+	err := s.validatePrice(ctx, price)
+	if err != nil {
+		return "", err
+	}
+
+	return price, nil
+}
+
+// validatePrice checks that a price is a plain decimal with no more decimals than the channel's price precision
+func (s *SmartContract) validatePrice(ctx contractapi.TransactionContextInterface, price Price) error {
+	if !pricePattern.MatchString(string(price)) {
+		return NewError(ErrInvalidInput, "invalid price %q: prices are decimal strings such as \"101.25\"", price)
+	}
+
+	config, err := s.GetPricePrecision(ctx)
+	if err != nil {
+		return err
+	}
+	if point := strings.IndexByte(string(price), '.'); point != -1 && len(price)-point-1 > config.MaxDecimals {
+		return NewError(ErrInvalidInput, "invalid price %s: at most %d decimals are allowed", price, config.MaxDecimals)
+	}
+
+	return nil
+}
+
+// value returns the price as a number, for computations. Prices are validated on the way in, so an unparsable one,
+// including the empty price of a counter that was never made, counts as zero
+func (p Price) value() float64 {
+	value, err := strconv.ParseFloat(string(p), 64)
+	if err != nil {
+		return 0
+	}
+
+	return value
+}
+
+// formatPrice turns a computed price into a Price rounded to the given number of decimals, without trailing zeros
+func formatPrice(value float64, decimals int) Price {
+	scale := math.Pow10(decimals)
+	return Price(strconv.FormatFloat(math.Round(value*scale)/scale, 'f', -1, 64))
+}
@@ -0,0 +1,191 @@
+package chaincode
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// OracleMark is a price a pricing oracle submitted for a cusip, signed with the key of the oracle's certificate
+type OracleMark struct {
+	Cusip       string    `json:"cusip"`
+	Price       Price     `json:"price"`
+	AsOf        time.Time `json:"asOf"` // When the price was observed
+	OracleMSP   string    `json:"oracleMSP"`
+	Signature   string    `json:"signature"` // Base64 signature of the submission, see priceSubmissionMessage
+	SubmittedAt time.Time `json:"submittedAt"`
+}
+
+const (
+	oracleMarkObjectType = "oraclemark"
+	// Layout of the as-of time in the keys of oracle marks, which sorts them in time order
+	oracleMarkTimeLayout = "20060102T150405.000000000Z"
+)
+
+// ⭐ Functions ⭐
+
+// SubmitPrice stores a mark of a cusip from a pricing oracle, one of the oracle organizations of the contract settings.
+// The signature is the base64 signature of the submission by the key of the caller's certificate: ECDSA over the SHA-256
+// of priceSubmissionMessage, in ASN.1, or Ed25519 over the message itself. An oracle submits one price per cusip and as-of time,
+// which cannot be in the future
+func (s *SmartContract) SubmitPrice(ctx contractapi.TransactionContextInterface, cusip, price string, asOf time.Time, signature string) (*WriteResponse, error) {
+	toUTC(&asOf)
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	config, err := s.GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !containsString(config.OracleMSPs, mspID) {
+		return nil, fmt.Errorf("%s is not a pricing oracle", mspID)
+	}
+
+	mark, err := s.parsePrice(ctx, price)
+	if err != nil {
+		return nil, err
+	}
+	if mark.value() <= 0 {
+		return nil, fmt.Errorf("mark price must be positive: %s", mark)
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if asOf.After(now) {
+		return nil, fmt.Errorf("a price cannot be as of %v, after the transaction timestamp %v", asOf.Format(time.RFC3339), now.Format(time.RFC3339))
+	}
+
+	err = verifyPriceSignature(ctx, priceSubmissionMessage(cusip, price, asOf), signature)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := []string{cusip, asOf.Format(oracleMarkTimeLayout), mspID}
+	var existing OracleMark
+	exists, err := s.getCompositeRecord(ctx, oracleMarkObjectType, attributes, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("%s already submitted a price for Cusip %s as of %v", mspID, cusip, asOf.Format(time.RFC3339))
+	}
+
+	oracleMark := OracleMark{
+		Cusip:       cusip,
+		Price:       mark,
+		AsOf:        asOf,
+		OracleMSP:   mspID,
+		Signature:   signature,
+		SubmittedAt: now,
+	}
+	err = s.putCompositeRecord(ctx, oracleMarkObjectType, attributes, oracleMark)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, &oracleMark)
+}
+
+// GetLatestMark returns the oracle mark of a cusip with the latest as-of time, for marking positions to market
+func (s *SmartContract) GetLatestMark(ctx contractapi.TransactionContextInterface, cusip string) (*OracleMark, error) {
+	marks, err := s.getOracleMarks(ctx, cusip, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	if len(marks) == 0 {
+		return nil, fmt.Errorf("no oracle mark for Cusip %s", cusip)
+	}
+
+	return &marks[len(marks)-1], nil
+}
+
+// GetMarkHistory returns the oracle marks of a cusip as of fromDate to toDate (YYYY-MM-DD), both included, in time order.
+// Either date can be empty to leave that end of the range open
+func (s *SmartContract) GetMarkHistory(ctx contractapi.TransactionContextInterface, cusip, fromDate, toDate string) ([]OracleMark, error) {
+	from, to, err := parseDateRange(fromDate, toDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getOracleMarks(ctx, cusip, from, to)
+}
+
+// ⭐ Helper functions ⭐
+
+// getOracleMarks returns the oracle marks of a cusip as of from, included, to to, excluded, in time order. A zero time leaves
+// that end of the range open
+func (s *SmartContract) getOracleMarks(ctx contractapi.TransactionContextInterface, cusip string, from, to time.Time) ([]OracleMark, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(oracleMarkObjectType, []string{cusip})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get oracle marks: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	marks := []OracleMark{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over oracle marks: %v", err)
+		}
+
+		var mark OracleMark
+		err = json.Unmarshal(queryResponse.Value, &mark)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling oracle mark JSON: %v", err)
+		}
+		if (!from.IsZero() && mark.AsOf.Before(from)) || (!to.IsZero() && !mark.AsOf.Before(to)) {
+			continue
+		}
+		marks = append(marks, mark)
+	}
+
+	return marks, nil
+}
+
+// priceSubmissionMessage returns the message an oracle signs to submit a price: the cusip, the price as passed and the
+// as-of time in RFC 3339 UTC, separated by "|", e.g. "3140X9NN1|101.25|2023-01-09T12:00:00Z"
+func priceSubmissionMessage(cusip, price string, asOf time.Time) []byte {
+	return []byte(cusip + "|" + price + "|" + asOf.UTC().Format(time.RFC3339Nano))
+}
+
+// verifyPriceSignature checks a base64 signature of message by the key of the caller's certificate
+func verifyPriceSignature(ctx contractapi.TransactionContextInterface, message []byte, signature string) error {
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %v", err)
+	}
+	certificate, err := ctx.GetClientIdentity().GetX509Certificate()
+	if err != nil {
+		return fmt.Errorf("failed to get the caller's certificate: %v", err)
+	}
+	if certificate == nil {
+		return fmt.Errorf("the caller has no certificate to verify the signature with")
+	}
+
+	valid := false
+	switch publicKey := certificate.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		valid = ecdsa.VerifyASN1(publicKey, digest[:], signatureBytes)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(publicKey, message, signatureBytes)
+	default:
+		return fmt.Errorf("unsupported certificate key type %T", certificate.PublicKey)
+	}
+	if !valid {
+		return fmt.Errorf("the signature does not match the submission")
+	}
+
+	return nil
+}
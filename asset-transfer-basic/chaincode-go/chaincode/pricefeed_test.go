@@ -0,0 +1,119 @@
+package chaincode_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+// oracle is a pricing oracle of Org2, signing with the key of its certificate
+type oracle struct {
+	key *ecdsa.PrivateKey
+}
+
+// setUpPriceFeed returns a world where Org2 is the pricing oracle, and its signing key
+func setUpPriceFeed(t *testing.T, contract *chaincode.SmartContract) (*world, *oracle) {
+	w := setUp(t, contract)
+	_, err := contract.UpdateConfig(w.begin(org1), `{"allowedCouponTypes":["FIXED"],"settlementCurrency":"USD","adminMSPs":["Org1MSP"],"oracleMSPs":["Org2MSP"]}`)
+	require.NoError(t, err)
+	w.commit()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return w, &oracle{key: key}
+}
+
+// begin starts a transaction of the oracle's organization presenting the oracle's certificate
+func (o *oracle) begin(w *world, mspID string) *mocks.TransactionContext {
+	ctx := w.begin(mspID)
+	ctx.GetClientIdentity().(*mocks.ClientIdentity).GetX509CertificateReturns(&x509.Certificate{PublicKey: &o.key.PublicKey}, nil)
+	return ctx
+}
+
+// sign returns the oracle's signature of a price submission
+func (o *oracle) sign(t *testing.T, cusip, price string, asOf time.Time) string {
+	digest := sha256.Sum256([]byte(cusip + "|" + price + "|" + asOf.UTC().Format(time.RFC3339Nano)))
+	signature, err := ecdsa.SignASN1(rand.Reader, o.key, digest[:])
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+func (o *oracle) submit(t *testing.T, w *world, contract *chaincode.SmartContract, price string, asOf time.Time) {
+	_, err := contract.SubmitPrice(o.begin(w, org2), testCusip, price, asOf, o.sign(t, testCusip, price, asOf))
+	require.NoError(t, err)
+	w.commit()
+}
+
+func TestSubmitPrice(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w, oracle := setUpPriceFeed(t, contract)
+
+	_, err := contract.GetLatestMark(w.begin(org1), testCusip)
+	require.EqualError(t, err, "no oracle mark for Cusip "+testCusip)
+
+	oracle.submit(t, w, contract, "100.5", testTime.Add(-48*time.Hour))
+	oracle.submit(t, w, contract, "101.25", testTime)
+	oracle.submit(t, w, contract, "100.75", testTime.Add(-24*time.Hour))
+
+	latest, err := contract.GetLatestMark(w.begin(org1), testCusip)
+	require.NoError(t, err)
+	require.Equal(t, chaincode.Price("101.25"), latest.Price)
+	require.Equal(t, org2, latest.OracleMSP)
+	require.Equal(t, testTime, latest.AsOf)
+
+	history, err := contract.GetMarkHistory(w.begin(org1), testCusip, "", "")
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	require.Equal(t, chaincode.Price("100.5"), history[0].Price)
+	require.Equal(t, chaincode.Price("100.75"), history[1].Price)
+
+	history, err = contract.GetMarkHistory(w.begin(org1), testCusip, "2023-01-08", "2023-01-08")
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, chaincode.Price("100.75"), history[0].Price)
+}
+
+func TestSubmitPriceErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		mspID     string
+		price     string
+		signPrice string
+		asOf      time.Time
+		err       string
+	}{
+		{name: "not an oracle", mspID: org1, price: "101", err: "Org1MSP is not a pricing oracle"},
+		{name: "signature of another price", mspID: org2, price: "101", signPrice: "99", err: "the signature does not match the submission"},
+		{name: "future price", mspID: org2, price: "101", asOf: testTime.Add(time.Hour), err: "a price cannot be as of 2023-01-09T13:00:00Z, after the transaction timestamp 2023-01-09T12:00:00Z"},
+		{name: "not a price", mspID: org2, price: "par", err: contractError(chaincode.ErrInvalidInput, `invalid price "par": prices are decimal strings such as "101.25"`)},
+		{name: "already submitted", mspID: org2, price: "100.5", err: "Org2MSP already submitted a price for Cusip " + testCusip + " as of 2023-01-09T11:00:00Z"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract := &chaincode.SmartContract{}
+			w, oracle := setUpPriceFeed(t, contract)
+			oracle.submit(t, w, contract, "100.5", testTime.Add(-time.Hour))
+
+			asOf := test.asOf
+			if asOf.IsZero() {
+				asOf = testTime.Add(-time.Hour)
+			}
+			signPrice := test.signPrice
+			if signPrice == "" {
+				signPrice = test.price
+			}
+			_, err := contract.SubmitPrice(oracle.begin(w, test.mspID), testCusip, test.price, asOf, oracle.sign(t, testCusip, signPrice, asOf))
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
@@ -0,0 +1,523 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// Inventory is the private inventory of an organization, in the organization's implicit collection. It is kept as one blob
+// or, once migrated, with one key per item
+type Inventory struct {
+	Assets []*PrivateAgencyMBSPassthrough `json:"assets"`
+}
+
+// AssetMetadata records who added an item to an inventory and when
+type AssetMetadata struct {
+	Owner       string    `json:"owner"`       //The Organization that owns the asset
+	OwnerId     string    `json:"ownerId"`     //The HyperledgerFabric identifier for the Organization that owns the asset
+	DateCreated time.Time `json:"dateCreated"` //The date the asset was created
+}
+
+// PrivateAgencyMBSPassthrough is an item of a private inventory
+type PrivateAgencyMBSPassthrough struct {
+	Metadata AssetMetadata         `json:"metadata"` // Represents private information of the bond.
+	Content  *AgencyMBSPassthrough `json:"content"`  // It's the bond itself. Its UID links a listed item to its bond on the ledger
+	Status   string                `json:"status"`   // Listing status of the item: Held, Listed, PendingSale or Sold
+}
+
+// Listing statuses of an inventory item
+const (
+	StatusHeld        = "Held"        // Only in the private inventory
+	StatusListed      = "Listed"      // Published on the ledger
+	StatusPendingSale = "PendingSale" // Its bond on the ledger is reserved for a trade that has not settled yet
+	StatusSold        = "Sold"        // Its bond on the ledger now belongs to someone else
+)
+
+// ⭐ Functions ⭐
+
+// GetInventory returns the inventory of the caller's organization, or nil if it has none.
+// The status of listed items is brought up to date with their bonds on the ledger
+func (s *SmartContract) GetInventory(ctx contractapi.TransactionContextInterface) (*Inventory, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inventory, err := stores.Inventory.GetInventory(mspID)
+	if err != nil || inventory == nil {
+		return nil, err
+	}
+
+	err = s.syncListingStatuses(ctx, inventory)
+	if err != nil {
+		return nil, err
+	}
+
+	return inventory, nil
+}
+
+// AddToInventory adds a bond to the caller's inventory. A bond whose CUSIP is already in the inventory is rejected,
+// unless upsert is set, in which case the item's bond data is replaced and its metadata and status are kept.
+// A bond with invalid fields is rejected with a ValidationError
+func (s *SmartContract) AddToInventory(ctx contractapi.TransactionContextInterface, bondJSON string, upsert bool) (*WriteResponse, error) {
+	var bond AgencyMBSPassthrough
+	err := json.Unmarshal([]byte(bondJSON), &bond)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bond JSON: %v", err)
+	}
+	config, err := s.GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = validateBond(&bond, config.AllowedCouponTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	// The ledger fields are set when the bond is listed
+	bond.UID = ""
+	bond.OwnerHash = ""
+	bond.ReservedFor = ""
+
+	if upsert {
+		inventory, err := s.GetInventory(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get inventory: %v", err)
+		}
+		existing := findInventoryItem(inventory, bond.Cusip)
+		if existing != nil {
+			bond.UID = existing.Content.UID
+			existing.Content = &bond
+			err = s.putInventory(ctx, inventory)
+			if err != nil {
+				return nil, err
+			}
+			return newWriteResponse(ctx, nil)
+		}
+	}
+
+	err = s.addToInventory(ctx, &bond, StatusHeld)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// AddToInventoryAuto adds a fixed bond to the caller's inventory
+func (s *SmartContract) AddToInventoryAuto(ctx contractapi.TransactionContextInterface) (*WriteResponse, error) {
+	// Bond details to be added to the inventory
+	bond := AgencyMBSPassthrough{
+		Bond:                            "FR RA8888",
+		Cusip:                           "Cusip123",
+		OriginalFace:                    100000000,
+		Class1:                          "passthrough",
+		Class2:                          "MBS 30yr",
+		Class3:                          "Freddie Mac",
+		Class4:                          "LB200",
+		Coupon:                          6,
+		CouponType:                      "FIXED",
+		IssueYear:                       2023,
+		IssueDate:                       "2023-01-09T12:00:00Z",
+		OriginationAmount:               23148038600,
+		Factor:                          0.96735693,
+		FactorDate:                      "2024-01-02T12:00:00Z",
+		WeightedAverageCoupon:           6.895,
+		WeightedAverageLoanAge:          7,
+		WeightedAverageMaturity:         350,
+		WeightedAverageOriginalMaturity: 359,
+		LoanSize:                        189432.61,
+		LoanToValue:                     74,
+		Fico:                            747,
+		Cpr1m:                           7.07805522352359,
+		Cpr3m:                           6.72923614321762,
+		Cpr6m:                           0,
+		Cpr12m:                          0,
+		Servicer:                        "MULTIPLE",
+		Geography:                       "8.1% MI",
+		PurchasePercent:                 86.11,
+		RefinancePercent:                2.57,
+		ThirdpartyOriginationPercent:    22.74,
+		LoanCount:                       1202,
+	}
+
+	err := s.addToInventory(ctx, &bond, StatusHeld)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// GetInventoryByStatus returns the items of the caller's inventory with the given listing status
+func (s *SmartContract) GetInventoryByStatus(ctx contractapi.TransactionContextInterface, status string) ([]*PrivateAgencyMBSPassthrough, error) {
+	if !isListingStatus(status) {
+		return nil, fmt.Errorf("unknown listing status %s", status)
+	}
+
+	return s.searchInventory(ctx, func(asset *PrivateAgencyMBSPassthrough) bool {
+		return asset.listingStatus() == status
+	})
+}
+
+// FromInventoryToLedger lists a held item of the caller's inventory as a bond on the ledger, owned by the caller.
+// The transaction ID becomes the UID of the bond, and the item keeps it to follow the bond
+func (s *SmartContract) FromInventoryToLedger(ctx contractapi.TransactionContextInterface, cusip string) (*WriteResponse, error) {
+	inventory, err := s.GetInventory(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory: %v", err)
+	}
+
+	item := findInventoryItem(inventory, cusip)
+	if item == nil {
+		return nil, NewError(ErrNotFound, "bond with CUSIP %s not found in the inventory", cusip)
+	}
+	if item.listingStatus() != StatusHeld {
+		return nil, fmt.Errorf("the bond with Cusip %s cannot be listed while %s", cusip, item.listingStatus())
+	}
+	if item.Content.OriginalFace <= 0 {
+		return nil, fmt.Errorf("the bond with Cusip %s has no original face to list", cusip)
+	}
+
+	err = s.requireActivePool(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := s.callerOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger, err := s.getCusipLedger(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	uid := ctx.GetStub().GetTxID()
+	publicBond := *item.Content
+	publicBond.UID = uid
+	publicBond.OwnerHash = owner.commit(uid)
+	publicBond.ReservedFor = ""
+	publicBond.Status = BondListed
+	ledger.Bonds = append(ledger.Bonds, publicBond)
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store bond: %v", err)
+	}
+
+	item.Content.UID = uid
+	item.Status = StatusListed
+	err = s.putInventory(ctx, inventory)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, uid)
+}
+
+// DelistBond takes a bond the caller listed off the ledger and holds it in the caller's inventory again.
+// Bonds that are reserved for a trade cannot be delisted
+func (s *SmartContract) DelistBond(ctx contractapi.TransactionContextInterface, cusip string) (*WriteResponse, error) {
+	owner, err := s.callerOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	inventory, err := s.GetInventory(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory: %v", err)
+	}
+
+	ledger, err := s.getCusipLedger(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bonds the caller owns without having listed them from its inventory, such as bonds it bought,
+	// are delisted into a new item, or into the item of a position it sold before
+	index := -1
+	item := findInventoryItem(inventory, cusip)
+	switch {
+	case item == nil || item.listingStatus() == StatusSold:
+		index = findOwnedBond(ledger, owner, cusip)
+	case item.listingStatus() == StatusListed:
+		for i, bond := range ledger.Bonds {
+			if bond.UID == item.Content.UID {
+				index = i
+				break
+			}
+		}
+	default:
+		return nil, fmt.Errorf("the bond with Cusip %s cannot be delisted while %s", cusip, item.listingStatus())
+	}
+	if index == -1 {
+		return nil, fmt.Errorf("the caller has no bond with Cusip %s on the ledger", cusip)
+	}
+
+	bond := ledger.Bonds[index]
+	if bond.ReservedFor != "" {
+		return nil, NewConflictError(bond.UID, bond.Cusip, bond.ReservedFor)
+	}
+
+	ledger.Bonds = append(ledger.Bonds[:index], ledger.Bonds[index+1:]...)
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	bond.UID = ""
+	bond.OwnerHash = ""
+	if item == nil {
+		err = s.addToInventory(ctx, &bond, StatusHeld)
+		if err != nil {
+			return nil, err
+		}
+		return newWriteResponse(ctx, nil)
+	}
+
+	item.Content = &bond
+	item.Status = StatusHeld
+	err = s.putInventory(ctx, inventory)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// RemoveFromInventory removes a held or sold item from the caller's inventory by its CUSIP
+func (s *SmartContract) RemoveFromInventory(ctx contractapi.TransactionContextInterface, cusip string) (*WriteResponse, error) {
+	inventory, err := s.GetInventory(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory: %v", err)
+	}
+	if inventory == nil {
+		return nil, NewError(ErrNotFound, "inventory not found")
+	}
+
+	// Find the bond in the inventory by its CUSIP and remove it
+	found := false
+	for i, item := range inventory.Assets {
+		if item.Content != nil && item.Content.Cusip == cusip {
+			if item.listingStatus() == StatusListed || item.listingStatus() == StatusPendingSale {
+				return nil, fmt.Errorf("the bond with Cusip %s cannot be removed while %s", cusip, item.listingStatus())
+			}
+			inventory.Assets = append(inventory.Assets[:i], inventory.Assets[i+1:]...)
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, NewError(ErrNotFound, "bond with CUSIP %s not found in the inventory", cusip)
+	}
+
+	err = s.putInventory(ctx, inventory)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// EditBondInInventory replaces the bond data of an item of the caller's inventory. The item keeps its link to the ledger.
+// A bond with invalid fields is rejected with a ValidationError
+func (s *SmartContract) EditBondInInventory(ctx contractapi.TransactionContextInterface, bondJSON string) (*WriteResponse, error) {
+	var bond AgencyMBSPassthrough
+	err := json.Unmarshal([]byte(bondJSON), &bond)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bond JSON: %v", err)
+	}
+	config, err := s.GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = validateBond(&bond, config.AllowedCouponTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	inventory, err := s.GetInventory(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory: %v", err)
+	}
+
+	item := findInventoryItem(inventory, bond.Cusip)
+	if item == nil {
+		return nil, NewError(ErrNotFound, "bond with CUSIP %s not found in the inventory", bond.Cusip)
+	}
+	bond.UID = item.Content.UID
+	bond.OwnerHash = ""
+	bond.ReservedFor = ""
+	item.Content = &bond
+
+	err = s.putInventory(ctx, inventory)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// ⭐ Helper functions ⭐
+
+// addToInventory adds a bond to the caller's inventory with the given listing status. A bond whose CUSIP is already there is rejected.
+// Only the item of the CUSIP is read, so that in the per-key layout adding bonds of different CUSIPs does not conflict
+func (s *SmartContract) addToInventory(ctx contractapi.TransactionContextInterface, bond *AgencyMBSPassthrough, status string) error {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return err
+	}
+
+	existing, err := stores.Inventory.GetInventoryItem(mspID, bond.Cusip)
+	if err != nil {
+		return fmt.Errorf("failed to get inventory: %v", err)
+	}
+	if existing != nil {
+		err = s.syncListingStatuses(ctx, &Inventory{Assets: []*PrivateAgencyMBSPassthrough{existing}})
+		if err != nil {
+			return err
+		}
+		return NewDuplicateInventoryError(bond.Cusip, existing.Metadata, existing.listingStatus())
+	}
+
+	metadata, err := generateMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to generate metadata: %v", err)
+	}
+
+	return stores.Inventory.AddInventoryItem(mspID, &PrivateAgencyMBSPassthrough{
+		Metadata: metadata,
+		Content:  bond,
+		Status:   status,
+	})
+}
+
+// putInventory stores the caller's inventory in its implicit collection
+func (s *SmartContract) putInventory(ctx contractapi.TransactionContextInterface, inventory *Inventory) error {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return err
+	}
+
+	return stores.Inventory.PutInventory(mspID, inventory)
+}
+
+// syncListingStatuses updates the status of the listed items of an inventory from their bonds on the ledger.
+// Trades settle on the ledger without touching the seller's inventory, so this is where a listed item becomes sold
+func (s *SmartContract) syncListingStatuses(ctx contractapi.TransactionContextInterface, inventory *Inventory) error {
+	listed := false
+	for _, item := range inventory.Assets {
+		if item.Content != nil && item.Content.UID != "" && item.listingStatus() != StatusSold {
+			listed = true
+			break
+		}
+	}
+	if !listed {
+		return nil
+	}
+
+	owner, err := s.callerOwner(ctx)
+	if err != nil {
+		return err
+	}
+	ledger, err := s.GetLedger(ctx)
+	if err != nil {
+		return err
+	}
+
+	bonds := map[string]AgencyMBSPassthrough{}
+	for _, bond := range ledger.Bonds {
+		bonds[bond.UID] = bond
+	}
+	for _, item := range inventory.Assets {
+		if item.Content == nil || item.Content.UID == "" || item.listingStatus() == StatusSold {
+			continue
+		}
+		bond, ok := bonds[item.Content.UID]
+		switch {
+		case !ok || !owner.owns(&bond):
+			item.Status = StatusSold
+		case bond.ReservedFor != "":
+			item.Status = StatusPendingSale
+		default:
+			item.Status = StatusListed
+		}
+	}
+
+	return nil
+}
+
+// generateMetadata returns the metadata of an item the caller adds to its inventory
+func generateMetadata(ctx contractapi.TransactionContextInterface) (AssetMetadata, error) {
+	orgID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return AssetMetadata{}, err
+	}
+
+	orgName, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return AssetMetadata{}, err
+	}
+
+	// The transaction timestamp keeps the metadata the same on every endorser
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return AssetMetadata{}, err
+	}
+
+	return AssetMetadata{
+		Owner:       orgName,
+		OwnerId:     orgID,
+		DateCreated: now,
+	}, nil
+}
+
+// findInventoryItem returns the inventory item with the given CUSIP, or nil if there is none
+func findInventoryItem(inventory *Inventory, cusip string) *PrivateAgencyMBSPassthrough {
+	if inventory == nil {
+		return nil
+	}
+	for _, item := range inventory.Assets {
+		if item.Content != nil && item.Content.Cusip == cusip {
+			return item
+		}
+	}
+
+	return nil
+}
+
+// listingStatus returns the listing status of an inventory item. Items stored before statuses existed are held
+func (p *PrivateAgencyMBSPassthrough) listingStatus() string {
+	if p.Status == "" {
+		return StatusHeld
+	}
+	return p.Status
+}
+
+// isListingStatus returns true when status is one of the known listing statuses
+func isListingStatus(status string) bool {
+	switch status {
+	case StatusHeld, StatusListed, StatusPendingSale, StatusSold:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package chaincode_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+const inventoryBond = `{"bond":"FR RA8888","cusip":"3132DWAR4","originalFace":100000000,"coupon":6,"couponType":"FIXED","factor":0.96735693,"issueDate":"2023-01-09"}`
+
+func TestAddToInventory(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		bondJSON string
+		upsert   bool
+		code     string
+		field    string
+		err      string
+	}{
+		{name: "new bond", bondJSON: inventoryBond},
+		{name: "duplicate", existing: inventoryBond, bondJSON: inventoryBond, code: "DUPLICATE"},
+		{name: "upsert", existing: inventoryBond, bondJSON: `{"bond":"FR RA8888","cusip":"3132DWAR4","originalFace":100000000,"coupon":6,"couponType":"FIXED","factor":0.9}`, upsert: true},
+		{name: "invalid cusip", bondJSON: `{"cusip":"3132DWAR5","originalFace":100000000,"coupon":6,"couponType":"FIXED","factor":1}`, code: "VALIDATION", field: "cusip"},
+		{name: "invalid factor", bondJSON: `{"cusip":"3132DWAR4","originalFace":100000000,"coupon":6,"couponType":"FIXED","factor":1.5}`, code: "VALIDATION", field: "factor"},
+		{name: "not JSON", bondJSON: `{"cusip":`, err: "failed to unmarshal bond JSON"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract := &chaincode.SmartContract{}
+			w := setUp(t, contract)
+			if test.existing != "" {
+				_, err := contract.AddToInventory(w.begin(org2), test.existing, false)
+				require.NoError(t, err)
+				w.commit()
+			}
+
+			_, err := contract.AddToInventory(w.begin(org2), test.bondJSON, test.upsert)
+			switch {
+			case test.err != "":
+				require.ErrorContains(t, err, test.err)
+				return
+			case test.code == "DUPLICATE":
+				var duplicate *chaincode.DuplicateInventoryError
+				require.True(t, errors.As(err, &duplicate), "expected a DuplicateInventoryError, got %v", err)
+				require.Equal(t, test.code, duplicate.Code)
+				return
+			case test.code != "":
+				var validation *chaincode.ValidationError
+				require.True(t, errors.As(err, &validation), "expected a ValidationError, got %v", err)
+				require.Equal(t, test.code, validation.Code)
+				require.Equal(t, test.field, validation.Violations[0].Field)
+				return
+			}
+			require.NoError(t, err)
+			w.commit()
+
+			inventory, err := contract.GetInventory(w.begin(org2))
+			require.NoError(t, err)
+			require.Len(t, inventory.Assets, 1)
+			require.Equal(t, testCusip, inventory.Assets[0].Content.Cusip)
+			require.Equal(t, chaincode.StatusHeld, inventory.Assets[0].Status)
+
+			// Inventories are private to their organization
+			other, err := contract.GetInventory(w.begin(org1))
+			require.NoError(t, err)
+			require.Nil(t, other)
+		})
+	}
+}
+
+func TestInventoryListing(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUp(t, contract)
+
+			_, err := contract.AddToInventory(w.begin(org2), inventoryBond, false)
+			require.NoError(t, err)
+			w.commit()
+
+			_, err = contract.FromInventoryToLedger(w.begin(org2), testCusip)
+			require.NoError(t, err)
+			w.commit()
+
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Len(t, bonds, 1)
+			require.Equal(t, org2, bonds[0].OwnerHash)
+
+			listed, err := contract.GetInventoryByStatus(w.begin(org2), chaincode.StatusListed)
+			require.NoError(t, err)
+			require.Len(t, listed, 1)
+			require.Equal(t, bonds[0].UID, listed[0].Content.UID)
+
+			// A listed item stays in the inventory until it is delisted
+			_, err = contract.RemoveFromInventory(w.begin(org2), testCusip)
+			require.EqualError(t, err, "the bond with Cusip "+testCusip+" cannot be removed while Listed")
+
+			_, err = contract.DelistBond(w.begin(org2), testCusip)
+			require.NoError(t, err)
+			w.commit()
+
+			bonds, err = contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Empty(t, bonds)
+
+			_, err = contract.RemoveFromInventory(w.begin(org2), testCusip)
+			require.NoError(t, err)
+			w.commit()
+
+			held, err := contract.GetInventoryByStatus(w.begin(org2), chaincode.StatusHeld)
+			require.NoError(t, err)
+			require.Empty(t, held)
+		})
+	}
+}
+
+func TestFromInventoryToLedgerErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		cusip string
+		err   string
+	}{
+		{name: "not in the inventory", cusip: otherCusip, err: contractError(chaincode.ErrNotFound, "bond with CUSIP "+otherCusip+" not found in the inventory")},
+		{name: "already listed", cusip: testCusip, err: "the bond with Cusip " + testCusip + " cannot be listed while Listed"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract := &chaincode.SmartContract{}
+			w := setUp(t, contract)
+			_, err := contract.AddToInventory(w.begin(org2), inventoryBond, false)
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.FromInventoryToLedger(w.begin(org2), testCusip)
+			require.NoError(t, err)
+			w.commit()
+
+			_, err = contract.FromInventoryToLedger(w.begin(org2), test.cusip)
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestMigrateInventory(t *testing.T) {
+	// Built without a layout, the contract follows the layout each organization migrated its inventory to
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+	_, err := contract.AddToInventory(w.begin(org2), inventoryBond, false)
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.FromInventoryToLedger(w.begin(org2), testCusip)
+	require.NoError(t, err)
+	w.commit()
+
+	response, err := contract.MigrateInventory(w.begin(org2))
+	require.NoError(t, err)
+	require.Equal(t, 1, response.Result)
+	w.commit()
+
+	_, err = contract.MigrateInventory(w.begin(org2))
+	require.EqualError(t, err, "the inventory of Org2MSP is already migrated")
+
+	listed, err := contract.GetInventoryByStatus(w.begin(org2), chaincode.StatusListed)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+
+	// Adding reads only the items of the CUSIP
+	_, err = contract.AddToInventory(w.begin(org2), inventoryBond, false)
+	var duplicate *chaincode.DuplicateInventoryError
+	require.True(t, errors.As(err, &duplicate))
+	_, err = contract.AddToInventory(w.begin(org2), `{"bond":"FR SD8888","cusip":"3133KR5L4","originalFace":50000000,"coupon":5,"couponType":"FIXED","factor":1}`, false)
+	require.NoError(t, err)
+	w.commit()
+
+	inventory, err := contract.GetInventory(w.begin(org2))
+	require.NoError(t, err)
+	require.Len(t, inventory.Assets, 2)
+}
@@ -0,0 +1,772 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// Repo is a repurchase agreement: the seller pledges a bond as collateral against cash on the open leg
+// and repays the cash plus repo interest on the close leg, which lifts the lien
+type Repo struct {
+	RepoID       string    `json:"repoID"`
+	UID          string    `json:"uid"` // Bond pledged as collateral
+	Cusip        string    `json:"cusip"`
+	OriginalFace int64     `json:"originalFace"` // In cents
+	SellerHash   string    `json:"sellerHash"`   // Borrows cash and pledges the collateral
+	BuyerHash    string    `json:"buyerHash"`    // Lends cash and holds the lien on the collateral
+	CashAmount   float64   `json:"cashAmount"`
+	RepoRate     float64   `json:"repoRate"`     // Annual rate, e.g. 0.05 for 5%
+	Haircut      float64   `json:"haircut"`      // Share of the collateral value not lent against, e.g. 0.02 for 2%
+	MarginPosted float64   `json:"marginPosted"` // Cash margin posted by the seller to meet margin calls, returned on the close leg
+	TermDays     int       `json:"termDays"`
+	State        string    `json:"state"` //"Proposed", "Open", "Closed", "Cancelled" or "Defaulted"
+	CreatedAt    time.Time `json:"createdAt"`
+	StartDate    time.Time `json:"startDate"`    // Open leg settlement
+	MaturityDate time.Time `json:"maturityDate"` // StartDate plus the term
+	CloseDate    time.Time `json:"closeDate"`    // Close leg settlement
+	Interest     float64   `json:"interest"`     // Repo interest paid on the close leg
+}
+
+// MarginCall asks the seller of a repo to post cash margin once the collateral, marked at its consensus price
+// and after the haircut, no longer covers the cash lent plus the accrued interest
+type MarginCall struct {
+	RepoID          string    `json:"repoID"`
+	Date            string    `json:"date"` // YYYY-MM-DD, at most one call per repo and day
+	Price           Price     `json:"price"`
+	CollateralValue float64   `json:"collateralValue"` // Marked value after the haircut
+	Exposure        float64   `json:"exposure"`        // Cash plus accrued interest, less the margin already posted
+	Shortfall       float64   `json:"shortfall"`
+	Deadline        time.Time `json:"deadline"`
+	State           string    `json:"state"` //"Open", "Met", "Defaulted" or "Closed"
+}
+
+const (
+	repoObjectType       = "repo"
+	marginCallObjectType = "margincall"
+	// Repo interest accrues on an actual/360 basis
+	repoDayCountBasis = 360.0
+	// Time the seller has to meet a margin call before the buyer may default the repo
+	marginCallGracePeriod = 24 * time.Hour
+)
+
+// ⭐ Functions ⭐
+
+// ProposeRepo offers the caller's bond with the given UID as collateral for cash from the buyer.
+// The bond is held for the repo until the buyer accepts it or the seller cancels it.
+// When the cusip has a consensus price, the cash cannot exceed the collateral value after the haircut
+func (s *SmartContract) ProposeRepo(ctx contractapi.TransactionContextInterface, repoID, uid, buyerHash string, cashAmount, repoRate, haircut float64, termDays int, createdAt time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkRepoTerms(ctx, repoID, cashAmount, repoRate, haircut, termDays)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger, err := s.getBondLedger(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	bondIndex := findBondByUID(ledger, uid)
+	if bondIndex == -1 {
+		return nil, NewError(ErrNotFound, "bond with UID %s not found", uid)
+	}
+	bond := &ledger.Bonds[bondIndex]
+	owner, err := s.callerOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !owner.owns(bond) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of the bond")
+	}
+	if owner.hash == buyerHash {
+		return nil, fmt.Errorf("you cannot enter a repo with yourself")
+	}
+	if bond.ReservedFor != "" {
+		return nil, NewConflictError(bond.UID, bond.Cusip, bond.ReservedFor)
+	}
+
+	return s.proposeRepo(ctx, ledger, bond, Repo{
+		RepoID:     repoID,
+		SellerHash: owner.hash,
+		BuyerHash:  buyerHash,
+		CashAmount: cashAmount,
+		RepoRate:   repoRate,
+		Haircut:    haircut,
+		TermDays:   termDays,
+		CreatedAt:  createdAt,
+	})
+}
+
+// OpenRepo offers a bond of the caller in the cusip with the given original face as collateral for cash from the buyer,
+// at the repo rate for the term, without a haircut. The buyer opens the repo with AcceptRepo, which pledges the bond
+// with a lien for the buyer. CloseRepo lifts the lien and DefaultRepo lets the buyer seize the bond
+func (s *SmartContract) OpenRepo(ctx contractapi.TransactionContextInterface, repoID, cusip, buyerHash string, face int64, cashAmount, repoRate float64, termDays int, createdAt time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkRepoTerms(ctx, repoID, cashAmount, repoRate, 0, termDays)
+	if err != nil {
+		return nil, err
+	}
+	if face <= 0 {
+		return nil, fmt.Errorf("face must be positive: %d", face)
+	}
+
+	owner, err := s.callerOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if owner.hash == buyerHash {
+		return nil, fmt.Errorf("you cannot enter a repo with yourself")
+	}
+	ledger, err := s.getCusipLedger(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+	bondIndex, err := repoCollateral(ledger, owner, cusip, face)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.proposeRepo(ctx, ledger, &ledger.Bonds[bondIndex], Repo{
+		RepoID:     repoID,
+		SellerHash: owner.hash,
+		BuyerHash:  buyerHash,
+		CashAmount: cashAmount,
+		RepoRate:   repoRate,
+		TermDays:   termDays,
+		CreatedAt:  createdAt,
+	})
+}
+
+// AcceptRepo settles the open leg of a proposed repo: the buyer lends the cash and the collateral is pledged to it.
+// The seller keeps the bond, but the buyer holds a lien on it until the close leg
+func (s *SmartContract) AcceptRepo(ctx contractapi.TransactionContextInterface, repoID string, startDate time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &startDate)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := s.getRepoInState(ctx, repoID, "Proposed")
+	if err != nil {
+		return nil, err
+	}
+	if !s.IsOwner(ctx, repo.BuyerHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the buyer of the repo")
+	}
+
+	ledger, err := s.getCusipLedger(ctx, repo.Cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	bondIndex := findBondByUID(ledger, repo.UID)
+	if bondIndex == -1 || ledger.Bonds[bondIndex].ReservedFor != repoID {
+		return nil, fmt.Errorf("the collateral of repo %s is no longer available", repoID)
+	}
+	err = ledger.Bonds[bondIndex].pledge(repo.BuyerHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// The buyer lends the cash
+	_, _, err = s.transferCash(ctx, ledger.cashBook(), repo.BuyerHash, repo.SellerHash, cents(repo.CashAmount))
+	if err != nil {
+		return nil, err
+	}
+	transaction := s.GenerateTransactionObject(repo.BuyerHash, repo.SellerHash, repo.Cusip, repo.OriginalFace, repoPrice(repo.CashAmount, repo.OriginalFace), startDate)
+	appendTransaction(ctx, ledger, transaction)
+
+	// The seller's collateral is now pledged against the cash, so its haircut is reserved in the collateral chaincode
+	err = s.reserveHaircut(ctx, repo.haircutRequest(repo.Haircut))
+	if err != nil {
+		return nil, err
+	}
+
+	repo.State = "Open"
+	repo.StartDate = startDate
+	repo.MaturityDate = startDate.AddDate(0, 0, repo.TermDays)
+	err = s.putRecord(ctx, repoObjectType, repoID, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitRepoEvent(ctx, RepoOpenedEvent, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// CloseRepo settles the close leg of an open repo: the seller repays the cash plus the interest accrued up to the close date,
+// net of the margin it posted, and the lien on the collateral is lifted. When the margin exceeds what the seller owes,
+// the buyer refunds the excess. Either party can close the repo
+func (s *SmartContract) CloseRepo(ctx contractapi.TransactionContextInterface, repoID string, closeDate time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &closeDate)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := s.getRepoInState(ctx, repoID, "Open")
+	if err != nil {
+		return nil, err
+	}
+	if !s.IsOwner(ctx, repo.SellerHash) && !s.IsOwner(ctx, repo.BuyerHash) {
+		return nil, NewError(ErrUnauthorized, "you are not a party to the repo")
+	}
+	if closeDate.Before(repo.StartDate) {
+		return nil, fmt.Errorf("repo %s cannot close before it started on %v", repoID, repo.StartDate)
+	}
+
+	ledger, err := s.getCusipLedger(ctx, repo.Cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	bondIndex := findBondByUID(ledger, repo.UID)
+	if bondIndex == -1 || ledger.Bonds[bondIndex].ReservedFor != repoID {
+		return nil, fmt.Errorf("the collateral of repo %s is no longer held for it", repoID)
+	}
+	ledger.Bonds[bondIndex].release()
+
+	// The seller repays the cash with interest, net of the margin the buyer gives back
+	repo.Interest = repo.accruedInterest(closeDate)
+	payer, payee, repayment := repo.SellerHash, repo.BuyerHash, repo.CashAmount+repo.Interest-repo.MarginPosted
+	if repayment < 0 {
+		payer, payee, repayment = payee, payer, -repayment
+	}
+	_, _, err = s.transferCash(ctx, ledger.cashBook(), payer, payee, cents(repayment))
+	if err != nil {
+		return nil, err
+	}
+	transaction := s.GenerateTransactionObject(repo.SellerHash, repo.BuyerHash, repo.Cusip, repo.OriginalFace, repoPrice(repo.CashAmount+repo.Interest, repo.OriginalFace), closeDate)
+	appendTransaction(ctx, ledger, transaction)
+
+	err = s.releaseHaircut(ctx, repo.haircutRequest(0))
+	if err != nil {
+		return nil, err
+	}
+
+	repo.State = "Closed"
+	repo.CloseDate = closeDate
+	err = s.putRecord(ctx, repoObjectType, repoID, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitRepoEvent(ctx, RepoClosedEvent, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	// The close leg repays the whole exposure, so pending margin calls no longer apply
+	err = s.closeMarginCalls(ctx, repoID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// CancelRepo withdraws a proposed repo if the caller is the seller, freeing the bond
+func (s *SmartContract) CancelRepo(ctx contractapi.TransactionContextInterface, repoID string) (*WriteResponse, error) {
+	repo, err := s.getRepoInState(ctx, repoID, "Proposed")
+	if err != nil {
+		return nil, err
+	}
+	if !s.IsOwner(ctx, repo.SellerHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the seller of the repo")
+	}
+
+	ledger, err := s.getCusipLedger(ctx, repo.Cusip)
+	if err != nil {
+		return nil, err
+	}
+	releaseReservations(ledger, repoID, "")
+
+	repo.State = "Cancelled"
+	err = s.putRecord(ctx, repoObjectType, repoID, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitRepoEvent(ctx, RepoCancelledEvent, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// GetRepoInterest returns the interest an open repo has accrued up to the given date
+func (s *SmartContract) GetRepoInterest(ctx contractapi.TransactionContextInterface, repoID string, asOf time.Time) (float64, error) {
+	toUTC(&asOf)
+
+	repo, err := s.getRepoInState(ctx, repoID, "Open")
+	if err != nil {
+		return 0, err
+	}
+
+	return repo.accruedInterest(asOf), nil
+}
+
+// CheckRepoMargin marks the collateral of an open repo at its latest consensus price and issues a margin call
+// for the shortfall when the value after the haircut no longer covers the exposure. Anyone can run it, at most once per day
+func (s *SmartContract) CheckRepoMargin(ctx contractapi.TransactionContextInterface, repoID string, asOf time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := s.getRepoInState(ctx, repoID, "Open")
+	if err != nil {
+		return nil, err
+	}
+
+	date := asOf.Format(markDateLayout)
+	var existing MarginCall
+	exists, err := s.getCompositeRecord(ctx, marginCallObjectType, []string{repoID, date}, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("the margin of repo %s was already called on %s", repoID, date)
+	}
+
+	consensus, err := s.latestConsensusPrice(ctx, repo.Cusip)
+	if err != nil {
+		return nil, err
+	}
+	if consensus == nil {
+		return nil, fmt.Errorf("no consensus price for Cusip %s to mark the collateral", repo.Cusip)
+	}
+
+	value := collateralValue(repo.OriginalFace, consensus.Median.value(), repo.Haircut)
+	exposure := repo.exposure(asOf)
+	if value >= exposure {
+		// Margin is maintained, no call
+		return newWriteResponse(ctx, nil)
+	}
+
+	marginCall := MarginCall{
+		RepoID:          repoID,
+		Date:            date,
+		Price:           consensus.Median,
+		CollateralValue: value,
+		Exposure:        exposure,
+		Shortfall:       exposure - value,
+		Deadline:        asOf.Add(marginCallGracePeriod),
+		State:           "Open",
+	}
+	err = s.putCompositeRecord(ctx, marginCallObjectType, []string{repoID, date}, marginCall)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, &marginCall)
+}
+
+// MeetMarginCall posts cash margin against an open margin call. The seller must post at least the shortfall before the deadline
+func (s *SmartContract) MeetMarginCall(ctx contractapi.TransactionContextInterface, repoID, date string, amount float64, timestamp time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := s.getRepoInState(ctx, repoID, "Open")
+	if err != nil {
+		return nil, err
+	}
+	if !s.IsOwner(ctx, repo.SellerHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the seller of the repo")
+	}
+
+	marginCall, err := s.getOpenMarginCall(ctx, repoID, date)
+	if err != nil {
+		return nil, err
+	}
+	if timestamp.After(marginCall.Deadline) {
+		return nil, fmt.Errorf("the margin call of %s on repo %s expired at %v", date, repoID, marginCall.Deadline)
+	}
+	if amount < marginCall.Shortfall {
+		return nil, fmt.Errorf("margin of %.2f does not cover the shortfall of %.2f", amount, marginCall.Shortfall)
+	}
+
+	cash := &cashBook{}
+	_, _, err = s.transferCash(ctx, cash, repo.SellerHash, repo.BuyerHash, cents(amount))
+	if err != nil {
+		return nil, err
+	}
+	err = s.saveCash(ctx, cash)
+	if err != nil {
+		return nil, err
+	}
+	repo.MarginPosted += amount
+	err = s.putRecord(ctx, repoObjectType, repoID, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	marginCall.State = "Met"
+	err = s.putCompositeRecord(ctx, marginCallObjectType, []string{repoID, date}, marginCall)
+	if err != nil {
+		return nil, err
+	}
+	return newWriteResponse(ctx, nil)
+}
+
+// DefaultRepo lets the buyer seize the collateral of a repo the seller did not buy back by its maturity date,
+// or whose margin call was not met by its deadline. The date is the day of that margin call, empty for a default at maturity
+func (s *SmartContract) DefaultRepo(ctx contractapi.TransactionContextInterface, repoID, date string, timestamp time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := s.getRepoInState(ctx, repoID, "Open")
+	if err != nil {
+		return nil, err
+	}
+	if !s.IsOwner(ctx, repo.BuyerHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the buyer of the repo")
+	}
+
+	var marginCall *MarginCall
+	if date == "" {
+		if !timestamp.After(repo.MaturityDate) {
+			return nil, fmt.Errorf("the seller has until %v to buy back the collateral", repo.MaturityDate)
+		}
+	} else {
+		marginCall, err = s.getOpenMarginCall(ctx, repoID, date)
+		if err != nil {
+			return nil, err
+		}
+		if !timestamp.After(marginCall.Deadline) {
+			return nil, fmt.Errorf("the seller has until %v to meet the margin call", marginCall.Deadline)
+		}
+	}
+
+	ledger, err := s.getCusipLedger(ctx, repo.Cusip)
+	if err != nil {
+		return nil, err
+	}
+	// The lien is enforced: the collateral is delivered to the buyer. It may be committed with the seller's owner secret,
+	// so it is found by the repo it is pledged for alone
+	bondIndex := findBondByUID(ledger, repo.UID)
+	if bondIndex == -1 || ledger.Bonds[bondIndex].ReservedFor != repoID {
+		return nil, fmt.Errorf("the collateral of repo %s is no longer held for it", repoID)
+	}
+	err = ledger.Bonds[bondIndex].deliver(s.ownerHashFor(ctx, repo.BuyerHash, repo.UID))
+	if err != nil {
+		return nil, err
+	}
+	err = emitBondTransferred(ctx, ledger.Bonds[bondIndex], repo.SellerHash, repoID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.releaseHaircut(ctx, repo.haircutRequest(0))
+	if err != nil {
+		return nil, err
+	}
+
+	repo.State = "Defaulted"
+	repo.CloseDate = timestamp
+	err = s.putRecord(ctx, repoObjectType, repoID, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	// Other pending margin calls no longer apply once the collateral is kept. The missed one is written last, so it stays Defaulted
+	err = s.closeMarginCalls(ctx, repoID)
+	if err != nil {
+		return nil, err
+	}
+	if marginCall != nil {
+		marginCall.State = "Defaulted"
+		err = s.putCompositeRecord(ctx, marginCallObjectType, []string{repoID, date}, marginCall)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = emitRepoEvent(ctx, RepoDefaultedEvent, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// GetRepoMarginCalls returns the margin calls issued on a repo, oldest first
+func (s *SmartContract) GetRepoMarginCalls(ctx contractapi.TransactionContextInterface, repoID string) ([]MarginCall, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(marginCallObjectType, []string{repoID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get margin calls: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	marginCalls := []MarginCall{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over margin calls: %v", err)
+		}
+
+		var marginCall MarginCall
+		err = json.Unmarshal(queryResponse.Value, &marginCall)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling margin call JSON: %v", err)
+		}
+		marginCalls = append(marginCalls, marginCall)
+	}
+
+	return marginCalls, nil
+}
+
+// GetYourOpenRepos returns the proposed and open repos where the caller is the seller or the buyer
+func (s *SmartContract) GetYourOpenRepos(ctx contractapi.TransactionContextInterface) ([]Repo, error) {
+	orgHash, err := s.GenerateOrgHash(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate org hash: %v", err)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(repoObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repos: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	repos := []Repo{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over repos: %v", err)
+		}
+
+		var repo Repo
+		err = json.Unmarshal(queryResponse.Value, &repo)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling repo JSON: %v", err)
+		}
+		if repo.State != "Proposed" && repo.State != "Open" {
+			continue
+		}
+		if repo.SellerHash == orgHash || repo.BuyerHash == orgHash {
+			repos = append(repos, repo)
+		}
+	}
+
+	return repos, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// checkRepoTerms checks the terms of a new repo, and that no repo has its ID yet
+func (s *SmartContract) checkRepoTerms(ctx contractapi.TransactionContextInterface, repoID string, cashAmount, repoRate, haircut float64, termDays int) error {
+	if cashAmount <= 0 {
+		return fmt.Errorf("cash amount must be positive: %v", cashAmount)
+	}
+	if repoRate < 0 {
+		return fmt.Errorf("repo rate cannot be negative: %v", repoRate)
+	}
+	if haircut < 0 || haircut >= 1 {
+		return fmt.Errorf("haircut must be at least 0 and below 1: %v", haircut)
+	}
+	if termDays <= 0 {
+		return fmt.Errorf("term must be at least one day: %d", termDays)
+	}
+
+	var existing Repo
+	exists, err := s.getRecord(ctx, repoObjectType, repoID, &existing)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return NewError(ErrAlreadyExists, "repo %s already exists", repoID)
+	}
+
+	return nil
+}
+
+// proposeRepo holds the seller's bond for a new repo and stores it as Proposed.
+// When the cusip has a consensus price, the cash cannot exceed the collateral value after the haircut
+func (s *SmartContract) proposeRepo(ctx contractapi.TransactionContextInterface, ledger *Ledger, bond *AgencyMBSPassthrough, repo Repo) (*WriteResponse, error) {
+	consensus, err := s.latestConsensusPrice(ctx, bond.Cusip)
+	if err != nil {
+		return nil, err
+	}
+	if consensus != nil {
+		lendable := collateralValue(bond.OriginalFace, consensus.Median.value(), repo.Haircut)
+		if repo.CashAmount > lendable {
+			return nil, fmt.Errorf("cash amount %.2f exceeds the collateral value of %.2f after the haircut", repo.CashAmount, lendable)
+		}
+	}
+
+	err = bond.reserve(repo.RepoID)
+	if err != nil {
+		return nil, err
+	}
+
+	repo.UID = bond.UID
+	repo.Cusip = bond.Cusip
+	repo.OriginalFace = bond.OriginalFace
+	repo.State = "Proposed"
+	err = s.putRecord(ctx, repoObjectType, repo.RepoID, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitRepoEvent(ctx, RepoProposedEvent, &repo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, repo.RepoID)
+}
+
+// repoCollateral returns the index in the ledger of a free bond of the cusip the owner holds whole, with the given original face
+func repoCollateral(ledger *Ledger, owner bondOwner, cusip string, face int64) (int, error) {
+	blocked := -1
+	for i, bond := range ledger.Bonds {
+		if bond.Cusip != cusip || bond.OriginalFace != face || !bond.tradable() || !owner.owns(&ledger.Bonds[i]) {
+			continue
+		}
+		if bond.ReservedFor == "" {
+			return i, nil
+		}
+		if blocked == -1 {
+			blocked = i
+		}
+	}
+	if blocked != -1 {
+		return -1, NewConflictError(ledger.Bonds[blocked].UID, cusip, ledger.Bonds[blocked].ReservedFor)
+	}
+
+	return -1, fmt.Errorf("you have no bond of Cusip %s with an original face of %d", cusip, face)
+}
+
+func (s *SmartContract) getRepoInState(ctx contractapi.TransactionContextInterface, repoID, state string) (*Repo, error) {
+	var repo Repo
+	exists, err := s.getRecord(ctx, repoObjectType, repoID, &repo)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, NewError(ErrNotFound, "repo %s not found", repoID)
+	}
+	if repo.State != state {
+		return nil, fmt.Errorf("repo %s is %s", repoID, repo.State)
+	}
+
+	return &repo, nil
+}
+
+func (s *SmartContract) getOpenMarginCall(ctx contractapi.TransactionContextInterface, repoID, date string) (*MarginCall, error) {
+	var marginCall MarginCall
+	exists, err := s.getCompositeRecord(ctx, marginCallObjectType, []string{repoID, date}, &marginCall)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("no margin call on repo %s on %s", repoID, date)
+	}
+	if marginCall.State != "Open" {
+		return nil, fmt.Errorf("the margin call of %s on repo %s is %s", date, repoID, marginCall.State)
+	}
+
+	return &marginCall, nil
+}
+
+// closeMarginCalls closes the margin calls of a repo that are still open
+func (s *SmartContract) closeMarginCalls(ctx contractapi.TransactionContextInterface, repoID string) error {
+	marginCalls, err := s.GetRepoMarginCalls(ctx, repoID)
+	if err != nil {
+		return err
+	}
+
+	for _, marginCall := range marginCalls {
+		if marginCall.State != "Open" {
+			continue
+		}
+		marginCall.State = "Closed"
+		err = s.putCompositeRecord(ctx, marginCallObjectType, []string{repoID, marginCall.Date}, marginCall)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exposure returns what the seller owes on the repo at asOf, net of the margin it posted
+func (r *Repo) exposure(asOf time.Time) float64 {
+	return r.CashAmount + r.accruedInterest(asOf) - r.MarginPosted
+}
+
+// collateralValue returns the value of a face amount at a price per 100, less the haircut
+func collateralValue(originalFace int64, price, haircut float64) float64 {
+	return dollars(originalFace) * price / 100 * (1 - haircut)
+}
+
+// accruedInterest returns the simple actual/360 interest on the cash from the start of the repo up to asOf
+func (r *Repo) accruedInterest(asOf time.Time) float64 {
+	if !asOf.After(r.StartDate) {
+		return 0
+	}
+	days := float64(int(asOf.Sub(r.StartDate).Hours() / 24))
+
+	return r.CashAmount * r.RepoRate * days / repoDayCountBasis
+}
+
+// haircutRequest describes the repo's collateral to the collateral chaincode, with the haircut to reserve
+func (r *Repo) haircutRequest(haircut float64) HaircutRequest {
+	return HaircutRequest{
+		Reference:    r.RepoID,
+		PartyHash:    r.SellerHash,
+		UID:          r.UID,
+		Cusip:        r.Cusip,
+		OriginalFace: r.OriginalFace,
+		Haircut:      haircut,
+		CashAmount:   r.CashAmount,
+	}
+}
+
+// repoPrice formats a repo leg's cash amount as a price per 100 of face, like the other transactions
+func repoPrice(cashAmount float64, originalFace int64) string {
+	return fmt.Sprintf("%.2f", cashAmount/dollars(originalFace)*100)
+}
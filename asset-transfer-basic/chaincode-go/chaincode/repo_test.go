@@ -0,0 +1,324 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+// openRepo returns a world where Org2 repos uid1 to Org1 for $900,000 at 5% over 30 days, opened at testTime
+func openRepo(t *testing.T, contract *chaincode.SmartContract) *world {
+	w := setUp(t, contract)
+	createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+	_, err := contract.DepositCash(w.begin(org1), org1, "USD", 200000000)
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.OpenRepo(w.begin(org2), "repo1", testCusip, org1, tradeFace, 900000, 0.05, 30, testTime)
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.AcceptRepo(w.begin(org1), "repo1", testTime)
+	require.NoError(t, err)
+	w.commit()
+	return w
+}
+
+// publishConsensus publishes a consensus price for testCusip on the day of at, marked by both organizations
+func publishConsensus(t *testing.T, w *world, contract *chaincode.SmartContract, price string, at time.Time) {
+	date := at.Format("2006-01-02")
+	marks := map[string]string{}
+	for _, mspID := range []string{org1, org2} {
+		mark := fmt.Sprintf(`{"cusip":%q,"date":%q,"price":%q,"contributorMSP":%q}`, testCusip, date, price, mspID)
+		marks[mspID] = mark
+		w.transient = map[string][]byte{"mark": []byte(mark)}
+		_, err := contract.ContributeMark(w.beginAt(mspID, at), testCusip, date, at)
+		require.NoError(t, err)
+		w.commit()
+	}
+
+	marksJSON, err := json.Marshal(marks)
+	require.NoError(t, err)
+	w.transient = map[string][]byte{"marks": marksJSON}
+	_, err = contract.PublishConsensusPrice(w.beginAt(org1, at), testCusip, date, at)
+	require.NoError(t, err)
+	w.commit()
+}
+
+// requireCollateral checks the owner, status and lien holder of uid1
+func requireCollateral(t *testing.T, w *world, contract *chaincode.SmartContract, ownerHash, status, lienHolder string) {
+	bonds, err := contract.GetAllBonds(w.begin(org1), false)
+	require.NoError(t, err)
+	require.Len(t, bonds, 1)
+	require.Equal(t, ownerHash, bonds[0].OwnerHash)
+	require.Equal(t, status, bonds[0].Status)
+	require.Equal(t, lienHolder, bonds[0].LienHolder)
+}
+
+func TestOpenRepo(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := openRepo(t, contract)
+
+	// The seller keeps the bond, pledged with a lien for the cash lender
+	requireCollateral(t, w, contract, org2, chaincode.BondPledged, org1)
+	requireCash(t, w, contract, 110000000, 90000000)
+	_, err := contract.OpenRepo(w.begin(org2), "repo2", testCusip, org1, tradeFace, 900000, 0.05, 30, testTime)
+	require.ErrorContains(t, err, "uid1")
+
+	// 36 days of 5% on $900,000 is $4,500 of interest
+	_, err = contract.DepositCash(w.begin(org1), org2, "USD", 450000)
+	require.NoError(t, err)
+	w.commit()
+	closed := testTime.Add(36 * 24 * time.Hour)
+	_, err = contract.CloseRepo(w.beginAt(org2, closed), "repo1", closed)
+	require.NoError(t, err)
+	w.commit()
+
+	requireCollateral(t, w, contract, org2, chaincode.BondListed, "")
+	requireCash(t, w, contract, 200450000, 0)
+}
+
+func TestCloseRepoRefundsExcessMargin(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := openRepo(t, contract)
+	_, err := contract.DepositCash(w.begin(org1), org2, "USD", 10000000)
+	require.NoError(t, err)
+	w.commit()
+
+	// At 89 the collateral no longer covers the cash, and the seller posts more margin than it owes
+	publishConsensus(t, w, contract, "89", testTime)
+	_, err = contract.CheckRepoMargin(w.begin(org1), "repo1", testTime)
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.MeetMarginCall(w.begin(org2), "repo1", "2023-01-09", 1000000, testTime)
+	require.NoError(t, err)
+	w.commit()
+	requireCash(t, w, contract, 210000000, 0)
+
+	_, err = contract.CloseRepo(w.begin(org1), "repo1", testTime)
+	require.NoError(t, err)
+	w.commit()
+
+	// The buyer refunds the margin beyond the cash lent
+	requireCash(t, w, contract, 200000000, 10000000)
+	requireCollateral(t, w, contract, org2, chaincode.BondListed, "")
+}
+
+func TestRepoLifecycle(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUpRepo(t, contract)
+			requireCollateral(t, w, contract, org2, chaincode.BondReserved, "")
+
+			_, err := contract.AcceptRepo(w.begin(org1), "repo1", testTime)
+			require.NoError(t, err)
+			require.Contains(t, w.events(), chaincode.RepoOpenedEvent)
+			w.commit()
+			requireCollateral(t, w, contract, org2, chaincode.BondPledged, org1)
+			requireCash(t, w, contract, 110000000, 90000000)
+
+			for _, mspID := range []string{org1, org2} {
+				repos, err := contract.GetYourOpenRepos(w.begin(mspID))
+				require.NoError(t, err)
+				require.Len(t, repos, 1)
+				require.Equal(t, "Open", repos[0].State)
+				require.Equal(t, testTime.AddDate(0, 0, 30), repos[0].MaturityDate)
+			}
+
+			// 10 days of 5% on $900,000 accrue $1,250 of interest
+			interest, err := contract.GetRepoInterest(w.begin(org1), "repo1", testTime.Add(10*24*time.Hour))
+			require.NoError(t, err)
+			require.InDelta(t, 1250, interest, 1e-9)
+
+			_, err = contract.DepositCash(w.begin(org1), org2, "USD", 125000)
+			require.NoError(t, err)
+			w.commit()
+			closed := testTime.Add(10 * 24 * time.Hour)
+			_, err = contract.CloseRepo(w.beginAt(org1, closed), "repo1", closed)
+			require.NoError(t, err)
+			require.Contains(t, w.events(), chaincode.RepoClosedEvent)
+			w.commit()
+
+			requireCollateral(t, w, contract, org2, chaincode.BondListed, "")
+			requireCash(t, w, contract, 200125000, 0)
+			repos, err := contract.GetYourOpenRepos(w.begin(org1))
+			require.NoError(t, err)
+			require.Empty(t, repos)
+		})
+	}
+}
+
+func TestCancelRepo(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpRepo(t, contract)
+
+	_, err := contract.CancelRepo(w.begin(org1), "repo1")
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "you are not the seller of the repo"))
+	_, err = contract.CancelRepo(w.begin(org2), "repo1")
+	require.NoError(t, err)
+	w.commit()
+
+	requireCollateral(t, w, contract, org2, chaincode.BondListed, "")
+	_, err = contract.AcceptRepo(w.begin(org1), "repo1", testTime)
+	require.EqualError(t, err, "repo repo1 is Cancelled")
+}
+
+func TestRepoErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(contract *chaincode.SmartContract, w *world) error
+		err  string
+	}{
+		{
+			name: "repo with yourself",
+			run: func(contract *chaincode.SmartContract, w *world) error {
+				_, err := contract.ProposeRepo(w.begin(org2), "repo2", "uid1", org2, 900000, 0.05, 0.02, 30, testTime)
+				return err
+			},
+			err: "you cannot enter a repo with yourself",
+		},
+		{
+			name: "repo ID taken",
+			run: func(contract *chaincode.SmartContract, w *world) error {
+				_, err := contract.OpenRepo(w.begin(org2), "repo1", testCusip, org1, tradeFace, 900000, 0.05, 30, testTime)
+				return err
+			},
+			err: contractError(chaincode.ErrAlreadyExists, "repo repo1 already exists"),
+		},
+		{
+			name: "no bond of the face",
+			run: func(contract *chaincode.SmartContract, w *world) error {
+				_, err := contract.OpenRepo(w.begin(org2), "repo2", testCusip, org1, tradeFace/2, 900000, 0.05, 30, testTime)
+				return err
+			},
+			err: "you have no bond of Cusip 3132DWAR4 with an original face of 50000000",
+		},
+		{
+			name: "accepted by the seller",
+			run: func(contract *chaincode.SmartContract, w *world) error {
+				_, err := contract.AcceptRepo(w.begin(org2), "repo1", testTime)
+				return err
+			},
+			err: contractError(chaincode.ErrUnauthorized, "you are not the buyer of the repo"),
+		},
+		{
+			name: "closed before it opened",
+			run: func(contract *chaincode.SmartContract, w *world) error {
+				_, err := contract.CloseRepo(w.begin(org2), "repo1", testTime)
+				return err
+			},
+			err: "repo repo1 is Proposed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract := &chaincode.SmartContract{}
+			w := setUpRepo(t, contract)
+
+			require.EqualError(t, test.run(contract, w), test.err)
+		})
+	}
+}
+
+func TestRepoMarginCall(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := openRepo(t, contract)
+
+	// At par the collateral covers the cash, so no margin is called
+	publishConsensus(t, w, contract, "100", testTime)
+	response, err := contract.CheckRepoMargin(w.begin(org1), "repo1", testTime)
+	require.NoError(t, err)
+	require.Nil(t, response.Result)
+	w.commit()
+	calls, err := contract.GetRepoMarginCalls(w.begin(org1), "repo1")
+	require.NoError(t, err)
+	require.Empty(t, calls)
+
+	// Marked at 89 the next day, the $1,000,000 of collateral is worth $890,000 against $900,125 lent with interest
+	nextDay := testTime.Add(24 * time.Hour)
+	publishConsensus(t, w, contract, "89", nextDay)
+	response, err = contract.CheckRepoMargin(w.beginAt(org1, nextDay), "repo1", nextDay)
+	require.NoError(t, err)
+	w.commit()
+	call := response.Result.(*chaincode.MarginCall)
+	require.Equal(t, "Open", call.State)
+	require.InDelta(t, 10125, call.Shortfall, 1e-9)
+	require.Equal(t, nextDay.Add(24*time.Hour), call.Deadline)
+
+	_, err = contract.CheckRepoMargin(w.beginAt(org1, nextDay), "repo1", nextDay)
+	require.EqualError(t, err, "the margin of repo repo1 was already called on 2023-01-10")
+	_, err = contract.MeetMarginCall(w.beginAt(org2, nextDay), "repo1", "2023-01-10", 10000, nextDay)
+	require.EqualError(t, err, "margin of 10000.00 does not cover the shortfall of 10125.00")
+
+	_, err = contract.MeetMarginCall(w.beginAt(org2, nextDay), "repo1", "2023-01-10", 10125, nextDay)
+	require.NoError(t, err)
+	w.commit()
+	requireCash(t, w, contract, 111012500, 88987500)
+	calls, err = contract.GetRepoMarginCalls(w.begin(org1), "repo1")
+	require.NoError(t, err)
+	require.Len(t, calls, 1)
+	require.Equal(t, "Met", calls[0].State)
+}
+
+func TestDefaultRepo(t *testing.T) {
+	tests := []struct {
+		name  string
+		date  string
+		early time.Time
+		late  time.Time
+		err   string
+	}{
+		{
+			name:  "at maturity",
+			early: testTime.AddDate(0, 0, 30),
+			late:  testTime.AddDate(0, 0, 31),
+			err:   "the seller has until 2023-02-08 12:00:00 +0000 UTC to buy back the collateral",
+		},
+		{
+			name:  "missed margin call",
+			date:  "2023-01-09",
+			early: testTime.Add(time.Hour),
+			late:  testTime.Add(25 * time.Hour),
+			err:   "the seller has until 2023-01-10 12:00:00 +0000 UTC to meet the margin call",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract := &chaincode.SmartContract{}
+			w := openRepo(t, contract)
+			publishConsensus(t, w, contract, "89", testTime)
+			_, err := contract.CheckRepoMargin(w.begin(org1), "repo1", testTime)
+			require.NoError(t, err)
+			w.commit()
+
+			_, err = contract.DefaultRepo(w.beginAt(org2, test.late), "repo1", test.date, test.late)
+			require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "you are not the buyer of the repo"))
+			_, err = contract.DefaultRepo(w.beginAt(org1, test.early), "repo1", test.date, test.early)
+			require.EqualError(t, err, test.err)
+
+			_, err = contract.DefaultRepo(w.beginAt(org1, test.late), "repo1", test.date, test.late)
+			require.NoError(t, err)
+			require.Contains(t, w.events(), chaincode.RepoDefaultedEvent)
+			require.Contains(t, w.events(), chaincode.BondTransferredEvent)
+			w.commit()
+
+			// The lender seizes the collateral in place of the cash it lent
+			requireCollateral(t, w, contract, org1, chaincode.BondSettled, "")
+			requireCash(t, w, contract, 110000000, 90000000)
+			calls, err := contract.GetRepoMarginCalls(w.begin(org1), "repo1")
+			require.NoError(t, err)
+			require.Len(t, calls, 1)
+			if test.date == "" {
+				require.Equal(t, "Closed", calls[0].State)
+			} else {
+				require.Equal(t, "Defaulted", calls[0].State)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package chaincode
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// WriteResponse wraps what a write function returns with the ID and time of the transaction,
+// so callers can keep a reference to it for later audit. The client adds the block it was committed in
+type WriteResponse struct {
+	TxID      string      `json:"txID"`
+	Timestamp time.Time   `json:"timestamp"`
+	Result    interface{} `json:"result"` // What the function returns, empty when it returns nothing
+}
+
+// ⭐ Helper functions ⭐
+
+// newWriteResponse builds the response of a write function from its result. Every write function ends with it,
+// so it also counts the invocation for the usage statistics
+func newWriteResponse(ctx contractapi.TransactionContextInterface, result interface{}) (*WriteResponse, error) {
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = recordUsage(ctx, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WriteResponse{
+		TxID:      ctx.GetStub().GetTxID(),
+		Timestamp: timestamp,
+		Result:    result,
+	}, nil
+}
@@ -0,0 +1,413 @@
+package chaincode
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// RFM is a request for a two-way market on a cusip, sent to selected dealers
+type RFM struct {
+	RFMID         string        `json:"rfmID"`
+	Cusip         string        `json:"cusip"`
+	OriginalFace  int64         `json:"originalFace"` // In cents
+	RequesterMSP  string        `json:"requesterMSP"`
+	RequesterHash string        `json:"requesterHash"` // Owner hash the requester trades under
+	Dealers       []string      `json:"dealers"`       // MSP IDs of the dealers asked for a market
+	Responses     []RFMResponse `json:"responses"`
+	State         string        `json:"state"` //"Open", "Traded" or "Cancelled"
+	CreatedAt     time.Time     `json:"createdAt"`
+}
+
+// RFMResponse is the public trace of a dealer's quote. The prices stay private, only their hash is shown
+type RFMResponse struct {
+	DealerMSP string    `json:"dealerMSP"`
+	QuoteHash string    `json:"quoteHash"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// TwoWayQuote is a dealer's private bid and ask for an RFM, kept in the requester's and the dealer's implicit collections
+type TwoWayQuote struct {
+	RFMID      string `json:"rfmID"`
+	DealerMSP  string `json:"dealerMSP"`
+	DealerHash string `json:"dealerHash"` // Owner hash the dealer trades under
+	BidPrice   Price  `json:"bidPrice"`
+	AskPrice   Price  `json:"askPrice"`
+}
+
+const (
+	rfmObjectType   = "rfm"
+	rfmQuoteKeyType = "rfmquote"
+)
+
+// ⭐ Functions ⭐
+
+// CreateRFM asks the given dealers for a two-way market on a cusip and face
+func (s *SmartContract) CreateRFM(ctx contractapi.TransactionContextInterface, rfmID, requesterHash, cusip string, originalFace int64, dealers []string, createdAt time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if originalFace <= 0 {
+		return nil, fmt.Errorf("face must be positive: %v", originalFace)
+	}
+	if len(dealers) == 0 {
+		return nil, fmt.Errorf("at least one dealer must be asked for a market")
+	}
+	if !s.IsOwner(ctx, requesterHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of %s", requesterHash)
+	}
+
+	var existing RFM
+	exists, err := s.getRecord(ctx, rfmObjectType, rfmID, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, NewError(ErrAlreadyExists, "RFM %s already exists", rfmID)
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	rfm := RFM{
+		RFMID:         rfmID,
+		Cusip:         cusip,
+		OriginalFace:  originalFace,
+		RequesterMSP:  mspID,
+		RequesterHash: requesterHash,
+		Dealers:       dealers,
+		Responses:     []RFMResponse{},
+		State:         "Open",
+		CreatedAt:     createdAt,
+	}
+	err = s.putRecord(ctx, rfmObjectType, rfmID, rfm)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.recordOrderEvents(ctx, OrderEvent{Action: "Create", OrderType: "RFM", OrderID: rfmID, Cusip: cusip, Face: originalFace})
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, rfmID)
+}
+
+// RespondToRFM stores the calling dealer's two-way quote, passed in the transient field "quote",
+// in the requester's and dealer's implicit collections, and puts its hash on the RFM
+func (s *SmartContract) RespondToRFM(ctx contractapi.TransactionContextInterface, rfmID string, timestamp time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	rfm, err := s.getOpenRFM(ctx, rfmID)
+	if err != nil {
+		return nil, err
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	if !containsString(rfm.Dealers, mspID) {
+		return nil, fmt.Errorf("%s was not asked for a market on RFM %s", mspID, rfmID)
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("error getting transient: %v", err)
+	}
+	quoteJSON, ok := transientMap["quote"]
+	if !ok {
+		return nil, NewError(ErrInvalidInput, "quote key not found in the transient map")
+	}
+
+	var quote TwoWayQuote
+	err = json.Unmarshal(quoteJSON, &quote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal quote JSON: %v", err)
+	}
+	if quote.RFMID != rfmID || quote.DealerMSP != mspID {
+		return nil, fmt.Errorf("the quote must be for RFM %s from %s", rfmID, mspID)
+	}
+	if !s.IsOwner(ctx, quote.DealerHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of %s", quote.DealerHash)
+	}
+	for _, price := range []Price{quote.BidPrice, quote.AskPrice} {
+		err = s.validatePrice(ctx, price)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if quote.BidPrice.value() <= 0 || quote.AskPrice.value() < quote.BidPrice.value() {
+		return nil, fmt.Errorf("the quote must have a positive bid not above the ask: %s / %s", quote.BidPrice, quote.AskPrice)
+	}
+
+	quoteKey, err := ctx.GetStub().CreateCompositeKey(rfmQuoteKeyType, []string{rfmID, mspID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	// The quote hash is verified when trading, so the quote bytes are stored as they were passed
+	for _, collection := range []string{implicitCollection(rfm.RequesterMSP), implicitCollection(mspID)} {
+		err = ctx.GetStub().PutPrivateData(collection, quoteKey, quoteJSON)
+		if err != nil {
+			return nil, fmt.Errorf("%s - failed to put quote: %v", collection, err)
+		}
+	}
+
+	hash := sha256.Sum256(quoteJSON)
+	response := RFMResponse{
+		DealerMSP: mspID,
+		QuoteHash: hex.EncodeToString(hash[:]),
+		Timestamp: timestamp,
+	}
+
+	// A dealer responding again replaces its previous quote
+	replaced := false
+	for i, existing := range rfm.Responses {
+		if existing.DealerMSP == mspID {
+			rfm.Responses[i] = response
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		rfm.Responses = append(rfm.Responses, response)
+	}
+
+	err = s.putRecord(ctx, rfmObjectType, rfmID, rfm)
+	if err != nil {
+		return nil, err
+	}
+
+	// The quote's prices are private, so only its hash goes into the public event
+	err = s.recordOrderEvents(ctx, OrderEvent{Action: "Answer", OrderType: "RFM", OrderID: rfmID, Cusip: rfm.Cusip, Face: rfm.OriginalFace, Detail: response.QuoteHash})
+	if err != nil {
+		return nil, err
+	}
+	return newWriteResponse(ctx, nil)
+}
+
+// GetRFMQuotes returns the quotes received for an RFM. Only the requester holds them
+func (s *SmartContract) GetRFMQuotes(ctx contractapi.TransactionContextInterface, rfmID string) ([]TwoWayQuote, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(implicitCollection(mspID), rfmQuoteKeyType, []string{rfmID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quotes: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	quotes := []TwoWayQuote{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over quotes: %v", err)
+		}
+
+		var quote TwoWayQuote
+		err = json.Unmarshal(queryResponse.Value, &quote)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling quote JSON: %v", err)
+		}
+		quotes = append(quotes, quote)
+	}
+
+	return quotes, nil
+}
+
+// TradeRFM lets the requester trade on one side of a dealer's quote: "Buy" lifts the dealer's ask and "Sell" hits its bid.
+// The bond changes hands and a Transaction is recorded as for any other trade.
+func (s *SmartContract) TradeRFM(ctx contractapi.TransactionContextInterface, rfmID, dealerMSP, side string, timestamp time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	rfm, err := s.getOpenRFM(ctx, rfmID)
+	if err != nil {
+		return nil, err
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	if mspID != rfm.RequesterMSP {
+		return nil, NewError(ErrUnauthorized, "you are not the requester of RFM %s", rfmID)
+	}
+
+	var response *RFMResponse
+	for i := range rfm.Responses {
+		if rfm.Responses[i].DealerMSP == dealerMSP {
+			response = &rfm.Responses[i]
+			break
+		}
+	}
+	if response == nil {
+		return nil, fmt.Errorf("%s has not responded to RFM %s", dealerMSP, rfmID)
+	}
+
+	quote, err := s.readVerifiedQuote(ctx, rfm, response)
+	if err != nil {
+		return nil, err
+	}
+
+	// Work out who delivers the bond and at which price
+	var buyerHash, sellerHash string
+	var price Price
+	switch side {
+	case "Buy":
+		buyerHash, sellerHash, price = rfm.RequesterHash, quote.DealerHash, quote.AskPrice
+	case "Sell":
+		buyerHash, sellerHash, price = quote.DealerHash, rfm.RequesterHash, quote.BidPrice
+	default:
+		return nil, fmt.Errorf("side must be Buy or Sell: %s", side)
+	}
+
+	ledger, err := s.getCusipLedger(ctx, rfm.Cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	// The quote is for the face of the RFM, so a bond of exactly that face is delivered
+	bondIndex, err := reserveBond(ledger, s.ownerFor(ctx, sellerHash), rfm.Cusip, rfmID, rfm.OriginalFace)
+	if err != nil {
+		return nil, err
+	}
+	err = s.deliverFace(ctx, &ledger.Bonds[bondIndex], sellerHash, buyerHash, rfm.OriginalFace, rfmID)
+	if err != nil {
+		return nil, err
+	}
+	releaseReservations(ledger, rfmID, "")
+
+	executed := len(ledger.Transactions)
+	transaction := s.GenerateTransactionObject(buyerHash, sellerHash, rfm.Cusip, rfm.OriginalFace, string(price), timestamp)
+	err = s.settleTransaction(ctx, ledger, transaction, price.value())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	rfm.State = "Traded"
+	err = s.putRecord(ctx, rfmObjectType, rfmID, rfm)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.recordOrderEvents(ctx, executionEvents(ledger, executed, "RFM", rfmID)...)
+	if err != nil {
+		return nil, err
+	}
+	return newWriteResponse(ctx, nil)
+}
+
+// CancelRFM withdraws an open RFM. Only the requester can cancel it
+func (s *SmartContract) CancelRFM(ctx contractapi.TransactionContextInterface, rfmID string) (*WriteResponse, error) {
+	rfm, err := s.getOpenRFM(ctx, rfmID)
+	if err != nil {
+		return nil, err
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	if mspID != rfm.RequesterMSP {
+		return nil, NewError(ErrUnauthorized, "you are not the requester of RFM %s", rfmID)
+	}
+
+	rfm.State = "Cancelled"
+	err = s.putRecord(ctx, rfmObjectType, rfmID, rfm)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.recordOrderEvents(ctx, OrderEvent{Action: "Cancel", OrderType: "RFM", OrderID: rfmID, Cusip: rfm.Cusip, Face: rfm.OriginalFace})
+	if err != nil {
+		return nil, err
+	}
+	return newWriteResponse(ctx, nil)
+}
+
+// ⭐ Helper functions ⭐
+
+func (s *SmartContract) getOpenRFM(ctx contractapi.TransactionContextInterface, rfmID string) (*RFM, error) {
+	var rfm RFM
+	exists, err := s.getRecord(ctx, rfmObjectType, rfmID, &rfm)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, NewError(ErrNotFound, "RFM %s not found", rfmID)
+	}
+	if rfm.State != "Open" {
+		return nil, fmt.Errorf("RFM %s is %s", rfmID, rfm.State)
+	}
+
+	return &rfm, nil
+}
+
+// readVerifiedQuote reads a dealer's quote from the requester's collection and checks it against the public hash
+func (s *SmartContract) readVerifiedQuote(ctx contractapi.TransactionContextInterface, rfm *RFM, response *RFMResponse) (*TwoWayQuote, error) {
+	quoteKey, err := ctx.GetStub().CreateCompositeKey(rfmQuoteKeyType, []string{rfm.RFMID, response.DealerMSP})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	quoteJSON, err := ctx.GetStub().GetPrivateData(implicitCollection(rfm.RequesterMSP), quoteKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quote: %v", err)
+	}
+	if quoteJSON == nil {
+		return nil, NewError(ErrNotFound, "quote from %s not found", response.DealerMSP)
+	}
+
+	publicHash, err := hex.DecodeString(response.QuoteHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode quote hash: %v", err)
+	}
+	hash := sha256.Sum256(quoteJSON)
+	if !bytes.Equal(hash[:], publicHash) {
+		return nil, fmt.Errorf("quote from %s does not match its hash on the ledger", response.DealerMSP)
+	}
+
+	var quote TwoWayQuote
+	err = json.Unmarshal(quoteJSON, &quote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal quote JSON: %v", err)
+	}
+
+	return &quote, nil
+}
+
+// containsString returns true when value is one of values
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,74 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+// setUpRFM returns a world where Org1 asks Org2 for a market on tradeFace of testCusip
+func setUpRFM(t *testing.T, contract *chaincode.SmartContract) *world {
+	w := setUp(t, contract)
+	_, err := contract.DepositCash(w.begin(org1), org1, "USD", 200000000)
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.CreateRFM(w.begin(org1), "rfm1", org1, testCusip, tradeFace, []string{org2}, testTime)
+	require.NoError(t, err)
+	w.commit()
+	return w
+}
+
+// respondToRFM sends the quote of Org2 on rfm1 under dealerHash
+func respondToRFM(w *world, contract *chaincode.SmartContract, dealerHash string) error {
+	quoteJSON, err := json.Marshal(chaincode.TwoWayQuote{RFMID: "rfm1", DealerMSP: org2, DealerHash: dealerHash, BidPrice: "99", AskPrice: tradePrice})
+	if err != nil {
+		return err
+	}
+	w.transient = map[string][]byte{"quote": quoteJSON}
+	_, err = contract.RespondToRFM(w.begin(org2), "rfm1", testTime)
+	return err
+}
+
+func TestTradeRFM(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpRFM(t, contract)
+	createBond(t, w, contract, "uid1", org2, testCusip, tradeFace/2)
+	createBond(t, w, contract, "uid2", org2, testCusip, tradeFace)
+
+	require.NoError(t, respondToRFM(w, contract, org2))
+	w.commit()
+
+	_, err := contract.TradeRFM(w.begin(org1), "rfm1", org2, "Buy", testTime)
+	require.NoError(t, err)
+	w.commit()
+
+	// Only the bond with the face of the RFM is delivered, at the dealer's ask
+	owners := map[string]string{}
+	bonds, err := contract.GetAllBonds(w.begin(org1), false)
+	require.NoError(t, err)
+	for _, bond := range bonds {
+		owners[bond.UID] = bond.OwnerHash
+	}
+	require.Equal(t, map[string]string{"uid1": org2, "uid2": org1}, owners)
+	requireCash(t, w, contract, 100500000, 99500000)
+}
+
+func TestTradeRFMErrors(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpRFM(t, contract)
+	createBond(t, w, contract, "uid1", org2, testCusip, tradeFace/2)
+
+	// A dealer can only quote under its own hash
+	err := respondToRFM(w, contract, org1)
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "you are not the owner of Org1MSP"))
+
+	require.NoError(t, respondToRFM(w, contract, org2))
+	w.commit()
+
+	// The dealer only holds a bond of half the face asked for
+	_, err = contract.TradeRFM(w.begin(org1), "rfm1", org2, "Buy", testTime)
+	require.EqualError(t, err, "the seller has no bond of Cusip 3132DWAR4 with an original face of 100000000")
+}
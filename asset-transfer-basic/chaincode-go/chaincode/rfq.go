@@ -0,0 +1,412 @@
+package chaincode
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// RFQ is a buyer's blind request for quotes on a cusip and face. Sellers quote until the deadline without seeing
+// each other's prices, and neither does the buyer: the quotes are only revealed once the deadline passed
+type RFQ struct {
+	RFQID        string     `json:"rfqID"`
+	Cusip        string     `json:"cusip"`
+	OriginalFace int64      `json:"originalFace"` // In cents
+	BuyerMSP     string     `json:"buyerMSP"`
+	BuyerHash    string     `json:"buyerHash"` // Owner hash the buyer trades under
+	Deadline     time.Time  `json:"deadline"`
+	Quotes       []RFQQuote `json:"quotes"`
+	State        string     `json:"state"`     //"Open" or "Awarded"
+	AwardedTo    string     `json:"awardedTo"` // MSP ID of the seller whose quote was accepted
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+// RFQQuote is the public trace of a seller's quote: the hash of the sealed quote until the seller reveals it after the deadline
+type RFQQuote struct {
+	SellerMSP   string    `json:"sellerMSP"`
+	QuoteHash   string    `json:"quoteHash"`
+	SubmittedAt time.Time `json:"submittedAt"`
+	Revealed    bool      `json:"revealed"`
+	SellerHash  string    `json:"sellerHash,omitempty"` // Set when revealed
+	Price       Price     `json:"price,omitempty"`      // Set when revealed
+}
+
+// SealedQuote is a seller's quote for an RFQ, kept in the seller's implicit collection only.
+// The salt keeps the price from being guessed from the public hash
+type SealedQuote struct {
+	RFQID      string `json:"rfqID"`
+	SellerMSP  string `json:"sellerMSP"`
+	SellerHash string `json:"sellerHash"` // Owner hash the seller trades under
+	Price      Price  `json:"price"`
+	Salt       string `json:"salt"`
+}
+
+const (
+	rfqObjectType      = "rfq"
+	sealedQuoteKeyType = "rfqquote"
+)
+
+// ⭐ Functions ⭐
+
+// CreateRFQ asks for quotes on a cusip and face until the deadline
+func (s *SmartContract) CreateRFQ(ctx contractapi.TransactionContextInterface, rfqID, buyerHash, cusip string, originalFace int64, deadline, createdAt time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	toUTC(&deadline)
+
+	if originalFace <= 0 {
+		return nil, fmt.Errorf("face must be positive: %v", originalFace)
+	}
+	if !deadline.After(createdAt) {
+		return nil, fmt.Errorf("the deadline must be after the RFQ is created")
+	}
+	if !s.IsOwner(ctx, buyerHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of %s", buyerHash)
+	}
+	err = s.requireActivePool(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing RFQ
+	exists, err := s.getRecord(ctx, rfqObjectType, rfqID, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, NewError(ErrAlreadyExists, "RFQ %s already exists", rfqID)
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	rfq := RFQ{
+		RFQID:        rfqID,
+		Cusip:        cusip,
+		OriginalFace: originalFace,
+		BuyerMSP:     mspID,
+		BuyerHash:    buyerHash,
+		Deadline:     deadline,
+		Quotes:       []RFQQuote{},
+		State:        "Open",
+		CreatedAt:    createdAt,
+	}
+	err = s.putRecord(ctx, rfqObjectType, rfqID, rfq)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.recordOrderEvents(ctx, OrderEvent{Action: "Create", OrderType: "RFQ", OrderID: rfqID, Cusip: cusip, Face: originalFace})
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, rfqID)
+}
+
+// SubmitQuote seals the calling seller's quote, passed in the transient field "quote", in the seller's implicit collection
+// and puts its hash on the RFQ. Quotes are accepted until the deadline, and quoting again replaces the previous quote
+func (s *SmartContract) SubmitQuote(ctx contractapi.TransactionContextInterface, rfqID string, timestamp time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	rfq, err := s.getOpenRFQ(ctx, rfqID)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !now.Before(rfq.Deadline) {
+		return nil, fmt.Errorf("RFQ %s stopped taking quotes at %v", rfqID, rfq.Deadline)
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	if mspID == rfq.BuyerMSP {
+		return nil, fmt.Errorf("you cannot quote on your own RFQ")
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("error getting transient: %v", err)
+	}
+	quoteJSON, ok := transientMap["quote"]
+	if !ok {
+		return nil, NewError(ErrInvalidInput, "quote key not found in the transient map")
+	}
+
+	var quote SealedQuote
+	err = json.Unmarshal(quoteJSON, &quote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal quote JSON: %v", err)
+	}
+	if quote.RFQID != rfqID || quote.SellerMSP != mspID {
+		return nil, fmt.Errorf("the quote must be for RFQ %s from %s", rfqID, mspID)
+	}
+	if quote.Salt == "" {
+		return nil, fmt.Errorf("the quote must have a salt")
+	}
+	// The seller hash is only revealed after the deadline, so it is bound to the seller now
+	if !s.IsOwner(ctx, quote.SellerHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of %s", quote.SellerHash)
+	}
+	err = s.validatePrice(ctx, quote.Price)
+	if err != nil {
+		return nil, err
+	}
+	if quote.Price.value() <= 0 {
+		return nil, fmt.Errorf("the quote must have a positive price: %s", quote.Price)
+	}
+
+	quoteKey, err := ctx.GetStub().CreateCompositeKey(sealedQuoteKeyType, []string{rfqID, mspID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	// The quote hash is verified when revealing, so the quote bytes are stored as they were passed
+	err = ctx.GetStub().PutPrivateData(implicitCollection(mspID), quoteKey, quoteJSON)
+	if err != nil {
+		return nil, fmt.Errorf("%s - failed to put quote: %v", implicitCollection(mspID), err)
+	}
+
+	hash := sha256.Sum256(quoteJSON)
+	sealed := RFQQuote{
+		SellerMSP:   mspID,
+		QuoteHash:   hex.EncodeToString(hash[:]),
+		SubmittedAt: timestamp,
+	}
+
+	replaced := false
+	for i, existing := range rfq.Quotes {
+		if existing.SellerMSP == mspID {
+			rfq.Quotes[i] = sealed
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		rfq.Quotes = append(rfq.Quotes, sealed)
+	}
+
+	err = s.putRecord(ctx, rfqObjectType, rfqID, rfq)
+	if err != nil {
+		return nil, err
+	}
+
+	// The price is sealed, so only the hash goes into the public event
+	err = s.recordOrderEvents(ctx, OrderEvent{Action: "Answer", OrderType: "RFQ", OrderID: rfqID, Cusip: rfq.Cusip, Face: rfq.OriginalFace, Detail: sealed.QuoteHash})
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// RevealQuotes opens the calling seller's sealed quote on an RFQ once the deadline passed, after checking it against
+// the hash on the RFQ. Only the seller's peers hold the quote, so each seller reveals its own. Quotes left sealed cannot win
+func (s *SmartContract) RevealQuotes(ctx contractapi.TransactionContextInterface, rfqID string) (*WriteResponse, error) {
+	rfq, err := s.getOpenRFQ(ctx, rfqID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.requireRFQDeadlinePassed(ctx, rfq)
+	if err != nil {
+		return nil, err
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	var sealed *RFQQuote
+	for i := range rfq.Quotes {
+		if rfq.Quotes[i].SellerMSP == mspID {
+			sealed = &rfq.Quotes[i]
+			break
+		}
+	}
+	if sealed == nil {
+		return nil, fmt.Errorf("%s has not quoted on RFQ %s", mspID, rfqID)
+	}
+	if sealed.Revealed {
+		return nil, fmt.Errorf("the quote of %s on RFQ %s is already revealed", mspID, rfqID)
+	}
+
+	quoteKey, err := ctx.GetStub().CreateCompositeKey(sealedQuoteKeyType, []string{rfqID, mspID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+	quoteJSON, err := ctx.GetStub().GetPrivateData(implicitCollection(mspID), quoteKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quote: %v", err)
+	}
+	if quoteJSON == nil {
+		return nil, NewError(ErrNotFound, "quote of %s not found", mspID)
+	}
+
+	publicHash, err := hex.DecodeString(sealed.QuoteHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode quote hash: %v", err)
+	}
+	hash := sha256.Sum256(quoteJSON)
+	if !bytes.Equal(hash[:], publicHash) {
+		return nil, fmt.Errorf("quote of %s does not match its hash on the ledger", mspID)
+	}
+
+	var quote SealedQuote
+	err = json.Unmarshal(quoteJSON, &quote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal quote JSON: %v", err)
+	}
+
+	sealed.Revealed = true
+	sealed.SellerHash = quote.SellerHash
+	sealed.Price = quote.Price
+	err = s.putRecord(ctx, rfqObjectType, rfqID, rfq)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// AwardRFQ accepts the best revealed quote of an RFQ: the lowest price, and of equal prices the one submitted first.
+// Only the buyer can award it, once the deadline passed. The bond changes hands and a Transaction is recorded as for any other trade
+func (s *SmartContract) AwardRFQ(ctx contractapi.TransactionContextInterface, rfqID string, timestamp time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	rfq, err := s.getOpenRFQ(ctx, rfqID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.requireRFQDeadlinePassed(ctx, rfq)
+	if err != nil {
+		return nil, err
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	if mspID != rfq.BuyerMSP {
+		return nil, NewError(ErrUnauthorized, "you are not the buyer of RFQ %s", rfqID)
+	}
+
+	var best *RFQQuote
+	for i, quote := range rfq.Quotes {
+		if !quote.Revealed {
+			continue
+		}
+		if best == nil || quote.Price.value() < best.Price.value() ||
+			(quote.Price.value() == best.Price.value() && quote.SubmittedAt.Before(best.SubmittedAt)) {
+			best = &rfq.Quotes[i]
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no quote on RFQ %s has been revealed", rfqID)
+	}
+
+	ledger, err := s.getCusipLedger(ctx, rfq.Cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	// The buyer pays for the face of the RFQ, so a bond of exactly that face is delivered
+	bondIndex, err := reserveBond(ledger, s.ownerFor(ctx, best.SellerHash), rfq.Cusip, rfqID, rfq.OriginalFace)
+	if err != nil {
+		return nil, err
+	}
+	err = s.deliverFace(ctx, &ledger.Bonds[bondIndex], best.SellerHash, rfq.BuyerHash, rfq.OriginalFace, rfqID)
+	if err != nil {
+		return nil, err
+	}
+	releaseReservations(ledger, rfqID, "")
+
+	executed := len(ledger.Transactions)
+	transaction := s.GenerateTransactionObject(rfq.BuyerHash, best.SellerHash, rfq.Cusip, rfq.OriginalFace, string(best.Price), timestamp)
+	err = s.settleTransaction(ctx, ledger, transaction, best.Price.value())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	rfq.State = "Awarded"
+	rfq.AwardedTo = best.SellerMSP
+	err = s.putRecord(ctx, rfqObjectType, rfqID, rfq)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.recordOrderEvents(ctx, executionEvents(ledger, executed, "RFQ", rfqID)...)
+	if err != nil {
+		return nil, err
+	}
+	return newWriteResponse(ctx, best.SellerMSP)
+}
+
+// GetRFQ returns an RFQ with the hashes of its sealed quotes and the prices of the revealed ones
+func (s *SmartContract) GetRFQ(ctx contractapi.TransactionContextInterface, rfqID string) (*RFQ, error) {
+	var rfq RFQ
+	exists, err := s.getRecord(ctx, rfqObjectType, rfqID, &rfq)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, NewError(ErrNotFound, "RFQ %s not found", rfqID)
+	}
+
+	return &rfq, nil
+}
+
+// ⭐ Helper functions ⭐
+
+func (s *SmartContract) getOpenRFQ(ctx contractapi.TransactionContextInterface, rfqID string) (*RFQ, error) {
+	rfq, err := s.GetRFQ(ctx, rfqID)
+	if err != nil {
+		return nil, err
+	}
+	if rfq.State != "Open" {
+		return nil, fmt.Errorf("RFQ %s is %s", rfqID, rfq.State)
+	}
+
+	return rfq, nil
+}
+
+// requireRFQDeadlinePassed fails while the RFQ still takes quotes. The transaction timestamp is compared,
+// so that a client cannot open the quotes early by passing a later time
+func (s *SmartContract) requireRFQDeadlinePassed(ctx contractapi.TransactionContextInterface, rfq *RFQ) error {
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	if now.Before(rfq.Deadline) {
+		return fmt.Errorf("RFQ %s takes quotes until %v", rfq.RFQID, rfq.Deadline)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+// setUpRFQ returns a world where Org1 asks for quotes on tradeFace of testCusip until an hour after testTime
+func setUpRFQ(t *testing.T, contract *chaincode.SmartContract) *world {
+	w := setUp(t, contract)
+	_, err := contract.DepositCash(w.begin(org1), org1, "USD", 200000000)
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.CreateRFQ(w.begin(org1), "rfq1", org1, testCusip, tradeFace, testTime.Add(time.Hour), testTime)
+	require.NoError(t, err)
+	w.commit()
+	return w
+}
+
+// submitQuote seals a quote of Org2 on rfq1 under sellerHash
+func submitQuote(w *world, contract *chaincode.SmartContract, sellerHash string) error {
+	quoteJSON, err := json.Marshal(chaincode.SealedQuote{RFQID: "rfq1", SellerMSP: org2, SellerHash: sellerHash, Price: tradePrice, Salt: "salt"})
+	if err != nil {
+		return err
+	}
+	w.transient = map[string][]byte{"quote": quoteJSON}
+	_, err = contract.SubmitQuote(w.begin(org2), "rfq1", testTime)
+	return err
+}
+
+func TestAwardRFQ(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpRFQ(t, contract)
+	createBond(t, w, contract, "uid1", org2, testCusip, tradeFace/2)
+	createBond(t, w, contract, "uid2", org2, testCusip, tradeFace)
+
+	require.NoError(t, submitQuote(w, contract, org2))
+	w.commit()
+	closed := testTime.Add(2 * time.Hour)
+	_, err := contract.RevealQuotes(w.beginAt(org2, closed), "rfq1")
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.AwardRFQ(w.beginAt(org1, closed), "rfq1", closed)
+	require.NoError(t, err)
+	w.commit()
+
+	// Only the bond with the face of the RFQ is delivered
+	owners := map[string]string{}
+	bonds, err := contract.GetAllBonds(w.begin(org1), false)
+	require.NoError(t, err)
+	for _, bond := range bonds {
+		owners[bond.UID] = bond.OwnerHash
+	}
+	require.Equal(t, map[string]string{"uid1": org2, "uid2": org1}, owners)
+	requireCash(t, w, contract, 100500000, 99500000)
+}
+
+func TestAwardRFQErrors(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpRFQ(t, contract)
+	createBond(t, w, contract, "uid1", org2, testCusip, tradeFace/2)
+
+	// A seller can only quote under its own hash
+	err := submitQuote(w, contract, org1)
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "you are not the owner of Org1MSP"))
+
+	require.NoError(t, submitQuote(w, contract, org2))
+	w.commit()
+	closed := testTime.Add(2 * time.Hour)
+	_, err = contract.RevealQuotes(w.beginAt(org2, closed), "rfq1")
+	require.NoError(t, err)
+	w.commit()
+
+	// The seller only holds a bond of half the face asked for
+	_, err = contract.AwardRFQ(w.beginAt(org1, closed), "rfq1", closed)
+	require.EqualError(t, err, "the seller has no bond of Cusip 3132DWAR4 with an original face of 100000000")
+}
@@ -0,0 +1,287 @@
+package chaincode
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// IssuerContract exposes the issuance and maintenance functions of the SmartContract under its own namespace,
+// e.g. "IssuerContract:RegisterPool". Only the admin organizations can call it
+type IssuerContract struct {
+	SmartContract
+}
+
+// TraderContract exposes the trading functions of the SmartContract under its own namespace, e.g. "TraderContract:CreateTrade":
+// every function that is neither an issuer nor an auditor function. Any identified organization can call it
+type TraderContract struct {
+	SmartContract
+}
+
+// AuditorContract exposes the read-only functions over every party's bonds and transactions under its own namespace,
+// e.g. "AuditorContract:GetBondHistory". Only the admin organizations and the regulator can call it
+type AuditorContract struct {
+	SmartContract
+}
+
+// Functions of the IssuerContract
+var issuerFunctions = []string{
+	"CreateBondPublic",
+	"ImportBonds",
+	"ListBond",
+	"RegisterPool",
+	"UpdatePoolFactor",
+	"UpdateFactors",
+	"ProcessCorporateAction",
+	"SetBenchmarkPoint",
+	"UpdateConfig",
+	"SetFXRate",
+	"SetCashAgent",
+	"SetClockSkewTolerance",
+	"SetCollateralChaincode",
+	"SetPricePrecision",
+	"SetRegulator",
+	"RegisterCounterparty",
+	"SuspendCounterparty",
+	"RestoreBond",
+	"MigrateFaceToCents",
+	"MigrateCashToCents",
+	"MigrateLedgerLayout",
+	"ClearLedger",
+	"GetUsage",
+	"FindDuplicateBonds",
+}
+
+// Functions of the AuditorContract
+var auditorFunctions = []string{
+	"GetAllBonds",
+	"GetAllTransactions",
+	"GetLedger",
+	"GetTransactionsByParty",
+	"GetTransactionsByCusip",
+	"GetBondHistory",
+	"GetBondAsOf",
+	"GetBondEndorsementPolicy",
+	"GetComplianceFiling",
+	"GetAuditTrail",
+}
+
+// Read-only functions of the TraderContract and the IssuerContract, which Gateway clients evaluate on a peer instead of
+// submitting for ordering. Every other function of theirs writes to the ledger. All the AuditorContract functions are read-only
+var queryFunctions = []string{
+	"AccruedInterest",
+	"CheckDirectTrades",
+	"ComputeWAL",
+	"ComputeYield",
+	"ComputeNetObligations",
+	"ExportBondsCSV",
+	"ExportInventory",
+	"ExportOrderEvents",
+	"ExportTransactionsFIX",
+	"FindDuplicateBonds",
+	"FindInInventory",
+	"GenerateOrgHash",
+	"GenerateTransactionObject",
+	"GetAllYourBonds",
+	"GetAllocations",
+	"GetAuction",
+	"GetAxes",
+	"GetBenchmarkCurve",
+	"GetBond",
+	"GetBondsByCoupon",
+	"GetBondsByIssueYear",
+	"GetBondsByServicer",
+	"GetBondsByTag",
+	"GetCashAgent",
+	"GetCashBalance",
+	"GetCashObligations",
+	"GetClockSkewTolerance",
+	"GetCollateralChaincode",
+	"GetComplianceReport",
+	"GetConfig",
+	"GetConsensusPrice",
+	"GetCounterparty",
+	"GetCurrentOfferPrice",
+	"GetDecliningOffers",
+	"GetDirectTrade",
+	"GetFXRate",
+	"GetFactorHistory",
+	"GetIncomingInventoryTransfers",
+	"GetInventory",
+	"GetInventoryByStatus",
+	"GetInventoryPage",
+	"GetInventoryTransfer",
+	"GetInventoryValuation",
+	"GetLatestConsensusPrice",
+	"GetLatestMark",
+	"GetLedgerSummary",
+	"GetMarkHistory",
+	"GetMarket",
+	"GetOffers",
+	"GetOpenTradesForMyBonds",
+	"GetOrgProfile",
+	"GetPool",
+	"GetPositionReport",
+	"GetPricePrecision",
+	"GetRFMQuotes",
+	"GetRFQ",
+	"GetRepoInterest",
+	"GetRepoMarginCalls",
+	"GetSettlementStatus",
+	"GetTBATrade",
+	"GetTradeByReference",
+	"GetTransaction",
+	"GetUsage",
+	"GetWatchlist",
+	"GetWatchlistHits",
+	"GetYourDirectTrades",
+	"GetYourDistributions",
+	"GetYourOpenLoans",
+	"GetYourOpenRepos",
+	"GetYourPledges",
+	"IsOwner",
+	"NextPaymentDate",
+	"PriceFromYield",
+	"QueryBonds",
+	"QueryBondsWithPagination",
+	"ReadCounterOffers",
+	"ReadSettlementPackets",
+	"ReadTradeComments",
+	"SearchInventory",
+	"VerifyOwnershipProof",
+	"VerifySettlementPacketHash",
+	"VerifyTradeComments",
+}
+
+// ⭐ Functions ⭐
+
+// NewContracts returns the trader, issuer and auditor contracts, sharing the storage layout, to register together.
+// The trader contract comes first, so functions called without a namespace are trading functions
+func NewContracts(storageLayout string) []contractapi.ContractInterface {
+	return []contractapi.ContractInterface{
+		&TraderContract{SmartContract{StorageLayout: storageLayout}},
+		&IssuerContract{SmartContract{StorageLayout: storageLayout}},
+		&AuditorContract{SmartContract{StorageLayout: storageLayout}},
+	}
+}
+
+// GetIgnoredFunctions leaves out every function of the SmartContract but the issuer functions
+func (c *IssuerContract) GetIgnoredFunctions() []string {
+	return functionsExcept(issuerFunctions)
+}
+
+// GetEvaluateTransactions marks the read-only issuer functions, for clients to evaluate rather than submit
+func (c *IssuerContract) GetEvaluateTransactions() []string {
+	return queryFunctions
+}
+
+// GetBeforeTransaction returns the authorization run before every issuer function
+func (c *IssuerContract) GetBeforeTransaction() interface{} {
+	return c.authorize
+}
+
+// GetAfterTransaction returns the audit run after every issuer function that succeeded
+func (c *IssuerContract) GetAfterTransaction() interface{} {
+	return c.recordAuditEntry
+}
+
+// GetIgnoredFunctions leaves out the issuer and auditor functions
+func (c *TraderContract) GetIgnoredFunctions() []string {
+	return append(append([]string{}, issuerFunctions...), auditorFunctions...)
+}
+
+// GetEvaluateTransactions marks the read-only trader functions, for clients to evaluate rather than submit
+func (c *TraderContract) GetEvaluateTransactions() []string {
+	return queryFunctions
+}
+
+// GetBeforeTransaction returns the authorization run before every trader function
+func (c *TraderContract) GetBeforeTransaction() interface{} {
+	return c.authorize
+}
+
+// GetAfterTransaction returns the audit run after every trader function that succeeded
+func (c *TraderContract) GetAfterTransaction() interface{} {
+	return c.recordAuditEntry
+}
+
+// GetIgnoredFunctions leaves out every function of the SmartContract but the auditor functions
+func (c *AuditorContract) GetIgnoredFunctions() []string {
+	return functionsExcept(auditorFunctions)
+}
+
+// GetEvaluateTransactions marks every auditor function as read-only, for clients to evaluate rather than submit
+func (c *AuditorContract) GetEvaluateTransactions() []string {
+	return auditorFunctions
+}
+
+// GetBeforeTransaction returns the authorization run before every auditor function
+func (c *AuditorContract) GetBeforeTransaction() interface{} {
+	return c.authorize
+}
+
+// GetAfterTransaction returns the audit run after every auditor function that succeeded
+func (c *AuditorContract) GetAfterTransaction() interface{} {
+	return c.recordAuditEntry
+}
+
+// ⭐ Helper functions ⭐
+
+// authorize lets only the admin organizations issue and maintain bonds
+func (c *IssuerContract) authorize(ctx contractapi.TransactionContextInterface) error {
+	return c.requireAdmin(ctx)
+}
+
+// authorize lets any organization with an identity trade. Which organizations can endorse their trades is left
+// to the channel policy of the namespace
+func (c *TraderContract) authorize(ctx contractapi.TransactionContextInterface) error {
+	_, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	return nil
+}
+
+// authorize lets the admin organizations and the regulator audit every party's bonds and transactions
+func (c *AuditorContract) authorize(ctx contractapi.TransactionContextInterface) error {
+	return c.requireAuditor(ctx)
+}
+
+// requireAuditor checks that the caller is one of the admin organizations or the regulator
+func (s *SmartContract) requireAuditor(ctx contractapi.TransactionContextInterface) error {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	var regulator RegulatorConfig
+	exists, err := s.getRecord(ctx, configObjectType, regulatorConfigID, &regulator)
+	if err != nil {
+		return err
+	}
+	if exists && regulator.MSPID == mspID {
+		return nil
+	}
+	if s.requireAdmin(ctx) != nil {
+		return NewError(ErrUnauthorized, "only the admin organizations and the regulator can audit")
+	}
+
+	return nil
+}
+
+// functionsExcept returns the exported methods of the SmartContract that are not in functions
+func functionsExcept(functions []string) []string {
+	contractType := reflect.TypeOf(&SmartContract{})
+	excluded := []string{}
+	for i := 0; i < contractType.NumMethod(); i++ {
+		name := contractType.Method(i).Name
+		if !containsString(functions, name) {
+			excluded = append(excluded, name)
+		}
+	}
+
+	return excluded
+}
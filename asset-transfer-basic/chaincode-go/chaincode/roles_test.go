@@ -0,0 +1,84 @@
+package chaincode_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContractsCoverEveryFunction(t *testing.T) {
+	contracts := chaincode.NewContracts("")
+	_, err := chaincode.NewEnvelopeChaincode(contracts...)
+	require.NoError(t, err)
+
+	// Every function of the SmartContract is in exactly one contract
+	contractType := reflect.TypeOf(&chaincode.SmartContract{})
+	for i := 0; i < contractType.NumMethod(); i++ {
+		name := contractType.Method(i).Name
+		if _, ok := reflect.TypeOf(&contractapi.Contract{}).MethodByName(name); ok {
+			continue
+		}
+		exposed := 0
+		for _, contract := range contracts {
+			ignored := contract.(contractapi.IgnoreContractInterface).GetIgnoredFunctions()
+			if !containsName(ignored, name) {
+				exposed++
+			}
+		}
+		require.Equal(t, 1, exposed, name)
+	}
+}
+
+func TestEvaluateTransactions(t *testing.T) {
+	contracts := chaincode.NewContracts("")
+	evaluated := map[string]bool{}
+	for _, contract := range contracts {
+		ignored := contract.(contractapi.IgnoreContractInterface).GetIgnoredFunctions()
+		for _, name := range contract.(contractapi.EvaluationContractInterface).GetEvaluateTransactions() {
+			_, ok := reflect.TypeOf(&chaincode.SmartContract{}).MethodByName(name)
+			require.True(t, ok, name)
+			if !containsName(ignored, name) {
+				evaluated[name] = true
+			}
+		}
+	}
+
+	for _, name := range []string{"GetAllBonds", "GetBond", "GetLedger", "GetPositionReport", "GetUsage"} {
+		require.True(t, evaluated[name], name)
+	}
+	for _, name := range []string{"CreateTrade", "CreateBondPublic", "CheckRepoMargin"} {
+		require.False(t, evaluated[name], name)
+	}
+}
+
+func TestContractAuthorization(t *testing.T) {
+	contracts := chaincode.NewContracts("")
+	trader, issuer, auditor := contracts[0], contracts[1], contracts[2]
+	authorize := func(contract contractapi.ContractInterface, w *world, mspID string) error {
+		return contract.GetBeforeTransaction().(func(contractapi.TransactionContextInterface) error)(w.begin(mspID))
+	}
+
+	w := setUp(t, &chaincode.SmartContract{})
+	require.NoError(t, authorize(trader, w, org2))
+	require.NoError(t, authorize(issuer, w, org1))
+	require.EqualError(t, authorize(issuer, w, org2), contractError(chaincode.ErrUnauthorized, "only Org1MSP can run this function"))
+	require.NoError(t, authorize(auditor, w, org1))
+	require.EqualError(t, authorize(auditor, w, org2), contractError(chaincode.ErrUnauthorized, "only the admin organizations and the regulator can audit"))
+
+	_, err := issuer.(*chaincode.IssuerContract).SetRegulator(w.begin(org1), org2)
+	require.NoError(t, err)
+	w.commit()
+	require.NoError(t, authorize(auditor, w, org2))
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
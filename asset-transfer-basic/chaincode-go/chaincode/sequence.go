@@ -0,0 +1,124 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// OrderEvent is one order-related action of an organization, numbered in the order the organization took them
+type OrderEvent struct {
+	MSPID      string    `json:"mspID"`
+	Sequence   int       `json:"sequence"`  // Starts at 1 and increases by one with every action of the organization
+	Action     string    `json:"action"`    //"Create", "Cancel", "Answer", "Amend" or "Execute"
+	OrderType  string    `json:"orderType"` //"Trade", "Offer", "Declining", "RFM", "RFQ", "Auction" or "TBA"
+	OrderID    string    `json:"orderID"`
+	Cusip      string    `json:"cusip"`
+	Face       int64     `json:"face"` // In cents
+	Price      Price     `json:"price"`
+	Detail     string    `json:"detail,omitempty"`     // The answer value of a trade answer, the quote hash of an RFM answer, or the bid hash of an auction bid
+	BuyerHash  string    `json:"buyerHash,omitempty"`  // Set for executions
+	SellerHash string    `json:"sellerHash,omitempty"` // Set for executions
+	TxID       string    `json:"txID"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+const (
+	orderSequenceObjectType = "orderseq"
+	orderEventKeyType       = "orderevent"
+)
+
+// ⭐ Functions ⭐
+
+// ExportOrderEvents returns the caller's order events from the given sequence number on, in sequence order
+func (s *SmartContract) ExportOrderEvents(ctx contractapi.TransactionContextInterface, fromSequence int) ([]OrderEvent, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	// Sequence numbers are zero-padded in the keys, so the iterator returns them in order
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(orderEventKeyType, []string{mspID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order events: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	events := []OrderEvent{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over order events: %v", err)
+		}
+
+		var event OrderEvent
+		err = json.Unmarshal(queryResponse.Value, &event)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling order event JSON: %v", err)
+		}
+		if event.Sequence >= fromSequence {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// recordOrderEvents numbers the events with the caller's next sequence numbers and stores them.
+// The stored sequence numbers only advance when the transaction commits, so a function must record all its events in a single call
+func (s *SmartContract) recordOrderEvents(ctx contractapi.TransactionContextInterface, events ...OrderEvent) error {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	var sequence int
+	_, err = s.getRecord(ctx, orderSequenceObjectType, mspID, &sequence)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		sequence++
+		event.MSPID = mspID
+		event.Sequence = sequence
+		event.TxID = ctx.GetStub().GetTxID()
+		event.Timestamp = timestamp
+
+		err = s.putCompositeRecord(ctx, orderEventKeyType, []string{mspID, fmt.Sprintf("%010d", sequence)}, event)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.putRecord(ctx, orderSequenceObjectType, mspID, sequence)
+}
+
+// executionEvents returns an Execute event for each transaction added to the ledger from index from on
+func executionEvents(ledger *Ledger, from int, orderType, orderID string) []OrderEvent {
+	var events []OrderEvent
+	for _, transaction := range ledger.Transactions[from:] {
+		events = append(events, OrderEvent{
+			Action:     "Execute",
+			OrderType:  orderType,
+			OrderID:    orderID,
+			Cusip:      transaction.Cusip,
+			Face:       transaction.OriginalFace,
+			Price:      transaction.BoughtPrice,
+			BuyerHash:  transaction.BuyerID,
+			SellerHash: transaction.SellerID,
+		})
+	}
+
+	return events
+}
@@ -0,0 +1,289 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// SettlementInstructions is how one side of an agreed direct trade means to settle it. Each side states its own account
+// and the account it expects the other side to use, so that the two sets can be matched field by field
+type SettlementInstructions struct {
+	TradeID             string    `json:"tradeID"`
+	Side                string    `json:"side"` // "Buyer" or "Seller"
+	PartyHash           string    `json:"partyHash"`
+	Account             string    `json:"account"`             // The submitting side's account
+	CounterpartyAccount string    `json:"counterpartyAccount"` // The account the submitting side expects the other side to use
+	WireDetailsHash     string    `json:"wireDetailsHash"`     // Hash of the wire details both sides agreed on off-chain
+	SettleDate          string    `json:"settleDate"`          // YYYY-MM-DD
+	SubmittedAt         time.Time `json:"submittedAt"`
+}
+
+// SettlementMatch is the outcome of matching the instructions of both sides of a direct trade
+type SettlementMatch struct {
+	TradeID          string    `json:"tradeID"`
+	State            string    `json:"state"`            // "Pending" until both sides submitted, then "Matched" or "Mismatched"
+	MismatchedFields []string  `json:"mismatchedFields"` // Fields the two sides disagree on, for operations to chase
+	SettleDate       string    `json:"settleDate,omitempty"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+const (
+	settlementInstructionsObjectType = "settlementinstructions"
+	settlementMatchObjectType        = "settlementmatch"
+)
+
+// ⭐ Functions ⭐
+
+// SubmitSettlementInstructions records the caller's settlement instructions (as JSON) for a direct trade agreed by both sides,
+// when the contract settings require instructions. Once both sides submitted, they are matched: the trade becomes Matched
+// when they agree, and otherwise SettlementMismatched is raised with the fields that differ. Either side can resubmit until they match
+func (s *SmartContract) SubmitSettlementInstructions(ctx contractapi.TransactionContextInterface, tradeID, instructionsJSON string) (*WriteResponse, error) {
+	var instructions SettlementInstructions
+	err := json.Unmarshal([]byte(instructionsJSON), &instructions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal settlement instructions JSON: %v", err)
+	}
+	if instructions.Account == "" || instructions.CounterpartyAccount == "" || instructions.WireDetailsHash == "" {
+		return nil, fmt.Errorf("settlement instructions must have an account, a counterparty account and a wire details hash")
+	}
+	_, err = parseDate(instructions.SettleDate)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger, trade, answer, err := s.getAgreedTrade(ctx, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	if trade.State != "Agreed" {
+		return nil, fmt.Errorf("direct trade %s is already %s", tradeID, trade.State)
+	}
+	callerHash, err := s.getEncryptionKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	side, otherSide := "Buyer", "Seller"
+	if callerHash == answer.SellerIDHash {
+		side, otherSide = otherSide, side
+	} else if callerHash != trade.BidderHash {
+		return nil, NewError(ErrUnauthorized, "you are not a party to direct trade %s", tradeID)
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	instructions.TradeID = tradeID
+	instructions.Side = side
+	instructions.PartyHash = callerHash
+	instructions.SubmittedAt = now
+	err = s.putCompositeRecord(ctx, settlementInstructionsObjectType, []string{tradeID, side}, instructions)
+	if err != nil {
+		return nil, err
+	}
+
+	match := SettlementMatch{TradeID: tradeID, State: "Pending", MismatchedFields: []string{}, UpdatedAt: now}
+	var other SettlementInstructions
+	exists, err := s.getCompositeRecord(ctx, settlementInstructionsObjectType, []string{tradeID, otherSide}, &other)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		match.MismatchedFields = mismatchedFields(&instructions, &other)
+		match.State = "Mismatched"
+		if len(match.MismatchedFields) == 0 {
+			match.State = "Matched"
+			match.SettleDate = instructions.SettleDate
+		}
+	}
+	err = s.putRecord(ctx, settlementMatchObjectType, tradeID, match)
+	if err != nil {
+		return nil, err
+	}
+
+	switch match.State {
+	case "Matched":
+		err = directTradeLifecycle.move(tradeID, &trade.State, "Matched")
+		if err != nil {
+			return nil, err
+		}
+		err = s.updateLedger(ctx, ledger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update ledger: %v", err)
+		}
+		err = emitTradeEvent(ctx, TradeMatchedEvent, trade, answer.SellerIDHash, answer.BuyerResponse.CounterPrice, "")
+	case "Mismatched":
+		err = emitEvent(ctx, SettlementMismatchedEvent, match)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, match)
+}
+
+// GetSettlementStatus returns the outcome of matching the settlement instructions of a direct trade
+func (s *SmartContract) GetSettlementStatus(ctx contractapi.TransactionContextInterface, tradeID string) (*SettlementMatch, error) {
+	var match SettlementMatch
+	exists, err := s.getRecord(ctx, settlementMatchObjectType, tradeID, &match)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("no settlement instructions for direct trade %s", tradeID)
+	}
+
+	return &match, nil
+}
+
+// SettleMatchedTrade settles a direct trade whose settlement instructions matched, from their settle date on.
+// Either side can settle it. It is SettleTradeDvP under the name the settlement instructions flow introduced
+func (s *SmartContract) SettleMatchedTrade(ctx contractapi.TransactionContextInterface, tradeID string) (*WriteResponse, error) {
+	return s.SettleTradeDvP(ctx, tradeID)
+}
+
+// SettleTradeDvP settles a direct trade whose settlement instructions matched, from their settle date on, delivery versus
+// payment: the buyer's cash account is debited, the seller's credited and the bond delivered to the buyer in one transaction.
+// When either leg cannot complete, because the buyer is short of cash or the seller's bond is no longer held for the trade,
+// the transaction fails and neither leg happens. Either side can settle it
+func (s *SmartContract) SettleTradeDvP(ctx contractapi.TransactionContextInterface, tradeID string) (*WriteResponse, error) {
+	ledger, trade, answer, err := s.getAgreedTrade(ctx, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	if trade.State != "Matched" {
+		return nil, fmt.Errorf("the settlement instructions of direct trade %s have not matched", tradeID)
+	}
+	if !s.IsOwner(ctx, trade.BidderHash) && !s.IsOwner(ctx, answer.SellerIDHash) {
+		return nil, NewError(ErrUnauthorized, "you are not a party to direct trade %s", tradeID)
+	}
+
+	match, err := s.GetSettlementStatus(ctx, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if now.Format(markDateLayout) < match.SettleDate {
+		return nil, fmt.Errorf("direct trade %s settles on %s", tradeID, match.SettleDate)
+	}
+
+	executed := len(ledger.Transactions)
+	err = s.settleDirectTrade(ctx, ledger, trade, answer, now)
+	if err != nil {
+		return nil, err
+	}
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	err = s.recordOrderEvents(ctx, executionEvents(ledger, executed, "Trade", tradeID)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// ⭐ Helper functions ⭐
+
+// settleOrAgree settles a direct trade both sides said yes to or, when the contract settings require settlement instructions,
+// marks it Agreed until they match. The bond of the agreed answer stays held for the trade and the others are freed
+func (s *SmartContract) settleOrAgree(ctx contractapi.TransactionContextInterface, ledger *Ledger, trade *DirectTrade, answer *Answer, timestamp time.Time) error {
+	config, err := s.GetConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if !config.RequireInstructions {
+		return s.settleDirectTrade(ctx, ledger, trade, answer, timestamp)
+	}
+
+	err = directTradeLifecycle.move(trade.DirectTradeID, &trade.State, "Agreed")
+	if err != nil {
+		return err
+	}
+	for i := range trade.Answers {
+		if &trade.Answers[i] != answer {
+			releaseAnswer(ledger, trade.DirectTradeID, &trade.Answers[i])
+		}
+	}
+	// The bond pinned to the agreed answer is traded until the trade settles
+	bondIndex := findBondByUID(ledger, answer.BondUID)
+	if bondIndex != -1 && ledger.Bonds[bondIndex].Status == BondReserved {
+		err = bondLifecycle.move(answer.BondUID, &ledger.Bonds[bondIndex].Status, BondTraded)
+		if err != nil {
+			return err
+		}
+	}
+
+	return emitTradeEvent(ctx, TradeAgreedEvent, trade, answer.SellerIDHash, answer.BuyerResponse.CounterPrice, "")
+}
+
+// getAgreedTrade returns the ledger of a direct trade awaiting settlement, the trade and the answer both sides agreed on
+func (s *SmartContract) getAgreedTrade(ctx contractapi.TransactionContextInterface, tradeID string) (*Ledger, *DirectTrade, *Answer, error) {
+	ledger, err := s.getTradeLedger(ctx, tradeID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for i := range ledger.DirectTrades {
+		trade := &ledger.DirectTrades[i]
+		if trade.DirectTradeID != tradeID {
+			continue
+		}
+		if !trade.awaitingSettlement() {
+			return nil, nil, nil, fmt.Errorf("direct trade %s is not awaiting settlement", tradeID)
+		}
+		answer := trade.agreedAnswer()
+		if answer != nil {
+			return ledger, trade, answer, nil
+		}
+		return nil, nil, nil, fmt.Errorf("direct trade %s has no agreed answer", tradeID)
+	}
+
+	return nil, nil, nil, NewError(ErrNotFound, "direct trade not found")
+}
+
+// awaitingSettlement reports whether both sides agreed on the trade and it waits for its settlement instructions or settle date
+func (t *DirectTrade) awaitingSettlement() bool {
+	return t.State == "Agreed" || t.State == "Matched"
+}
+
+// agreedAnswer returns the answer both sides of a direct trade said yes to, or nil while there is none
+func (t *DirectTrade) agreedAnswer() *Answer {
+	for i := range t.Answers {
+		answer := &t.Answers[i]
+		if answer.SellerResponse.Value == "done" && answer.BuyerResponse.Value == "done" {
+			return answer
+		}
+	}
+
+	return nil
+}
+
+// mismatchedFields returns the fields two sets of settlement instructions disagree on.
+// Each side's account must be the account the other side expects
+func mismatchedFields(a, b *SettlementInstructions) []string {
+	fields := []string{}
+	if a.Account != b.CounterpartyAccount {
+		fields = append(fields, a.Side+".account")
+	}
+	if b.Account != a.CounterpartyAccount {
+		fields = append(fields, b.Side+".account")
+	}
+	if a.WireDetailsHash != b.WireDetailsHash {
+		fields = append(fields, "wireDetailsHash")
+	}
+	if a.SettleDate != b.SettleDate {
+		fields = append(fields, "settleDate")
+	}
+
+	return fields
+}
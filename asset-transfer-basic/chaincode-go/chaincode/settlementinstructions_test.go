@@ -0,0 +1,195 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	buyerInstructions  = `{"account":"ORG1-001","counterpartyAccount":"ORG2-001","wireDetailsHash":"abc123","settleDate":"2023-01-10"}`
+	sellerInstructions = `{"account":"ORG2-001","counterpartyAccount":"ORG1-001","wireDetailsHash":"abc123","settleDate":"2023-01-10"}`
+)
+
+func TestSettlementInstructionMatching(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUpTrade(t, contract, 200000000)
+			_, err := contract.UpdateConfig(w.begin(org1), `{"allowedCouponTypes":["FIXED"],"settlementCurrency":"USD","adminMSPs":["Org1MSP"],"requireInstructions":true}`)
+			require.NoError(t, err)
+			w.commit()
+
+			// Both sides agree, but nothing settles until the instructions match
+			_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.AnswerTradeAsOwner(w.begin(org1), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			require.Contains(t, w.events(), chaincode.TradeAgreedEvent)
+			require.NotContains(t, w.events(), chaincode.TradeSettledEvent)
+			w.commit()
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Equal(t, chaincode.BondTraded, bonds[0].Status)
+
+			_, err = contract.SubmitSettlementInstructions(w.begin(org1), "trade1", buyerInstructions)
+			require.NoError(t, err)
+			w.commit()
+			response, err := contract.SubmitSettlementInstructions(w.begin(org2), "trade1", `{"account":"ORG2-001","counterpartyAccount":"ORG1-999","wireDetailsHash":"abc123","settleDate":"2023-01-11"}`)
+			require.NoError(t, err)
+			require.Contains(t, w.events(), chaincode.SettlementMismatchedEvent)
+			mismatch := response.Result.(chaincode.SettlementMatch)
+			require.Equal(t, "Mismatched", mismatch.State)
+			require.Equal(t, []string{"Buyer.account", "settleDate"}, mismatch.MismatchedFields)
+			w.commit()
+
+			_, err = contract.SubmitSettlementInstructions(w.begin(org2), "trade1", sellerInstructions)
+			require.NoError(t, err)
+			require.Contains(t, w.events(), chaincode.TradeMatchedEvent)
+			w.commit()
+
+			_, err = contract.SettleMatchedTrade(w.begin(org1), "trade1")
+			require.EqualError(t, err, "direct trade trade1 settles on 2023-01-10")
+
+			_, err = contract.SettleMatchedTrade(w.beginAt(org2, testTime.Add(24*time.Hour)), "trade1")
+			require.NoError(t, err)
+			require.Contains(t, w.events(), chaincode.TradeSettledEvent)
+			w.commit()
+
+			bonds, err = contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Equal(t, org1, bonds[0].OwnerHash)
+			require.Empty(t, bonds[0].ReservedFor)
+			require.Equal(t, chaincode.BondSettled, bonds[0].Status)
+		})
+	}
+}
+
+func TestUnmatchedAgreedTrade(t *testing.T) {
+	tests := []struct {
+		name  string
+		end   func(contract *chaincode.SmartContract, w *world) error
+		state string
+	}{
+		{
+			name: "cancelled by the bidder",
+			end: func(contract *chaincode.SmartContract, w *world) error {
+				_, err := contract.CloseDirectTrade(w.begin(org1), "trade2")
+				return err
+			},
+			state: "Cancelled",
+		},
+		{
+			name: "expired",
+			end: func(contract *chaincode.SmartContract, w *world) error {
+				_, err := contract.ExpireStaleTrades(w.beginAt(org2, testTime.Add(2*time.Hour)))
+				return err
+			},
+			state: "Expired",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract := &chaincode.SmartContract{}
+			w := setUp(t, contract)
+			createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+			_, err := contract.UpdateConfig(w.begin(org1), `{"allowedCouponTypes":["FIXED"],"settlementCurrency":"USD","adminMSPs":["Org1MSP"],"requireInstructions":true}`)
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.CreateTrade(w.begin(org1), "trade2", org1, testCusip, "2023-01-09T12:00:00Z", tradeFace, tradePrice, false, "2023-01-09T13:00:00Z")
+			require.NoError(t, err)
+			w.commit()
+
+			// The trade is agreed, but the instructions of both sides never match
+			_, err = contract.AnswerTrade(w.begin(org2), "trade2", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.AnswerTradeAsOwner(w.begin(org1), "trade2", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.SubmitSettlementInstructions(w.begin(org1), "trade2", buyerInstructions)
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.SubmitSettlementInstructions(w.begin(org2), "trade2", `{"account":"ORG2-001","counterpartyAccount":"ORG1-999","wireDetailsHash":"abc123","settleDate":"2023-01-10"}`)
+			require.NoError(t, err)
+			w.commit()
+
+			require.NoError(t, test.end(contract, w))
+			w.commit()
+
+			trade, err := contract.GetDirectTrade(w.begin(org1), "trade2")
+			require.NoError(t, err)
+			require.Equal(t, test.state, trade.State)
+			bonds, err := contract.GetAllBonds(w.begin(org2), false)
+			require.NoError(t, err)
+			require.Equal(t, org2, bonds[0].OwnerHash)
+			require.Empty(t, bonds[0].ReservedFor)
+			require.Equal(t, chaincode.BondListed, bonds[0].Status)
+		})
+	}
+}
+
+func TestSubmitSettlementInstructionsErrors(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpTrade(t, contract, 200000000)
+
+	_, err := contract.SubmitSettlementInstructions(w.begin(org1), "trade1", buyerInstructions)
+	require.EqualError(t, err, "direct trade trade1 is not awaiting settlement")
+
+	_, err = contract.SubmitSettlementInstructions(w.begin(org1), "trade1", `{"account":"ORG1-001","settleDate":"2023-01-10"}`)
+	require.EqualError(t, err, "settlement instructions must have an account, a counterparty account and a wire details hash")
+}
+
+func TestSettleTradeDvP(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUpTrade(t, contract, 200000000)
+			_, err := contract.UpdateConfig(w.begin(org1), `{"allowedCouponTypes":["FIXED"],"settlementCurrency":"USD","adminMSPs":["Org1MSP"],"requireInstructions":true}`)
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.AnswerTradeAsOwner(w.begin(org1), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.SubmitSettlementInstructions(w.begin(org1), "trade1", buyerInstructions)
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.SubmitSettlementInstructions(w.begin(org2), "trade1", sellerInstructions)
+			require.NoError(t, err)
+			w.commit()
+
+			// The cash agent burns most of the buyer's cash, so the payment leg fails and the bond stays with the seller
+			_, err = contract.WithdrawCash(w.begin(org1), org1, "USD", 150000000)
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.SettleTradeDvP(w.beginAt(org2, testTime.Add(24*time.Hour)), "trade1")
+			require.ErrorContains(t, err, "insufficient cash")
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Equal(t, org2, bonds[0].OwnerHash)
+			require.Equal(t, "trade1", bonds[0].ReservedFor)
+			requireCash(t, w, contract, 50000000, 0)
+
+			// Once cash is minted back, both legs settle together
+			_, err = contract.DepositCash(w.begin(org1), org1, "USD", 150000000)
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.SettleTradeDvP(w.beginAt(org2, testTime.Add(24*time.Hour)), "trade1")
+			require.NoError(t, err)
+			require.Contains(t, w.events(), chaincode.TradeSettledEvent)
+			w.commit()
+
+			bonds, err = contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Equal(t, org1, bonds[0].OwnerHash)
+			requireCash(t, w, contract, 100500000, 99500000)
+		})
+	}
+}
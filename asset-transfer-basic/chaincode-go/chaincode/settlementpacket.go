@@ -0,0 +1,206 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// TradeSettlementPacket is what one side of an agreed direct trade privately hands the other to settle it: the account and
+// wire details behind its public settlement instructions. It is kept in both sides' implicit collections
+type TradeSettlementPacket struct {
+	TradeID     string `json:"tradeID"`
+	Side        string `json:"side"` // Side sending the packet, "Buyer" or "Seller"
+	FromMSP     string `json:"fromMSP"`
+	ToMSP       string `json:"toMSP"`
+	Account     string `json:"account"`
+	WireDetails string `json:"wireDetails"`
+	Salt        string `json:"salt"` // Keeps the hash from being matched against likely details
+}
+
+// SettlementPacketHash is the public trace of a settlement packet. Its hash lets either side prove in a dispute
+// which details were sent, without the details ever being public
+type SettlementPacketHash struct {
+	TradeID  string    `json:"tradeID"`
+	Side     string    `json:"side"`
+	FromMSP  string    `json:"fromMSP"`
+	ToMSP    string    `json:"toMSP"`
+	Hash     string    `json:"hash"` // SHA-256 of the packet JSON as it was passed, in hex
+	SharedAt time.Time `json:"sharedAt"`
+}
+
+const (
+	settlementPacketKeyType          = "settlementpacket"
+	settlementPacketHashObjectType   = "settlementpackethash"
+	settlementPacketTransientFieldID = "settlementpacket"
+)
+
+// ⭐ Functions ⭐
+
+// ShareSettlementPacket hands the other side of a direct trade awaiting settlement the caller's settlement packet, passed as JSON
+// in the "settlementpacket" transient field. The packet goes to the implicit collections of both sides and its hash to the ledger,
+// and SettlementPacketShared is raised. Sharing again replaces the caller's packet until the trade settles
+func (s *SmartContract) ShareSettlementPacket(ctx contractapi.TransactionContextInterface, tradeID string) (*WriteResponse, error) {
+	_, trade, answer, err := s.getAgreedTrade(ctx, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	side, err := s.settlementSide(ctx, trade, answer)
+	if err != nil {
+		return nil, err
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("error getting transient: %v", err)
+	}
+	packetJSON, ok := transientMap[settlementPacketTransientFieldID]
+	if !ok {
+		return nil, NewError(ErrInvalidInput, "settlementpacket key not found in the transient map")
+	}
+
+	var packet TradeSettlementPacket
+	err = json.Unmarshal(packetJSON, &packet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal settlement packet JSON: %v", err)
+	}
+	if packet.TradeID != tradeID || packet.Side != side || packet.FromMSP != mspID {
+		return nil, fmt.Errorf("the settlement packet must be for direct trade %s from the %s side, %s", tradeID, side, mspID)
+	}
+	if packet.ToMSP == "" || packet.ToMSP == mspID {
+		return nil, fmt.Errorf("the settlement packet must be sent to the other side")
+	}
+	if packet.Account == "" || packet.WireDetails == "" || packet.Salt == "" {
+		return nil, fmt.Errorf("the settlement packet must have an account, wire details and a salt")
+	}
+
+	packetKey, err := ctx.GetStub().CreateCompositeKey(settlementPacketKeyType, []string{tradeID, side})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	// The hash is verified against the packet bytes, so they are stored as they were passed
+	for _, collection := range []string{implicitCollection(mspID), implicitCollection(packet.ToMSP)} {
+		err = ctx.GetStub().PutPrivateData(collection, packetKey, packetJSON)
+		if err != nil {
+			return nil, fmt.Errorf("%s - failed to put settlement packet: %v", collection, err)
+		}
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(packetJSON)
+	record := SettlementPacketHash{
+		TradeID:  tradeID,
+		Side:     side,
+		FromMSP:  mspID,
+		ToMSP:    packet.ToMSP,
+		Hash:     hex.EncodeToString(hash[:]),
+		SharedAt: now,
+	}
+	err = s.putCompositeRecord(ctx, settlementPacketHashObjectType, []string{tradeID, side}, record)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, SettlementPacketSharedEvent, record)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, record)
+}
+
+// ReadSettlementPackets returns the settlement packets of a direct trade the caller sent or received, from its implicit collection.
+// Only the two sides of the trade can read them
+func (s *SmartContract) ReadSettlementPackets(ctx contractapi.TransactionContextInterface, tradeID string) ([]TradeSettlementPacket, error) {
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	trade, err := stores.Trades.GetDirectTrade(tradeID)
+	if err != nil {
+		return nil, err
+	}
+	if trade == nil {
+		return nil, NewError(ErrNotFound, "direct trade not found")
+	}
+	answer := trade.agreedAnswer()
+	if !s.IsOwner(ctx, trade.BidderHash) && (answer == nil || !s.IsOwner(ctx, answer.SellerIDHash)) {
+		return nil, NewError(ErrUnauthorized, "you are not a party to direct trade %s", tradeID)
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(implicitCollection(mspID), settlementPacketKeyType, []string{tradeID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settlement packets: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	packets := []TradeSettlementPacket{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over settlement packets: %v", err)
+		}
+
+		var packet TradeSettlementPacket
+		err = json.Unmarshal(queryResponse.Value, &packet)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling settlement packet JSON: %v", err)
+		}
+		packets = append(packets, packet)
+	}
+
+	return packets, nil
+}
+
+// VerifySettlementPacketHash reports whether a settlement packet JSON is the one a side of a direct trade shared, by its hash
+// on the ledger. It settles disputes over which details were sent: anyone shown the packet can check it
+func (s *SmartContract) VerifySettlementPacketHash(ctx contractapi.TransactionContextInterface, tradeID, side, packetJSON string) (bool, error) {
+	var record SettlementPacketHash
+	exists, err := s.getCompositeRecord(ctx, settlementPacketHashObjectType, []string{tradeID, side}, &record)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, NewError(ErrNotFound, "no settlement packet of the %s side of direct trade %s", side, tradeID)
+	}
+
+	hash := sha256.Sum256([]byte(packetJSON))
+	return hex.EncodeToString(hash[:]) == record.Hash, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// settlementSide returns the side of an agreed direct trade the caller is on, "Buyer" or "Seller"
+func (s *SmartContract) settlementSide(ctx contractapi.TransactionContextInterface, trade *DirectTrade, answer *Answer) (string, error) {
+	callerHash, err := s.getEncryptionKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	switch callerHash {
+	case trade.BidderHash:
+		return "Buyer", nil
+	case answer.SellerIDHash:
+		return "Seller", nil
+	}
+
+	return "", NewError(ErrUnauthorized, "you are not a party to direct trade %s", trade.DirectTradeID)
+}
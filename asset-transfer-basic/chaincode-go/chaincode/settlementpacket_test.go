@@ -0,0 +1,91 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+const sellerPacket = `{"tradeID":"trade1","side":"Seller","fromMSP":"Org2MSP","toMSP":"Org1MSP","account":"ORG2-001","wireDetails":"ABA 021000021 ACCT 12345","salt":"s1"}`
+
+// setUpAgreedTrade returns a world where both sides agreed on trade1 and it waits for its settlement instructions
+func setUpAgreedTrade(t *testing.T, contract *chaincode.SmartContract) *world {
+	w := setUpTrade(t, contract, 200000000)
+	_, err := contract.UpdateConfig(w.begin(org1), `{"allowedCouponTypes":["FIXED"],"settlementCurrency":"USD","adminMSPs":["Org1MSP"],"requireInstructions":true}`)
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.AnswerTradeAsOwner(w.begin(org1), "trade1", org2, "done", testTime, "")
+	require.NoError(t, err)
+	w.commit()
+	return w
+}
+
+func TestShareSettlementPacket(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUpAgreedTrade(t, contract)
+
+			w.transient = map[string][]byte{"settlementpacket": []byte(sellerPacket)}
+			response, err := contract.ShareSettlementPacket(w.begin(org2), "trade1")
+			require.NoError(t, err)
+			require.Contains(t, w.events(), chaincode.SettlementPacketSharedEvent)
+			record := response.Result.(chaincode.SettlementPacketHash)
+			require.Equal(t, "Seller", record.Side)
+			require.Equal(t, org1, record.ToMSP)
+			w.commit()
+
+			// The buyer reads the packet from its own collection
+			packets, err := contract.ReadSettlementPackets(w.begin(org1), "trade1")
+			require.NoError(t, err)
+			require.Len(t, packets, 1)
+			require.Equal(t, "ORG2-001", packets[0].Account)
+			packets, err = contract.ReadSettlementPackets(w.begin(org2), "trade1")
+			require.NoError(t, err)
+			require.Len(t, packets, 1)
+
+			verified, err := contract.VerifySettlementPacketHash(w.begin(org1), "trade1", "Seller", sellerPacket)
+			require.NoError(t, err)
+			require.True(t, verified)
+			verified, err = contract.VerifySettlementPacketHash(w.begin(org1), "trade1", "Seller", `{"tradeID":"trade1","side":"Seller","account":"ORG2-999"}`)
+			require.NoError(t, err)
+			require.False(t, verified)
+		})
+	}
+}
+
+func TestShareSettlementPacketErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		mspID     string
+		transient map[string][]byte
+		err       string
+	}{
+		{name: "no packet", mspID: org2, err: contractError(chaincode.ErrInvalidInput, "settlementpacket key not found in the transient map")},
+		{name: "the other side's packet", mspID: org1, transient: map[string][]byte{"settlementpacket": []byte(sellerPacket)}, err: "the settlement packet must be for direct trade trade1 from the Buyer side, Org1MSP"},
+		{name: "sent to itself", mspID: org2, transient: map[string][]byte{"settlementpacket": []byte(`{"tradeID":"trade1","side":"Seller","fromMSP":"Org2MSP","toMSP":"Org2MSP","account":"ORG2-001","wireDetails":"x","salt":"s1"}`)}, err: "the settlement packet must be sent to the other side"},
+		{name: "without a salt", mspID: org2, transient: map[string][]byte{"settlementpacket": []byte(`{"tradeID":"trade1","side":"Seller","fromMSP":"Org2MSP","toMSP":"Org1MSP","account":"ORG2-001","wireDetails":"x"}`)}, err: "the settlement packet must have an account, wire details and a salt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract := &chaincode.SmartContract{}
+			w := setUpAgreedTrade(t, contract)
+
+			w.transient = test.transient
+			_, err := contract.ShareSettlementPacket(w.begin(test.mspID), "trade1")
+			require.EqualError(t, err, test.err)
+		})
+	}
+
+	contract := &chaincode.SmartContract{}
+	w := setUpTrade(t, contract, 200000000)
+	_, err := contract.ShareSettlementPacket(w.begin(org2), "trade1")
+	require.EqualError(t, err, "direct trade trade1 is not awaiting settlement")
+	_, err = contract.VerifySettlementPacketHash(w.begin(org1), "trade1", "Seller", sellerPacket)
+	require.EqualError(t, err, contractError(chaincode.ErrNotFound, "no settlement packet of the Seller side of direct trade trade1"))
+}
@@ -0,0 +1,265 @@
+package chaincode
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Functions ⭐
+
+// TransferShare transfers faceAmount (in cents of current face) of the caller's share of a bond to another party, which makes
+// the bond syndicated: owned pro rata by the holders of its OwnershipShares, which always sum to its current face.
+// A syndicated bond cannot be traded whole, only shares of it in direct trades, and the shares follow factor updates
+func (s *SmartContract) TransferShare(ctx contractapi.TransactionContextInterface, uid, toOwner string, faceAmount int64) (*WriteResponse, error) {
+	if toOwner == "" {
+		return nil, fmt.Errorf("the new owner cannot be empty")
+	}
+	if faceAmount <= 0 {
+		return nil, fmt.Errorf("the face to transfer must be positive: %d", faceAmount)
+	}
+
+	ledger, err := s.getBondLedger(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	bondIndex := findBondByUID(ledger, uid)
+	if bondIndex == -1 {
+		return nil, NewError(ErrNotFound, "bond with UID %s not found", uid)
+	}
+	bond := &ledger.Bonds[bondIndex]
+
+	owner, err := s.callerOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	held := owner.share(bond)
+	if held == 0 {
+		return nil, fmt.Errorf("you do not hold a share of the bond")
+	}
+	if toOwner == owner.hash {
+		return nil, fmt.Errorf("you cannot transfer a share to yourself")
+	}
+	if bond.ReservedFor != "" {
+		return nil, NewConflictError(bond.UID, bond.Cusip, bond.ReservedFor)
+	}
+	if !bond.tradable() {
+		return nil, fmt.Errorf("bond %s is %s", bond.UID, bond.Status)
+	}
+	err = s.requireActivePool(ctx, bond.Cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	// The first transfer splits the bond. Shares are always held under party hashes, even for a bond committed to its owner
+	if !bond.syndicated() {
+		bond.OwnershipShares = map[string]int64{owner.hash: held}
+		bond.OwnerHash = owner.hash
+	}
+	err = bond.moveShare(owner.hash, toOwner, faceAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	transaction := s.GenerateTransactionObject(toOwner, owner.hash, bond.Cusip, faceAmount, "", timestamp)
+	transaction.Type = "ShareTransfer"
+	appendTransaction(ctx, ledger, transaction)
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+	err = emitShareTransferred(ctx, bond, owner.hash, toOwner, faceAmount, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, bond.OwnershipShares)
+}
+
+// ⭐ Helper functions ⭐
+
+// syndicated reports whether the bond is owned in shares rather than whole by its OwnerHash
+func (bond *AgencyMBSPassthrough) syndicated() bool {
+	return len(bond.OwnershipShares) > 0
+}
+
+// shareFace returns the face the shares of the bond sum to: its current face or, before any factor update, its original face
+func (bond *AgencyMBSPassthrough) shareFace() int64 {
+	if bond.CurrentFace > 0 {
+		return bond.CurrentFace
+	}
+
+	return bond.OriginalFace
+}
+
+// deliverable reports whether a seller holding held of the bond can deliver face for a trade. A whole bond is delivered when
+// face is 0 or its original face, while a syndicated bond only delivers a face its seller's share covers
+func (bond *AgencyMBSPassthrough) deliverable(held, face int64) bool {
+	if bond.syndicated() {
+		return face != 0 && held >= face
+	}
+
+	return face == 0 || bond.OriginalFace == face
+}
+
+// moveShare moves face from one holder's share to another's and checks that the shares still sum to the current face.
+// The OwnerHash of a syndicated bond is its largest holder, and a bond left with a single holder is owned whole again
+func (bond *AgencyMBSPassthrough) moveShare(fromHash, toHash string, face int64) error {
+	if bond.OwnershipShares[fromHash] < face {
+		return fmt.Errorf("%s holds %d of bond %s, not %d", fromHash, bond.OwnershipShares[fromHash], bond.UID, face)
+	}
+	bond.OwnershipShares[fromHash] -= face
+	if bond.OwnershipShares[fromHash] == 0 {
+		delete(bond.OwnershipShares, fromHash)
+	}
+	bond.OwnershipShares[toHash] += face
+
+	var total int64
+	for _, share := range bond.OwnershipShares {
+		total += share
+	}
+	if total != bond.shareFace() {
+		return fmt.Errorf("the shares of bond %s sum to %d, not its current face of %d", bond.UID, total, bond.shareFace())
+	}
+
+	holders := bond.shareHolders()
+	if len(holders) == 1 {
+		bond.OwnerHash = holders[0]
+		bond.OwnershipShares = nil
+		return nil
+	}
+	if _, ok := bond.OwnershipShares[bond.OwnerHash]; !ok {
+		bond.OwnerHash = holders[0]
+		for _, holder := range holders {
+			if bond.OwnershipShares[holder] > bond.OwnershipShares[bond.OwnerHash] {
+				bond.OwnerHash = holder
+			}
+		}
+	}
+
+	return nil
+}
+
+// rescaleShares scales the shares of a syndicated bond pro rata after its current face changed from priorFace.
+// The rounding difference goes to the largest holder, so that the shares still sum to the current face
+func (bond *AgencyMBSPassthrough) rescaleShares(priorFace int64) {
+	if !bond.syndicated() || priorFace == 0 {
+		return
+	}
+
+	face := bond.shareFace()
+	var total int64
+	for _, holder := range bond.shareHolders() {
+		bond.OwnershipShares[holder] = bond.OwnershipShares[holder] * face / priorFace
+		total += bond.OwnershipShares[holder]
+	}
+	bond.OwnershipShares[bond.OwnerHash] += face - total
+}
+
+// splitPiece cuts a piece of the given original face off the bond, under a new UID. The current face is shared pro rata
+// between the piece and what is left of the bond. The piece is owned whole, and the shares of a syndicated bond are rescaled to what is left
+func (bond *AgencyMBSPassthrough) splitPiece(uid string, face int64) AgencyMBSPassthrough {
+	priorFace := bond.shareFace()
+	piece := *bond
+	piece.UID = uid
+	piece.OriginalFace = face
+	piece.OwnershipShares = nil
+	if bond.CurrentFace != 0 {
+		piece.CurrentFace = int64(math.Round(float64(bond.CurrentFace) * float64(face) / float64(bond.OriginalFace)))
+		bond.CurrentFace -= piece.CurrentFace
+	}
+	bond.OriginalFace -= face
+	bond.rescaleShares(priorFace)
+
+	return piece
+}
+
+// holdings returns the original face each holder of the bond owns: the whole of it for its OwnerHash,
+// or pro rata to the shares of a syndicated bond, in holder order
+func (bond *AgencyMBSPassthrough) holdings() ([]string, map[string]int64) {
+	if !bond.syndicated() {
+		return []string{bond.OwnerHash}, map[string]int64{bond.OwnerHash: bond.OriginalFace}
+	}
+
+	holders := bond.shareHolders()
+	faces := map[string]int64{}
+	var total int64
+	for _, holder := range holders {
+		faces[holder] = bond.OriginalFace * bond.OwnershipShares[holder] / bond.shareFace()
+		total += faces[holder]
+	}
+	faces[bond.OwnerHash] += bond.OriginalFace - total
+
+	return holders, faces
+}
+
+// shareHolders returns the holders of the shares of the bond, sorted so that iterating over them is deterministic
+func (bond *AgencyMBSPassthrough) shareHolders() []string {
+	holders := []string{}
+	for holder := range bond.OwnershipShares {
+		holders = append(holders, holder)
+	}
+	sort.Strings(holders)
+
+	return holders
+}
+
+// share returns the face of the bond the organization holds: its share of a syndicated bond, or the whole current face of a bond it owns
+func (o bondOwner) share(bond *AgencyMBSPassthrough) int64 {
+	if bond.syndicated() {
+		return bond.OwnershipShares[o.hash]
+	}
+	if o.owns(bond) {
+		return bond.shareFace()
+	}
+
+	return 0
+}
+
+// holdsPosition checks if the owner holds a tradable bond of the cusip, whole or in shares
+func holdsPosition(ledger *Ledger, owner bondOwner, cusip string) bool {
+	for i, bond := range ledger.Bonds {
+		if bond.Cusip == cusip && bond.tradable() && owner.share(&ledger.Bonds[i]) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// emitShareTransferred raises BondTransferred for a share of a syndicated bond that moved from one holder to another
+func emitShareTransferred(ctx contractapi.TransactionContextInterface, bond *AgencyMBSPassthrough, fromHash, toHash string, face int64, orderID string) error {
+	return emitEvent(ctx, BondTransferredEvent, BondTransferEvent{
+		UID:      bond.UID,
+		Cusip:    bond.Cusip,
+		FromHash: fromHash,
+		ToHash:   toHash,
+		Face:     face,
+		OrderID:  orderID,
+	})
+}
+
+// deliverFace hands the buyer the face it bought of a bond held for a trade: the share of a syndicated bond, or else the whole bond.
+// It raises BondTransferred for what moved
+func (s *SmartContract) deliverFace(ctx contractapi.TransactionContextInterface, bond *AgencyMBSPassthrough, sellerHash, buyerHash string, face int64, orderID string) error {
+	if bond.syndicated() {
+		err := bond.moveShare(sellerHash, buyerHash, face)
+		if err != nil {
+			return err
+		}
+		return emitShareTransferred(ctx, bond, sellerHash, buyerHash, face, orderID)
+	}
+
+	err := bond.deliver(s.ownerHashFor(ctx, buyerHash, bond.UID))
+	if err != nil {
+		return err
+	}
+	return emitBondTransferred(ctx, *bond, sellerHash, orderID)
+}
@@ -0,0 +1,88 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferShare(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUp(t, contract)
+			createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+
+			_, err := contract.TransferShare(w.begin(org2), "uid1", org1, 40000000)
+			require.NoError(t, err)
+			require.Contains(t, w.events(), chaincode.BondTransferredEvent)
+			w.commit()
+
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Equal(t, map[string]int64{org1: 40000000, org2: 60000000}, bonds[0].OwnershipShares)
+			require.Equal(t, org2, bonds[0].OwnerHash)
+
+			// No one can move a syndicated bond whole
+			_, err = contract.TransferBond(w.begin(org2), "uid1", org1)
+			require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "you are not the owner of the bond"))
+
+			// A direct trade for part of Org2's share moves only that part
+			_, err = contract.DepositCash(w.begin(org1), org1, "USD", 200000000)
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.CreateTrade(w.begin(org1), "trade1", org1, testCusip, "2023-01-09T12:00:00Z", 20000000, tradePrice, false, "")
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.AnswerTradeAsOwner(w.begin(org1), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			require.Contains(t, w.events(), chaincode.TradeSettledEvent)
+			w.commit()
+
+			bonds, err = contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Equal(t, map[string]int64{org1: 60000000, org2: 40000000}, bonds[0].OwnershipShares)
+			require.Empty(t, bonds[0].ReservedFor)
+
+			// A bond left with a single holder is owned whole again
+			_, err = contract.TransferShare(w.begin(org2), "uid1", org1, 40000000)
+			require.NoError(t, err)
+			w.commit()
+
+			bonds, err = contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Empty(t, bonds[0].OwnershipShares)
+			require.Equal(t, org1, bonds[0].OwnerHash)
+		})
+	}
+}
+
+func TestTransferShareErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		mspID string
+		to    string
+		face  int64
+		err   string
+	}{
+		{name: "more than the share", mspID: org2, to: org1, face: tradeFace + 1, err: "Org2MSP holds 100000000 of bond uid1, not 100000001"},
+		{name: "no share", mspID: org1, to: org2, face: 100, err: "you do not hold a share of the bond"},
+		{name: "to itself", mspID: org2, to: org2, face: 100, err: "you cannot transfer a share to yourself"},
+		{name: "nothing", mspID: org2, to: org1, face: 0, err: "the face to transfer must be positive: 0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract := &chaincode.SmartContract{}
+			w := setUp(t, contract)
+			createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+
+			_, err := contract.TransferShare(w.begin(test.mspID), "uid1", test.to, test.face)
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
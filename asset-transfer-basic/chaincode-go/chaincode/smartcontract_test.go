@@ -0,0 +1,376 @@
+package chaincode_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//go:generate counterfeiter -o mocks/transaction.go -fake-name TransactionContext . transactionContext
+type transactionContext interface {
+	contractapi.TransactionContextInterface
+}
+
+//go:generate counterfeiter -o mocks/chaincodestub.go -fake-name ChaincodeStub . chaincodeStub
+type chaincodeStub interface {
+	shim.ChaincodeStubInterface
+}
+
+//go:generate counterfeiter -o mocks/statequeryiterator.go -fake-name StateQueryIterator . stateQueryIterator
+type stateQueryIterator interface {
+	shim.StateQueryIteratorInterface
+}
+
+//go:generate counterfeiter -o mocks/clientidentity.go -fake-name ClientIdentity . clientIdentity
+type clientIdentity interface {
+	cid.ClientIdentity
+}
+
+// Layouts every ledger test runs on
+var layouts = []string{chaincode.LegacyBlobLayout, chaincode.PerKeyLayout}
+
+const (
+	org1 = "Org1MSP"
+	org2 = "Org2MSP"
+
+	testCusip  = "3132DWAR4"
+	otherCusip = "3133KR5L4"
+)
+
+var testTime = time.Date(2023, 1, 9, 12, 0, 0, 0, time.UTC)
+
+// world is an in-memory world state behind the fake stubs. Like on a peer, a transaction does not read its own writes:
+// they are kept apart until commit
+type world struct {
+	state     map[string][]byte
+	private   map[string]map[string][]byte
+	params    map[string][]byte
+	transient map[string][]byte
+	pending   *writeSet
+	txNumber  int
+}
+
+// writeSet holds the writes of the transaction in progress. A nil value deletes the key
+type writeSet struct {
+	state   map[string][]byte
+	private map[string]map[string][]byte
+	params  map[string][]byte
+	events  []string
+}
+
+func newWorld() *world {
+	return &world{
+		state:   map[string][]byte{},
+		private: map[string]map[string][]byte{},
+		params:  map[string][]byte{},
+	}
+}
+
+// begin starts a transaction of the given organization at testTime and returns its context.
+// The writes of a transaction that was not committed are dropped
+func (w *world) begin(mspID string) *mocks.TransactionContext {
+	w.txNumber++
+	pending := &writeSet{
+		state:   map[string][]byte{},
+		private: map[string]map[string][]byte{},
+		params:  map[string][]byte{},
+	}
+	w.pending = pending
+	transient := w.transient
+	w.transient = nil
+
+	stub := &mocks.ChaincodeStub{}
+	stub.GetTxIDReturns(fmt.Sprintf("tx%d", w.txNumber))
+	stub.GetTxTimestampReturns(timestamppb.New(testTime), nil)
+	stub.GetTransientReturns(transient, nil)
+	stub.CreateCompositeKeyCalls(shim.CreateCompositeKey)
+	stub.SplitCompositeKeyCalls(splitCompositeKey)
+
+	stub.GetStateCalls(func(key string) ([]byte, error) {
+		return w.state[key], nil
+	})
+	stub.PutStateCalls(func(key string, value []byte) error {
+		pending.state[key] = append([]byte{}, value...)
+		return nil
+	})
+	stub.DelStateCalls(func(key string) error {
+		pending.state[key] = nil
+		return nil
+	})
+	stub.GetStateByPartialCompositeKeyCalls(func(objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+		prefix, err := shim.CreateCompositeKey(objectType, attributes)
+		if err != nil {
+			return nil, err
+		}
+		return iterate(w.state, prefix), nil
+	})
+	stub.GetStateByPartialCompositeKeyWithPaginationCalls(func(objectType string, attributes []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+		prefix, err := shim.CreateCompositeKey(objectType, attributes)
+		if err != nil {
+			return nil, nil, err
+		}
+		return iteratePage(w.state, prefix, pageSize, bookmark)
+	})
+	stub.GetQueryResultReturns(nil, fmt.Errorf("rich queries need CouchDB"))
+	stub.GetStateValidationParameterCalls(func(key string) ([]byte, error) {
+		return w.params[key], nil
+	})
+	stub.SetStateValidationParameterCalls(func(key string, policy []byte) error {
+		pending.params[key] = policy
+		return nil
+	})
+
+	stub.GetPrivateDataCalls(func(collection, key string) ([]byte, error) {
+		return w.private[collection][key], nil
+	})
+	stub.GetPrivateDataHashCalls(func(collection, key string) ([]byte, error) {
+		value := w.private[collection][key]
+		if value == nil {
+			return nil, nil
+		}
+		hash := sha256.Sum256(value)
+		return hash[:], nil
+	})
+	putPrivate := func(collection, key string, value []byte) error {
+		if pending.private[collection] == nil {
+			pending.private[collection] = map[string][]byte{}
+		}
+		pending.private[collection][key] = value
+		return nil
+	}
+	stub.PutPrivateDataCalls(func(collection, key string, value []byte) error {
+		return putPrivate(collection, key, append([]byte{}, value...))
+	})
+	stub.DelPrivateDataCalls(func(collection, key string) error {
+		return putPrivate(collection, key, nil)
+	})
+	stub.PurgePrivateDataCalls(func(collection, key string) error {
+		return putPrivate(collection, key, nil)
+	})
+	stub.GetPrivateDataByPartialCompositeKeyCalls(func(collection, objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+		prefix, err := shim.CreateCompositeKey(objectType, attributes)
+		if err != nil {
+			return nil, err
+		}
+		return iterate(w.private[collection], prefix), nil
+	})
+
+	stub.SetEventCalls(func(name string, payload []byte) error {
+		pending.events = append(pending.events, name)
+		return nil
+	})
+
+	identity := &mocks.ClientIdentity{}
+	identity.GetMSPIDReturns(mspID, nil)
+	identity.GetIDReturns("x509::CN=user1::CN=ca."+strings.ToLower(mspID), nil)
+
+	ctx := &mocks.TransactionContext{}
+	ctx.GetStubReturns(stub)
+	ctx.GetClientIdentityReturns(identity)
+	return ctx
+}
+
+// beginAt starts a transaction of the given organization at the given time
+func (w *world) beginAt(mspID string, at time.Time) *mocks.TransactionContext {
+	ctx := w.begin(mspID)
+	ctx.GetStub().(*mocks.ChaincodeStub).GetTxTimestampReturns(timestamppb.New(at), nil)
+	return ctx
+}
+
+// commit applies the writes of the transaction in progress
+func (w *world) commit() {
+	apply := func(target map[string][]byte, writes map[string][]byte) {
+		for key, value := range writes {
+			if value == nil {
+				delete(target, key)
+				continue
+			}
+			target[key] = value
+		}
+	}
+
+	apply(w.state, w.pending.state)
+	apply(w.params, w.pending.params)
+	for collection, writes := range w.pending.private {
+		if w.private[collection] == nil {
+			w.private[collection] = map[string][]byte{}
+		}
+		apply(w.private[collection], writes)
+	}
+	w.pending = nil
+}
+
+// events returns the names of the events the transaction in progress raised
+func (w *world) events() []string {
+	return w.pending.events
+}
+
+// iterate returns an iterator over the keys of values that start with prefix, in key order
+func iterate(values map[string][]byte, prefix string) *mocks.StateQueryIterator {
+	return iterateKeys(values, prefixedKeys(values, prefix))
+}
+
+// iteratePage returns an iterator over up to pageSize keys of values that start with prefix, from the bookmark on,
+// and the bookmark of the next page. Like on a peer, the bookmark is the key to resume from
+func iteratePage(values map[string][]byte, prefix string, pageSize int32, bookmark string) (*mocks.StateQueryIterator, *peer.QueryResponseMetadata, error) {
+	if bookmark != "" && !strings.HasPrefix(bookmark, prefix) {
+		return nil, nil, fmt.Errorf("bookmark %q is outside of the range", bookmark)
+	}
+
+	keys := []string{}
+	for _, key := range prefixedKeys(values, prefix) {
+		if key >= bookmark {
+			keys = append(keys, key)
+		}
+	}
+	metadata := &peer.QueryResponseMetadata{}
+	if len(keys) > int(pageSize) {
+		metadata.Bookmark = keys[pageSize]
+		keys = keys[:pageSize]
+	}
+	metadata.FetchedRecordsCount = int32(len(keys))
+
+	return iterateKeys(values, keys), metadata, nil
+}
+
+// prefixedKeys returns the keys of values that start with prefix, in key order
+func prefixedKeys(values map[string][]byte, prefix string) []string {
+	keys := []string{}
+	for key := range values {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// iterateKeys returns an iterator over the given keys of values
+func iterateKeys(values map[string][]byte, keys []string) *mocks.StateQueryIterator {
+	next := 0
+	iterator := &mocks.StateQueryIterator{}
+	iterator.HasNextCalls(func() bool {
+		return next < len(keys)
+	})
+	iterator.NextCalls(func() (*queryresult.KV, error) {
+		key := keys[next]
+		next++
+		return &queryresult.KV{Key: key, Value: values[key]}, nil
+	})
+	return iterator
+}
+
+// splitCompositeKey undoes shim.CreateCompositeKey
+func splitCompositeKey(key string) (string, []string, error) {
+	if !strings.HasPrefix(key, "\x00") || !strings.HasSuffix(key, "\x00") {
+		return "", nil, fmt.Errorf("%q is not a composite key", key)
+	}
+	parts := strings.Split(key[1:len(key)-1], "\x00")
+	return parts[0], parts[1:], nil
+}
+
+// setUp returns a world where both organizations have their encryption key
+func setUp(t *testing.T, contract *chaincode.SmartContract) *world {
+	w := newWorld()
+	for _, mspID := range []string{org1, org2} {
+		_, err := contract.SetEncryptionKey(w.begin(mspID))
+		require.NoError(t, err)
+		w.commit()
+	}
+	return w
+}
+
+// createBond adds a public bond owned by ownerHash
+func createBond(t *testing.T, w *world, contract *chaincode.SmartContract, uid, ownerHash, cusip string, originalFace int64) {
+	_, err := contract.CreateBondPublic(w.begin(org1), uid, ownerHash, "FR "+uid, cusip, "passthrough", originalFace)
+	require.NoError(t, err)
+	w.commit()
+}
+
+func TestContractMetadata(t *testing.T) {
+	_, err := chaincode.NewEnvelopeChaincode(&chaincode.SmartContract{})
+	require.NoError(t, err)
+}
+
+func TestCreateBondPublic(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUp(t, contract)
+
+			createBond(t, w, contract, "uid1", org2, testCusip, 100000000)
+			createBond(t, w, contract, "uid2", org1, otherCusip, 50000000)
+			createBond(t, w, contract, "uid3", org2, testCusip, 25000000)
+
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Len(t, bonds, 3)
+
+			uids := []string{}
+			for _, bond := range bonds {
+				if bond.Cusip == testCusip {
+					require.Equal(t, org2, bond.OwnerHash)
+					uids = append(uids, bond.UID)
+				}
+			}
+			require.ElementsMatch(t, []string{"uid1", "uid3"}, uids)
+		})
+	}
+}
+
+func TestCreateBondPublicErrors(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUp(t, contract)
+
+			ctx := w.begin(org1)
+			stub := ctx.GetStub().(*mocks.ChaincodeStub)
+			stub.GetStateReturns(nil, fmt.Errorf("world state unavailable"))
+			stub.GetStateByPartialCompositeKeyReturns(nil, fmt.Errorf("world state unavailable"))
+
+			_, err := contract.CreateBondPublic(ctx, "uid1", org2, "FR uid1", testCusip, "passthrough", 100000000)
+			require.ErrorContains(t, err, "world state unavailable")
+		})
+	}
+}
+
+func TestBondEndorsementPolicy(t *testing.T) {
+	tests := []struct {
+		layout    string
+		endorsers []string
+		err       string
+	}{
+		{layout: chaincode.LegacyBlobLayout, err: "bonds have no endorsement policy of their own in the blob layout"},
+		{layout: chaincode.PerKeyLayout, endorsers: []string{org2}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: test.layout}
+			w := setUp(t, contract)
+			createBond(t, w, contract, "uid1", org2, testCusip, 100000000)
+
+			policy, err := contract.GetBondEndorsementPolicy(w.begin(org1), "uid1")
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.endorsers, policy.Endorsers)
+		})
+	}
+}
@@ -0,0 +1,1608 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ⭐ Data Structures ⭐
+
+// BondStore keeps the public bonds
+type BondStore interface {
+	GetBonds() ([]AgencyMBSPassthrough, error)
+	GetBondsByCusip(cusip string) ([]AgencyMBSPassthrough, error)
+	// GetBond returns the bond with the given UID, or nil if there is none
+	GetBond(uid string) (*AgencyMBSPassthrough, error)
+	// PutBonds replaces the stored bonds with the given ones
+	PutBonds(bonds []AgencyMBSPassthrough) error
+	// PutCusipBonds replaces the stored bonds of a cusip with the given ones and leaves the other bonds alone
+	PutCusipBonds(cusip string, bonds []AgencyMBSPassthrough) error
+	// QueryBonds returns up to pageSize bonds matching a CouchDB query, starting at the bookmark, and the bookmark of the next page.
+	// A pageSize of zero returns every match. Layouts that cannot run the query pick the bonds with match instead,
+	// and fail when it is nil
+	QueryBonds(query string, match func(AgencyMBSPassthrough) bool, pageSize int32, bookmark string) ([]AgencyMBSPassthrough, string, error)
+	// GetBondEndorsers returns the organizations whose peers must endorse updates of a bond, empty while the chaincode
+	// endorsement policy applies. Layouts that keep the bonds under a shared key fail
+	GetBondEndorsers(uid string) ([]string, error)
+	// ForEachBond calls visit with every stored bond. Layouts with a key per bond read them one at a time
+	ForEachBond(visit func(AgencyMBSPassthrough)) error
+}
+
+// TradeStore keeps the direct trades and the transactions they settle into
+type TradeStore interface {
+	GetDirectTrades() ([]DirectTrade, error)
+	GetDirectTradesByCusip(cusip string) ([]DirectTrade, error)
+	// GetDirectTrade returns the direct trade with the given ID, or nil if there is none
+	GetDirectTrade(directTradeID string) (*DirectTrade, error)
+	// PutDirectTrades replaces the stored direct trades with the given ones
+	PutDirectTrades(trades []DirectTrade) error
+	// PutCusipDirectTrades replaces the stored direct trades of a cusip with the given ones and leaves the other trades alone
+	PutCusipDirectTrades(cusip string, trades []DirectTrade) error
+	GetTransactions() ([]Transaction, error)
+	// PutTransactions replaces the stored transactions with the given ones
+	PutTransactions(transactions []Transaction) error
+	// AddTransactions records the given transactions after the stored ones
+	AddTransactions(transactions []Transaction) error
+	// QueryTransactions returns up to pageSize transactions of a party or a cusip, as the index type says, with a timestamp
+	// from from on and before to, starting at the bookmark, and the bookmark of the next page. A zero from or to leaves that end open.
+	// A pageSize of zero returns every match
+	QueryTransactions(indexType, value string, from, to time.Time, pageSize int32, bookmark string) ([]Transaction, string, error)
+	// ForEachDirectTrade calls visit with every stored direct trade. Layouts with a key per trade read them one at a time
+	ForEachDirectTrade(visit func(DirectTrade)) error
+	// ForEachTransaction calls visit with every stored transaction. Layouts with a key per transaction read them one at a time
+	ForEachTransaction(visit func(Transaction)) error
+}
+
+// InventoryStore keeps the private inventory of each organization in its implicit collection
+type InventoryStore interface {
+	// GetInventory returns the inventory of an organization, or nil if it has none
+	GetInventory(mspID string) (*Inventory, error)
+	PutInventory(mspID string, inventory *Inventory) error
+	// GetInventoryItem returns the item of an organization's inventory with the given CUSIP, or nil if there is none
+	GetInventoryItem(mspID, cusip string) (*PrivateAgencyMBSPassthrough, error)
+	// AddInventoryItem adds an item to an organization's inventory and leaves the other items alone
+	AddInventoryItem(mspID string, item *PrivateAgencyMBSPassthrough) error
+}
+
+// Stores groups the stores the contract logic reads and writes through, so that it does not depend on the key layout
+type Stores struct {
+	Bonds     BondStore
+	Trades    TradeStore
+	Inventory InventoryStore
+}
+
+// LedgerLayoutConfig records the layout MigrateLedgerLayout moved the public ledger of the channel to
+type LedgerLayoutConfig struct {
+	Layout     string    `json:"layout"`
+	MigratedAt time.Time `json:"migratedAt"`
+}
+
+// Storage layouts the stores can be built on
+const (
+	// Everything public under the single "ledger" key and each inventory under a single "inventory" key
+	LegacyBlobLayout = "blob"
+	// Each bond, trade, transaction and inventory item under its own composite key
+	PerKeyLayout = "perkey"
+)
+
+// Object types of the composite keys of the per-key layout
+const (
+	bondKeyType        = "bond"
+	tradeKeyType       = "trade"
+	transactionKeyType = "txn"
+	inventoryKeyType   = "inv"
+
+	// Indexes from a cusip to the UIDs of its bonds and the IDs of its direct trades, including deleted ones.
+	// Their values are a single byte, since storing an empty value deletes the key
+	bondCusipIndexType  = "bondcusip"
+	tradeCusipIndexType = "tradecusip"
+
+	// Indexes from each party and each cusip to their transactions, in timestamp order.
+	// Their keys end with the timestamp of the transaction and the attributes of its key
+	transactionPartyIndexType = "txnparty"
+	transactionCusipIndexType = "txncusip"
+)
+
+const (
+	ledgerLayoutConfigID = "ledgerlayout"
+
+	// Records of the layout MigrateInventory moved the inventory of each organization to, keyed by MSP ID
+	inventoryLayoutObjectType = "inventorylayout"
+
+	// Layout of the transaction timestamp in the keys of new transactions, which sorts in time order
+	transactionKeyTimeLayout = "20060102T150405.000000000Z"
+)
+
+// ⭐ Functions ⭐
+
+// MigrateLedgerLayout moves the bonds, direct trades and transactions of the channel to the per-key layout,
+// with the cusip indexes the per-cusip reads go through. Only the admin organization can run it. It can be run again
+// to rebuild the indexes of records written by an older version. Inventories keep the layout the contract is built with
+func (s *SmartContract) MigrateLedgerLayout(ctx contractapi.TransactionContextInterface) (*WriteResponse, error) {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s.StorageLayout == LegacyBlobLayout {
+		return nil, fmt.Errorf("the contract is built with the %s layout", LegacyBlobLayout)
+	}
+
+	ledger, err := s.GetLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	perKey := &perKeyStore{ctx: ctx}
+	err = perKey.PutBonds(ledger.Bonds)
+	if err != nil {
+		return nil, err
+	}
+	err = perKey.PutDirectTrades(ledger.DirectTrades)
+	if err != nil {
+		return nil, err
+	}
+	err = perKey.PutTransactions(ledger.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	err = perKey.indexTransactions()
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.GetStub().DelState("ledger")
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete ledger: %v", err)
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = s.putRecord(ctx, configObjectType, ledgerLayoutConfigID, LedgerLayoutConfig{Layout: PerKeyLayout, MigratedAt: timestamp})
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// MigrateInventory moves the caller's inventory from its single "inventory" blob to one key per item, so that adding
+// or changing an item no longer conflicts with concurrent changes to the others. Each organization migrates its own inventory,
+// once. It has no effect on a contract built with a storage layout, whose inventories are always in that layout
+func (s *SmartContract) MigrateInventory(ctx contractapi.TransactionContextInterface) (*WriteResponse, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	if s.StorageLayout != "" {
+		return nil, fmt.Errorf("the contract is built with the %s layout", s.StorageLayout)
+	}
+	var config LedgerLayoutConfig
+	_, err = s.getRecord(ctx, inventoryLayoutObjectType, mspID, &config)
+	if err != nil {
+		return nil, err
+	}
+	if config.Layout == PerKeyLayout {
+		return nil, fmt.Errorf("the inventory of %s is already migrated", mspID)
+	}
+
+	blob := &blobStore{ctx: ctx}
+	inventory, err := blob.GetInventory(mspID)
+	if err != nil {
+		return nil, err
+	}
+	migrated := 0
+	if inventory != nil {
+		err = (&perKeyStore{ctx: ctx}).PutInventory(mspID, inventory)
+		if err != nil {
+			return nil, err
+		}
+		err = ctx.GetStub().DelPrivateData(implicitCollection(mspID), "inventory")
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete inventory of %s: %v", mspID, err)
+		}
+		migrated = len(inventory.Assets)
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = s.putRecord(ctx, inventoryLayoutObjectType, mspID, LedgerLayoutConfig{Layout: PerKeyLayout, MigratedAt: timestamp})
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, migrated)
+}
+
+// ⭐ Helper functions ⭐
+
+// stores returns the stores of the transaction. The public ledger is in the layout the contract is built with or,
+// if it is built without one, the layout the channel migrated to. Inventories are likewise in the layout the contract
+// is built with or the layout their organization migrated to. The legacy blob layout is the default
+func (s *SmartContract) stores(ctx contractapi.TransactionContextInterface) (*Stores, error) {
+	ledgerLayout := s.StorageLayout
+	if ledgerLayout == "" {
+		var config LedgerLayoutConfig
+		_, err := s.getRecord(ctx, configObjectType, ledgerLayoutConfigID, &config)
+		if err != nil {
+			return nil, err
+		}
+		ledgerLayout = config.Layout
+	}
+
+	ledgerStores, err := layoutStores(ctx, ledgerLayout)
+	if err != nil {
+		return nil, err
+	}
+	if s.StorageLayout == "" {
+		return &Stores{Bonds: ledgerStores.Bonds, Trades: ledgerStores.Trades, Inventory: &migratedInventoryStore{contract: s, ctx: ctx}}, nil
+	}
+	inventoryStores, err := layoutStores(ctx, s.StorageLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Stores{Bonds: ledgerStores.Bonds, Trades: ledgerStores.Trades, Inventory: inventoryStores.Inventory}, nil
+}
+
+// migratedInventoryStore keeps each inventory in the layout its organization migrated to with MigrateInventory
+type migratedInventoryStore struct {
+	contract *SmartContract
+	ctx      contractapi.TransactionContextInterface
+}
+
+// store returns the store of an organization's inventory. The layout record is public,
+// so that an organization can also write to the inventory of another
+func (m *migratedInventoryStore) store(mspID string) (InventoryStore, error) {
+	var config LedgerLayoutConfig
+	_, err := m.contract.getRecord(m.ctx, inventoryLayoutObjectType, mspID, &config)
+	if err != nil {
+		return nil, err
+	}
+	if config.Layout == PerKeyLayout {
+		return &perKeyStore{ctx: m.ctx}, nil
+	}
+
+	return &blobStore{ctx: m.ctx}, nil
+}
+
+func (m *migratedInventoryStore) GetInventory(mspID string) (*Inventory, error) {
+	store, err := m.store(mspID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetInventory(mspID)
+}
+
+func (m *migratedInventoryStore) PutInventory(mspID string, inventory *Inventory) error {
+	store, err := m.store(mspID)
+	if err != nil {
+		return err
+	}
+	return store.PutInventory(mspID, inventory)
+}
+
+func (m *migratedInventoryStore) GetInventoryItem(mspID, cusip string) (*PrivateAgencyMBSPassthrough, error) {
+	store, err := m.store(mspID)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetInventoryItem(mspID, cusip)
+}
+
+func (m *migratedInventoryStore) AddInventoryItem(mspID string, item *PrivateAgencyMBSPassthrough) error {
+	store, err := m.store(mspID)
+	if err != nil {
+		return err
+	}
+	return store.AddInventoryItem(mspID, item)
+}
+
+// layoutStores returns the stores of one layout
+func layoutStores(ctx contractapi.TransactionContextInterface, layout string) (*Stores, error) {
+	switch layout {
+	case "", LegacyBlobLayout:
+		blob := &blobStore{ctx: ctx}
+		return &Stores{Bonds: blob, Trades: blob, Inventory: blob}, nil
+	case PerKeyLayout:
+		perKey := &perKeyStore{ctx: ctx}
+		return &Stores{Bonds: perKey, Trades: perKey, Inventory: perKey}, nil
+	}
+
+	return nil, fmt.Errorf("unknown storage layout %s", layout)
+}
+
+// blobStore implements the stores on the legacy layout. The ledger is read once and every write rewrites it whole
+type blobStore struct {
+	ctx    contractapi.TransactionContextInterface
+	ledger *Ledger
+}
+
+func (b *blobStore) load() (*Ledger, error) {
+	if b.ledger != nil {
+		return b.ledger, nil
+	}
+
+	ledgerBytes, err := b.ctx.GetStub().GetState("ledger")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ledger from world state: %v", err)
+	}
+	b.ledger = &Ledger{
+		Bonds:        []AgencyMBSPassthrough{},
+		DirectTrades: []DirectTrade{},
+		Transactions: []Transaction{},
+	}
+	if ledgerBytes != nil {
+		err = json.Unmarshal(ledgerBytes, b.ledger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal ledger: %v", err)
+		}
+	}
+
+	return b.ledger, nil
+}
+
+func (b *blobStore) save() error {
+	ledgerBytes, err := json.Marshal(b.ledger)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ledger: %v", err)
+	}
+
+	err = b.ctx.GetStub().PutState("ledger", ledgerBytes)
+	if err != nil {
+		return fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return nil
+}
+
+func (b *blobStore) GetBonds() ([]AgencyMBSPassthrough, error) {
+	ledger, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+	return ledger.Bonds, nil
+}
+
+func (b *blobStore) PutBonds(bonds []AgencyMBSPassthrough) error {
+	ledger, err := b.load()
+	if err != nil {
+		return err
+	}
+	ledger.Bonds = bonds
+	return b.save()
+}
+
+func (b *blobStore) GetDirectTrades() ([]DirectTrade, error) {
+	ledger, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+	return ledger.DirectTrades, nil
+}
+
+func (b *blobStore) PutDirectTrades(trades []DirectTrade) error {
+	ledger, err := b.load()
+	if err != nil {
+		return err
+	}
+	ledger.DirectTrades = trades
+	return b.save()
+}
+
+func (b *blobStore) GetTransactions() ([]Transaction, error) {
+	ledger, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+	return ledger.Transactions, nil
+}
+
+func (b *blobStore) PutTransactions(transactions []Transaction) error {
+	ledger, err := b.load()
+	if err != nil {
+		return err
+	}
+	ledger.Transactions = transactions
+	return b.save()
+}
+
+func (b *blobStore) ForEachBond(visit func(AgencyMBSPassthrough)) error {
+	ledger, err := b.load()
+	if err != nil {
+		return err
+	}
+	for _, bond := range ledger.Bonds {
+		visit(bond)
+	}
+	return nil
+}
+
+func (b *blobStore) ForEachDirectTrade(visit func(DirectTrade)) error {
+	ledger, err := b.load()
+	if err != nil {
+		return err
+	}
+	for _, trade := range ledger.DirectTrades {
+		visit(trade)
+	}
+	return nil
+}
+
+func (b *blobStore) ForEachTransaction(visit func(Transaction)) error {
+	ledger, err := b.load()
+	if err != nil {
+		return err
+	}
+	for _, transaction := range ledger.Transactions {
+		visit(transaction)
+	}
+	return nil
+}
+
+func (b *blobStore) GetBondsByCusip(cusip string) ([]AgencyMBSPassthrough, error) {
+	ledger, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+
+	bonds := []AgencyMBSPassthrough{}
+	for _, bond := range ledger.Bonds {
+		if bond.Cusip == cusip {
+			bonds = append(bonds, bond)
+		}
+	}
+	return bonds, nil
+}
+
+func (b *blobStore) QueryBonds(query string, match func(AgencyMBSPassthrough) bool, pageSize int32, bookmark string) ([]AgencyMBSPassthrough, string, error) {
+	if match == nil {
+		return nil, "", fmt.Errorf("rich queries need the %s layout. Run MigrateLedgerLayout first", PerKeyLayout)
+	}
+
+	start := 0
+	if bookmark != "" {
+		var err error
+		start, err = strconv.Atoi(bookmark)
+		if err != nil || start < 0 {
+			return nil, "", fmt.Errorf("invalid bookmark %s", bookmark)
+		}
+	}
+
+	ledger, err := b.load()
+	if err != nil {
+		return nil, "", err
+	}
+
+	// The bookmark is the position in the ledger to resume from
+	bonds := []AgencyMBSPassthrough{}
+	for i := start; i < len(ledger.Bonds); i++ {
+		if !match(ledger.Bonds[i]) {
+			continue
+		}
+		if pageSize > 0 && len(bonds) == int(pageSize) {
+			return bonds, strconv.Itoa(i), nil
+		}
+		bonds = append(bonds, ledger.Bonds[i])
+	}
+	return bonds, "", nil
+}
+
+func (b *blobStore) GetBond(uid string) (*AgencyMBSPassthrough, error) {
+	ledger, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, bond := range ledger.Bonds {
+		if bond.UID == uid {
+			return &bond, nil
+		}
+	}
+	return nil, nil
+}
+
+func (b *blobStore) GetBondEndorsers(uid string) ([]string, error) {
+	return nil, fmt.Errorf("bonds have no endorsement policy of their own in the %s layout", LegacyBlobLayout)
+}
+
+// PutCusipBonds keeps the bonds of the cusip where they were in the ledger, so that the order of the bonds does not change
+func (b *blobStore) PutCusipBonds(cusip string, bonds []AgencyMBSPassthrough) error {
+	ledger, err := b.load()
+	if err != nil {
+		return err
+	}
+
+	given := map[string]AgencyMBSPassthrough{}
+	for _, bond := range bonds {
+		given[bond.UID] = bond
+	}
+	merged := []AgencyMBSPassthrough{}
+	for _, bond := range ledger.Bonds {
+		if bond.Cusip != cusip {
+			merged = append(merged, bond)
+		} else if replacement, ok := given[bond.UID]; ok {
+			merged = append(merged, replacement)
+			delete(given, bond.UID)
+		}
+	}
+	for _, bond := range bonds {
+		if _, ok := given[bond.UID]; ok {
+			merged = append(merged, bond)
+		}
+	}
+
+	ledger.Bonds = merged
+	return b.save()
+}
+
+func (b *blobStore) GetDirectTradesByCusip(cusip string) ([]DirectTrade, error) {
+	ledger, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+
+	trades := []DirectTrade{}
+	for _, trade := range ledger.DirectTrades {
+		if trade.Cusip == cusip {
+			trades = append(trades, trade)
+		}
+	}
+	return trades, nil
+}
+
+func (b *blobStore) GetDirectTrade(directTradeID string) (*DirectTrade, error) {
+	ledger, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, trade := range ledger.DirectTrades {
+		if trade.DirectTradeID == directTradeID {
+			return &trade, nil
+		}
+	}
+	return nil, nil
+}
+
+// PutCusipDirectTrades keeps the trades of the cusip where they were in the ledger, like PutCusipBonds
+func (b *blobStore) PutCusipDirectTrades(cusip string, trades []DirectTrade) error {
+	ledger, err := b.load()
+	if err != nil {
+		return err
+	}
+
+	given := map[string]DirectTrade{}
+	for _, trade := range trades {
+		given[trade.DirectTradeID] = trade
+	}
+	merged := []DirectTrade{}
+	for _, trade := range ledger.DirectTrades {
+		if trade.Cusip != cusip {
+			merged = append(merged, trade)
+		} else if replacement, ok := given[trade.DirectTradeID]; ok {
+			merged = append(merged, replacement)
+			delete(given, trade.DirectTradeID)
+		}
+	}
+	for _, trade := range trades {
+		if _, ok := given[trade.DirectTradeID]; ok {
+			merged = append(merged, trade)
+		}
+	}
+
+	ledger.DirectTrades = merged
+	return b.save()
+}
+
+func (b *blobStore) AddTransactions(transactions []Transaction) error {
+	ledger, err := b.load()
+	if err != nil {
+		return err
+	}
+	ledger.Transactions = append(ledger.Transactions, transactions...)
+	return b.save()
+}
+
+// QueryTransactions reads the ledger whole. The bookmark is the position in the ledger to resume from
+func (b *blobStore) QueryTransactions(indexType, value string, from, to time.Time, pageSize int32, bookmark string) ([]Transaction, string, error) {
+	start := 0
+	if bookmark != "" {
+		var err error
+		start, err = strconv.Atoi(bookmark)
+		if err != nil || start < 0 {
+			return nil, "", fmt.Errorf("invalid bookmark %s", bookmark)
+		}
+	}
+
+	ledger, err := b.load()
+	if err != nil {
+		return nil, "", err
+	}
+
+	transactions := []Transaction{}
+	for i := start; i < len(ledger.Transactions); i++ {
+		transaction := ledger.Transactions[i]
+		if !containsString(transactionIndexValues(indexType, transaction), value) {
+			continue
+		}
+		if (!from.IsZero() && transaction.Timestamp.Before(from)) || (!to.IsZero() && !transaction.Timestamp.Before(to)) {
+			continue
+		}
+		if pageSize > 0 && len(transactions) == int(pageSize) {
+			return transactions, strconv.Itoa(i), nil
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, "", nil
+}
+
+func (b *blobStore) GetInventory(mspID string) (*Inventory, error) {
+	inventoryBytes, err := b.ctx.GetStub().GetPrivateData(implicitCollection(mspID), "inventory")
+	if err != nil {
+		return nil, fmt.Errorf("%s - failed to get inventory: %v", implicitCollection(mspID), err)
+	}
+	if inventoryBytes == nil {
+		return nil, nil
+	}
+
+	var inventory Inventory
+	err = json.Unmarshal(inventoryBytes, &inventory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal inventory: %v", err)
+	}
+
+	return &inventory, nil
+}
+
+func (b *blobStore) PutInventory(mspID string, inventory *Inventory) error {
+	inventoryBytes, err := json.Marshal(inventory)
+	if err != nil {
+		return fmt.Errorf("failed to marshal inventory: %v", err)
+	}
+	err = b.ctx.GetStub().PutPrivateData(implicitCollection(mspID), "inventory", inventoryBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put inventory of %s: %v", mspID, err)
+	}
+
+	return nil
+}
+
+func (b *blobStore) GetInventoryItem(mspID, cusip string) (*PrivateAgencyMBSPassthrough, error) {
+	inventory, err := b.GetInventory(mspID)
+	if err != nil || inventory == nil {
+		return nil, err
+	}
+
+	return findInventoryItem(inventory, cusip), nil
+}
+
+// AddInventoryItem rewrites the whole inventory, so it conflicts with every concurrent change to the inventory
+func (b *blobStore) AddInventoryItem(mspID string, item *PrivateAgencyMBSPassthrough) error {
+	inventory, err := b.GetInventory(mspID)
+	if err != nil {
+		return err
+	}
+	if inventory == nil {
+		inventory = &Inventory{Assets: []*PrivateAgencyMBSPassthrough{}}
+	}
+	inventory.Assets = append(inventory.Assets, item)
+
+	return b.PutInventory(mspID, inventory)
+}
+
+// perKeyStore implements the stores with one composite key per record, and an index from each cusip to its bonds and trades.
+// Replacing a set of records writes only the records that changed and deletes the keys of the records that are gone,
+// so that transactions on different records do not conflict
+type perKeyStore struct {
+	ctx contractapi.TransactionContextInterface
+}
+
+func (p *perKeyStore) GetBonds() ([]AgencyMBSPassthrough, error) {
+	bonds := []AgencyMBSPassthrough{}
+	err := p.forEach(bondKeyType, func(value []byte) error {
+		var bond AgencyMBSPassthrough
+		err := json.Unmarshal(value, &bond)
+		bonds = append(bonds, bond)
+		return err
+	})
+	return bonds, err
+}
+
+func (p *perKeyStore) ForEachBond(visit func(AgencyMBSPassthrough)) error {
+	return p.forEach(bondKeyType, func(value []byte) error {
+		var bond AgencyMBSPassthrough
+		err := json.Unmarshal(value, &bond)
+		if err == nil {
+			visit(bond)
+		}
+		return err
+	})
+}
+
+func (p *perKeyStore) GetBondsByCusip(cusip string) ([]AgencyMBSPassthrough, error) {
+	bonds := []AgencyMBSPassthrough{}
+	err := p.forEachIndexed(bondCusipIndexType, bondKeyType, cusip, func(value []byte) error {
+		var bond AgencyMBSPassthrough
+		err := json.Unmarshal(value, &bond)
+		bonds = append(bonds, bond)
+		return err
+	})
+	return bonds, err
+}
+
+func (p *perKeyStore) GetBond(uid string) (*AgencyMBSPassthrough, error) {
+	var bond AgencyMBSPassthrough
+	found, err := p.get(bondKeyType, uid, &bond)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &bond, nil
+}
+
+func (p *perKeyStore) QueryBonds(query string, match func(AgencyMBSPassthrough) bool, pageSize int32, bookmark string) ([]AgencyMBSPassthrough, string, error) {
+	var resultsIterator shim.StateQueryIteratorInterface
+	var err error
+	nextBookmark := ""
+	if pageSize > 0 {
+		var metadata *peer.QueryResponseMetadata
+		resultsIterator, metadata, err = p.ctx.GetStub().GetQueryResultWithPagination(query, pageSize, bookmark)
+		if err == nil {
+			nextBookmark = metadata.Bookmark
+		}
+	} else {
+		resultsIterator, err = p.ctx.GetStub().GetQueryResult(query)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to run bond query: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	// Other records can have the fields the query selects on, so only the bond keys are kept
+	bonds := []AgencyMBSPassthrough{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, "", fmt.Errorf("error iterating over bond query results: %v", err)
+		}
+		objectType, _, err := p.ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil || objectType != bondKeyType {
+			continue
+		}
+
+		var bond AgencyMBSPassthrough
+		err = json.Unmarshal(queryResponse.Value, &bond)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to unmarshal %s record: %v", bondKeyType, err)
+		}
+		bonds = append(bonds, bond)
+	}
+
+	return bonds, nextBookmark, nil
+}
+
+func (p *perKeyStore) PutBonds(bonds []AgencyMBSPassthrough) error {
+	records := map[string]indexedRecord{}
+	for _, bond := range bonds {
+		records[bond.UID] = indexedRecord{cusip: bond.Cusip, record: bond}
+	}
+	err := p.replace(bondKeyType, bondCusipIndexType, records)
+	if err != nil {
+		return err
+	}
+	return p.endorseOwners(bonds)
+}
+
+func (p *perKeyStore) PutCusipBonds(cusip string, bonds []AgencyMBSPassthrough) error {
+	records := map[string]interface{}{}
+	for _, bond := range bonds {
+		records[bond.UID] = bond
+	}
+	err := p.replaceCusip(bondKeyType, bondCusipIndexType, cusip, records)
+	if err != nil {
+		return err
+	}
+	return p.endorseOwners(bonds)
+}
+
+func (p *perKeyStore) GetBondEndorsers(uid string) ([]string, error) {
+	key, err := p.ctx.GetStub().CreateCompositeKey(bondKeyType, []string{uid})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s key: %v", bondKeyType, err)
+	}
+	policy, err := p.ctx.GetStub().GetStateValidationParameter(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endorsement policy of bond %s: %v", uid, err)
+	}
+	if len(policy) == 0 {
+		return []string{}, nil
+	}
+
+	endorsementPolicy, err := statebased.NewStateEP(policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse endorsement policy of bond %s: %v", uid, err)
+	}
+	return endorsementPolicy.ListOrgs(), nil
+}
+
+func (p *perKeyStore) GetDirectTrades() ([]DirectTrade, error) {
+	trades := []DirectTrade{}
+	err := p.forEach(tradeKeyType, func(value []byte) error {
+		var trade DirectTrade
+		err := json.Unmarshal(value, &trade)
+		trades = append(trades, trade)
+		return err
+	})
+	return trades, err
+}
+
+func (p *perKeyStore) ForEachDirectTrade(visit func(DirectTrade)) error {
+	return p.forEach(tradeKeyType, func(value []byte) error {
+		var trade DirectTrade
+		err := json.Unmarshal(value, &trade)
+		if err == nil {
+			visit(trade)
+		}
+		return err
+	})
+}
+
+func (p *perKeyStore) GetDirectTradesByCusip(cusip string) ([]DirectTrade, error) {
+	trades := []DirectTrade{}
+	err := p.forEachIndexed(tradeCusipIndexType, tradeKeyType, cusip, func(value []byte) error {
+		var trade DirectTrade
+		err := json.Unmarshal(value, &trade)
+		trades = append(trades, trade)
+		return err
+	})
+	return trades, err
+}
+
+func (p *perKeyStore) GetDirectTrade(directTradeID string) (*DirectTrade, error) {
+	var trade DirectTrade
+	found, err := p.get(tradeKeyType, directTradeID, &trade)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &trade, nil
+}
+
+func (p *perKeyStore) PutDirectTrades(trades []DirectTrade) error {
+	records := map[string]indexedRecord{}
+	for _, trade := range trades {
+		records[trade.DirectTradeID] = indexedRecord{cusip: trade.Cusip, record: trade}
+	}
+	return p.replace(tradeKeyType, tradeCusipIndexType, records)
+}
+
+func (p *perKeyStore) PutCusipDirectTrades(cusip string, trades []DirectTrade) error {
+	records := map[string]interface{}{}
+	for _, trade := range trades {
+		records[trade.DirectTradeID] = trade
+	}
+	return p.replaceCusip(tradeKeyType, tradeCusipIndexType, cusip, records)
+}
+
+func (p *perKeyStore) GetTransactions() ([]Transaction, error) {
+	transactions := []Transaction{}
+	err := p.forEach(transactionKeyType, func(value []byte) error {
+		var transaction Transaction
+		err := json.Unmarshal(value, &transaction)
+		transactions = append(transactions, transaction)
+		return err
+	})
+	return transactions, err
+}
+
+func (p *perKeyStore) ForEachTransaction(visit func(Transaction)) error {
+	return p.forEach(transactionKeyType, func(value []byte) error {
+		var transaction Transaction
+		err := json.Unmarshal(value, &transaction)
+		if err == nil {
+			visit(transaction)
+		}
+		return err
+	})
+}
+
+// PutTransactions rewrites the stored transactions that changed, in key order, and adds the ones beyond them
+func (p *perKeyStore) PutTransactions(transactions []Transaction) error {
+	stub := p.ctx.GetStub()
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(transactionKeyType, []string{})
+	if err != nil {
+		return fmt.Errorf("failed to get %s records: %v", transactionKeyType, err)
+	}
+	defer resultsIterator.Close()
+
+	i := 0
+	for ; resultsIterator.HasNext(); i++ {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return fmt.Errorf("error iterating over %s records: %v", transactionKeyType, err)
+		}
+		if i >= len(transactions) {
+			err = stub.DelState(queryResponse.Key)
+			if err != nil {
+				return fmt.Errorf("failed to delete transaction: %v", err)
+			}
+			err = p.reindexTransaction(queryResponse.Key, queryResponse.Value, nil)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err = p.putIfChanged(queryResponse.Key, queryResponse.Value, transactions[i])
+		if err != nil {
+			return err
+		}
+		err = p.reindexTransaction(queryResponse.Key, queryResponse.Value, &transactions[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	if i < len(transactions) {
+		return p.AddTransactions(transactions[i:])
+	}
+	return nil
+}
+
+// AddTransactions keys new transactions by the transaction timestamp, the transaction ID and their position in the
+// transaction, so that the key order is the recording order and adding them does not read the stored ones.
+// Transactions recorded before that were keyed by their zero-padded position, which sorts before
+func (p *perKeyStore) AddTransactions(transactions []Transaction) error {
+	timestamp, err := txTimestamp(p.ctx)
+	if err != nil {
+		return err
+	}
+
+	for i, transaction := range transactions {
+		key, err := p.ctx.GetStub().CreateCompositeKey(transactionKeyType, []string{timestamp.Format(transactionKeyTimeLayout), p.ctx.GetStub().GetTxID(), fmt.Sprintf("%04d", i)})
+		if err != nil {
+			return fmt.Errorf("failed to create %s key: %v", transactionKeyType, err)
+		}
+		err = p.putIfChanged(key, nil, transaction)
+		if err != nil {
+			return err
+		}
+		err = p.reindexTransaction(key, nil, &transactions[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// QueryTransactions range reads the index keys of the party or cusip from the first one at or after from, a page at a time,
+// and reads the transactions they point to one by one. The bookmark is the index key to resume from
+func (p *perKeyStore) QueryTransactions(indexType, value string, from, to time.Time, pageSize int32, bookmark string) ([]Transaction, string, error) {
+	stub := p.ctx.GetStub()
+
+	fromKey := ""
+	if !from.IsZero() {
+		fromKey = from.UTC().Format(transactionKeyTimeLayout)
+	}
+	if bookmark == "" && fromKey != "" {
+		prefix, err := stub.CreateCompositeKey(indexType, []string{value})
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create %s key: %v", indexType, err)
+		}
+		bookmark = prefix + fromKey
+	}
+
+	var resultsIterator shim.StateQueryIteratorInterface
+	var err error
+	nextBookmark := ""
+	if pageSize > 0 {
+		var metadata *peer.QueryResponseMetadata
+		resultsIterator, metadata, err = stub.GetStateByPartialCompositeKeyWithPagination(indexType, []string{value}, pageSize, bookmark)
+		if err == nil {
+			nextBookmark = metadata.Bookmark
+		}
+	} else {
+		resultsIterator, err = stub.GetStateByPartialCompositeKey(indexType, []string{value})
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get %s index: %v", indexType, err)
+	}
+	defer resultsIterator.Close()
+
+	transactions := []Transaction{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, "", fmt.Errorf("error iterating over %s index: %v", indexType, err)
+		}
+		_, attributes, err := stub.SplitCompositeKey(queryResponse.Key)
+		if err != nil || len(attributes) < 3 {
+			return nil, "", fmt.Errorf("failed to split %s key %s: %v", indexType, queryResponse.Key, err)
+		}
+		if attributes[1] < fromKey {
+			// Only read without pagination, which cannot start at the from date
+			continue
+		}
+		if !to.IsZero() && attributes[1] >= to.UTC().Format(transactionKeyTimeLayout) {
+			// The index is in timestamp order, so there is nothing further in the range
+			return transactions, "", nil
+		}
+
+		key, err := stub.CreateCompositeKey(transactionKeyType, attributes[2:])
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create %s key: %v", transactionKeyType, err)
+		}
+		transactionJSON, err := stub.GetState(key)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read transaction: %v", err)
+		}
+		if transactionJSON == nil {
+			continue
+		}
+		var transaction Transaction
+		err = json.Unmarshal(transactionJSON, &transaction)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to unmarshal %s record: %v", transactionKeyType, err)
+		}
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, nextBookmark, nil
+}
+
+func (p *perKeyStore) GetInventory(mspID string) (*Inventory, error) {
+	resultsIterator, err := p.ctx.GetStub().GetPrivateDataByPartialCompositeKey(implicitCollection(mspID), inventoryKeyType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("%s - failed to get inventory: %v", implicitCollection(mspID), err)
+	}
+	defer resultsIterator.Close()
+
+	var inventory *Inventory
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over inventory: %v", err)
+		}
+
+		var item PrivateAgencyMBSPassthrough
+		err = json.Unmarshal(queryResponse.Value, &item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal inventory item: %v", err)
+		}
+		if inventory == nil {
+			inventory = &Inventory{Assets: []*PrivateAgencyMBSPassthrough{}}
+		}
+		inventory.Assets = append(inventory.Assets, &item)
+	}
+
+	return inventory, nil
+}
+
+// PutInventory keys each item by its CUSIP and the UID of its bond on the ledger, empty while the item is held
+func (p *perKeyStore) PutInventory(mspID string, inventory *Inventory) error {
+	collection := implicitCollection(mspID)
+	stub := p.ctx.GetStub()
+
+	keep := map[string][]byte{}
+	for _, item := range inventory.Assets {
+		if item.Content == nil {
+			continue
+		}
+		key, err := stub.CreateCompositeKey(inventoryKeyType, []string{item.Content.Cusip, item.Content.UID})
+		if err != nil {
+			return fmt.Errorf("failed to create %s key: %v", inventoryKeyType, err)
+		}
+		itemBytes, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("failed to marshal inventory item: %v", err)
+		}
+		keep[key] = itemBytes
+	}
+
+	resultsIterator, err := stub.GetPrivateDataByPartialCompositeKey(collection, inventoryKeyType, []string{})
+	if err != nil {
+		return fmt.Errorf("%s - failed to get inventory: %v", collection, err)
+	}
+	defer resultsIterator.Close()
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return fmt.Errorf("error iterating over inventory: %v", err)
+		}
+		if _, ok := keep[queryResponse.Key]; !ok {
+			err = stub.DelPrivateData(collection, queryResponse.Key)
+			if err != nil {
+				return fmt.Errorf("failed to delete inventory item of %s: %v", mspID, err)
+			}
+		}
+	}
+
+	// The write set is sorted by key, so the order of the writes does not matter
+	for key, itemBytes := range keep {
+		err = stub.PutPrivateData(collection, key, itemBytes)
+		if err != nil {
+			return fmt.Errorf("failed to put inventory item of %s: %v", mspID, err)
+		}
+	}
+
+	return nil
+}
+
+// GetInventoryItem reads only the keys of the CUSIP
+func (p *perKeyStore) GetInventoryItem(mspID, cusip string) (*PrivateAgencyMBSPassthrough, error) {
+	resultsIterator, err := p.ctx.GetStub().GetPrivateDataByPartialCompositeKey(implicitCollection(mspID), inventoryKeyType, []string{cusip})
+	if err != nil {
+		return nil, fmt.Errorf("%s - failed to get inventory: %v", implicitCollection(mspID), err)
+	}
+	defer resultsIterator.Close()
+
+	if !resultsIterator.HasNext() {
+		return nil, nil
+	}
+	queryResponse, err := resultsIterator.Next()
+	if err != nil {
+		return nil, fmt.Errorf("error iterating over inventory: %v", err)
+	}
+	var item PrivateAgencyMBSPassthrough
+	err = json.Unmarshal(queryResponse.Value, &item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal inventory item: %v", err)
+	}
+
+	return &item, nil
+}
+
+// AddInventoryItem writes only the key of the item
+func (p *perKeyStore) AddInventoryItem(mspID string, item *PrivateAgencyMBSPassthrough) error {
+	key, err := p.ctx.GetStub().CreateCompositeKey(inventoryKeyType, []string{item.Content.Cusip, item.Content.UID})
+	if err != nil {
+		return fmt.Errorf("failed to create %s key: %v", inventoryKeyType, err)
+	}
+	itemBytes, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to marshal inventory item: %v", err)
+	}
+	err = p.ctx.GetStub().PutPrivateData(implicitCollection(mspID), key, itemBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put inventory item of %s: %v", mspID, err)
+	}
+
+	return nil
+}
+
+// indexedRecord is a record of the per-key layout along with the cusip it is indexed under
+type indexedRecord struct {
+	cusip  string
+	record interface{}
+}
+
+// forEach calls visit with the value of every record under a composite key of the given object type, in key order
+func (p *perKeyStore) forEach(objectType string, visit func([]byte) error) error {
+	resultsIterator, err := p.ctx.GetStub().GetStateByPartialCompositeKey(objectType, []string{})
+	if err != nil {
+		return fmt.Errorf("failed to get %s records: %v", objectType, err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return fmt.Errorf("error iterating over %s records: %v", objectType, err)
+		}
+		err = visit(queryResponse.Value)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal %s record: %v", objectType, err)
+		}
+	}
+
+	return nil
+}
+
+// forEachIndexed calls visit with the value of every record of the given object type indexed under a cusip.
+// Only the index keys of the cusip are range read, the records themselves are read one by one
+func (p *perKeyStore) forEachIndexed(indexType, objectType, cusip string, visit func([]byte) error) error {
+	ids, err := p.indexedIDs(indexType, cusip)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		key, err := p.ctx.GetStub().CreateCompositeKey(objectType, []string{id})
+		if err != nil {
+			return fmt.Errorf("failed to create %s key: %v", objectType, err)
+		}
+		value, err := p.ctx.GetStub().GetState(key)
+		if err != nil {
+			return fmt.Errorf("failed to read %s %s: %v", objectType, id, err)
+		}
+		if value == nil {
+			continue
+		}
+		err = visit(value)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal %s record: %v", objectType, err)
+		}
+	}
+
+	return nil
+}
+
+// indexedIDs returns the IDs indexed under a cusip
+func (p *perKeyStore) indexedIDs(indexType, cusip string) ([]string, error) {
+	resultsIterator, err := p.ctx.GetStub().GetStateByPartialCompositeKey(indexType, []string{cusip})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s index: %v", indexType, err)
+	}
+	defer resultsIterator.Close()
+
+	ids := []string{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over %s index: %v", indexType, err)
+		}
+		_, attributes, err := p.ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split %s key: %v", indexType, err)
+		}
+		ids = append(ids, attributes[1])
+	}
+
+	return ids, nil
+}
+
+// get reads the record stored under objectType~id into record. It returns false when there is none
+func (p *perKeyStore) get(objectType, id string, record interface{}) (bool, error) {
+	key, err := p.ctx.GetStub().CreateCompositeKey(objectType, []string{id})
+	if err != nil {
+		return false, fmt.Errorf("failed to create %s key: %v", objectType, err)
+	}
+	value, err := p.ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s %s: %v", objectType, id, err)
+	}
+	if value == nil {
+		return false, nil
+	}
+
+	err = json.Unmarshal(value, record)
+	if err != nil {
+		return false, fmt.Errorf("failed to unmarshal %s %s: %v", objectType, id, err)
+	}
+	return true, nil
+}
+
+// putIfChanged stores a record under key unless current, the value already stored there, is the same
+func (p *perKeyStore) putIfChanged(key string, current []byte, record interface{}) error {
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %v", err)
+	}
+	if current != nil && bytes.Equal(current, recordBytes) {
+		return nil
+	}
+
+	err = p.ctx.GetStub().PutState(key, recordBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put %s: %v", key, err)
+	}
+	return nil
+}
+
+// replace makes the records of an object type exactly the given ones, keyed by id, and their index the cusips of the records
+func (p *perKeyStore) replace(objectType, indexType string, records map[string]indexedRecord) error {
+	stub := p.ctx.GetStub()
+
+	// Index keys already right are not rewritten, since that would conflict with every read of the cusip.
+	// The index keys of deleted records are kept, so that the history of a cusip still finds them
+	indexed := map[string]bool{}
+	indexIterator, err := stub.GetStateByPartialCompositeKey(indexType, []string{})
+	if err != nil {
+		return fmt.Errorf("failed to get %s index: %v", indexType, err)
+	}
+	defer indexIterator.Close()
+	for indexIterator.HasNext() {
+		queryResponse, err := indexIterator.Next()
+		if err != nil {
+			return fmt.Errorf("error iterating over %s index: %v", indexType, err)
+		}
+		_, attributes, err := stub.SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return fmt.Errorf("failed to split %s key: %v", indexType, err)
+		}
+		record, ok := records[attributes[1]]
+		if !ok {
+			continue
+		}
+		if record.cusip == attributes[0] {
+			indexed[attributes[1]] = true
+			continue
+		}
+		err = stub.DelState(queryResponse.Key)
+		if err != nil {
+			return fmt.Errorf("failed to delete %s index of %s: %v", indexType, attributes[1], err)
+		}
+	}
+
+	stored := map[string][]byte{}
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(objectType, []string{})
+	if err != nil {
+		return fmt.Errorf("failed to get %s records: %v", objectType, err)
+	}
+	defer resultsIterator.Close()
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return fmt.Errorf("error iterating over %s records: %v", objectType, err)
+		}
+		_, attributes, err := stub.SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return fmt.Errorf("failed to split %s key: %v", objectType, err)
+		}
+		if _, ok := records[attributes[0]]; !ok {
+			err = stub.DelState(queryResponse.Key)
+			if err != nil {
+				return fmt.Errorf("failed to delete %s %s: %v", objectType, attributes[0], err)
+			}
+			continue
+		}
+		stored[attributes[0]] = queryResponse.Value
+	}
+
+	for id, record := range records {
+		key, err := stub.CreateCompositeKey(objectType, []string{id})
+		if err != nil {
+			return fmt.Errorf("failed to create %s key: %v", objectType, err)
+		}
+		err = p.putIfChanged(key, stored[id], record.record)
+		if err != nil {
+			return err
+		}
+		if indexed[id] {
+			continue
+		}
+		err = p.putIndex(indexType, record.cusip, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replaceCusip makes the records of an object type indexed under a cusip exactly the given ones, keyed by id.
+// Records of other cusips are neither read nor written, and the index keys of deleted records are kept
+func (p *perKeyStore) replaceCusip(objectType, indexType, cusip string, records map[string]interface{}) error {
+	stub := p.ctx.GetStub()
+
+	ids, err := p.indexedIDs(indexType, cusip)
+	if err != nil {
+		return err
+	}
+	indexed := map[string]bool{}
+	for _, id := range ids {
+		indexed[id] = true
+		if _, ok := records[id]; ok {
+			continue
+		}
+
+		// The index key is kept for the history of the cusip, so the record may be gone already
+		key, err := stub.CreateCompositeKey(objectType, []string{id})
+		if err != nil {
+			return fmt.Errorf("failed to create %s key: %v", objectType, err)
+		}
+		current, err := stub.GetState(key)
+		if err != nil {
+			return fmt.Errorf("failed to read %s %s: %v", objectType, id, err)
+		}
+		if current == nil {
+			continue
+		}
+		err = stub.DelState(key)
+		if err != nil {
+			return fmt.Errorf("failed to delete %s %s: %v", objectType, id, err)
+		}
+	}
+
+	for id, record := range records {
+		key, err := stub.CreateCompositeKey(objectType, []string{id})
+		if err != nil {
+			return fmt.Errorf("failed to create %s key: %v", objectType, err)
+		}
+		// The records of the cusip were read already, so reading them again adds nothing to the read set
+		current, err := stub.GetState(key)
+		if err != nil {
+			return fmt.Errorf("failed to read %s %s: %v", objectType, id, err)
+		}
+		err = p.putIfChanged(key, current, record)
+		if err != nil {
+			return err
+		}
+		if indexed[id] {
+			continue
+		}
+		err = p.putIndex(indexType, cusip, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// endorseOwners makes the owner's organization the endorser of each bond that is new or changed hands, so that from then on
+// only transactions its peers endorse can update the bond. The stored owner is still the previous one until the
+// transaction commits. Bonds are only committed with an owner secret by their owner's own transactions,
+// so the organization of a committed bond is the caller's
+func (p *perKeyStore) endorseOwners(bonds []AgencyMBSPassthrough) error {
+	stub := p.ctx.GetStub()
+	for _, bond := range bonds {
+		key, err := stub.CreateCompositeKey(bondKeyType, []string{bond.UID})
+		if err != nil {
+			return fmt.Errorf("failed to create %s key: %v", bondKeyType, err)
+		}
+		current, err := stub.GetState(key)
+		if err != nil {
+			return fmt.Errorf("failed to read %s %s: %v", bondKeyType, bond.UID, err)
+		}
+		if current != nil {
+			var stored AgencyMBSPassthrough
+			err = json.Unmarshal(current, &stored)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal %s %s: %v", bondKeyType, bond.UID, err)
+			}
+			if stored.OwnerHash == bond.OwnerHash {
+				continue
+			}
+		}
+
+		ownerMSP := bond.OwnerHash
+		if isOwnerCommitment(bond.OwnerHash) {
+			ownerMSP, err = p.ctx.GetClientIdentity().GetMSPID()
+			if err != nil {
+				return fmt.Errorf("failed to get MSP ID: %v", err)
+			}
+		}
+		policy, err := ownerEndorsementPolicy(ownerMSP)
+		if err != nil {
+			return err
+		}
+		err = stub.SetStateValidationParameter(key, policy)
+		if err != nil {
+			return fmt.Errorf("failed to set endorsement policy of bond %s: %v", bond.UID, err)
+		}
+	}
+
+	return nil
+}
+
+// reindexTransaction brings the party and cusip index keys of the transaction stored under key in line with the transaction,
+// given the value stored there before, nil for a new one. A nil transaction was deleted. Index keys already right are not rewritten
+func (p *perKeyStore) reindexTransaction(key string, current []byte, transaction *Transaction) error {
+	stub := p.ctx.GetStub()
+
+	stale := map[string]bool{}
+	if current != nil {
+		var stored Transaction
+		err := json.Unmarshal(current, &stored)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal %s record: %v", transactionKeyType, err)
+		}
+		keys, err := p.transactionIndexKeys(key, stored)
+		if err != nil {
+			return err
+		}
+		for _, indexKey := range keys {
+			stale[indexKey] = true
+		}
+	}
+
+	if transaction != nil {
+		keys, err := p.transactionIndexKeys(key, *transaction)
+		if err != nil {
+			return err
+		}
+		for _, indexKey := range keys {
+			if stale[indexKey] {
+				delete(stale, indexKey)
+				continue
+			}
+			err = stub.PutState(indexKey, []byte{0})
+			if err != nil {
+				return fmt.Errorf("failed to put transaction index %s: %v", indexKey, err)
+			}
+		}
+	}
+
+	for indexKey := range stale {
+		err := stub.DelState(indexKey)
+		if err != nil {
+			return fmt.Errorf("failed to delete transaction index %s: %v", indexKey, err)
+		}
+	}
+	return nil
+}
+
+// indexTransactions writes the party and cusip index keys of every stored transaction, including the ones recorded before
+// the indexes existed
+func (p *perKeyStore) indexTransactions() error {
+	resultsIterator, err := p.ctx.GetStub().GetStateByPartialCompositeKey(transactionKeyType, []string{})
+	if err != nil {
+		return fmt.Errorf("failed to get %s records: %v", transactionKeyType, err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return fmt.Errorf("error iterating over %s records: %v", transactionKeyType, err)
+		}
+		var transaction Transaction
+		err = json.Unmarshal(queryResponse.Value, &transaction)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal %s record: %v", transactionKeyType, err)
+		}
+		err = p.reindexTransaction(queryResponse.Key, nil, &transaction)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// transactionIndexKeys returns the party and cusip index keys of the transaction stored under key
+func (p *perKeyStore) transactionIndexKeys(key string, transaction Transaction) ([]string, error) {
+	stub := p.ctx.GetStub()
+	_, keyAttributes, err := stub.SplitCompositeKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split %s key: %v", transactionKeyType, err)
+	}
+
+	keys := []string{}
+	for _, indexType := range []string{transactionPartyIndexType, transactionCusipIndexType} {
+		for _, value := range transactionIndexValues(indexType, transaction) {
+			attributes := append([]string{value, transaction.Timestamp.UTC().Format(transactionKeyTimeLayout)}, keyAttributes...)
+			indexKey, err := stub.CreateCompositeKey(indexType, attributes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create %s key: %v", indexType, err)
+			}
+			keys = append(keys, indexKey)
+		}
+	}
+	return keys, nil
+}
+
+// transactionIndexValues returns the values a transaction is indexed under in an index: its parties or its cusip
+func transactionIndexValues(indexType string, transaction Transaction) []string {
+	if indexType == transactionCusipIndexType {
+		return []string{transaction.Cusip}
+	}
+	if transaction.BuyerID == transaction.SellerID {
+		return []string{transaction.BuyerID}
+	}
+	return []string{transaction.BuyerID, transaction.SellerID}
+}
+
+func (p *perKeyStore) putIndex(indexType, cusip, id string) error {
+	key, err := p.ctx.GetStub().CreateCompositeKey(indexType, []string{cusip, id})
+	if err != nil {
+		return fmt.Errorf("failed to create %s key: %v", indexType, err)
+	}
+	err = p.ctx.GetStub().PutState(key, []byte{0})
+	if err != nil {
+		return fmt.Errorf("failed to put %s index of %s: %v", indexType, id, err)
+	}
+	return nil
+}
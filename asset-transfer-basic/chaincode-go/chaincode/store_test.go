@@ -0,0 +1,89 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+// setUpLedger returns a world with bonds of both cusips, a settled direct trade, an open one and recorded transactions
+func setUpLedger(t *testing.T, contract *chaincode.SmartContract) *world {
+	w := setUpTrade(t, contract, 200000000)
+	createBond(t, w, contract, "uid2", org1, otherCusip, tradeFace/2)
+
+	_, err := contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.AnswerTradeAsOwner(w.begin(org1), "trade1", org2, "done", testTime, "")
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.CreateTrade(w.begin(org2), "trade2", org2, otherCusip, "2023-01-09T12:00:00Z", tradeFace/2, tradePrice, false, "")
+	require.NoError(t, err)
+	w.commit()
+
+	recordTransactions(t, w, contract, otherCusip, [][2]string{{org1, org2}, {org2, org1}})
+	return w
+}
+
+// requireSameLedger checks that two ledgers hold the same records, in any order
+func requireSameLedger(t *testing.T, expected, actual *chaincode.Ledger) {
+	require.ElementsMatch(t, expected.Bonds, actual.Bonds)
+	require.ElementsMatch(t, expected.DirectTrades, actual.DirectTrades)
+	require.ElementsMatch(t, expected.Transactions, actual.Transactions)
+}
+
+func TestMigrateLedgerLayout(t *testing.T) {
+	// Built without a layout, the contract follows the layout the channel migrated to
+	contract := &chaincode.SmartContract{}
+	w := setUpLedger(t, contract)
+	before, err := contract.GetLedger(w.begin(org1))
+	require.NoError(t, err)
+
+	_, err = contract.MigrateLedgerLayout(w.begin(org1))
+	require.NoError(t, err)
+	w.commit()
+	require.NotContains(t, w.state, "ledger")
+
+	after, err := contract.GetLedger(w.begin(org1))
+	require.NoError(t, err)
+	requireSameLedger(t, before, after)
+
+	// Running it again only rebuilds the indexes
+	_, err = contract.MigrateLedgerLayout(w.begin(org1))
+	require.NoError(t, err)
+	w.commit()
+	again, err := contract.GetLedger(w.begin(org1))
+	require.NoError(t, err)
+	requireSameLedger(t, before, again)
+
+	// The per-cusip reads go through the indexes the migration wrote
+	trade, err := contract.GetDirectTrade(w.begin(org1), "trade2")
+	require.NoError(t, err)
+	require.Equal(t, otherCusip, trade.Cusip)
+	page, err := contract.GetTransactionsByCusip(w.begin(org1), otherCusip, "", "", 10, "")
+	require.NoError(t, err)
+	require.Len(t, page.Transactions, 2)
+}
+
+func TestMigrateLedgerLayoutErrors(t *testing.T) {
+	contract := &chaincode.SmartContract{StorageLayout: chaincode.LegacyBlobLayout}
+	w := setUp(t, contract)
+
+	_, err := contract.MigrateLedgerLayout(w.begin(org1))
+	require.EqualError(t, err, "the contract is built with the blob layout")
+}
+
+func TestLayoutParity(t *testing.T) {
+	ledgers := map[string]*chaincode.Ledger{}
+	for _, layout := range layouts {
+		contract := &chaincode.SmartContract{StorageLayout: layout}
+		w := setUpLedger(t, contract)
+
+		ledger, err := contract.GetLedger(w.begin(org1))
+		require.NoError(t, err)
+		ledgers[layout] = ledger
+	}
+
+	requireSameLedger(t, ledgers[chaincode.LegacyBlobLayout], ledgers[chaincode.PerKeyLayout])
+}
@@ -0,0 +1,234 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// BondTag is a free-form label an owner attached to one of its bonds, e.g. "CRA-eligible" or "for-sale-Q3".
+// Public tags are on the world state for every organization to query. Private tags stay in the owner's implicit collection
+type BondTag struct {
+	Tag        string    `json:"tag"`
+	UID        string    `json:"uid"`
+	OwnerHash  string    `json:"ownerHash"` // Owner hash of the bond when it was tagged. The tag lapses once the bond changes hands or is re-committed
+	Visibility string    `json:"visibility"`
+	TaggedAt   time.Time `json:"taggedAt"`
+}
+
+// Tag visibilities
+const (
+	TagPublic  = "Public"
+	TagPrivate = "Private"
+)
+
+const (
+	bondTagKeyType = "bondtag"
+	maxTagLength   = 64
+)
+
+// ⭐ Functions ⭐
+
+// TagBond attaches a tag to one of the caller's bonds, publicly or only in the caller's implicit collection
+func (s *SmartContract) TagBond(ctx contractapi.TransactionContextInterface, uid, tag, visibility string) (*WriteResponse, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" || len(tag) > maxTagLength {
+		return nil, fmt.Errorf("a tag must have between 1 and %d characters", maxTagLength)
+	}
+	if visibility != TagPublic && visibility != TagPrivate {
+		return nil, fmt.Errorf("visibility must be %s or %s: %s", TagPublic, TagPrivate, visibility)
+	}
+
+	bond, err := s.getOwnedBond(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bondTag := BondTag{
+		Tag:        tag,
+		UID:        uid,
+		OwnerHash:  bond.OwnerHash,
+		Visibility: visibility,
+		TaggedAt:   timestamp,
+	}
+
+	if visibility == TagPublic {
+		err = s.putCompositeRecord(ctx, bondTagKeyType, []string{tag, uid}, bondTag)
+		if err != nil {
+			return nil, err
+		}
+		return newWriteResponse(ctx, bondTag)
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(bondTagKeyType, []string{tag, uid})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s key: %v", bondTagKeyType, err)
+	}
+	bondTagJSON, err := json.Marshal(bondTag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal bond tag: %v", err)
+	}
+	err = ctx.GetStub().PutPrivateData(implicitCollection(mspID), key, bondTagJSON)
+	if err != nil {
+		return nil, fmt.Errorf("%s - failed to put bond tag: %v", implicitCollection(mspID), err)
+	}
+
+	return newWriteResponse(ctx, bondTag)
+}
+
+// UntagBond removes a tag from one of the caller's bonds, whatever its visibility
+func (s *SmartContract) UntagBond(ctx contractapi.TransactionContextInterface, uid, tag string) (*WriteResponse, error) {
+	_, err := s.getOwnedBond(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(bondTagKeyType, []string{tag, uid})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s key: %v", bondTagKeyType, err)
+	}
+	err = ctx.GetStub().DelState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete bond tag: %v", err)
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	err = ctx.GetStub().DelPrivateData(implicitCollection(mspID), key)
+	if err != nil {
+		return nil, fmt.Errorf("%s - failed to delete bond tag: %v", implicitCollection(mspID), err)
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// GetBondsByTag returns the bonds carrying a tag: those tagged publicly by their owners, and those the caller tagged privately.
+// Tags put on a bond by a previous owner are left out
+func (s *SmartContract) GetBondsByTag(ctx contractapi.TransactionContextInterface, tag string) ([]AgencyMBSPassthrough, error) {
+	tags, err := s.getPublicBondTags(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+	privateTags, err := s.getPrivateBondTags(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+	tags = append(tags, privateTags...)
+
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bonds := []AgencyMBSPassthrough{}
+	seen := map[string]bool{}
+	for _, bondTag := range tags {
+		if seen[bondTag.UID] {
+			continue
+		}
+		bond, err := stores.Bonds.GetBond(bondTag.UID)
+		if err != nil {
+			return nil, err
+		}
+		if bond == nil || bond.OwnerHash != bondTag.OwnerHash {
+			continue
+		}
+		seen[bondTag.UID] = true
+		bonds = append(bonds, *bond)
+	}
+
+	return bonds, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// getOwnedBond returns the bond with the given UID, provided the caller owns it
+func (s *SmartContract) getOwnedBond(ctx contractapi.TransactionContextInterface, uid string) (*AgencyMBSPassthrough, error) {
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bond, err := stores.Bonds.GetBond(uid)
+	if err != nil {
+		return nil, err
+	}
+	if bond == nil {
+		return nil, NewError(ErrNotFound, "bond with UID %s not found", uid)
+	}
+	if !s.ownsBond(ctx, bond) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of the bond")
+	}
+
+	return bond, nil
+}
+
+func (s *SmartContract) getPublicBondTags(ctx contractapi.TransactionContextInterface, tag string) ([]BondTag, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(bondTagKeyType, []string{tag})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bond tags: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	tags := []BondTag{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over bond tags: %v", err)
+		}
+
+		var bondTag BondTag
+		err = json.Unmarshal(queryResponse.Value, &bondTag)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling bond tag JSON: %v", err)
+		}
+		tags = append(tags, bondTag)
+	}
+
+	return tags, nil
+}
+
+func (s *SmartContract) getPrivateBondTags(ctx contractapi.TransactionContextInterface, tag string) ([]BondTag, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(implicitCollection(mspID), bondTagKeyType, []string{tag})
+	if err != nil {
+		return nil, fmt.Errorf("%s - failed to get bond tags: %v", implicitCollection(mspID), err)
+	}
+	defer resultsIterator.Close()
+
+	tags := []BondTag{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over bond tags: %v", err)
+		}
+
+		var bondTag BondTag
+		err = json.Unmarshal(queryResponse.Value, &bondTag)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling bond tag JSON: %v", err)
+		}
+		tags = append(tags, bondTag)
+	}
+
+	return tags, nil
+}
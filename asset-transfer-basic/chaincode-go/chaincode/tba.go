@@ -0,0 +1,327 @@
+package chaincode
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// TBATrade is a To-Be-Announced trade: the buyer agrees on agency, coupon, maturity and settlement month, and the seller
+// announces the pools it delivers before settlement. Faces are original faces, as everywhere on the ledger
+type TBATrade struct {
+	TBAID           string          `json:"tbaID"`
+	Agency          string          `json:"agency"`          // e.g. "Freddie Mac"
+	Coupon          float64         `json:"coupon"`          // Annual coupon rate in percent
+	MaturityYears   int             `json:"maturityYears"`   // 30 or 15
+	SettlementMonth string          `json:"settlementMonth"` // YYYY-MM
+	Face            int64           `json:"face"`            // In cents
+	Price           Price           `json:"price"`
+	BuyerHash       string          `json:"buyerHash"`
+	SellerHash      string          `json:"sellerHash"`
+	State           string          `json:"state"` //"Open", "Allocated" or "Settled"
+	Allocations     []TBAAllocation `json:"allocations"`
+	CreatedAt       time.Time       `json:"createdAt"`
+}
+
+// TBAAllocation is a bond the seller delivers into a TBA trade
+type TBAAllocation struct {
+	UID   string `json:"uid"`
+	Cusip string `json:"cusip"`
+	Face  int64  `json:"face"` // In cents
+}
+
+const (
+	tbaObjectType = "tba"
+
+	// Good delivery: the allocated face may differ from the traded face by at most this share of it
+	tbaFaceVariance = 0.0001
+
+	tbaMonthLayout = "2006-01"
+)
+
+// ⭐ Functions ⭐
+
+// CreateTBATrade records a TBA trade the caller buys from sellerHash. It binds the seller once the seller allocates pools to it
+func (s *SmartContract) CreateTBATrade(ctx contractapi.TransactionContextInterface, tbaID, buyerHash, sellerHash, agency string, coupon float64, maturityYears int, settlementMonth string, face int64, price string, createdAt time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.IsOwner(ctx, buyerHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the owner of %s", buyerHash)
+	}
+	if buyerHash == sellerHash {
+		return nil, fmt.Errorf("you cannot trade with yourself")
+	}
+	if agency == "" {
+		return nil, fmt.Errorf("agency cannot be empty")
+	}
+	if coupon <= 0 {
+		return nil, fmt.Errorf("coupon must be positive: %v", coupon)
+	}
+	if maturityYears <= 0 {
+		return nil, fmt.Errorf("maturity must be positive: %d", maturityYears)
+	}
+	if face <= 0 {
+		return nil, fmt.Errorf("face must be positive: %d", face)
+	}
+	_, err = time.Parse(tbaMonthLayout, settlementMonth)
+	if err != nil {
+		return nil, fmt.Errorf("settlement month must be in the YYYY-MM format: %v", err)
+	}
+	parsedPrice, err := s.parsePrice(ctx, price)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing TBATrade
+	exists, err := s.getRecord(ctx, tbaObjectType, tbaID, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, NewError(ErrAlreadyExists, "TBA trade %s already exists", tbaID)
+	}
+
+	trade := TBATrade{
+		TBAID:           tbaID,
+		Agency:          agency,
+		Coupon:          coupon,
+		MaturityYears:   maturityYears,
+		SettlementMonth: settlementMonth,
+		Face:            face,
+		Price:           parsedPrice,
+		BuyerHash:       buyerHash,
+		SellerHash:      sellerHash,
+		State:           "Open",
+		Allocations:     []TBAAllocation{},
+		CreatedAt:       createdAt,
+	}
+	err = s.putRecord(ctx, tbaObjectType, tbaID, trade)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.recordOrderEvents(ctx, OrderEvent{Action: "Create", OrderType: "TBA", OrderID: tbaID, Face: face, Price: parsedPrice})
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, tbaID)
+}
+
+// AllocatePools announces the pools the seller delivers into a TBA trade. Every free bond the seller holds in the given
+// cusips is allocated and held for the trade. Each cusip must match the agency, coupon and maturity of the trade,
+// and the allocated face must be within the good delivery variance of the traded face.
+// Allocating again before settlement replaces the previous allocation
+func (s *SmartContract) AllocatePools(ctx contractapi.TransactionContextInterface, tbaID string, cusips []string) (*WriteResponse, error) {
+	trade, err := s.GetTBATrade(ctx, tbaID)
+	if err != nil {
+		return nil, err
+	}
+	err = tbaLifecycle.check(tbaID, trade.State, "Allocated")
+	if err != nil {
+		return nil, err
+	}
+	if !s.IsOwner(ctx, trade.SellerHash) {
+		return nil, NewError(ErrUnauthorized, "you are not the seller of TBA trade %s", tbaID)
+	}
+	seller, err := s.callerOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(cusips) == 0 {
+		return nil, fmt.Errorf("at least one pool must be allocated")
+	}
+
+	// The pools span several cusips, so they are all held on one ledger
+	ledger, err := s.GetLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+	releaseReservations(ledger, tbaID, "")
+
+	allocations := []TBAAllocation{}
+	var allocated int64
+	for i, cusip := range cusips {
+		if containsString(cusips[:i], cusip) {
+			return nil, fmt.Errorf("the pool %s is allocated more than once", cusip)
+		}
+		err = s.requireGoodDeliveryPool(ctx, ledger, trade, cusip)
+		if err != nil {
+			return nil, err
+		}
+
+		found := false
+		for j := range ledger.Bonds {
+			bond := &ledger.Bonds[j]
+			if bond.Cusip != cusip || !seller.owns(bond) || bond.ReservedFor != "" {
+				continue
+			}
+			err = bond.reserve(tbaID)
+			if err != nil {
+				return nil, err
+			}
+			allocations = append(allocations, TBAAllocation{UID: bond.UID, Cusip: cusip, Face: bond.OriginalFace})
+			allocated += bond.OriginalFace
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("you have no free bond of Cusip %s", cusip)
+		}
+	}
+
+	if math.Abs(float64(allocated-trade.Face)) > float64(trade.Face)*tbaFaceVariance {
+		return nil, fmt.Errorf("the allocated face %d is outside the good delivery variance of the traded face %d", allocated, trade.Face)
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	trade.Allocations = allocations
+	err = tbaLifecycle.move(tbaID, &trade.State, "Allocated")
+	if err != nil {
+		return nil, err
+	}
+	err = s.putRecord(ctx, tbaObjectType, tbaID, trade)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, allocations)
+}
+
+// SettleTBA settles an allocated TBA trade from the start of its settlement month. The allocated bonds go to the buyer,
+// each with its own transaction at the trade price, paid from the buyer's cash account. Either counterparty can settle
+func (s *SmartContract) SettleTBA(ctx contractapi.TransactionContextInterface, tbaID string, timestamp time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	trade, err := s.GetTBATrade(ctx, tbaID)
+	if err != nil {
+		return nil, err
+	}
+	err = tbaLifecycle.check(tbaID, trade.State, "Settled")
+	if err != nil {
+		return nil, err
+	}
+	if !s.IsOwner(ctx, trade.BuyerHash) && !s.IsOwner(ctx, trade.SellerHash) {
+		return nil, NewError(ErrUnauthorized, "you are not a counterparty of TBA trade %s", tbaID)
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if now.Format(tbaMonthLayout) < trade.SettlementMonth {
+		return nil, fmt.Errorf("TBA trade %s settles in %s", tbaID, trade.SettlementMonth)
+	}
+
+	ledger, err := s.GetLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	executed := len(ledger.Transactions)
+	for _, allocation := range trade.Allocations {
+		bondIndex := findBondByUID(ledger, allocation.UID)
+		if bondIndex == -1 || ledger.Bonds[bondIndex].ReservedFor != tbaID {
+			return nil, fmt.Errorf("bond %s is no longer allocated to TBA trade %s", allocation.UID, tbaID)
+		}
+		bond := &ledger.Bonds[bondIndex]
+		err = bond.deliver(s.ownerHashFor(ctx, trade.BuyerHash, bond.UID))
+		if err != nil {
+			return nil, err
+		}
+
+		transaction := s.GenerateTransactionObject(trade.BuyerHash, trade.SellerHash, bond.Cusip, bond.OriginalFace, string(trade.Price), timestamp)
+		err = s.settleTransaction(ctx, ledger, transaction, trade.Price.value())
+		if err != nil {
+			return nil, err
+		}
+
+		err = emitBondTransferred(ctx, *bond, trade.SellerHash, tbaID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	err = tbaLifecycle.move(tbaID, &trade.State, "Settled")
+	if err != nil {
+		return nil, err
+	}
+	err = s.putRecord(ctx, tbaObjectType, tbaID, trade)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.recordOrderEvents(ctx, executionEvents(ledger, executed, "TBA", tbaID)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// GetTBATrade returns a TBA trade with its allocation
+func (s *SmartContract) GetTBATrade(ctx contractapi.TransactionContextInterface, tbaID string) (*TBATrade, error) {
+	var trade TBATrade
+	exists, err := s.getRecord(ctx, tbaObjectType, tbaID, &trade)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, NewError(ErrNotFound, "TBA trade %s not found", tbaID)
+	}
+
+	return &trade, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// requireGoodDeliveryPool returns an error unless a cusip can be delivered into the TBA trade. The coupon must match.
+// The agency is checked against Class3 and the maturity against Class2 (e.g. "MBS 30yr") of the bonds that carry them
+func (s *SmartContract) requireGoodDeliveryPool(ctx contractapi.TransactionContextInterface, ledger *Ledger, trade *TBATrade, cusip string) error {
+	err := s.requireActivePool(ctx, cusip)
+	if err != nil {
+		return err
+	}
+
+	terms, err := s.getCouponTerms(ctx, cusip)
+	if err != nil {
+		return err
+	}
+	if terms.coupon != trade.Coupon {
+		return fmt.Errorf("the pool %s has a %v coupon, the TBA trade is for %v", cusip, terms.coupon, trade.Coupon)
+	}
+
+	maturity := fmt.Sprintf("%dyr", trade.MaturityYears)
+	for _, bond := range ledger.Bonds {
+		if bond.Cusip != cusip {
+			continue
+		}
+		if bond.Class3 != "" && !strings.EqualFold(bond.Class3, trade.Agency) {
+			return fmt.Errorf("the pool %s is a %s pool, the TBA trade is for %s", cusip, bond.Class3, trade.Agency)
+		}
+		if bond.Class2 != "" && !strings.Contains(strings.ToLower(bond.Class2), maturity) {
+			return fmt.Errorf("the pool %s is a %s pool, the TBA trade is for %d years", cusip, bond.Class2, trade.MaturityYears)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettleTBAWithSeveralPools(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUp(t, contract)
+			createBond(t, w, contract, "uid1", org2, testCusip, tradeFace/2)
+			createBond(t, w, contract, "uid2", org2, otherCusip, tradeFace/2)
+			for _, cusip := range []string{testCusip, otherCusip} {
+				_, err := contract.RegisterPool(w.begin(org1), cusip, "", 5.5, 1, "2023-01-01", 360)
+				require.NoError(t, err)
+				w.commit()
+			}
+			_, err := contract.DepositCash(w.begin(org1), org1, "USD", 200000000)
+			require.NoError(t, err)
+			w.commit()
+
+			_, err = contract.CreateTBATrade(w.begin(org1), "tba1", org1, org2, "FNMA", 5.5, 30, "2023-01", tradeFace, tradePrice, testTime)
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.AllocatePools(w.begin(org2), "tba1", []string{testCusip, otherCusip})
+			require.NoError(t, err)
+			w.commit()
+
+			_, err = contract.SettleTBA(w.begin(org1), "tba1", testTime)
+			require.NoError(t, err)
+			w.commit()
+
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Len(t, bonds, 2)
+			for _, bond := range bonds {
+				require.Equal(t, org1, bond.OwnerHash)
+				require.Empty(t, bond.ReservedFor)
+			}
+			// Both allocated bonds are paid for
+			requireCash(t, w, contract, 100500000, 99500000)
+
+			transactions, err := contract.GetAllTransactions(w.begin(org1))
+			require.NoError(t, err)
+			require.Len(t, transactions, 2)
+			trade, err := contract.GetTBATrade(w.begin(org1), "tba1")
+			require.NoError(t, err)
+			require.Equal(t, "Settled", trade.State)
+
+			_, err = contract.SettleTBA(w.begin(org1), "tba1", testTime)
+			require.EqualError(t, err, chaincode.NewTransitionError("TBA trade", "tba1", "Settled", "Settled", "Settled is a final state").Error())
+		})
+	}
+}
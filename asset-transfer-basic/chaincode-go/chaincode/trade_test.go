@@ -0,0 +1,493 @@
+package chaincode_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	tradeFace  = 100000000 // $1,000,000 of original face, in cents
+	tradePrice = "99.5"
+)
+
+// setUpTrade returns a world where Org2 owns a bond of testCusip, Org1 has cash, in cents, to buy it with,
+// and Org1 bids on it with direct trade "trade1"
+func setUpTrade(t *testing.T, contract *chaincode.SmartContract, cash int64) *world {
+	w := setUp(t, contract)
+	createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+
+	_, err := contract.DepositCash(w.begin(org1), org1, "USD", cash)
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.CreateTrade(w.begin(org1), "trade1", org1, testCusip, "2023-01-09T12:00:00Z", tradeFace, tradePrice, false, "")
+	require.NoError(t, err)
+	w.commit()
+	return w
+}
+
+func TestDirectTradeSettles(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUpTrade(t, contract, 200000000)
+
+			_, err := contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+			trade, err := contract.GetDirectTrade(w.begin(org1), "trade1")
+			require.NoError(t, err)
+			require.Equal(t, "Answered", trade.State)
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Equal(t, chaincode.BondReserved, bonds[0].Status)
+
+			_, err = contract.AnswerTradeAsOwner(w.begin(org1), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			require.Contains(t, w.events(), chaincode.TradeSettledEvent)
+			w.commit()
+			trade, err = contract.GetDirectTrade(w.begin(org1), "trade1")
+			require.NoError(t, err)
+			require.Equal(t, "Settled", trade.State)
+
+			bonds, err = contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Len(t, bonds, 1)
+			require.Equal(t, org1, bonds[0].OwnerHash)
+			require.Empty(t, bonds[0].ReservedFor)
+			require.Equal(t, chaincode.BondSettled, bonds[0].Status)
+
+			buyer, err := contract.GetCashBalance(w.begin(org1), org1)
+			require.NoError(t, err)
+			require.Equal(t, int64(100500000), buyer.Balance)
+			seller, err := contract.GetCashBalance(w.begin(org2), org2)
+			require.NoError(t, err)
+			require.Equal(t, int64(99500000), seller.Balance)
+
+			transactions, err := contract.GetAllTransactions(w.begin(org1))
+			require.NoError(t, err)
+			require.Len(t, transactions, 1)
+			require.Equal(t, org1, transactions[0].BuyerID)
+			require.Equal(t, org2, transactions[0].SellerID)
+			require.Equal(t, int64(tradeFace), transactions[0].OriginalFace)
+
+			_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+			require.EqualError(t, err, "direct trade is closed")
+		})
+	}
+}
+
+func TestDirectTradeInCurrency(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUp(t, contract)
+			createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+			_, err := contract.SetFXRate(w.begin(org1), "EUR", 0.9, testTime)
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.DepositCash(w.begin(org1), org1, "USD", 100000)
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.DepositCash(w.begin(org1), org1, "EUR", 200000000)
+			require.NoError(t, err)
+			w.commit()
+
+			_, err = contract.CreateTradeInCurrency(w.begin(org1), "trade1", org1, testCusip, "USD", "2023-01-09T12:00:00Z", tradeFace, tradePrice, false, "")
+			require.EqualError(t, err, contractError(chaincode.ErrInvalidInput, "USD is the settlement currency: create the trade with CreateTrade"))
+			_, err = contract.CreateTradeInCurrency(w.begin(org1), "trade1", org1, testCusip, "GBP", "2023-01-09T12:00:00Z", tradeFace, tradePrice, false, "")
+			require.EqualError(t, err, "no FX rate for GBP")
+
+			_, err = contract.CreateTradeInCurrency(w.begin(org1), "trade1", org1, testCusip, "EUR", "2023-01-09T12:00:00Z", tradeFace, tradePrice, false, "")
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.AnswerTradeAsOwner(w.begin(org1), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+
+			// Paid from the EUR balance, leaving the USD one alone
+			buyer, err := contract.GetCashBalance(w.begin(org1), org1)
+			require.NoError(t, err)
+			require.Equal(t, "USD", buyer.Currency)
+			require.Equal(t, int64(100000), buyer.Balance)
+			require.Equal(t, int64(100500000), buyer.Balances["EUR"])
+			seller, err := contract.GetCashBalance(w.begin(org2), org2)
+			require.NoError(t, err)
+			require.Equal(t, "EUR", seller.Currency)
+			require.Equal(t, int64(99500000), seller.Balance)
+
+			transactions, err := contract.GetAllTransactions(w.begin(org1))
+			require.NoError(t, err)
+			require.Len(t, transactions, 1)
+			require.Equal(t, "EUR", transactions[0].BuyerCurrency)
+			require.Equal(t, "EUR", transactions[0].SellerCurrency)
+			require.Equal(t, 1.0, transactions[0].BuyerFXRate)
+		})
+	}
+}
+
+func TestConvertCash(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+	_, err := contract.SetFXRate(w.begin(org1), "EUR", 0.9, testTime)
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.DepositCash(w.begin(org1), org2, "USD", 100000)
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.ConvertCash(w.begin(org2), "USD", "EUR", 100100)
+	require.EqualError(t, err, "insufficient cash: balance 1000.00 USD, needed 1001.00")
+	_, err = contract.ConvertCash(w.begin(org2), "USD", "USD", 10000)
+	require.EqualError(t, err, contractError(chaincode.ErrInvalidInput, `cash can only be converted between two different currencies: "USD" and "USD"`))
+	_, err = contract.ConvertCash(w.begin(org2), "USD", "GBP", 10000)
+	require.EqualError(t, err, "no FX rate for GBP")
+
+	_, err = contract.ConvertCash(w.begin(org2), "USD", "EUR", 40000)
+	require.NoError(t, err)
+	w.commit()
+	_, err = contract.ConvertCash(w.begin(org2), "EUR", "USD", 9000)
+	require.NoError(t, err)
+	w.commit()
+
+	account, err := contract.GetCashBalance(w.begin(org2), org2)
+	require.NoError(t, err)
+	require.Equal(t, int64(70000), account.Balance)
+	require.Equal(t, int64(27000), account.Balances["EUR"])
+
+	_, err = contract.WithdrawCash(w.begin(org1), org2, "EUR", 27100)
+	require.EqualError(t, err, "insufficient cash: balance 270.00 EUR, needed 271.00")
+	_, err = contract.WithdrawCash(w.begin(org1), org2, "EUR", 27000)
+	require.NoError(t, err)
+}
+
+func TestMigrateCashToCents(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+	key, err := shim.CreateCompositeKey("cashaccount", []string{org2})
+	require.NoError(t, err)
+	w.state[key] = []byte(`{"ownerHash":"Org2MSP","currency":"USD","balance":1234.56,"balances":{"EUR":10.5}}`)
+
+	_, err = contract.MigrateCashToCents(w.begin(org1))
+	require.NoError(t, err)
+	w.commit()
+
+	account, err := contract.GetCashBalance(w.begin(org2), org2)
+	require.NoError(t, err)
+	require.Equal(t, int64(123456), account.Balance)
+	require.Equal(t, map[string]int64{"EUR": 1050}, account.Balances)
+
+	_, err = contract.MigrateCashToCents(w.begin(org1))
+	require.EqualError(t, err, "cash balances are already in cents")
+}
+
+func TestDirectTradeDeliversPinnedBond(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUp(t, contract)
+
+			// The seller's first bond of the cusip is not of the face bid for
+			createBond(t, w, contract, "uid0", org2, testCusip, tradeFace/2)
+			createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+			_, err := contract.DepositCash(w.begin(org1), org1, "USD", 200000000)
+			require.NoError(t, err)
+			w.commit()
+			_, err = contract.CreateTrade(w.begin(org1), "trade1", org1, testCusip, "2023-01-09T12:00:00Z", tradeFace, tradePrice, false, "")
+			require.NoError(t, err)
+			w.commit()
+
+			_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+
+			trades, err := contract.CheckDirectTrades(w.begin(org1), testCusip)
+			require.NoError(t, err)
+			require.Len(t, trades, 1)
+			require.Equal(t, "uid1", trades[0].Answers[0].BondUID)
+
+			_, err = contract.AnswerTradeAsOwner(w.begin(org1), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+
+			owners := map[string]string{}
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			for _, bond := range bonds {
+				owners[bond.UID] = bond.OwnerHash
+			}
+			require.Equal(t, map[string]string{"uid0": org2, "uid1": org1}, owners)
+		})
+	}
+}
+
+func TestAnswerTradeWithoutBondOfFace(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+	createBond(t, w, contract, "uid0", org2, testCusip, tradeFace/2)
+	_, err := contract.CreateTrade(w.begin(org1), "trade1", org1, testCusip, "2023-01-09T12:00:00Z", tradeFace, tradePrice, false, "")
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+	require.EqualError(t, err, "the seller has no bond of Cusip 3132DWAR4 with an original face of 100000000")
+}
+
+func TestDirectTradeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		cash   int64
+		answer func(contract *chaincode.SmartContract, w *world) error
+		err    string
+	}{
+		{
+			name: "seller without a bond",
+			cash: 200000000,
+			answer: func(contract *chaincode.SmartContract, w *world) error {
+				_, err := contract.SetEncryptionKey(w.begin("Org3MSP"))
+				if err != nil {
+					return err
+				}
+				w.commit()
+
+				_, err = contract.AnswerTrade(w.begin("Org3MSP"), "trade1", "Org3MSP", "done", testTime, "")
+				return err
+			},
+			err: "the seller does not own a position in Cusip " + testCusip,
+		},
+		{
+			name: "answer as another seller",
+			cash: 200000000,
+			answer: func(contract *chaincode.SmartContract, w *world) error {
+				_, err := contract.AnswerTrade(w.begin(org1), "trade1", org2, "done", testTime, "")
+				return err
+			},
+			err: contractError(chaincode.ErrUnauthorized, "you are not the owner of Org2MSP"),
+		},
+		{
+			name: "trade ID taken",
+			cash: 200000000,
+			answer: func(contract *chaincode.SmartContract, w *world) error {
+				_, err := contract.CreateTrade(w.begin(org1), "trade1", org1, testCusip, "2023-01-09T12:00:00Z", tradeFace, tradePrice, false, "")
+				return err
+			},
+			err: contractError(chaincode.ErrAlreadyExists, "direct trade trade1 already exists"),
+		},
+		{
+			name: "empty trade ID",
+			cash: 200000000,
+			answer: func(contract *chaincode.SmartContract, w *world) error {
+				_, err := contract.CreateTrade(w.begin(org1), "", org1, testCusip, "2023-01-09T12:00:00Z", tradeFace, tradePrice, false, "")
+				return err
+			},
+			err: contractError(chaincode.ErrInvalidInput, "the direct trade ID cannot be empty"),
+		},
+		{
+			name: "unknown trade",
+			cash: 200000000,
+			answer: func(contract *chaincode.SmartContract, w *world) error {
+				_, err := contract.AnswerTrade(w.begin(org2), "trade2", org2, "done", testTime, "")
+				return err
+			},
+			err: contractError(chaincode.ErrNotFound, "direct trade not found"),
+		},
+		{
+			name: "answer as a buyer who is not the bidder",
+			cash: 200000000,
+			answer: func(contract *chaincode.SmartContract, w *world) error {
+				_, err := contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+				if err != nil {
+					return err
+				}
+				w.commit()
+
+				_, err = contract.AnswerTradeAsOwner(w.begin(org2), "trade1", org2, "done", testTime, "")
+				return err
+			},
+			err: contractError(chaincode.ErrUnauthorized, "you are not the owner of the trade"),
+		},
+		{
+			name: "insufficient cash",
+			cash: 50000000,
+			answer: func(contract *chaincode.SmartContract, w *world) error {
+				_, err := contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+				if err != nil {
+					return err
+				}
+				w.commit()
+
+				_, err = contract.AnswerTradeAsOwner(w.begin(org1), "trade1", org2, "done", testTime, "")
+				return err
+			},
+			err: "insufficient cash for Org1MSP: balance 500000.00, needed 995000.00",
+		},
+		{
+			name: "counter price that is not a price",
+			cash: 200000000,
+			answer: func(contract *chaincode.SmartContract, w *world) error {
+				_, err := contract.AnswerTrade(w.begin(org2), "trade1", org2, "counter", testTime, "cheap")
+				return err
+			},
+			err: "invalid price",
+		},
+	}
+
+	for _, test := range tests {
+		for _, layout := range layouts {
+			t.Run(test.name+"/"+layout, func(t *testing.T) {
+				contract := &chaincode.SmartContract{StorageLayout: layout}
+				w := setUpTrade(t, contract, test.cash)
+
+				err := test.answer(contract, w)
+				require.ErrorContains(t, err, test.err)
+			})
+		}
+	}
+}
+
+func TestDirectTradeHoldsBond(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUpTrade(t, contract, 200000000)
+
+			_, err := contract.CreateTrade(w.begin(org1), "trade2", org1, testCusip, "2023-01-09T12:00:00Z", tradeFace, tradePrice, false, "")
+			require.NoError(t, err)
+			w.commit()
+
+			_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+
+			// The only bond of the seller is held for trade1
+			_, err = contract.AnswerTrade(w.begin(org2), "trade2", org2, "done", testTime, "")
+			var conflict *chaincode.ConflictError
+			require.True(t, errors.As(err, &conflict), "expected a ConflictError, got %v", err)
+			require.Equal(t, "uid1", conflict.UID)
+			require.Equal(t, "trade1", conflict.BlockingTradeID)
+
+			// Closing trade1 frees the bond
+			_, err = contract.CloseDirectTrade(w.begin(org1), "trade1")
+			require.NoError(t, err)
+			w.commit()
+
+			_, err = contract.AnswerTrade(w.begin(org2), "trade2", org2, "done", testTime, "")
+			require.NoError(t, err)
+			w.commit()
+
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Equal(t, "trade2", bonds[0].ReservedFor)
+		})
+	}
+}
+
+func TestExpireStaleTradesAcrossCusips(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUp(t, contract)
+			createBond(t, w, contract, "uid1", org2, testCusip, tradeFace)
+			createBond(t, w, contract, "uid2", org2, otherCusip, tradeFace)
+			_, err := contract.DepositCash(w.begin(org1), org1, "USD", 400000000)
+			require.NoError(t, err)
+			w.commit()
+
+			expiries := map[string]time.Time{"trade1": testTime.Add(time.Hour), "trade2": testTime.Add(time.Hour), "trade3": testTime.Add(3 * time.Hour)}
+			cusips := map[string]string{"trade1": testCusip, "trade2": otherCusip, "trade3": testCusip}
+			for _, tradeID := range []string{"trade1", "trade2", "trade3"} {
+				_, err = contract.CreateTrade(w.begin(org1), tradeID, org1, cusips[tradeID], "2023-01-09T12:00:00Z", tradeFace, tradePrice, false, expiries[tradeID].Format(time.RFC3339))
+				require.NoError(t, err)
+				w.commit()
+			}
+			for _, tradeID := range []string{"trade1", "trade2"} {
+				_, err = contract.AnswerTrade(w.begin(org2), tradeID, org2, "done", testTime, "")
+				require.NoError(t, err)
+				w.commit()
+			}
+
+			// Both stale trades are expired in the same transaction, although they are on different cusips
+			response, err := contract.ExpireStaleTrades(w.beginAt(org2, testTime.Add(2*time.Hour)))
+			require.NoError(t, err)
+			require.ElementsMatch(t, []string{"trade1", "trade2"}, response.Result)
+			w.commit()
+
+			for tradeID, state := range map[string]string{"trade1": "Expired", "trade2": "Expired", "trade3": "Open"} {
+				trade, err := contract.GetDirectTrade(w.begin(org1), tradeID)
+				require.NoError(t, err)
+				require.Equal(t, state, trade.State, tradeID)
+			}
+			bonds, err := contract.GetAllBonds(w.begin(org1), false)
+			require.NoError(t, err)
+			require.Len(t, bonds, 2)
+			for _, bond := range bonds {
+				require.Empty(t, bond.ReservedFor, bond.UID)
+			}
+		})
+	}
+}
+
+func TestCloseDirectTradeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		mspID   string
+		tradeID string
+		err     string
+	}{
+		{name: "not the bidder", mspID: org2, tradeID: "trade1", err: contractError(chaincode.ErrUnauthorized, "you are not the owner of the trade")},
+		{name: "unknown trade", mspID: org1, tradeID: "trade2", err: contractError(chaincode.ErrNotFound, "direct trade not found")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract := &chaincode.SmartContract{}
+			w := setUpTrade(t, contract, 200000000)
+
+			_, err := contract.CloseDirectTrade(w.begin(test.mspID), test.tradeID)
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestGetOpenTradesForMyBonds(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUpTrade(t, contract, 200000000)
+			createBond(t, w, contract, "uid2", org1, otherCusip, tradeFace)
+
+			// The bidder owns nothing of the cusip it bids on
+			trades, err := contract.GetOpenTradesForMyBonds(w.begin(org1))
+			require.NoError(t, err)
+			require.Empty(t, trades)
+
+			trades, err = contract.GetOpenTradesForMyBonds(w.begin(org2))
+			require.NoError(t, err)
+			require.Len(t, trades, 1)
+			require.Equal(t, "trade1", trades[0].DirectTrade.DirectTradeID)
+			require.Equal(t, int64(tradeFace), trades[0].RemainingFace)
+			require.Equal(t, int64(tradeFace), trades[0].OwnedFace)
+			require.Empty(t, trades[0].YourAnswer)
+			require.Empty(t, trades[0].BestAnswer)
+
+			_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "counter", testTime, "99.75")
+			require.NoError(t, err)
+			w.commit()
+
+			trades, err = contract.GetOpenTradesForMyBonds(w.begin(org2))
+			require.NoError(t, err)
+			require.Len(t, trades, 1)
+			require.Equal(t, "counter", trades[0].YourAnswer)
+			require.Equal(t, "counter", trades[0].BestAnswer)
+			require.Equal(t, chaincode.Price("99.75"), trades[0].BestPrice)
+		})
+	}
+}
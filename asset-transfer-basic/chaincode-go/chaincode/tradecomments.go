@@ -0,0 +1,253 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// TradeComment is a message one side of a negotiation on a direct trade sends the other: the bidder or the seller of an answer.
+// It is kept in both their implicit collections, and the trade only carries its link in the hash chain of their comments
+type TradeComment struct {
+	DirectTradeID string `json:"directTradeID"`
+	SellerIDHash  string `json:"sellerIDHash"` // Seller of the answer negotiated on
+	FromMSP       string `json:"fromMSP"`
+	ToMSP         string `json:"toMSP"`
+	Message       string `json:"message"`
+	Salt          string `json:"salt"` // Keeps the hash from being matched against likely messages
+}
+
+// TradeCommentLink is the public trace of a comment. Hash is the SHA-256 of the previous link's hash of the same negotiation,
+// in hex and empty for the first comment, followed by the comment JSON as it was passed. Replaying the comments through the
+// chain proves the whole negotiation, in order, without the messages ever being public
+type TradeCommentLink struct {
+	SellerIDHash string    `json:"sellerIDHash"`
+	FromMSP      string    `json:"fromMSP"`
+	Hash         string    `json:"hash"`
+	PostedAt     time.Time `json:"postedAt"`
+}
+
+const (
+	tradeCommentKeyType          = "tradecomment"
+	tradeCommentTransientFieldID = "tradecomment"
+)
+
+// ⭐ Functions ⭐
+
+// PostTradeComment sends the other side of the negotiation between the bidder of a direct trade and a seller who answered it
+// the comment passed as JSON in the "tradecomment" transient field. The comment goes to the implicit collections of both sides,
+// and its link to the hash chain of their comments on the trade
+func (s *SmartContract) PostTradeComment(ctx contractapi.TransactionContextInterface, directTradeID, sellerIDHash string) (*WriteResponse, error) {
+	ledger, err := s.getTradeLedger(ctx, directTradeID)
+	if err != nil {
+		return nil, err
+	}
+	var foundTrade *DirectTrade
+	for i := range ledger.DirectTrades {
+		if ledger.DirectTrades[i].DirectTradeID == directTradeID {
+			foundTrade = &ledger.DirectTrades[i]
+			break
+		}
+	}
+	if foundTrade == nil {
+		return nil, NewError(ErrNotFound, "direct trade not found")
+	}
+	if !s.IsOwner(ctx, sellerIDHash) && !s.IsOwner(ctx, foundTrade.BidderHash) {
+		return nil, NewError(ErrUnauthorized, "you are not a counterparty of the answer")
+	}
+	answered := false
+	for _, answer := range foundTrade.Answers {
+		if answer.SellerIDHash == sellerIDHash {
+			answered = true
+			break
+		}
+	}
+	if !answered {
+		return nil, fmt.Errorf("there is not an answer for this identifier: %v", sellerIDHash)
+	}
+	// The comment goes to the other side of the answer: the bidder when the seller posts it, the seller otherwise
+	counterparty := sellerIDHash
+	if s.IsOwner(ctx, sellerIDHash) {
+		counterparty = foundTrade.BidderHash
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("error getting transient: %v", err)
+	}
+	commentJSON, ok := transientMap[tradeCommentTransientFieldID]
+	if !ok {
+		return nil, NewError(ErrInvalidInput, "tradecomment key not found in the transient map")
+	}
+
+	var comment TradeComment
+	err = json.Unmarshal(commentJSON, &comment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal trade comment JSON: %v", err)
+	}
+	if comment.DirectTradeID != directTradeID || comment.SellerIDHash != sellerIDHash || comment.FromMSP != mspID {
+		return nil, fmt.Errorf("the comment must be on the answer of %s to direct trade %s from %s", sellerIDHash, directTradeID, mspID)
+	}
+	if comment.ToMSP != counterparty {
+		return nil, fmt.Errorf("the comment must be sent to %s", counterparty)
+	}
+	if comment.Message == "" || comment.Salt == "" {
+		return nil, fmt.Errorf("the comment must have a message and a salt")
+	}
+
+	links := foundTrade.commentLinks(sellerIDHash)
+	previous := ""
+	if len(links) > 0 {
+		previous = links[len(links)-1].Hash
+	}
+	commentKey, err := ctx.GetStub().CreateCompositeKey(tradeCommentKeyType, []string{directTradeID, sellerIDHash, fmt.Sprintf("%06d", len(links))})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	// The chain is verified against the comment bytes, so they are stored as they were passed
+	for _, collection := range []string{implicitCollection(mspID), implicitCollection(comment.ToMSP)} {
+		err = ctx.GetStub().PutPrivateData(collection, commentKey, commentJSON)
+		if err != nil {
+			return nil, fmt.Errorf("%s - failed to put trade comment: %v", collection, err)
+		}
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	link := TradeCommentLink{
+		SellerIDHash: sellerIDHash,
+		FromMSP:      mspID,
+		Hash:         commentChainHash(previous, commentJSON),
+		PostedAt:     now,
+	}
+	foundTrade.Comments = append(foundTrade.Comments, link)
+
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ledger: %v", err)
+	}
+
+	return newWriteResponse(ctx, link)
+}
+
+// ReadTradeComments returns the comments between the bidder of a direct trade and a seller, oldest first,
+// from the caller's implicit collection. Only those two sides can read them
+func (s *SmartContract) ReadTradeComments(ctx contractapi.TransactionContextInterface, directTradeID, sellerIDHash string) ([]TradeComment, error) {
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	trade, err := stores.Trades.GetDirectTrade(directTradeID)
+	if err != nil {
+		return nil, err
+	}
+	if trade == nil {
+		return nil, NewError(ErrNotFound, "direct trade not found")
+	}
+	if !s.IsOwner(ctx, sellerIDHash) && !s.IsOwner(ctx, trade.BidderHash) {
+		return nil, NewError(ErrUnauthorized, "you are not a counterparty of the answer")
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(implicitCollection(mspID), tradeCommentKeyType, []string{directTradeID, sellerIDHash})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trade comments: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	comments := []TradeComment{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over trade comments: %v", err)
+		}
+
+		var comment TradeComment
+		err = json.Unmarshal(queryResponse.Value, &comment)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling trade comment JSON: %v", err)
+		}
+		comments = append(comments, comment)
+	}
+
+	return comments, nil
+}
+
+// VerifyTradeComments checks comments presented in a dispute, passed as a JSON array of the comments as they were posted,
+// against the hash chain on a direct trade. It holds when they are the first comments between the bidder and the seller, in order.
+// At least one comment must be presented
+func (s *SmartContract) VerifyTradeComments(ctx contractapi.TransactionContextInterface, directTradeID, sellerIDHash, commentsJSON string) (bool, error) {
+	var comments []json.RawMessage
+	err := json.Unmarshal([]byte(commentsJSON), &comments)
+	if err != nil {
+		return false, fmt.Errorf("failed to unmarshal trade comments JSON: %v", err)
+	}
+
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return false, err
+	}
+	trade, err := stores.Trades.GetDirectTrade(directTradeID)
+	if err != nil {
+		return false, err
+	}
+	if trade == nil {
+		return false, NewError(ErrNotFound, "direct trade not found")
+	}
+
+	if len(comments) == 0 {
+		return false, NewError(ErrInvalidInput, "at least one comment must be presented")
+	}
+
+	links := trade.commentLinks(sellerIDHash)
+	if len(comments) > len(links) {
+		return false, nil
+	}
+	previous := ""
+	for i, comment := range comments {
+		previous = commentChainHash(previous, comment)
+		if previous != links[i].Hash {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// commentLinks returns the links of the hash chain of the comments between the bidder of a trade and a seller, oldest first
+func (trade *DirectTrade) commentLinks(sellerIDHash string) []TradeCommentLink {
+	links := []TradeCommentLink{}
+	for _, link := range trade.Comments {
+		if link.SellerIDHash == sellerIDHash {
+			links = append(links, link)
+		}
+	}
+
+	return links
+}
+
+// commentChainHash returns the link hash of a comment: the SHA-256 of the previous link's hash followed by the comment JSON, in hex
+func commentChainHash(previous string, commentJSON []byte) string {
+	hash := sha256.Sum256(append([]byte(previous), commentJSON...))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,85 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+// postTradeComment posts a comment on the answer of Org2 to trade1 and returns the comment JSON as it was passed
+func postTradeComment(t *testing.T, w *world, contract *chaincode.SmartContract, from, to, message string) []byte {
+	commentJSON, err := json.Marshal(chaincode.TradeComment{DirectTradeID: "trade1", SellerIDHash: org2, FromMSP: from, ToMSP: to, Message: message, Salt: "salt"})
+	require.NoError(t, err)
+	w.transient = map[string][]byte{"tradecomment": commentJSON}
+	_, err = contract.PostTradeComment(w.begin(from), "trade1", org2)
+	require.NoError(t, err)
+	w.commit()
+	return commentJSON
+}
+
+func TestPostTradeComment(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpTrade(t, contract, 200000000)
+	_, err := contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+	require.NoError(t, err)
+	w.commit()
+
+	first := postTradeComment(t, w, contract, org2, org1, "Can you do 99.25?")
+	second := postTradeComment(t, w, contract, org1, org2, "Not below 99.5")
+
+	for _, mspID := range []string{org1, org2} {
+		comments, err := contract.ReadTradeComments(w.begin(mspID), "trade1", org2)
+		require.NoError(t, err)
+		require.Len(t, comments, 2)
+		require.Equal(t, "Can you do 99.25?", comments[0].Message)
+		require.Equal(t, "Not below 99.5", comments[1].Message)
+	}
+
+	trade, err := contract.GetDirectTrade(w.begin(org1), "trade1")
+	require.NoError(t, err)
+	require.Len(t, trade.Comments, 2)
+	require.Equal(t, org2, trade.Comments[0].FromMSP)
+
+	verify := func(comments ...json.RawMessage) bool {
+		commentsJSON, err := json.Marshal(comments)
+		require.NoError(t, err)
+		valid, err := contract.VerifyTradeComments(w.begin(org1), "trade1", org2, string(commentsJSON))
+		require.NoError(t, err)
+		return valid
+	}
+	require.True(t, verify(first, second))
+	require.True(t, verify(first))
+	require.False(t, verify(second, first))
+	require.False(t, verify([]byte(`{"message":"Deal at 99.25"}`)))
+
+	_, err = contract.VerifyTradeComments(w.begin(org1), "trade1", org2, "[]")
+	require.EqualError(t, err, contractError(chaincode.ErrInvalidInput, "at least one comment must be presented"))
+}
+
+func TestPostTradeCommentErrors(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	w := setUpTrade(t, contract, 200000000)
+
+	// Comments are only posted on an existing answer
+	_, err := contract.PostTradeComment(w.begin(org2), "trade1", org2)
+	require.EqualError(t, err, "there is not an answer for this identifier: Org2MSP")
+
+	_, err = contract.AnswerTrade(w.begin(org2), "trade1", org2, "done", testTime, "")
+	require.NoError(t, err)
+	w.commit()
+
+	_, err = contract.PostTradeComment(w.begin(org2), "trade1", org2)
+	require.EqualError(t, err, contractError(chaincode.ErrInvalidInput, "tradecomment key not found in the transient map"))
+
+	// The seller can only send its comments to the bidder
+	for _, to := range []string{"Org2MSP", "Org3MSP"} {
+		w.transient = map[string][]byte{"tradecomment": []byte(`{"directTradeID":"trade1","sellerIDHash":"Org2MSP","fromMSP":"Org2MSP","toMSP":"` + to + `","message":"Hi","salt":"salt"}`)}
+		_, err = contract.PostTradeComment(w.begin(org2), "trade1", org2)
+		require.EqualError(t, err, "the comment must be sent to Org1MSP")
+	}
+
+	_, err = contract.ReadTradeComments(w.begin(org2), "trade1", "Org3MSP")
+	require.EqualError(t, err, contractError(chaincode.ErrUnauthorized, "you are not a counterparty of the answer"))
+}
@@ -0,0 +1,67 @@
+package chaincode
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	tradeSequenceObjectType = "tradeseq"
+
+	// Number of counters the numbers of a day are spread over. Trades created at the same time most likely
+	// increment different counters, so they do not conflict with each other
+	tradeSequenceShards = 8
+)
+
+// ⭐ Functions ⭐
+
+// GetTradeByReference returns the direct trade with the given reference number, e.g. DT-20240115-000123
+func (s *SmartContract) GetTradeByReference(ctx contractapi.TransactionContextInterface, reference string) (*DirectTrade, error) {
+	ledger, err := s.GetLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, trade := range ledger.DirectTrades {
+		if trade.Reference == reference {
+			return &trade, nil
+		}
+	}
+
+	return nil, NewError(ErrNotFound, "direct trade with reference %s not found", reference)
+}
+
+// ⭐ Helper functions ⭐
+
+// nextTradeReference returns the reference number of a trade created in the transaction, as DT-YYYYMMDD-NNNNNN.
+// Each day has tradeSequenceShards counters, picked from the transaction ID. Counter c of shard k hands out
+// the number (c-1)*tradeSequenceShards+k+1, so no two shards can give the same number
+func (s *SmartContract) nextTradeReference(ctx contractapi.TransactionContextInterface) (string, error) {
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return "", err
+	}
+	date := timestamp.Format("20060102")
+
+	hash := fnv.New32a()
+	hash.Write([]byte(ctx.GetStub().GetTxID()))
+	shard := int(hash.Sum32() % tradeSequenceShards)
+
+	attributes := []string{date, strconv.Itoa(shard)}
+	var count int
+	_, err = s.getCompositeRecord(ctx, tradeSequenceObjectType, attributes, &count)
+	if err != nil {
+		return "", err
+	}
+	count++
+	err = s.putCompositeRecord(ctx, tradeSequenceObjectType, attributes, count)
+	if err != nil {
+		return "", err
+	}
+
+	number := (count-1)*tradeSequenceShards + shard + 1
+	return fmt.Sprintf("DT-%s-%06d", date, number), nil
+}
@@ -0,0 +1,94 @@
+package chaincode
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// TransactionPage is one page of the transactions of a party or a cusip
+type TransactionPage struct {
+	Transactions []Transaction `json:"transactions"`
+	Bookmark     string        `json:"bookmark"` // Pass it back to get the next page. Empty on the last page
+}
+
+// Largest page the transaction history queries return
+const maxTransactionPageSize = 100
+
+// ⭐ Functions ⭐
+
+// GetTransactionsByParty returns up to pageSize transactions the party bought or sold between fromDate and toDate (YYYY-MM-DD),
+// both included, starting at the bookmark of the previous page. An empty date leaves that end of the range open.
+// On the per-key layout the transactions are range read from an index of each party, in timestamp order
+func (s *SmartContract) GetTransactionsByParty(ctx contractapi.TransactionContextInterface, partyHash, fromDate, toDate string, pageSize int, bookmark string) (*TransactionPage, error) {
+	if partyHash == "" {
+		return nil, fmt.Errorf("party cannot be empty")
+	}
+
+	return s.queryTransactions(ctx, transactionPartyIndexType, partyHash, fromDate, toDate, pageSize, bookmark)
+}
+
+// GetTransactionsByCusip returns up to pageSize transactions of a cusip between fromDate and toDate (YYYY-MM-DD),
+// both included, starting at the bookmark of the previous page. An empty date leaves that end of the range open.
+// On the per-key layout the transactions are range read from an index of each cusip, in timestamp order
+func (s *SmartContract) GetTransactionsByCusip(ctx contractapi.TransactionContextInterface, cusip, fromDate, toDate string, pageSize int, bookmark string) (*TransactionPage, error) {
+	if cusip == "" {
+		return nil, fmt.Errorf("cusip cannot be empty")
+	}
+
+	return s.queryTransactions(ctx, transactionCusipIndexType, cusip, fromDate, toDate, pageSize, bookmark)
+}
+
+// ⭐ Helper functions ⭐
+
+// queryTransactions returns a page of the transactions indexed under value in the given index, within a date range
+func (s *SmartContract) queryTransactions(ctx contractapi.TransactionContextInterface, indexType, value, fromDate, toDate string, pageSize int, bookmark string) (*TransactionPage, error) {
+	if pageSize <= 0 || pageSize > maxTransactionPageSize {
+		return nil, fmt.Errorf("page size must be between 1 and %d: %d", maxTransactionPageSize, pageSize)
+	}
+
+	from, to, err := parseDateRange(fromDate, toDate)
+	if err != nil {
+		return nil, err
+	}
+
+	stores, err := s.stores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	transactions, nextBookmark, err := stores.Trades.QueryTransactions(indexType, value, from, to, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TransactionPage{Transactions: transactions, Bookmark: nextBookmark}, nil
+}
+
+// parseDateRange parses a range of dates (YYYY-MM-DD), both included, to the start of the first day and the end of the last one.
+// An empty date leaves that end of the range open and zero
+func parseDateRange(fromDate, toDate string) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+	if fromDate != "" {
+		from, err = parseDate(fromDate)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	if toDate != "" {
+		to, err = parseDate(toDate)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		// The whole last day is in the range
+		to = to.AddDate(0, 0, 1)
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return time.Time{}, time.Time{}, NewError(ErrInvalidInput, "the date range from %s to %s is empty", fromDate, toDate)
+	}
+
+	return from, to, nil
+}
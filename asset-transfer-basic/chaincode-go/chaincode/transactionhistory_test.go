@@ -0,0 +1,126 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+// recordTransactions records a transaction a day from 2023-01-01 on, between the given buyers and sellers
+func recordTransactions(t *testing.T, w *world, contract *chaincode.SmartContract, cusip string, parties [][2]string) {
+	for i, party := range parties {
+		timestamp := time.Date(2023, 1, 1+i, 15, 0, 0, 0, time.UTC)
+		_, err := contract.CreateTransaction(w.begin(org1), party[0], party[1], cusip, tradeFace, tradePrice, timestamp)
+		require.NoError(t, err)
+		w.commit()
+	}
+}
+
+func TestGetTransactionsByParty(t *testing.T) {
+	tests := []struct {
+		name     string
+		party    string
+		fromDate string
+		toDate   string
+		days     []int
+	}{
+		{name: "every transaction of a party", party: org1, days: []int{1, 2, 4}},
+		{name: "from a date", party: org1, fromDate: "2023-01-02", days: []int{2, 4}},
+		{name: "up to a date", party: org1, toDate: "2023-01-02", days: []int{1, 2}},
+		{name: "within a day", party: org2, fromDate: "2023-01-03", toDate: "2023-01-03", days: []int{3}},
+		{name: "nothing in range", party: org2, fromDate: "2023-02-01", days: []int{}},
+	}
+
+	for _, layout := range layouts {
+		contract := &chaincode.SmartContract{StorageLayout: layout}
+		w := setUp(t, contract)
+		recordTransactions(t, w, contract, testCusip, [][2]string{{org1, org2}, {org2, org1}, {org2, "Org3MSP"}, {org1, org2}})
+
+		for _, test := range tests {
+			t.Run(layout+"/"+test.name, func(t *testing.T) {
+				page, err := contract.GetTransactionsByParty(w.begin(org1), test.party, test.fromDate, test.toDate, 10, "")
+				require.NoError(t, err)
+				require.Empty(t, page.Bookmark)
+
+				days := []int{}
+				for _, transaction := range page.Transactions {
+					require.Contains(t, []string{transaction.BuyerID, transaction.SellerID}, test.party)
+					days = append(days, transaction.Timestamp.Day())
+				}
+				require.Equal(t, test.days, days)
+			})
+		}
+	}
+}
+
+func TestGetTransactionsByCusipPages(t *testing.T) {
+	for _, layout := range layouts {
+		t.Run(layout, func(t *testing.T) {
+			contract := &chaincode.SmartContract{StorageLayout: layout}
+			w := setUp(t, contract)
+			recordTransactions(t, w, contract, testCusip, [][2]string{{org1, org2}, {org2, org1}, {org1, org2}})
+			recordTransactions(t, w, contract, otherCusip, [][2]string{{org1, org2}})
+
+			days := []int{}
+			bookmark := ""
+			for pages := 0; ; pages++ {
+				require.Less(t, pages, 3)
+				page, err := contract.GetTransactionsByCusip(w.begin(org1), testCusip, "", "", 2, bookmark)
+				require.NoError(t, err)
+				for _, transaction := range page.Transactions {
+					require.Equal(t, testCusip, transaction.Cusip)
+					days = append(days, transaction.Timestamp.Day())
+				}
+				bookmark = page.Bookmark
+				if bookmark == "" {
+					break
+				}
+			}
+			require.Equal(t, []int{1, 2, 3}, days)
+		})
+	}
+}
+
+func TestGetTransactionsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		cusip    string
+		fromDate string
+		toDate   string
+		pageSize int
+		err      string
+	}{
+		{name: "no cusip", pageSize: 10, err: "cusip cannot be empty"},
+		{name: "page too large", cusip: testCusip, pageSize: 101, err: "page size must be between 1 and 100: 101"},
+		{name: "bad date", cusip: testCusip, fromDate: "01/02/2023", pageSize: 10, err: "date must be in the YYYY-MM-DD format"},
+		{name: "empty range", cusip: testCusip, fromDate: "2023-01-03", toDate: "2023-01-02", pageSize: 10, err: "the date range from 2023-01-03 to 2023-01-02 is empty"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract := &chaincode.SmartContract{}
+			w := setUp(t, contract)
+
+			_, err := contract.GetTransactionsByCusip(w.begin(org1), test.cusip, test.fromDate, test.toDate, test.pageSize, "")
+			require.ErrorContains(t, err, test.err)
+		})
+	}
+}
+
+func TestMigratedTransactionsAreIndexed(t *testing.T) {
+	// Built without a layout, the contract follows the layout the channel migrated to
+	contract := &chaincode.SmartContract{}
+	w := setUp(t, contract)
+	recordTransactions(t, w, contract, testCusip, [][2]string{{org1, org2}, {org2, org1}})
+
+	_, err := contract.MigrateLedgerLayout(w.begin(org1))
+	require.NoError(t, err)
+	w.commit()
+
+	page, err := contract.GetTransactionsByParty(w.begin(org2), org2, "2023-01-02", "", 10, "")
+	require.NoError(t, err)
+	require.Len(t, page.Transactions, 1)
+	require.Equal(t, org1, page.Transactions[0].SellerID)
+}
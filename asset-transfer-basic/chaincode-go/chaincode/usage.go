@@ -0,0 +1,104 @@
+package chaincode
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// UsageCount is the number of successful invocations of a write function by an organization on a day
+type UsageCount struct {
+	Date        string `json:"date"` // YYYY-MM-DD, in UTC
+	MSPID       string `json:"mspID"`
+	Function    string `json:"function"`
+	Invocations int    `json:"invocations"`
+}
+
+const usageKeyType = "usage"
+
+// ⭐ Functions ⭐
+
+// GetUsage returns the invocations of each write function by each organization on a day, ordered by organization and function.
+// Only the admin organization can read usage
+func (s *SmartContract) GetUsage(ctx contractapi.TransactionContextInterface, date string) ([]UsageCount, error) {
+	err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, err = parseDate(date)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(usageKeyType, []string{date})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	counts := map[string]*UsageCount{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over usage: %v", err)
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split usage key: %v", err)
+		}
+		mspID, function := attributes[1], attributes[2]
+
+		count, ok := counts[mspID+"~"+function]
+		if !ok {
+			count = &UsageCount{Date: date, MSPID: mspID, Function: function}
+			counts[mspID+"~"+function] = count
+		}
+		count.Invocations++
+	}
+
+	usage := make([]UsageCount, 0, len(counts))
+	for _, count := range counts {
+		usage = append(usage, *count)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].MSPID != usage[j].MSPID {
+			return usage[i].MSPID < usage[j].MSPID
+		}
+		return usage[i].Function < usage[j].Function
+	})
+
+	return usage, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// recordUsage counts the invocation of the current write function by the caller's organization.
+// Each transaction writes its own key instead of incrementing a shared counter, so concurrent writes never conflict
+func recordUsage(ctx contractapi.TransactionContextInterface, timestamp time.Time) error {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	// The function name may be qualified with the contract name, e.g. "SmartContract:CreateTrade"
+	function, _ := ctx.GetStub().GetFunctionAndParameters()
+	function = function[strings.LastIndex(function, ":")+1:]
+
+	key, err := ctx.GetStub().CreateCompositeKey(usageKeyType, []string{timestamp.Format(markDateLayout), mspID, function, ctx.GetStub().GetTxID()})
+	if err != nil {
+		return fmt.Errorf("failed to create %s key: %v", usageKeyType, err)
+	}
+
+	err = ctx.GetStub().PutState(key, []byte{1})
+	if err != nil {
+		return fmt.Errorf("failed to put usage: %v", err)
+	}
+
+	return nil
+}
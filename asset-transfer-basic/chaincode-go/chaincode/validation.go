@@ -0,0 +1,125 @@
+package chaincode
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Codes of the field violations reported in a ValidationError
+const (
+	InvalidCusipCode      = "INVALID_CUSIP"
+	InvalidFaceCode       = "INVALID_FACE"
+	InvalidCouponCode     = "INVALID_COUPON"
+	InvalidCouponTypeCode = "INVALID_COUPON_TYPE"
+	InvalidFactorCode     = "INVALID_FACTOR"
+	InvalidDateCode       = "INVALID_DATE"
+	InvalidAmountCode     = "INVALID_AMOUNT"
+	InvalidPercentCode    = "INVALID_PERCENT"
+	InvalidUIDCode        = "INVALID_UID"
+	InvalidOwnerCode      = "INVALID_OWNER"
+	InvalidRecordCode     = "INVALID_RECORD"
+)
+
+// ⭐ Helper functions ⭐
+
+// validateBond checks the fields of a bond passed as JSON and returns a ValidationError listing every rule it breaks,
+// or nil if it is valid. The ledger fields (UID, OwnerHash and ReservedFor) are set by the chaincode and are not checked.
+// The coupon type must be one of couponTypes, the ones the contract settings allow
+func validateBond(bond *AgencyMBSPassthrough, couponTypes []string) error {
+	violations := []FieldViolation{}
+	violate := func(field, code, format string, args ...interface{}) {
+		violations = append(violations, FieldViolation{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if !validCusip(bond.Cusip) {
+		violate("cusip", InvalidCusipCode, "%q is not a 9-character CUSIP with a valid check digit", bond.Cusip)
+	}
+	if bond.OriginalFace <= 0 {
+		violate("originalFace", InvalidFaceCode, "original face must be positive: %d", bond.OriginalFace)
+	}
+	if bond.Coupon <= 0 {
+		violate("coupon", InvalidCouponCode, "coupon must be positive: %v", bond.Coupon)
+	}
+	if !containsString(couponTypes, strings.ToUpper(bond.CouponType)) {
+		violate("couponType", InvalidCouponTypeCode, "coupon type must be one of %s: %q", strings.Join(couponTypes, ", "), bond.CouponType)
+	}
+	if bond.Factor <= 0 || bond.Factor > 1 {
+		violate("factor", InvalidFactorCode, "factor must be greater than 0 and at most 1: %v", bond.Factor)
+	}
+	if bond.IssueDate != "" && !validDate(bond.IssueDate) {
+		violate("issueDate", InvalidDateCode, "issue date must be an ISO-8601 date, e.g. 2023-01-09 or 2023-01-09T12:00:00Z: %q", bond.IssueDate)
+	}
+	if bond.FactorDate != "" && !validDate(bond.FactorDate) {
+		violate("factorDate", InvalidDateCode, "factor date must be an ISO-8601 date, e.g. 2023-01-09 or 2023-01-09T12:00:00Z: %q", bond.FactorDate)
+	}
+	if bond.MaturityDate != "" && !validDate(bond.MaturityDate) {
+		violate("maturityDate", InvalidDateCode, "maturity date must be an ISO-8601 date, e.g. 2023-01-09 or 2023-01-09T12:00:00Z: %q", bond.MaturityDate)
+	}
+	if bond.OriginationAmount < 0 {
+		violate("originationAmount", InvalidAmountCode, "origination amount cannot be negative: %d", bond.OriginationAmount)
+	}
+
+	percents := []struct {
+		field string
+		value float64
+	}{
+		{"purchasePercent", bond.PurchasePercent},
+		{"refinancePercent", bond.RefinancePercent},
+		{"thirdpartyOriginationPercent", bond.ThirdpartyOriginationPercent},
+	}
+	for _, percent := range percents {
+		if percent.value < 0 || percent.value > 100 {
+			violate(percent.field, InvalidPercentCode, "%s must be between 0 and 100: %v", percent.field, percent.value)
+		}
+	}
+
+	if len(violations) > 0 {
+		return NewValidationError(violations)
+	}
+	return nil
+}
+
+// validCusip checks that a CUSIP has 9 characters and that the last one is the check digit of the first 8
+func validCusip(cusip string) bool {
+	if len(cusip) != 9 {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < 8; i++ {
+		var value int
+		c := cusip[i]
+		switch {
+		case c >= '0' && c <= '9':
+			value = int(c - '0')
+		case c >= 'A' && c <= 'Z':
+			value = int(c-'A') + 10
+		case c == '*':
+			value = 36
+		case c == '@':
+			value = 37
+		case c == '#':
+			value = 38
+		default:
+			return false
+		}
+		// Every second character is doubled
+		if i%2 == 1 {
+			value *= 2
+		}
+		sum += value/10 + value%10
+	}
+
+	check := (10 - sum%10) % 10
+	return cusip[8] == byte('0'+check)
+}
+
+// validDate checks that a date is in the ISO-8601 format, either a calendar date or a full RFC3339 timestamp
+func validDate(value string) bool {
+	if _, err := time.Parse("2006-01-02", value); err == nil {
+		return true
+	}
+	_, err := time.Parse(time.RFC3339Nano, value)
+	return err == nil
+}
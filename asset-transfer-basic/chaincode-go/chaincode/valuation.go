@@ -0,0 +1,180 @@
+package chaincode
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// InventoryValuation is the current face and market value of an organization's positions
+type InventoryValuation struct {
+	CurrentFace  float64           `json:"currentFace"`  // Original face times the pool factor
+	MarketValue  float64           `json:"marketValue"`  // Current face at the latest consensus median of each cusip
+	UnpricedFace float64           `json:"unpricedFace"` // Current face of the positions with no consensus price yet
+	ByClass      []ValuationBucket `json:"byClass"`
+	ByCoupon     []ValuationBucket `json:"byCoupon"`
+}
+
+// ValuationBucket is the share of an InventoryValuation that falls in one class or coupon bucket
+type ValuationBucket struct {
+	Bucket       string  `json:"bucket"`
+	Positions    int     `json:"positions"`
+	CurrentFace  float64 `json:"currentFace"`
+	MarketValue  float64 `json:"marketValue"`
+	UnpricedFace float64 `json:"unpricedFace"`
+}
+
+const (
+	// Width of the coupon buckets, in percent
+	couponBucketWidth = 0.5
+
+	// Bucket of the positions whose cusip has no pool data, and so no factor or coupon
+	unknownCouponBucket = "Unknown"
+)
+
+// ⭐ Functions ⭐
+
+// GetInventoryValuation values the caller's positions at the latest consensus price of each cusip,
+// broken down by class and by coupon bucket. Positions of a cusip with no consensus price are reported as unpriced face.
+// The positions are the bonds the caller owns on the ledger, plus the items of its private inventory that are not listed
+func (s *SmartContract) GetInventoryValuation(ctx contractapi.TransactionContextInterface) (*InventoryValuation, error) {
+	bonds, err := s.getInventoryPositions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	valuation := &InventoryValuation{}
+	byClass := map[string]*ValuationBucket{}
+	byCoupon := map[string]*ValuationBucket{}
+	// Cusips repeat across positions, so their pool data and price are looked up once
+	pools := map[string]*Pool{}
+	prices := map[string]*ConsensusPrice{}
+
+	for _, bond := range bonds {
+		pool, ok := pools[bond.Cusip]
+		if !ok {
+			var record Pool
+			exists, err := s.getRecord(ctx, poolObjectType, bond.Cusip, &record)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				pool = &record
+			}
+			pools[bond.Cusip] = pool
+		}
+
+		consensus, ok := prices[bond.Cusip]
+		if !ok {
+			consensus, err = s.latestConsensusPrice(ctx, bond.Cusip)
+			if err != nil {
+				return nil, err
+			}
+			prices[bond.Cusip] = consensus
+		}
+
+		// Pool data is the reference for factor and coupon. Bonds without it fall back on their own
+		currentFace := dollars(bond.OriginalFace)
+		couponBucket := unknownCouponBucket
+		if pool != nil {
+			currentFace *= pool.Factor
+			couponBucket = couponBucketFor(pool.Coupon)
+		} else if bond.Factor != 0 {
+			currentFace *= bond.Factor
+		}
+		if pool == nil && bond.Coupon != 0 {
+			couponBucket = couponBucketFor(bond.Coupon)
+		}
+
+		var marketValue, unpricedFace float64
+		if consensus != nil {
+			marketValue = currentFace * consensus.Median.value() / 100
+		} else {
+			unpricedFace = currentFace
+		}
+
+		valuation.CurrentFace += currentFace
+		valuation.MarketValue += marketValue
+		valuation.UnpricedFace += unpricedFace
+		addToBucket(byClass, bond.Class1, currentFace, marketValue, unpricedFace)
+		addToBucket(byCoupon, couponBucket, currentFace, marketValue, unpricedFace)
+	}
+
+	valuation.ByClass = sortedBuckets(byClass)
+	valuation.ByCoupon = sortedBuckets(byCoupon)
+
+	return valuation, nil
+}
+
+// ⭐ Helper functions ⭐
+
+// getInventoryPositions returns the bonds the caller owns on the ledger and the bonds of its held inventory items.
+// Listed items are counted through their bond on the ledger, which reflects partial sales
+func (s *SmartContract) getInventoryPositions(ctx contractapi.TransactionContextInterface) ([]AgencyMBSPassthrough, error) {
+	owner, err := s.callerOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	inventory, err := s.GetInventory(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory: %v", err)
+	}
+
+	var positions []AgencyMBSPassthrough
+	if inventory != nil {
+		for _, item := range inventory.Assets {
+			if item.Content != nil && item.listingStatus() == StatusHeld {
+				positions = append(positions, *item.Content)
+			}
+		}
+	}
+
+	bonds, err := s.getAllBonds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, bond := range bonds {
+		if owner.owns(&bond) {
+			positions = append(positions, bond)
+		}
+	}
+
+	return positions, nil
+}
+
+// couponBucketFor returns the label of the coupon bucket a coupon falls in, e.g. "5.5-6.0" for 5.75
+func couponBucketFor(coupon float64) string {
+	lower := math.Floor(coupon/couponBucketWidth) * couponBucketWidth
+	return fmt.Sprintf("%.1f-%.1f", lower, lower+couponBucketWidth)
+}
+
+// addToBucket adds a position to the bucket with the given name, creating it if needed
+func addToBucket(buckets map[string]*ValuationBucket, name string, currentFace, marketValue, unpricedFace float64) {
+	bucket, ok := buckets[name]
+	if !ok {
+		bucket = &ValuationBucket{Bucket: name}
+		buckets[name] = bucket
+	}
+	bucket.Positions++
+	bucket.CurrentFace += currentFace
+	bucket.MarketValue += marketValue
+	bucket.UnpricedFace += unpricedFace
+}
+
+// sortedBuckets returns the buckets ordered by name, so that the result does not depend on map iteration order
+func sortedBuckets(buckets map[string]*ValuationBucket) []ValuationBucket {
+	sorted := make([]ValuationBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		sorted = append(sorted, *bucket)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Bucket < sorted[j].Bucket
+	})
+
+	return sorted
+}
@@ -0,0 +1,299 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// Watchlist is the set of cusips and coupons an organization wants to hear about, kept in its implicit collection
+type Watchlist struct {
+	Cusips  []string  `json:"cusips"`
+	Coupons []float64 `json:"coupons"` // Matches every cusip whose pool pays this coupon, in percent
+}
+
+// WatchlistHit flags a trade, offer or axe that matched an organization's watchlist when it was created
+type WatchlistHit struct {
+	MSPID     string    `json:"mspID"`
+	Kind      string    `json:"kind"` //"Trade", "Offer" or "Axe"
+	ID        string    `json:"id"`
+	Cusip     string    `json:"cusip"` // Empty for a bucket axe
+	Timestamp time.Time `json:"timestamp"`
+}
+
+const (
+	watchlistKey         = "watchlist"
+	watchKeyType         = "watch"
+	watcherObjectType    = "watcher"
+	watchlistHitKeyType  = "watchhit"
+	watchlistHitEventKey = "WatchlistHit"
+)
+
+// ⭐ Functions ⭐
+
+// SetWatchlist replaces the caller's watchlist. An empty watchlist stops the notifications.
+// The criteria stay in the caller's implicit collection. Other organizations' transactions only probe the hashes
+// of their keys, so all that is public is that the caller has a watchlist and, through the hits, what matched it
+func (s *SmartContract) SetWatchlist(ctx contractapi.TransactionContextInterface, cusips []string, coupons []float64) (*WriteResponse, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	collection := implicitCollection(mspID)
+
+	previous, err := s.getWatchlist(ctx, mspID)
+	if err != nil {
+		return nil, err
+	}
+	previousKeys, err := previous.watchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range previousKeys {
+		err = ctx.GetStub().DelPrivateData(collection, key)
+		if err != nil {
+			return nil, fmt.Errorf("%s - failed to delete watch key: %v", collection, err)
+		}
+	}
+
+	watchlist := Watchlist{Cusips: cusips, Coupons: coupons}
+	keys, err := watchlist.watchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		err = ctx.GetStub().PutPrivateData(collection, key, []byte{1})
+		if err != nil {
+			return nil, fmt.Errorf("%s - failed to put watch key: %v", collection, err)
+		}
+	}
+
+	watchlistJSON, err := json.Marshal(watchlist)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal watchlist: %v", err)
+	}
+	err = ctx.GetStub().PutPrivateData(collection, watchlistKey, watchlistJSON)
+	if err != nil {
+		return nil, fmt.Errorf("%s - failed to put watchlist: %v", collection, err)
+	}
+
+	// The public watcher entry tells other organizations' transactions whose collections to probe
+	if len(cusips) == 0 && len(coupons) == 0 {
+		err = s.deleteRecord(ctx, watcherObjectType, mspID)
+	} else {
+		err = s.putRecord(ctx, watcherObjectType, mspID, mspID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, nil)
+}
+
+// GetWatchlist returns the caller's watchlist
+func (s *SmartContract) GetWatchlist(ctx contractapi.TransactionContextInterface) (*Watchlist, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	return s.getWatchlist(ctx, mspID)
+}
+
+// GetWatchlistHits returns the trades, offers and axes that matched the caller's watchlist, oldest first
+func (s *SmartContract) GetWatchlistHits(ctx contractapi.TransactionContextInterface) ([]WatchlistHit, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(watchlistHitKeyType, []string{mspID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get watchlist hits: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	hits := []WatchlistHit{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over watchlist hits: %v", err)
+		}
+
+		var hit WatchlistHit
+		err = json.Unmarshal(queryResponse.Value, &hit)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling watchlist hit JSON: %v", err)
+		}
+		hits = append(hits, hit)
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Timestamp.Before(hits[j].Timestamp)
+	})
+
+	return hits, nil
+}
+
+// ⭐ Helper functions ⭐
+
+func (s *SmartContract) getWatchlist(ctx contractapi.TransactionContextInterface, mspID string) (*Watchlist, error) {
+	collection := implicitCollection(mspID)
+	watchlistJSON, err := ctx.GetStub().GetPrivateData(collection, watchlistKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s - failed to get watchlist: %v", collection, err)
+	}
+
+	watchlist := &Watchlist{Cusips: []string{}, Coupons: []float64{}}
+	if watchlistJSON == nil {
+		return watchlist, nil
+	}
+	err = json.Unmarshal(watchlistJSON, watchlist)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal watchlist: %v", err)
+	}
+
+	return watchlist, nil
+}
+
+// watchKeys returns the private keys that mark each criterion of the watchlist
+func (w *Watchlist) watchKeys(ctx contractapi.TransactionContextInterface) ([]string, error) {
+	var keys []string
+	for _, cusip := range w.Cusips {
+		key, err := watchKey(ctx, "cusip", cusip)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	for _, coupon := range w.Coupons {
+		key, err := watchKey(ctx, "coupon", fmt.Sprintf("%v", coupon))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// watchKey returns the private key marking that a watchlist has the criterion, "cusip" or "coupon", with the given value
+func watchKey(ctx contractapi.TransactionContextInterface, criterion, value string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(watchKeyType, []string{criterion, value})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	return key, nil
+}
+
+// notifyWatchers flags a new trade, offer or axe for every other organization whose watchlist it matches,
+// and emits a single WatchlistHit event naming them. A cusip is matched on its own and on the coupon of its pool.
+// Each watch key is probed through its hash, which every peer holds even for collections it is not a member of
+func (s *SmartContract) notifyWatchers(ctx contractapi.TransactionContextInterface, kind, id, cusip string, coupon float64) error {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	if cusip != "" && coupon == 0 {
+		var pool Pool
+		exists, err := s.getRecord(ctx, poolObjectType, cusip, &pool)
+		if err != nil {
+			return err
+		}
+		if exists {
+			coupon = pool.Coupon
+		}
+	}
+	criteria := Watchlist{}
+	if cusip != "" {
+		criteria.Cusips = []string{cusip}
+	}
+	if coupon != 0 {
+		criteria.Coupons = []float64{coupon}
+	}
+	keys, err := criteria.watchKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	watchers, err := s.getWatchers(ctx)
+	if err != nil {
+		return err
+	}
+
+	var notified []string
+	for _, watcher := range watchers {
+		if watcher == mspID {
+			continue
+		}
+		for _, key := range keys {
+			hash, err := ctx.GetStub().GetPrivateDataHash(implicitCollection(watcher), key)
+			if err != nil {
+				return fmt.Errorf("%s - failed to get watch key hash: %v", implicitCollection(watcher), err)
+			}
+			if hash == nil {
+				continue
+			}
+
+			hit := WatchlistHit{MSPID: watcher, Kind: kind, ID: id, Cusip: cusip, Timestamp: timestamp}
+			err = s.putCompositeRecord(ctx, watchlistHitKeyType, []string{watcher, kind, id}, hit)
+			if err != nil {
+				return err
+			}
+			notified = append(notified, watcher)
+			break
+		}
+	}
+	if len(notified) == 0 {
+		return nil
+	}
+
+	event := struct {
+		MSPIDs []string `json:"mspIDs"`
+		Kind   string   `json:"kind"`
+		ID     string   `json:"id"`
+		Cusip  string   `json:"cusip"`
+	}{notified, kind, id, cusip}
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal watchlist event: %v", err)
+	}
+
+	return ctx.GetStub().SetEvent(watchlistHitEventKey, eventJSON)
+}
+
+// getWatchers returns the MSP IDs of the organizations with a watchlist
+func (s *SmartContract) getWatchers(ctx contractapi.TransactionContextInterface) ([]string, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(watcherObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get watchers: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var watchers []string
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over watchers: %v", err)
+		}
+
+		var watcher string
+		err = json.Unmarshal(queryResponse.Value, &watcher)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling watcher JSON: %v", err)
+		}
+		watchers = append(watchers, watcher)
+	}
+
+	return watchers, nil
+}
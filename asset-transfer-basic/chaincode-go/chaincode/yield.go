@@ -0,0 +1,269 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ⭐ Data Structures ⭐
+
+// BenchmarkPoint is an admin-maintained point of the benchmark curve transactions are spread against
+type BenchmarkPoint struct {
+	Name        string    `json:"name"` // e.g. "UST10Y"
+	TenorMonths int       `json:"tenorMonths"`
+	Yield       float64   `json:"yield"` // In percent, e.g. 4.25
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+const benchmarkObjectType = "benchmark"
+
+// ⭐ Functions ⭐
+
+// SetBenchmarkPoint stores a point of the benchmark curve. Only the admin organization can maintain the curve
+func (s *SmartContract) SetBenchmarkPoint(ctx contractapi.TransactionContextInterface, name string, tenorMonths int, yield float64, updatedAt time.Time) (*WriteResponse, error) {
+	err := s.checkClientTime(ctx, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tenorMonths <= 0 {
+		return nil, fmt.Errorf("tenor must be at least one month: %d", tenorMonths)
+	}
+
+	point := BenchmarkPoint{
+		Name:        name,
+		TenorMonths: tenorMonths,
+		Yield:       yield,
+		UpdatedAt:   updatedAt,
+	}
+	err = s.putRecord(ctx, benchmarkObjectType, name, point)
+	if err != nil {
+		return nil, err
+	}
+	return newWriteResponse(ctx, nil)
+}
+
+// GetBenchmarkCurve returns every point of the benchmark curve
+func (s *SmartContract) GetBenchmarkCurve(ctx contractapi.TransactionContextInterface) ([]BenchmarkPoint, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(benchmarkObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get benchmark curve: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	points := []BenchmarkPoint{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over benchmark curve: %v", err)
+		}
+
+		var point BenchmarkPoint
+		err = json.Unmarshal(queryResponse.Value, &point)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling benchmark point JSON: %v", err)
+		}
+		points = append(points, point)
+	}
+
+	return points, nil
+}
+
+// CreateSpreadTrade creates a direct trade negotiated as a spread in basis points to a benchmark of the curve.
+// Answers counter with spreads too, and the dollar price is fixed at the benchmark level when the trade executes.
+// The trade expires at expiresAtString or, if it is empty, after the default trade expiry of the contract settings
+func (s *SmartContract) CreateSpreadTrade(ctx contractapi.TransactionContextInterface, directTradeID, bidderHash, cusip, benchmark, createdAtString string, originalFace int64, bidSpread string, allowPartial bool, expiresAtString string) (*WriteResponse, error) {
+	createdAt, err := parseTimestamp(createdAtString)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkClientTime(ctx, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	spread, err := s.parsePrice(ctx, bidSpread)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt, err := s.parseTradeExpiry(ctx, expiresAtString, createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Both are needed to turn the spread into a price
+	_, err = s.GetPool(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.getBenchmarkPoint(ctx, benchmark)
+	if err != nil {
+		return nil, err
+	}
+	err = s.requireActivePool(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	reference, err := s.nextTradeReference(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	trade := DirectTrade{
+		DirectTradeID: directTradeID,
+		Reference:     reference,
+		Cusip:         cusip,
+		OriginalFace:  originalFace,
+		BidPrice:      spread,
+		BidderHash:    bidderHash,
+		State:         "Open",
+		Answers:       []Answer{},
+		CreatedAt:     createdAt,
+		AllowPartial:  allowPartial,
+		RemainingFace: originalFace,
+		Benchmark:     benchmark,
+		ExpiresAt:     expiresAt,
+	}
+
+	ledger, err := s.getCusipLedger(ctx, cusip)
+	if err != nil {
+		return nil, err
+	}
+
+	// Spread bids do not cross offers, which are priced in dollars
+	ledger.DirectTrades = append(ledger.DirectTrades, trade)
+	err = s.updateLedger(ctx, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store direct trade: %v", err)
+	}
+
+	err = emitTradeEvent(ctx, TradeCreatedEvent, &trade, "", spread, "")
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.notifyWatchers(ctx, "Trade", directTradeID, cusip, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.recordOrderEvents(ctx, OrderEvent{Action: "Create", OrderType: "Trade", OrderID: directTradeID, Cusip: cusip, Face: originalFace, Price: spread})
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteResponse(ctx, directTradeID)
+}
+
+// ⭐ Helper functions ⭐
+
+func (s *SmartContract) getBenchmarkPoint(ctx contractapi.TransactionContextInterface, name string) (*BenchmarkPoint, error) {
+	var point BenchmarkPoint
+	exists, err := s.getRecord(ctx, benchmarkObjectType, name, &point)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, NewError(ErrNotFound, "benchmark %s not found", name)
+	}
+
+	return &point, nil
+}
+
+// priceFromSpread returns the price of a cusip at a spread in basis points over the current level of a benchmark, and that level
+func (s *SmartContract) priceFromSpread(ctx contractapi.TransactionContextInterface, cusip, benchmark string, spread float64) (Price, float64, error) {
+	pool, err := s.GetPool(ctx, cusip)
+	if err != nil {
+		return "", 0, err
+	}
+	point, err := s.getBenchmarkPoint(ctx, benchmark)
+	if err != nil {
+		return "", 0, err
+	}
+
+	price := priceFromYield(point.Yield+spread/100, pool.Coupon, pool.WAM)
+	return formatPrice(price, 2), point.Yield, nil
+}
+
+// captureYieldAndSpread sets the yield implied by the traded price on the transaction and its spread to the benchmark point
+// whose tenor is closest to the pool's weighted average maturity. Transactions of unregistered pools are left as they are
+func (s *SmartContract) captureYieldAndSpread(ctx contractapi.TransactionContextInterface, transaction *Transaction, price float64) error {
+	var pool Pool
+	exists, err := s.getRecord(ctx, poolObjectType, transaction.Cusip, &pool)
+	if err != nil {
+		return err
+	}
+	if !exists || price <= 0 {
+		return nil
+	}
+
+	transaction.Yield = yieldFromPrice(price, pool.Coupon, pool.WAM)
+	if transaction.Benchmark != "" {
+		// Negotiated as a spread, which already names the benchmark
+		return nil
+	}
+
+	points, err := s.GetBenchmarkCurve(ctx)
+	if err != nil {
+		return err
+	}
+	closest := -1
+	for i, point := range points {
+		if closest == -1 || absInt(point.TenorMonths-pool.WAM) < absInt(points[closest].TenorMonths-pool.WAM) {
+			closest = i
+		}
+	}
+	if closest != -1 {
+		transaction.Benchmark = points[closest].Name
+		transaction.Spread = (transaction.Yield - points[closest].Yield) * 100
+	}
+
+	return nil
+}
+
+// priceFromYield returns the price per 100 of a bond paying a monthly coupon for the given number of months,
+// discounted at an annual yield in percent compounded monthly
+func priceFromYield(yield, coupon float64, months int) float64 {
+	rate := yield / 100 / 12
+	payment := coupon / 12
+
+	price := 0.0
+	discount := 1.0
+	for month := 1; month <= months; month++ {
+		discount /= 1 + rate
+		price += payment * discount
+	}
+
+	return price + 100*discount
+}
+
+// yieldFromPrice returns the annual yield in percent implied by a price per 100, solved by bisection on priceFromYield
+func yieldFromPrice(price, coupon float64, months int) float64 {
+	low, high := -50.0, 100.0
+	for i := 0; i < 100; i++ {
+		mid := (low + high) / 2
+		// Price falls as the yield rises
+		if priceFromYield(mid, coupon, months) > price {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+
+	return math.Round((low+high)/2*1e6) / 1e6
+}
+
+func absInt(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
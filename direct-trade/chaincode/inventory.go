@@ -120,8 +120,12 @@ func GenerateMetadata(ctx contractapi.TransactionContextInterface) (AssetMetadat
 		return AssetMetadata{}, err
 	}
 
-	// Get the current time
-	now := time.Now()
+	// Use the transaction timestamp, which is the same on every endorsing peer, rather than the peer's clock
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return AssetMetadata{}, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	now := time.Unix(txTimestamp.GetSeconds(), int64(txTimestamp.GetNanos())).UTC()
 
 	// Create metadata
 	metadata := AssetMetadata{